@@ -0,0 +1,161 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// apiCacheEntry is the on-disk form of one cached GitHub API response. The
+// endpoint is kept so that entries can be pruned per action.
+type apiCacheEntry struct {
+	Endpoint  string    `json:"endpoint"`
+	FetchedAt time.Time `json:"fetchedAt"`
+	Body      string    `json:"body"`
+}
+
+// defaultAPICacheDir returns ~/.cache/gha-pinner/api (or the platform equivalent).
+func defaultAPICacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = filepath.Join(os.TempDir(), ".cache")
+	}
+	return filepath.Join(dir, "gha-pinner", "api")
+}
+
+func apiCacheEnabled() bool {
+	return !noCache && !forceClone && apiCacheTTL > 0
+}
+
+func resolvedAPICacheDir() string {
+	if apiCacheDir != "" {
+		return apiCacheDir
+	}
+	return defaultAPICacheDir()
+}
+
+func apiCachePath(endpoint string) string {
+	sum := sha256.Sum256([]byte(endpoint))
+	return filepath.Join(resolvedAPICacheDir(), hex.EncodeToString(sum[:])+".json")
+}
+
+func readAPICacheEntry(path string) (apiCacheEntry, error) {
+	var entry apiCacheEntry
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return entry, err
+	}
+	if err := json.Unmarshal(content, &entry); err != nil {
+		return entry, fmt.Errorf("invalid cache entry %s: %w", path, err)
+	}
+	return entry, nil
+}
+
+// writeAPICacheEntry writes entry to path through a temporary file and a
+// rename so that concurrent readers never see a partial entry.
+func writeAPICacheEntry(path string, entry apiCacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// cachedGitHubAPI performs a GET through githubAPI, serving the response from
+// the on-disk API cache while it is younger than --api-cache-ttl. Only
+// successful responses are cached.
+func cachedGitHubAPI(endpoint string) ExecResult {
+	if !apiCacheEnabled() {
+		return githubAPI("GET", endpoint, nil)
+	}
+
+	path := apiCachePath(endpoint)
+	if entry, err := readAPICacheEntry(path); err == nil && entry.Endpoint == endpoint && time.Since(entry.FetchedAt) < apiCacheTTL {
+		if debug {
+			fmt.Printf("API cache hit: %s\n", endpoint)
+		}
+		return ExecResult{Stdout: entry.Body}
+	}
+
+	result := githubAPI("GET", endpoint, nil)
+	if result.ExitCode == 0 {
+		entry := apiCacheEntry{Endpoint: endpoint, FetchedAt: time.Now(), Body: result.Stdout}
+		if err := writeAPICacheEntry(path, entry); err != nil && debug {
+			fmt.Printf("Warning: failed to write API cache entry %s: %v\n", path, err)
+		}
+	}
+	return result
+}
+
+// pruneAPICache removes entries from the API cache in dir and returns how many
+// were removed. With all every entry goes; otherwise an entry is removed when
+// it matches every given filter: fetched more than olderThan before now, and
+// belonging to action's repository. Entries that cannot be parsed fall back
+// to their modification time and never match an action.
+func pruneAPICache(dir string, olderThan time.Duration, action string, all bool, now time.Time) (int, error) {
+	files, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read cache directory %s: %w", dir, err)
+	}
+
+	prefix := ""
+	if action != "" {
+		repoName := action
+		if parts := strings.Split(action, "/"); len(parts) >= 2 {
+			repoName = parts[0] + "/" + parts[1]
+		}
+		prefix = "repos/" + strings.ToLower(repoName) + "/"
+	}
+
+	removed := 0
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(dir, file.Name())
+		if !all {
+			entry, err := readAPICacheEntry(path)
+			if err != nil {
+				info, statErr := file.Info()
+				if statErr != nil {
+					continue
+				}
+				entry = apiCacheEntry{FetchedAt: info.ModTime()}
+			}
+			if olderThan > 0 && now.Sub(entry.FetchedAt) < olderThan {
+				continue
+			}
+			if prefix != "" && !strings.HasPrefix(strings.ToLower(entry.Endpoint), prefix) {
+				continue
+			}
+		}
+		if err := os.Remove(path); err != nil {
+			return removed, fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func saveAPICacheGlobals(t *testing.T) {
+	t.Helper()
+	oldDir, oldTTL, oldNoCache, oldForce := apiCacheDir, apiCacheTTL, noCache, forceClone
+	t.Cleanup(func() {
+		apiCacheDir, apiCacheTTL, noCache, forceClone = oldDir, oldTTL, oldNoCache, oldForce
+	})
+}
+
+func TestAPICacheEnabled(t *testing.T) {
+	saveAPICacheGlobals(t)
+	apiCacheTTL = time.Hour
+
+	noCache, forceClone = false, false
+	if !apiCacheEnabled() {
+		t.Error("expected cache to be enabled by default")
+	}
+	noCache = true
+	if apiCacheEnabled() {
+		t.Error("expected --no-cache to disable the cache")
+	}
+	noCache, forceClone = false, true
+	if apiCacheEnabled() {
+		t.Error("expected --force-clone to disable the cache")
+	}
+}
+
+func TestCachedGitHubAPI_ServesFreshEntry(t *testing.T) {
+	saveAPICacheGlobals(t)
+	apiCacheDir = t.TempDir()
+	apiCacheTTL = time.Hour
+	noCache, forceClone = false, false
+
+	endpoint := "repos/example/never-resolved/git/refs/tags/v1"
+	path := apiCachePath(endpoint)
+	if filepath.Dir(path) != apiCacheDir {
+		t.Fatalf("expected cache file inside %s, got %s", apiCacheDir, path)
+	}
+	entry := apiCacheEntry{Endpoint: endpoint, FetchedAt: time.Now(), Body: `{"object":{"sha":"cached"}}`}
+	if err := writeAPICacheEntry(path, entry); err != nil {
+		t.Fatal(err)
+	}
+
+	result := cachedGitHubAPI(endpoint)
+	if result.ExitCode != 0 || result.Stdout != `{"object":{"sha":"cached"}}` {
+		t.Fatalf("expected cached response, got %+v", result)
+	}
+}
+
+func TestGetCommitHashViaAPI_DoesNotCacheBranches(t *testing.T) {
+	saveAPICacheGlobals(t)
+	oldMode, oldToken, oldWorkers := saveAuthGlobals()
+	oldBase := githubAPIBase
+	t.Cleanup(func() {
+		restoreAuthGlobals(oldMode, oldToken, oldWorkers)
+		githubAPIBase = oldBase
+	})
+	apiCacheDir = t.TempDir()
+	apiCacheTTL = time.Hour
+	noCache, forceClone = false, false
+
+	branchHead := testSHA
+	branchRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/example/action/git/refs/heads/main" {
+			branchRequests++
+			io.WriteString(w, `{"object":{"sha":"`+branchHead+`"}}`)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(server.Close)
+	githubAPIBase = server.URL
+	authMode, githubToken = "pat", "test-token"
+
+	if hash, _, err := getCommitHashViaAPI("example/action", "main"); err != nil || hash != testSHA {
+		t.Fatalf("first lookup: got %q, %v", hash, err)
+	}
+	branchHead = strings.Repeat("f", 40)
+	if hash, _, err := getCommitHashViaAPI("example/action", "main"); err != nil || hash != branchHead {
+		t.Fatalf("expected the moved branch head %s, got %q, %v", branchHead, hash, err)
+	}
+	if branchRequests != 2 {
+		t.Fatalf("expected every branch lookup to reach the API, got %d requests", branchRequests)
+	}
+}
+
+func TestPruneAPICache(t *testing.T) {
+	saveAPICacheGlobals(t)
+	apiCacheDir = t.TempDir()
+	now := time.Now()
+
+	write := func(endpoint string, age time.Duration) {
+		t.Helper()
+		entry := apiCacheEntry{Endpoint: endpoint, FetchedAt: now.Add(-age), Body: "{}"}
+		if err := writeAPICacheEntry(apiCachePath(endpoint), entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("repos/actions/checkout/git/refs/tags/v3", 60*24*time.Hour)
+	write("repos/actions/checkout/git/refs/tags/v4", time.Hour)
+	write("repos/actions/setup-go/git/refs/tags/v5", 60*24*time.Hour)
+	write("repos/example/tool/git/refs/tags/v1", time.Hour)
+
+	removed, err := pruneAPICache(apiCacheDir, 30*24*time.Hour, "", false, now)
+	if err != nil || removed != 2 {
+		t.Fatalf("--older-than removed %d, %v; want 2", removed, err)
+	}
+	removed, err = pruneAPICache(apiCacheDir, 0, "actions/checkout", false, now)
+	if err != nil || removed != 1 {
+		t.Fatalf("--action removed %d, %v; want 1", removed, err)
+	}
+	removed, err = pruneAPICache(apiCacheDir, 0, "", true, now)
+	if err != nil || removed != 1 {
+		t.Fatalf("--all removed %d, %v; want 1", removed, err)
+	}
+	if removed, err := pruneAPICache(filepath.Join(apiCacheDir, "missing"), 0, "", true, now); err != nil || removed != 0 {
+		t.Fatalf("missing directory: removed %d, %v", removed, err)
+	}
+}
+
+func TestVerifyCommitExists(t *testing.T) {
+	saveAPICacheGlobals(t)
+	apiCacheDir = t.TempDir()
+	apiCacheTTL = time.Hour
+	noCache, forceClone = false, false
+
+	commitSHA := strings.Repeat("a", 40)
+	tagObjectSHA := strings.Repeat("b", 40)
+	seed := func(sha, body string) {
+		t.Helper()
+		endpoint := "repos/example/never-resolved/commits/" + sha
+		if err := writeAPICacheEntry(apiCachePath(endpoint), apiCacheEntry{Endpoint: endpoint, FetchedAt: time.Now(), Body: body}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	seed(commitSHA, `{"sha":"`+commitSHA+`"}`)
+	// An annotated tag object SHA resolves to the commit it points at.
+	seed(tagObjectSHA, `{"sha":"`+commitSHA+`"}`)
+
+	if !verifyCommitExists("example/never-resolved/sub", commitSHA) {
+		t.Error("expected commit SHA to verify")
+	}
+	if verifyCommitExists("example/never-resolved", tagObjectSHA) {
+		t.Error("expected tag object SHA to fail verification")
+	}
+}
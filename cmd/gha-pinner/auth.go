@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// githubAPIBase is the REST API root for pat mode and GitHub App token
+// requests; tests point it at a local server.
+var githubAPIBase = "https://api.github.com"
+
+// appTokenRefreshMargin is how long before expiry an installation token is
+// replaced. Installation tokens live for an hour, so long organization runs
+// outlive the first one.
+const appTokenRefreshMargin = 5 * time.Minute
+
+// githubApp is the installation token source set up by --github-app-id, or nil
+// when authenticating as a user.
+var githubApp *appTokenSource
+
+type appTokenSource struct {
+	mu        sync.Mutex
+	appID     int
+	keyFile   string
+	org       string
+	token     string
+	expiresAt time.Time
+}
+
+// getInstallationToken authenticates as GitHub App appID with the private key
+// in keyFile and returns an installation access token. The installation is the
+// one on org, or the app's only installation when org is empty.
+func getInstallationToken(appID int, keyFile, org string) (string, error) {
+	token, _, err := requestInstallationToken(appID, keyFile, org)
+	return token, err
+}
+
+func requestInstallationToken(appID int, keyFile, org string) (string, time.Time, error) {
+	key, err := loadAppPrivateKey(keyFile)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	jwt, err := signAppJWT(appID, key, time.Now())
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	installationID, err := findAppInstallation(jwt, org)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	body, err := appAPIRequest("POST", fmt.Sprintf("app/installations/%d/access_tokens", installationID), jwt)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	var resp struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to parse installation token: %v", err)
+	}
+	if resp.Token == "" {
+		return "", time.Time{}, fmt.Errorf("GitHub returned an empty installation token")
+	}
+	return resp.Token, resp.ExpiresAt, nil
+}
+
+// loadAppPrivateKey reads the PEM private key downloaded from the GitHub App
+// settings page. GitHub issues PKCS#1 keys; PKCS#8 is accepted as well.
+func loadAppPrivateKey(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GitHub App key file: %v", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("GitHub App key file %s is not PEM encoded", path)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse GitHub App key file %s: %v", path, err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("GitHub App key file %s does not contain an RSA key", path)
+	}
+	return key, nil
+}
+
+// signAppJWT builds the RS256 JWT GitHub expects from an app. iat is backdated
+// a minute to allow for clock drift, and exp stays under the 10 minute limit.
+func signAppJWT(appID int, key *rsa.PrivateKey, now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": strconv.Itoa(appID),
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign GitHub App JWT: %v", err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func findAppInstallation(jwt, org string) (int64, error) {
+	if org != "" {
+		body, err := appAPIRequest("GET", fmt.Sprintf("orgs/%s/installation", org), jwt)
+		if err != nil {
+			return 0, fmt.Errorf("GitHub App is not installed on %s: %v", org, err)
+		}
+		var installation struct {
+			ID int64 `json:"id"`
+		}
+		if err := json.Unmarshal(body, &installation); err != nil {
+			return 0, fmt.Errorf("failed to parse installation: %v", err)
+		}
+		return installation.ID, nil
+	}
+
+	body, err := appAPIRequest("GET", "app/installations", jwt)
+	if err != nil {
+		return 0, err
+	}
+	var installations []struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal(body, &installations); err != nil {
+		return 0, fmt.Errorf("failed to parse installations: %v", err)
+	}
+	if len(installations) != 1 {
+		return 0, fmt.Errorf("GitHub App has %d installations; run against an organization or owner/repo to select one", len(installations))
+	}
+	return installations[0].ID, nil
+}
+
+// appAPIRequest calls the REST API authenticated as the app itself, which
+// only the installation endpoints accept.
+func appAPIRequest(method, endpoint, jwt string) ([]byte, error) {
+	timeout := 30 * time.Second
+	if networkTimeout > 0 {
+		timeout = networkTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var body io.Reader
+	if method != "GET" {
+		body = bytes.NewReader(nil)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, githubAPIBase+"/"+endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("github api %s %s failed: %s", method, endpoint, strings.TrimSpace(string(respBody)))
+	}
+	return respBody, nil
+}
+
+// current returns the installation token, requesting a new one when there is
+// none yet or it expires within appTokenRefreshMargin. On error the previous
+// token, if any, is returned alongside it.
+func (s *appTokenSource) current() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && time.Until(s.expiresAt) > appTokenRefreshMargin {
+		return s.token, nil
+	}
+	token, expiresAt, err := requestInstallationToken(s.appID, s.keyFile, s.org)
+	if err != nil {
+		return s.token, err
+	}
+	if s.token != "" {
+		logger.Infow("refreshed GitHub App installation token", "app_id", s.appID, "expires_at", expiresAt)
+	}
+	s.token = token
+	s.expiresAt = expiresAt
+	return token, nil
+}
+
+// initGitHubAppAuth sets up --github-app-id authentication for org and fetches
+// the first installation token so that configuration errors surface before any
+// repository is touched.
+func initGitHubAppAuth(org string) error {
+	source := &appTokenSource{appID: githubAppID, keyFile: githubAppKeyFile, org: org}
+	token, err := source.current()
+	if err != nil {
+		return fmt.Errorf("GitHub App authentication failed: %v", err)
+	}
+	githubApp = source
+	githubToken = token
+	logger.Infow("authenticated as GitHub App", "app_id", githubAppID, "org", org)
+	return nil
+}
+
+// currentGitHubToken returns the token for REST calls, clone URLs and the
+// GH_TOKEN of gh and git commands. With a GitHub App it is the installation
+// token, refreshed as needed; if a refresh fails the previous token is used
+// until GitHub rejects it.
+func currentGitHubToken() string {
+	if githubApp == nil {
+		return githubToken
+	}
+	token, err := githubApp.current()
+	if err != nil {
+		logger.Warnw("failed to refresh GitHub App installation token", "error", err)
+	}
+	return token
+}
+
+// appInstallationOrg picks the account whose installation a command acts on:
+// the organization argument, or the owner of a repository argument. Other
+// commands use the app's only installation.
+func appInstallationOrg(command string, args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	switch command {
+	case "organization":
+		return args[0]
+	case "repository":
+		if repoName, err := extractRepoNameFromURL(args[0]); err == nil {
+			if idx := strings.Index(repoName, "/"); idx > 0 {
+				return repoName[:idx]
+			}
+		}
+	}
+	return ""
+}
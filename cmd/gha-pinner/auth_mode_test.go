@@ -89,3 +89,32 @@ func TestCreatePullRequestFallback_PATMode(t *testing.T) {
 		t.Fatalf("unexpected fallback error: %s", result.Stderr)
 	}
 }
+
+func TestValidateRuntimeConfig_GitHubTokenEnv(t *testing.T) {
+	oldMode, oldToken, oldWorkers := saveAuthGlobals()
+	oldEnv, oldAppID := githubTokenEnv, githubAppID
+	t.Cleanup(func() {
+		restoreAuthGlobals(oldMode, oldToken, oldWorkers)
+		githubTokenEnv, githubAppID = oldEnv, oldAppID
+	})
+	t.Setenv("GITHUB_TOKEN", "default-token")
+	t.Setenv("GITHUB_AUTOMATION_TOKEN", " automation-token ")
+
+	authMode, githubTokenEnv = "pat", "GITHUB_AUTOMATION_TOKEN"
+	if err := validateRuntimeConfig(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if githubToken != "automation-token" {
+		t.Fatalf("expected the token from --github-token-env, got %q", githubToken)
+	}
+	env := commandEnv("gh")
+	if len(env) != 1 || env[0] != "GH_TOKEN=automation-token" {
+		t.Fatalf("expected GH_TOKEN for gh commands, got %v", env)
+	}
+
+	githubTokenEnv = "GHA_PINNER_UNSET_TOKEN"
+	err := validateRuntimeConfig()
+	if err == nil || !strings.Contains(err.Error(), "GHA_PINNER_UNSET_TOKEN") {
+		t.Fatalf("expected an error naming the unset variable, got %v", err)
+	}
+}
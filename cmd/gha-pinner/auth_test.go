@@ -0,0 +1,149 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func writeAppKey(t *testing.T) (*rsa.PrivateKey, string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "app.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return key, path
+}
+
+// fakeAppAPI serves the installation endpoints, checking that every request
+// carries a JWT signed by key, and issues tokens expiring after ttl.
+func fakeAppAPI(t *testing.T, key *rsa.PrivateKey, ttl time.Duration, issued *int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jwt := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		parts := strings.Split(jwt, ".")
+		if len(parts) != 3 {
+			http.Error(w, "bad jwt", http.StatusUnauthorized)
+			return
+		}
+		sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		claimsJSON, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		var claims map[string]interface{}
+		_ = json.Unmarshal(claimsJSON, &claims)
+		if claims["iss"] != "12345" {
+			http.Error(w, "bad issuer", http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/orgs/octo-org/installation":
+			fmt.Fprint(w, `{"id": 42}`)
+		case r.Method == "GET" && r.URL.Path == "/app/installations":
+			fmt.Fprint(w, `[{"id": 42}]`)
+		case r.Method == "POST" && r.URL.Path == "/app/installations/42/access_tokens":
+			n := atomic.AddInt32(issued, 1)
+			fmt.Fprintf(w, `{"token": "ghs_token%d", "expires_at": %q}`, n, time.Now().Add(ttl).UTC().Format(time.RFC3339))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGetInstallationToken(t *testing.T) {
+	key, keyFile := writeAppKey(t)
+	var issued int32
+	server := fakeAppAPI(t, key, time.Hour, &issued)
+	oldBase := githubAPIBase
+	githubAPIBase = server.URL
+	t.Cleanup(func() { githubAPIBase = oldBase })
+
+	for _, org := range []string{"octo-org", ""} {
+		token, err := getInstallationToken(12345, keyFile, org)
+		if err != nil {
+			t.Fatalf("org %q: unexpected error: %v", org, err)
+		}
+		if !strings.HasPrefix(token, "ghs_token") {
+			t.Fatalf("org %q: unexpected token %q", org, token)
+		}
+	}
+
+	if _, err := getInstallationToken(12345, keyFile, "other-org"); err == nil {
+		t.Fatal("expected error for an organization without the app installed")
+	}
+	if _, err := getInstallationToken(12345, filepath.Join(t.TempDir(), "missing.pem"), "octo-org"); err == nil {
+		t.Fatal("expected error for a missing key file")
+	}
+}
+
+func TestAppTokenSource_RefreshesBeforeExpiry(t *testing.T) {
+	key, keyFile := writeAppKey(t)
+	var issued int32
+	// Tokens expiring inside the refresh margin are replaced on every use.
+	server := fakeAppAPI(t, key, appTokenRefreshMargin/2, &issued)
+	oldBase := githubAPIBase
+	githubAPIBase = server.URL
+	t.Cleanup(func() { githubAPIBase = oldBase })
+
+	source := &appTokenSource{appID: 12345, keyFile: keyFile, org: "octo-org"}
+	first, err := source.current()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := source.current()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second || issued != 2 {
+		t.Fatalf("expected a refreshed token, got %q then %q (%d issued)", first, second, issued)
+	}
+
+	source.expiresAt = time.Now().Add(time.Hour)
+	if third, _ := source.current(); third != second || issued != 2 {
+		t.Fatalf("expected the cached token while it is fresh, got %q (%d issued)", third, issued)
+	}
+}
+
+func TestAppInstallationOrg(t *testing.T) {
+	tests := []struct {
+		command string
+		args    []string
+		want    string
+	}{
+		{"organization", []string{"octo-org"}, "octo-org"},
+		{"repository", []string{"octo-org/repo"}, "octo-org"},
+		{"repository", []string{"https://github.com/octo-org/repo.git"}, "octo-org"},
+		{"local-repository", []string{"."}, ""},
+		{"file", nil, ""},
+	}
+	for _, tt := range tests {
+		if got := appInstallationOrg(tt.command, tt.args); got != tt.want {
+			t.Errorf("appInstallationOrg(%q, %v) = %q, want %q", tt.command, tt.args, got, tt.want)
+		}
+	}
+}
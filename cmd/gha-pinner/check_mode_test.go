@@ -0,0 +1,321 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeWorkflow(t *testing.T, repoDir, name, content string) string {
+	t.Helper()
+	dir := filepath.Join(repoDir, ".github", "workflows")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFindUnpinnedActions(t *testing.T) {
+	repoDir := t.TempDir()
+	path := writeWorkflow(t, repoDir, "ci.yml", `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@0123456789abcdef0123456789abcdef01234567 # v4
+      - uses: actions/setup-go@v5
+      - uses: ./local-action
+      - uses: docker://alpine:3.19
+      - run: echo hi
+`)
+
+	got, err := findUnpinnedActions(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, []string{"actions/setup-go@v5"}) {
+		t.Fatalf("unexpected unpinned actions: %v", got)
+	}
+}
+
+func TestCheckLocalRepository(t *testing.T) {
+	repoDir := t.TempDir()
+	writeWorkflow(t, repoDir, "pinned.yml", `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@0123456789abcdef0123456789abcdef01234567
+`)
+	if err := checkLocalRepository(repoDir); err != nil {
+		t.Fatalf("expected clean repository to pass, got: %v", err)
+	}
+
+	writeWorkflow(t, repoDir, "unpinned.yml", `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@v4
+`)
+	if err := checkLocalRepository(repoDir); !errors.Is(err, errUnpinnedFound) {
+		t.Fatalf("expected errUnpinnedFound, got: %v", err)
+	}
+}
+
+func TestCollectUnpinnedActions_SortedAndDeduplicated(t *testing.T) {
+	repoDir := t.TempDir()
+	writeWorkflow(t, repoDir, "a.yml", `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/setup-go@v5
+      - uses: actions/checkout@v4
+`)
+	writeWorkflow(t, repoDir, "b.yml", `on: push
+jobs:
+  test:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@v4
+`)
+
+	got, err := collectUnpinnedActions(repoDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"actions/checkout@v4", "actions/setup-go@v5"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %v, expected %v", got, expected)
+	}
+}
+
+func TestParseExistingPin(t *testing.T) {
+	const sha = "0123456789abcdef0123456789abcdef01234567"
+	tests := []struct {
+		uses string
+		want PinnedAction
+	}{
+		{"actions/checkout@" + sha + " # v4.1.1 on 2024-01-15", PinnedAction{Action: "actions/checkout", Hash: sha, Version: "v4.1.1", PinnedOn: "2024-01-15"}},
+		{"actions/checkout@" + sha + " # @latest resolved to v4.2.1 on 2024-02-01", PinnedAction{Action: "actions/checkout", Hash: sha, Version: "v4.2.1", PinnedOn: "2024-02-01"}},
+		{"actions/checkout@" + sha + " # v4", PinnedAction{Action: "actions/checkout", Hash: sha, Version: "v4"}},
+		{"actions/checkout@" + sha + " # v4.1.1、2024-01-15に固定", PinnedAction{Action: "actions/checkout", Hash: sha, Version: "v4.1.1", PinnedOn: "2024-01-15"}},
+		{"actions/checkout@" + sha + " # v4.1.1 gepinnt am 2024-01-15", PinnedAction{Action: "actions/checkout", Hash: sha, Version: "v4.1.1", PinnedOn: "2024-01-15"}},
+		{"'github/codeql-action/init@" + sha + "'", PinnedAction{Action: "github/codeql-action/init", Hash: sha}},
+	}
+	for _, tt := range tests {
+		got, err := parseExistingPin(tt.uses)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.uses, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseExistingPin(%q) = %+v, want %+v", tt.uses, got, tt.want)
+		}
+	}
+	if _, err := parseExistingPin("actions/checkout@v4 # v4"); err == nil {
+		t.Error("expected an error for an unpinned reference")
+	}
+}
+
+func TestCollectPinnedActions_SortedAndDeduplicated(t *testing.T) {
+	repoDir := t.TempDir()
+	writeWorkflow(t, repoDir, "a.yml", `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/setup-go@1111111111111111111111111111111111111111 # v5 on 2024-03-01
+      - uses: actions/checkout@0123456789abcdef0123456789abcdef01234567 # v4 on 2024-01-15
+      - uses: actions/cache@v4
+      - run: |
+          echo "uses: example/ignored@2222222222222222222222222222222222222222"
+`)
+	writeWorkflow(t, repoDir, "b.yml", `on: push
+jobs:
+  test:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@0123456789abcdef0123456789abcdef01234567 # v4 on 2024-01-15
+`)
+
+	got, err := collectPinnedActions(repoDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []PinnedAction{
+		{Action: "actions/checkout", Version: "v4", Hash: "0123456789abcdef0123456789abcdef01234567", PinnedOn: "2024-01-15", Files: []string{".github/workflows/a.yml", ".github/workflows/b.yml"}},
+		{Action: "actions/setup-go", Version: "v5", Hash: "1111111111111111111111111111111111111111", PinnedOn: "2024-03-01", Files: []string{".github/workflows/a.yml"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if line := got[0].String(); line != "actions/checkout@0123456789abcdef0123456789abcdef01234567 # v4 on 2024-01-15" {
+		t.Errorf("unexpected text form: %q", line)
+	}
+}
+
+func TestCheckPinnedRegressions(t *testing.T) {
+	repoDir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		result := execCommandWithDir(repoDir, "git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "commit.gpgsign=false"}, args...)...)
+		if result.ExitCode != 0 {
+			t.Skipf("git %v failed: %s", args, result.Stderr)
+		}
+	}
+	git("init", "-q")
+	pinned := `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@0123456789abcdef0123456789abcdef01234567 # v4
+`
+	writeWorkflow(t, repoDir, "pinned.yml", pinned)
+	writeWorkflow(t, repoDir, "legacy.yml", `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/setup-go@v5
+`)
+	git("add", "-A")
+	git("commit", "-q", "-m", "initial")
+
+	// Files that were never fully pinned, and new untracked files, are ignored.
+	writeWorkflow(t, repoDir, "new.yml", `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/cache@v4
+`)
+	if err := checkPinnedRegressions(repoDir); err != nil {
+		t.Fatalf("expected no regressions, got: %v", err)
+	}
+
+	writeWorkflow(t, repoDir, "pinned.yml", `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@v4
+`)
+	if err := checkPinnedRegressions(repoDir); !errors.Is(err, errUnpinnedFound) {
+		t.Fatalf("expected errUnpinnedFound, got: %v", err)
+	}
+}
+
+func TestCheckPinnedRegressions_BaseRef(t *testing.T) {
+	oldBase := checkBaseRef
+	t.Cleanup(func() { checkBaseRef = oldBase })
+	repoDir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		result := execCommandWithDir(repoDir, "git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "commit.gpgsign=false"}, args...)...)
+		if result.ExitCode != 0 {
+			t.Skipf("git %v failed: %s", args, result.Stderr)
+		}
+	}
+	git("init", "-q", "-b", "main")
+	writeWorkflow(t, repoDir, "ci.yml", `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@0123456789abcdef0123456789abcdef01234567 # v4
+      - uses: actions/setup-go@v5
+`)
+	git("add", "-A")
+	git("commit", "-q", "-m", "initial")
+
+	// A pull request branch commits the de-pinning, so HEAD and the working
+	// tree agree and only the comparison with the base branch catches it.
+	git("checkout", "-q", "-b", "feature")
+	writeWorkflow(t, repoDir, "ci.yml", `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@v4
+      - uses: actions/setup-go@v5
+`)
+	git("commit", "-q", "-am", "unpin checkout")
+
+	checkBaseRef = ""
+	if err := checkPinnedRegressions(repoDir); err != nil {
+		t.Fatalf("expected no regression against HEAD, got: %v", err)
+	}
+	checkBaseRef = "main"
+	if err := checkPinnedRegressions(repoDir); !errors.Is(err, errUnpinnedFound) {
+		t.Fatalf("expected errUnpinnedFound against main, got: %v", err)
+	}
+	checkBaseRef = "no-such-branch"
+	if err := checkPinnedRegressions(repoDir); err == nil || errors.Is(err, errUnpinnedFound) {
+		t.Fatalf("expected an error for an unknown base ref, got: %v", err)
+	}
+}
+
+func TestExportActionsList(t *testing.T) {
+	oldFormat := outputFormat
+	t.Cleanup(func() { outputFormat = oldFormat })
+	repoDir := t.TempDir()
+	writeWorkflow(t, repoDir, "a.yml", `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/setup-go@v5
+      - uses: actions/checkout@0123456789abcdef0123456789abcdef01234567 # v4 on 2024-01-15
+      - uses: ./local-action
+`)
+	writeWorkflow(t, repoDir, "b.yml", `on: push
+jobs:
+  test:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/setup-go@v5
+`)
+
+	path := filepath.Join(t.TempDir(), "actions.txt")
+	outputFormat = "text"
+	if err := exportActionsList(repoDir, path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "actions/checkout@0123456789abcdef0123456789abcdef01234567\nactions/setup-go@v5\n"
+	if string(got) != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	outputFormat = "json"
+	if err := exportActionsList(repoDir, path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []ActionReference
+	if err := json.Unmarshal(got, &entries); err != nil {
+		t.Fatalf("invalid JSON %q: %v", got, err)
+	}
+	wantEntries := []ActionReference{
+		{Action: "actions/checkout", Version: "v4", IsPinned: true, Hash: "0123456789abcdef0123456789abcdef01234567", Files: []string{".github/workflows/a.yml"}},
+		{Action: "actions/setup-go", Version: "v5", Files: []string{".github/workflows/a.yml", ".github/workflows/b.yml"}},
+	}
+	if !reflect.DeepEqual(entries, wantEntries) {
+		t.Fatalf("got %+v, want %+v", entries, wantEntries)
+	}
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"os"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestValidateRuntimeConfig_InvalidAuthMode(t *testing.T) {
@@ -132,3 +134,164 @@ func TestValidateRuntimeConfig_EgressPolicyIgnoredWithoutInjectFlag(t *testing.T
 		t.Fatalf("expected no error when --inject-harden-runner is false, got: %v", err)
 	}
 }
+
+func TestValidateRuntimeConfig_OutputDirStructure(t *testing.T) {
+	oldMode, oldToken, oldWorkers := saveAuthGlobals()
+	oldStructure := outputDirStructure
+	t.Cleanup(func() {
+		restoreAuthGlobals(oldMode, oldToken, oldWorkers)
+		outputDirStructure = oldStructure
+	})
+
+	authMode = "gh"
+	repoWorkers = 2
+
+	for _, structure := range []string{"flat", "org/repo"} {
+		outputDirStructure = structure
+		if err := validateRuntimeConfig(); err != nil {
+			t.Fatalf("expected no error for output-dir-structure=%q, got: %v", structure, err)
+		}
+	}
+
+	outputDirStructure = "nested"
+	if err := validateRuntimeConfig(); err == nil {
+		t.Fatal("expected validation error for invalid output-dir-structure")
+	}
+}
+
+func TestValidateRuntimeConfig_SyncForkStrategy(t *testing.T) {
+	oldMode, oldToken, oldWorkers := saveAuthGlobals()
+	oldStrategy := syncForkStrategy
+	t.Cleanup(func() {
+		restoreAuthGlobals(oldMode, oldToken, oldWorkers)
+		syncForkStrategy = oldStrategy
+	})
+
+	authMode = "gh"
+	repoWorkers = 2
+
+	for _, strategy := range []string{"api", "gh-sync", "none"} {
+		syncForkStrategy = strategy
+		if err := validateRuntimeConfig(); err != nil {
+			t.Fatalf("expected no error for sync-fork-strategy=%q, got: %v", strategy, err)
+		}
+	}
+
+	syncForkStrategy = "rebase"
+	if err := validateRuntimeConfig(); err == nil {
+		t.Fatal("expected validation error for invalid sync-fork-strategy")
+	}
+
+	t.Setenv("GITHUB_TOKEN", "test-token")
+	authMode = "pat"
+	syncForkStrategy = "gh-sync"
+	if err := validateRuntimeConfig(); err == nil {
+		t.Fatal("expected validation error for gh-sync with --auth-mode pat")
+	}
+	syncForkStrategy = "api"
+	if err := validateRuntimeConfig(); err != nil {
+		t.Fatalf("expected no error for api with --auth-mode pat, got: %v", err)
+	}
+}
+
+func TestValidateRuntimeConfig_ForkVisibility(t *testing.T) {
+	oldMode, oldToken, oldWorkers := saveAuthGlobals()
+	oldVisibility := forkVisibility
+	t.Cleanup(func() {
+		restoreAuthGlobals(oldMode, oldToken, oldWorkers)
+		forkVisibility = oldVisibility
+	})
+
+	authMode = "gh"
+	repoWorkers = 2
+
+	for _, visibility := range []string{"", "public", "private"} {
+		forkVisibility = visibility
+		if err := validateRuntimeConfig(); err != nil {
+			t.Fatalf("expected no error for fork-visibility=%q, got: %v", visibility, err)
+		}
+	}
+
+	forkVisibility = "internal"
+	if err := validateRuntimeConfig(); err == nil {
+		t.Fatal("expected validation error for invalid fork-visibility")
+	}
+}
+
+func TestValidateRuntimeConfig_UnresolvablePolicies(t *testing.T) {
+	oldMode, oldToken, oldWorkers := saveAuthGlobals()
+	oldIgnore, oldFail := ignoreUnresolvable, failOnUnresolvable
+	t.Cleanup(func() {
+		restoreAuthGlobals(oldMode, oldToken, oldWorkers)
+		ignoreUnresolvable, failOnUnresolvable = oldIgnore, oldFail
+	})
+
+	authMode = "gh"
+	repoWorkers = 2
+
+	ignoreUnresolvable, failOnUnresolvable = true, false
+	if err := validateRuntimeConfig(); err != nil {
+		t.Fatalf("expected no error for --ignore-unresolvable, got: %v", err)
+	}
+	ignoreUnresolvable, failOnUnresolvable = false, true
+	if err := validateRuntimeConfig(); err != nil {
+		t.Fatalf("expected no error for --fail-on-unresolvable, got: %v", err)
+	}
+	ignoreUnresolvable, failOnUnresolvable = true, true
+	if err := validateRuntimeConfig(); err == nil {
+		t.Fatal("expected validation error when both unresolvable policies are set")
+	}
+}
+
+func TestActionCloneDepths(t *testing.T) {
+	old := cloneDepth
+	t.Cleanup(func() { cloneDepth = old })
+
+	tests := []struct {
+		depth int
+		want  []string
+	}{
+		{-1, []string{"--depth=1", "--depth=10", ""}},
+		{0, []string{""}},
+		{50, []string{"--depth=50"}},
+	}
+	for _, tt := range tests {
+		cloneDepth = tt.depth
+		if got := actionCloneDepths(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("--clone-depth %d: got %q, want %q", tt.depth, got, tt.want)
+		}
+	}
+
+	oldMode, oldToken, oldWorkers := saveAuthGlobals()
+	t.Cleanup(func() { restoreAuthGlobals(oldMode, oldToken, oldWorkers) })
+	authMode = "gh"
+	repoWorkers = 2
+	cloneDepth = -2
+	if err := validateRuntimeConfig(); err == nil {
+		t.Fatal("expected validation error for negative --clone-depth")
+	}
+}
+
+func TestValidateRuntimeConfig_ForkSyncRetries(t *testing.T) {
+	oldMode, oldToken, oldWorkers := saveAuthGlobals()
+	oldAttempts, oldDelay := forkSyncAttempts, forkSyncDelay
+	t.Cleanup(func() {
+		restoreAuthGlobals(oldMode, oldToken, oldWorkers)
+		forkSyncAttempts, forkSyncDelay = oldAttempts, oldDelay
+	})
+	authMode = "gh"
+	repoWorkers = 2
+
+	forkSyncAttempts, forkSyncDelay = 1, 0
+	if err := validateRuntimeConfig(); err != nil {
+		t.Fatalf("expected a single attempt without delay to be valid, got: %v", err)
+	}
+	forkSyncAttempts = 0
+	if err := validateRuntimeConfig(); err == nil {
+		t.Fatal("expected validation error for --retry-fork-sync 0")
+	}
+	forkSyncAttempts, forkSyncDelay = 3, -time.Second
+	if err := validateRuntimeConfig(); err == nil {
+		t.Fatal("expected validation error for a negative --fork-sync-delay")
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestBuildCommitMessage_WithCoAuthors(t *testing.T) {
+	got := buildCommitMessage("security: pin actions", "Details", []string{"Alice <alice@example.com>", "Bob <bob@example.com>"})
+	expected := "security: pin actions\n\nDetails\n\nCo-authored-by: Alice <alice@example.com>\nCo-authored-by: Bob <bob@example.com>"
+	if got != expected {
+		t.Fatalf("unexpected commit message:\n%q\nexpected:\n%q", got, expected)
+	}
+}
+
+func TestBuildCommitMessage_NoCoAuthors(t *testing.T) {
+	got := buildCommitMessage("title", "body", nil)
+	if got != "title\n\nbody" {
+		t.Fatalf("unexpected commit message: %q", got)
+	}
+}
+
+func TestLoadCoAuthorsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "co-authors.txt")
+	content := "# bots\nAlice <alice@example.com>\n\n  Bob <bob@example.com>  \n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := loadCoAuthorsFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"Alice <alice@example.com>", "Bob <bob@example.com>"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %v, expected %v", got, expected)
+	}
+}
+
+func TestLoadCoAuthorsFile_InvalidEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "co-authors.txt")
+	if err := os.WriteFile(path, []byte("Alice without email\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadCoAuthorsFile(path); err == nil {
+		t.Fatal("expected error for entry without email")
+	}
+}
+
+func TestMergeCoAuthors_DedupesByEmail(t *testing.T) {
+	flagAuthors := []string{"Alice <alice@example.com>"}
+	fileAuthors := []string{"Alice Smith <ALICE@example.com>", "Bob <bob@example.com>"}
+
+	got := mergeCoAuthors(flagAuthors, fileAuthors)
+	expected := []string{"Alice <alice@example.com>", "Bob <bob@example.com>"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %v, expected %v", got, expected)
+	}
+}
+
+func TestBuildCommitArgs_Signed(t *testing.T) {
+	args := buildCommitArgs("msg", true, "")
+	if !contains(args, "-S") {
+		t.Fatalf("expected -S in commit args, got %v", args)
+	}
+
+	args = buildCommitArgs("msg", true, "ABCDEF12")
+	if !contains(args, "--gpg-sign=ABCDEF12") {
+		t.Fatalf("expected --gpg-sign with key in commit args, got %v", args)
+	}
+}
+
+func TestBuildCommitArgs_Unsigned(t *testing.T) {
+	args := buildCommitArgs("msg", false, "")
+	expected := []string{"git", "commit", "-m", "msg"}
+	if !reflect.DeepEqual(args, expected) {
+		t.Fatalf("got %v, expected %v", args, expected)
+	}
+}
+
+func TestLoadCommitTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "commit-msg.txt")
+	content := "chore(ci): pin {{.PinnedCount}} actions in {{.Repo}}\n\nFiles: {{.Files}}\n\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := loadCommitTemplate(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := renderCommitTemplate(tmpl, "acme/api", []string{".github/workflows/ci.yml", ".github/workflows/release.yml"}, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "chore(ci): pin 3 actions in acme/api\n\nFiles: .github/workflows/ci.yml, .github/workflows/release.yml"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestLoadCommitTemplate_ParseError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.txt")
+	if err := os.WriteFile(path, []byte("{{.Repo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadCommitTemplate(path); err == nil || !strings.Contains(err.Error(), "--commit-template-file") {
+		t.Fatalf("expected parse error mentioning the flag, got %v", err)
+	}
+}
+
+func TestValidateRuntimeConfig_MessageTemplatePrecedence(t *testing.T) {
+	oldMode, oldToken, oldWorkers := saveAuthGlobals()
+	oldInline, oldFile, oldTmpl := messageTemplateRaw, commitTemplateFile, commitTemplate
+	t.Cleanup(func() {
+		restoreAuthGlobals(oldMode, oldToken, oldWorkers)
+		messageTemplateRaw, commitTemplateFile, commitTemplate = oldInline, oldFile, oldTmpl
+	})
+	authMode = "gh"
+	repoWorkers = 2
+
+	path := filepath.Join(t.TempDir(), "commit-msg.txt")
+	if err := os.WriteFile(path, []byte("from file"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	messageTemplateRaw = "inline {{.Repo}}"
+	commitTemplateFile = path
+	if err := validateRuntimeConfig(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := renderCommitTemplate(commitTemplate, "acme/api", nil, 0)
+	if err != nil || got != "inline acme/api" {
+		t.Fatalf("expected --message-template to take precedence, got %q (err %v)", got, err)
+	}
+}
+
+func TestCommitLocalChanges_OnlyRewrittenFiles(t *testing.T) {
+	oldCache, oldTmpl, oldSign := hashCache, commitTemplate, signCommits
+	t.Cleanup(func() { hashCache, commitTemplate, signCommits = oldCache, oldTmpl, oldSign })
+	hashCache = newActionHashCache()
+	hashCache.put("actions/checkout", "v4", lockEntry{hash: testSHA, resolvedVersion: "v4"})
+	commitTemplate = template.Must(template.New("msg").Parse("chore: pin {{.PinnedCount}} actions"))
+	signCommits = false
+
+	repoDir := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		result := execCommandWithDir(repoDir, "git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "commit.gpgsign=false"}, args...)...)
+		if result.ExitCode != 0 {
+			t.Skipf("git %v failed: %s", args, result.Stderr)
+		}
+		return result.Stdout
+	}
+	git("init", "-q")
+	git("config", "user.name", "test")
+	git("config", "user.email", "test@example.com")
+	git("config", "commit.gpgsign", "false")
+	writeWorkflow(t, repoDir, "ci.yml", "on: push\njobs:\n  build:\n    runs-on: ubuntu-latest\n    steps:\n      - uses: actions/checkout@v4\n")
+	for _, name := range []string{"README.md", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(repoDir, name), []byte("initial\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("add", "-A")
+	git("commit", "-q", "-m", "initial")
+
+	// Unrelated work in progress, one file modified and one staged.
+	for _, name := range []string{"README.md", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(repoDir, name), []byte("edited\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("add", "notes.txt")
+
+	summary, err := patchLocalRepository(repoDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := commitLocalChanges(repoDir, summary); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := strings.TrimSpace(git("show", "--name-only", "--format=", "HEAD")); got != ".github/workflows/ci.yml" {
+		t.Fatalf("expected only the rewritten workflow to be committed, got %q", got)
+	}
+	if got := git("status", "--porcelain"); !strings.Contains(got, " M README.md") || !strings.Contains(got, "M  notes.txt") {
+		t.Fatalf("expected unrelated changes to stay uncommitted, got:\n%s", got)
+	}
+}
@@ -102,7 +102,7 @@ runs:
 		t.Fatal(err)
 	}
 
-	if err := patchLocalRepository(repoDir); err != nil {
+	if _, err := patchLocalRepository(repoDir); err != nil {
 		t.Fatalf("patchLocalRepository returned unexpected error: %v", err)
 	}
 
@@ -114,3 +114,46 @@ runs:
 		t.Error("composite action file was unexpectedly modified")
 	}
 }
+
+func TestPatchLocalRepository_IgnoreCompositeActionRefs(t *testing.T) {
+	old := ignoreCompositeRefs
+	t.Cleanup(func() { ignoreCompositeRefs = old })
+	ignoreCompositeRefs = true
+
+	repoDir := t.TempDir()
+	workflowsDir := filepath.Join(repoDir, ".github", "workflows")
+	actionsDir := filepath.Join(repoDir, ".github", "actions", "my-action")
+	if err := os.MkdirAll(workflowsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(actionsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	wf := "on: [push]\njobs:\n  build:\n    runs-on: ubuntu-latest\n    steps:\n      - uses: actions/checkout@b4ffde65f46336ab88eb53be808477a3936bae11 # v4\n"
+	if err := os.WriteFile(filepath.Join(workflowsDir, "ci.yml"), []byte(wf), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ca := "runs:\n  using: composite\n  steps:\n    - uses: example/never-resolved@v1\n"
+	if err := os.WriteFile(filepath.Join(actionsDir, "action.yml"), []byte(ca), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	targets, err := listScanTargets(repoDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 1 || filepath.Base(targets[0]) != "ci.yml" {
+		t.Errorf("expected only the workflow file to be scanned, got %v", targets)
+	}
+
+	if _, err := patchLocalRepository(repoDir); err != nil {
+		t.Fatalf("patchLocalRepository returned unexpected error: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(actionsDir, "action.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != ca {
+		t.Errorf("composite action was modified:\n%s", got)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type dependabotConfig struct {
+	Updates []struct {
+		PackageEcosystem string   `yaml:"package-ecosystem"`
+		Directory        string   `yaml:"directory"`
+		Directories      []string `yaml:"directories"`
+	} `yaml:"updates"`
+}
+
+// parseDependabotConfig returns the de-duplicated directories of every
+// package-ecosystem: github-actions entry in a dependabot.yml file.
+func parseDependabotConfig(path string) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var cfg dependabotConfig
+	if err := yaml.Unmarshal(content, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	seen := map[string]bool{}
+	var dirs []string
+	for _, update := range cfg.Updates {
+		if update.PackageEcosystem != "github-actions" {
+			continue
+		}
+		entries := update.Directories
+		if update.Directory != "" {
+			entries = append([]string{update.Directory}, entries...)
+		}
+		for _, dir := range entries {
+			dir = "/" + strings.Trim(strings.TrimSpace(dir), "/")
+			if !seen[dir] {
+				seen[dir] = true
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// migrateDependabot pins the actions in every directory dependabot tracks
+// for GitHub Actions. "/" (or any directory containing .github/workflows) is
+// processed like local-repository; other directories are composite actions.
+func migrateDependabot(repoDir string) error {
+	configPath := filepath.Join(repoDir, ".github", "dependabot.yml")
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		configPath = filepath.Join(repoDir, ".github", "dependabot.yaml")
+	}
+	dirs, err := parseDependabotConfig(configPath)
+	if err != nil {
+		return err
+	}
+	if len(dirs) == 0 {
+		fmt.Printf("ℹ️  No github-actions entries found in %s\n", configPath)
+		return nil
+	}
+
+	fmt.Printf("📦 Found %d github-actions dependabot directories in %s: %s\n", len(dirs), filepath.Base(configPath), strings.Join(dirs, ", "))
+	for _, dir := range dirs {
+		target := filepath.Join(repoDir, filepath.FromSlash(strings.TrimPrefix(dir, "/")))
+		fmt.Printf("\n📂 Processing dependabot directory: %s\n", dir)
+		if _, err := os.Stat(filepath.Join(target, ".github", "workflows")); dir == "/" || err == nil {
+			if _, err := patchLocalRepository(target); err != nil {
+				return fmt.Errorf("failed to process %s: %w", dir, err)
+			}
+			continue
+		}
+		if err := patchActionDirectory(target); err != nil {
+			return fmt.Errorf("failed to process %s: %w", dir, err)
+		}
+	}
+	return nil
+}
+
+// patchActionDirectory pins the action.yml/action.yaml of a composite action directory.
+func patchActionDirectory(dir string) error {
+	patcher := &WorkflowPatcher{
+		egressPolicy:  egressPolicy,
+		pinRunners:    pinRunners,
+		runnerMap:     runnerMap,
+		preserveMtime: preserveMtime,
+	}
+	for _, name := range []string{"action.yml", "action.yaml"} {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		res, err := patcher.patchFile(path)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("   • %s: %d pinned, %d already pinned\n", path, res.actionsPinned, res.actionsAlreadyPinned)
+		return nil
+	}
+	fmt.Printf("ℹ️  No action.yml found in %s\n", dir)
+	return nil
+}
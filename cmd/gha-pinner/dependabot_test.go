@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseDependabotConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dependabot.yml")
+	content := `version: 2
+updates:
+  - package-ecosystem: github-actions
+    directory: /
+    schedule:
+      interval: weekly
+  - package-ecosystem: gomod
+    directory: /tools
+  - package-ecosystem: github-actions
+    directories:
+      - /.github/actions/setup/
+      - "/"
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dirs, err := parseDependabotConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"/", "/.github/actions/setup"}
+	if !reflect.DeepEqual(dirs, want) {
+		t.Fatalf("parseDependabotConfig() = %v, want %v", dirs, want)
+	}
+}
+
+func TestParseDependabotConfig_Missing(t *testing.T) {
+	if _, err := parseDependabotConfig(filepath.Join(t.TempDir(), "dependabot.yml")); err == nil {
+		t.Fatal("expected error for missing dependabot.yml")
+	}
+}
+
+func TestMigrateDependabot_PinnedComposite(t *testing.T) {
+	repoDir := t.TempDir()
+	actionDir := filepath.Join(repoDir, ".github", "actions", "setup")
+	if err := os.MkdirAll(actionDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	config := "version: 2\nupdates:\n  - package-ecosystem: github-actions\n    directory: /.github/actions/setup\n"
+	if err := os.WriteFile(filepath.Join(repoDir, ".github", "dependabot.yml"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	action := "runs:\n  using: composite\n  steps:\n    - uses: actions/checkout@b4ffde65f46336ab88eb53be808477a3936bae11 # v4\n"
+	if err := os.WriteFile(filepath.Join(actionDir, "action.yml"), []byte(action), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrateDependabot(repoDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
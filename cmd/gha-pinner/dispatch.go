@@ -0,0 +1,31 @@
+package main
+
+// ruleDispatchWithoutInputs identifies workflow_dispatch triggers that declare
+// no inputs. It is informational: nothing about pinning depends on it.
+const ruleDispatchWithoutInputs = "GHA010"
+
+// hasDispatchWithoutInputs reports whether a parsed workflow is triggered by
+// workflow_dispatch without an inputs: block, either because the event is
+// listed by name only (on: workflow_dispatch, on: [push, workflow_dispatch])
+// or because its inputs: map is missing or empty.
+func hasDispatchWithoutInputs(workflow map[string]interface{}) bool {
+	switch on := workflow["on"].(type) {
+	case string:
+		return on == "workflow_dispatch"
+	case []interface{}:
+		for _, event := range on {
+			if event == "workflow_dispatch" {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		dispatch, ok := on["workflow_dispatch"]
+		if !ok {
+			return false
+		}
+		config, _ := dispatch.(map[string]interface{})
+		inputs, _ := config["inputs"].(map[string]interface{})
+		return len(inputs) == 0
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestHasDispatchWithoutInputs(t *testing.T) {
+	tests := []struct {
+		name string
+		on   string
+		want bool
+	}{
+		{"event name", "on: workflow_dispatch", true},
+		{"event list", "on: [push, workflow_dispatch]", true},
+		{"empty trigger", "on:\n  workflow_dispatch:", true},
+		{"empty inputs", "on:\n  workflow_dispatch:\n    inputs: {}", true},
+		{"declared inputs", "on:\n  workflow_dispatch:\n    inputs:\n      env:\n        type: string\n        default: staging", false},
+		{"other events", "on:\n  push:\n    branches: [main]", false},
+		{"no trigger", "name: CI", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var workflow map[string]interface{}
+			if err := yaml.Unmarshal([]byte(tt.on+"\njobs: {}\n"), &workflow); err != nil {
+				t.Fatal(err)
+			}
+			if got := hasDispatchWithoutInputs(workflow); got != tt.want {
+				t.Errorf("hasDispatchWithoutInputs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkflowPatcher_DetectDispatchInputs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deploy.yml")
+	content := "on: workflow_dispatch\njobs:\n  deploy:\n    runs-on: ubuntu-latest\n    steps:\n      - run: ./deploy.sh\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, enabled := range []bool{true, false} {
+		p := &WorkflowPatcher{egressPolicy: "audit", detectDispatch: enabled}
+		res, err := p.patchFile(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := 0
+		if enabled {
+			want = 1
+		}
+		if res.dispatchFindings != want {
+			t.Errorf("enabled=%v: dispatchFindings = %d, want %d", enabled, res.dispatchFindings, want)
+		}
+	}
+	if got, _ := os.ReadFile(path); string(got) != content {
+		t.Errorf("the check must not modify the workflow:\n%s", got)
+	}
+}
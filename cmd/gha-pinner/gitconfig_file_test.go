@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCommandEnv_GitConfigFile(t *testing.T) {
+	old := gitConfigFile
+	t.Cleanup(func() { gitConfigFile = old })
+
+	gitConfigFile = ""
+	if env := commandEnv("git"); env != nil {
+		t.Fatalf("expected no extra env without --gitconfig-file, got %v", env)
+	}
+
+	gitConfigFile = "/tmp/ci-gitconfig"
+	for _, name := range []string{"git", "gh"} {
+		env := commandEnv(name)
+		if len(env) != 1 || env[0] != "GIT_CONFIG_GLOBAL=/tmp/ci-gitconfig" {
+			t.Errorf("commandEnv(%q) = %v", name, env)
+		}
+	}
+	if env := commandEnv("curl"); env != nil {
+		t.Errorf("expected no extra env for unrelated commands, got %v", env)
+	}
+}
+
+func TestExecCommand_PassesGitConfigGlobal(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	old := gitConfigFile
+	t.Cleanup(func() { gitConfigFile = old })
+
+	gitConfigFile = filepath.Join(t.TempDir(), "ci-gitconfig")
+	if err := os.WriteFile(gitConfigFile, []byte("[user]\n\tname = ci-bot\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	result := execCommandWithDir(t.TempDir(), "git", "config", "--global", "--get", "user.name")
+	if result.ExitCode != 0 || strings.TrimSpace(result.Stdout) != "ci-bot" {
+		t.Fatalf("expected git to read --gitconfig-file, got exit=%d stdout=%q stderr=%q", result.ExitCode, result.Stdout, result.Stderr)
+	}
+
+	if res := setGitConfig(t.TempDir(), "user.email", "ci-bot@example.com"); res.ExitCode != 0 {
+		t.Fatalf("setGitConfig failed: %s", res.Stderr)
+	}
+	content, err := os.ReadFile(gitConfigFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "ci-bot@example.com") {
+		t.Fatalf("expected setting to be written to --gitconfig-file, got %q", content)
+	}
+}
+
+func TestBuildGitEnv(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "http://old-proxy:3128")
+	env := buildGitEnv([]string{"HTTPS_PROXY=http://proxy:3128", "GIT_SSL_CAINFO=/etc/certs/a.crt", "GIT_SSL_CAINFO=/etc/certs/ca.crt"})
+
+	seen := map[string]string{}
+	for _, v := range env {
+		key, value, _ := strings.Cut(v, "=")
+		if _, dup := seen[key]; dup {
+			t.Fatalf("duplicate key %s in %v", key, env)
+		}
+		seen[key] = value
+	}
+	if seen["HTTPS_PROXY"] != "http://proxy:3128" || seen["GIT_SSL_CAINFO"] != "/etc/certs/ca.crt" {
+		t.Errorf("expected extra vars to override, got HTTPS_PROXY=%q GIT_SSL_CAINFO=%q", seen["HTTPS_PROXY"], seen["GIT_SSL_CAINFO"])
+	}
+	if _, ok := seen["PATH"]; !ok {
+		t.Error("expected the process environment to be kept")
+	}
+}
+
+func TestCommandEnv_WorkflowEnvVars(t *testing.T) {
+	old := gitEnvVars
+	t.Cleanup(func() { gitEnvVars = old })
+	gitEnvVars = []string{"GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=user.name", "GIT_CONFIG_VALUE_0=proxy-bot"}
+
+	if env := commandEnv("gh"); env != nil {
+		t.Errorf("expected gh to keep the process environment, got %v", env)
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	result := execCommandWithDir(t.TempDir(), "git", "config", "--get", "user.name")
+	if result.ExitCode != 0 || strings.TrimSpace(result.Stdout) != "proxy-bot" {
+		t.Fatalf("expected git to see --workflow-env-vars, got exit=%d stdout=%q stderr=%q", result.ExitCode, result.Stdout, result.Stderr)
+	}
+}
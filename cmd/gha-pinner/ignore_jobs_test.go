@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsIgnoredJob(t *testing.T) {
+	old := ignoreJobs
+	t.Cleanup(func() { ignoreJobs = old })
+	ignoreJobs = []string{"security-scan", "codeql-*"}
+
+	tests := []struct {
+		job      string
+		expected bool
+	}{
+		{"security-scan", true},
+		{"codeql-analysis", true},
+		{"build", false},
+		{"codeql", false},
+	}
+	for _, tc := range tests {
+		if got := isIgnoredJob(tc.job); got != tc.expected {
+			t.Errorf("isIgnoredJob(%q) = %v, expected %v", tc.job, got, tc.expected)
+		}
+	}
+}
+
+func TestReplaceUsesOnLines_SkipsIgnoredJob(t *testing.T) {
+	old := ignoreJobs
+	t.Cleanup(func() { ignoreJobs = old })
+	ignoreJobs = []string{"codeql-*"}
+
+	content := `on: push
+jobs:
+  codeql-analysis:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@v4
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@v4
+`
+	ranges := ignoredJobLineRanges(content)
+	if len(ranges) != 1 {
+		t.Fatalf("expected one ignored range, got %v", ranges)
+	}
+
+	updated := replaceUsesOnLines(content, "uses: actions/checkout@v4", "uses: actions/checkout@abc # v4", editableUsesLines(content))
+	lines := strings.Split(updated, "\n")
+	if lines[5] != "      - uses: actions/checkout@v4" {
+		t.Errorf("ignored job should be untouched, got %q", lines[5])
+	}
+	if lines[9] != "      - uses: actions/checkout@abc # v4" {
+		t.Errorf("build job should be pinned, got %q", lines[9])
+	}
+}
+
+func TestCollectJobSteps_SkipsIgnoredJobs(t *testing.T) {
+	old := ignoreJobs
+	t.Cleanup(func() { ignoreJobs = old })
+	ignoreJobs = []string{"security-scan"}
+
+	workflow := map[string]interface{}{
+		"jobs": map[string]interface{}{
+			"security-scan": map[string]interface{}{"steps": []interface{}{map[string]interface{}{"uses": "a/b@v1"}}},
+			"build":         map[string]interface{}{"steps": []interface{}{map[string]interface{}{"uses": "c/d@v1"}}},
+		},
+	}
+	steps := collectJobSteps(workflow, false)
+	if len(steps) != 1 || steps[0][0]["uses"] != "c/d@v1" {
+		t.Fatalf("expected only the build job's steps, got %v", steps)
+	}
+}
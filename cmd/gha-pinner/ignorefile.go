@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreFileName is the per-repository file listing workflow paths that
+// gha-pinner must not modify, using .gitignore syntax.
+const ignoreFileName = ".gha-pinner.ignore"
+
+type ignoreRule struct {
+	re       *regexp.Regexp
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// ignoreMatcher implements the subset of .gitignore semantics that matters for
+// workflow files: comments, negation, anchoring, directory-only rules and
+// the *, ? and ** wildcards. The last matching rule wins.
+type ignoreMatcher struct {
+	rules []ignoreRule
+}
+
+// loadIgnoreFile reads .gha-pinner.ignore from the repository root. A missing
+// file yields an empty matcher.
+func loadIgnoreFile(repoDir string) (*ignoreMatcher, error) {
+	file, err := os.Open(filepath.Join(repoDir, ignoreFileName))
+	if os.IsNotExist(err) {
+		return &ignoreMatcher{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", ignoreFileName, err)
+	}
+	defer file.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		patterns = append(patterns, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", ignoreFileName, err)
+	}
+	return newIgnoreMatcher(patterns), nil
+}
+
+func newIgnoreMatcher(patterns []string) *ignoreMatcher {
+	m := &ignoreMatcher{}
+	for _, raw := range patterns {
+		pattern := strings.TrimRight(raw, " \t\r")
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+		rule := ignoreRule{}
+		if strings.HasPrefix(pattern, "!") {
+			rule.negate = true
+			pattern = pattern[1:]
+		}
+		if strings.HasSuffix(pattern, "/") {
+			rule.dirOnly = true
+			pattern = strings.TrimSuffix(pattern, "/")
+		}
+		if strings.Contains(pattern, "/") {
+			rule.anchored = true
+			pattern = strings.TrimPrefix(pattern, "/")
+		}
+		if pattern == "" {
+			continue
+		}
+		rule.re = regexp.MustCompile("^" + globToRegexp(pattern) + "$")
+		m.rules = append(m.rules, rule)
+	}
+	return m
+}
+
+// globToRegexp converts a gitignore glob to a regular expression body.
+func globToRegexp(pattern string) string {
+	var sb strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "/**"):
+			sb.WriteString("(?:/.*)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return sb.String()
+}
+
+// matches reports whether relPath (slash-separated, relative to the repository
+// root) is ignored, either directly or because one of its parent directories is.
+func (m *ignoreMatcher) matches(relPath string) bool {
+	if m == nil || len(m.rules) == 0 {
+		return false
+	}
+	parts := strings.Split(strings.TrimPrefix(relPath, "/"), "/")
+	ignored := false
+	for i := range parts {
+		candidate := strings.Join(parts[:i+1], "/")
+		isDir := i < len(parts)-1
+		for _, rule := range m.rules {
+			if rule.dirOnly && !isDir {
+				continue
+			}
+			subject := parts[i]
+			if rule.anchored {
+				subject = candidate
+			}
+			if rule.re.MatchString(subject) {
+				ignored = !rule.negate
+			}
+		}
+		// As in git, a file inside an ignored directory cannot be re-included.
+		if ignored && isDir {
+			return true
+		}
+	}
+	return ignored
+}
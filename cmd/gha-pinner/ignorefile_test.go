@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIgnoreMatcher(t *testing.T) {
+	m := newIgnoreMatcher([]string{
+		"# generated workflows",
+		"release.yml",
+		".github/workflows/nightly-*.yml",
+		"!nightly-keep.yml",
+		"vendor/",
+		"**/legacy/**",
+	})
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{".github/workflows/release.yml", true},
+		{".github/workflows/ci.yml", false},
+		{".github/workflows/nightly-build.yml", true},
+		{".github/workflows/nightly-keep.yml", false},
+		{".github/actions/vendor/action.yml", true},
+		{".github/actions/legacy/setup/action.yml", true},
+		{".github/actions/vendor.yml", false},
+	}
+	for _, tc := range tests {
+		if got := m.matches(tc.path); got != tc.expected {
+			t.Errorf("matches(%q) = %v, expected %v", tc.path, got, tc.expected)
+		}
+	}
+}
+
+func TestLoadIgnoreFile_Missing(t *testing.T) {
+	m, err := loadIgnoreFile(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m.matches(".github/workflows/ci.yml") {
+		t.Fatal("empty matcher should not ignore anything")
+	}
+}
+
+func TestCollectUnpinnedActions_RespectsIgnoreFile(t *testing.T) {
+	repoDir := t.TempDir()
+	writeWorkflow(t, repoDir, "ci.yml", `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@v4
+`)
+	if err := os.WriteFile(filepath.Join(repoDir, ignoreFileName), []byte("ci.yml\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := collectUnpinnedActions(repoDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("expected ignored workflow to be skipped, got %v", got)
+	}
+}
+
+func TestPatchLocalRepository_MaxWorkflowFiles(t *testing.T) {
+	oldMax, oldCache := maxWorkflowFiles, hashCache
+	t.Cleanup(func() { maxWorkflowFiles, hashCache = oldMax, oldCache })
+	hashCache = newActionHashCache()
+	hashCache.put("actions/checkout", "v4", lockEntry{hash: testSHA, resolvedVersion: "v4"})
+	maxWorkflowFiles = 2
+
+	repoDir := t.TempDir()
+	workflow := "on: push\njobs:\n  build:\n    runs-on: ubuntu-latest\n    steps:\n      - uses: actions/checkout@v4\n"
+	for _, name := range []string{"d.yml", "a.yml", "c.yml", "b.yml"} {
+		writeWorkflow(t, repoDir, name, workflow)
+	}
+	if err := os.WriteFile(filepath.Join(repoDir, ignoreFileName), []byte("a.yml\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := patchLocalRepository(repoDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for name, wantPinned := range map[string]bool{"a.yml": false, "b.yml": true, "c.yml": true, "d.yml": false} {
+		content, err := os.ReadFile(filepath.Join(repoDir, ".github", "workflows", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pinned := strings.Contains(string(content), testSHA); pinned != wantPinned {
+			t.Errorf("%s: pinned = %v, expected %v", name, pinned, wantPinned)
+		}
+	}
+}
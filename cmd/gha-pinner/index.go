@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// actionIndex is a pre-built map of popular action references to commit hashes,
+// stored at ~/.config/gha-pinner/index.json and refreshed with update-index.
+type actionIndex struct {
+	GeneratedAt string            `json:"generated_at"`
+	Source      string            `json:"source"`
+	Actions     map[string]string `json:"actions"`
+}
+
+// gha-pinner configuration read from ~/.config/gha-pinner/config.yaml.
+type pinnerConfig struct {
+	IndexURL         string            `yaml:"index_url"`
+	TrustedOrgs      []string          `yaml:"trusted_orgs"`
+	ActionPrefixMap  map[string]string `yaml:"action_prefix_map"`
+	MinActionVersion map[string]string `yaml:"min_action_version"`
+	// Runtime switches, named after the globals they set: skip_pr_creation is
+	// --no-pr, no_push --no-push and ignore_pr_templates --ignore-templates.
+	SkipPRCreation    *bool `yaml:"skip_pr_creation"`
+	NoPush            *bool `yaml:"no_push"`
+	IgnorePRTemplates *bool `yaml:"ignore_pr_templates"`
+}
+
+// applyRuntimeSwitches sets the runtime flags named in cfg. A flag already
+// given on the command line stays on whatever the config says.
+func applyRuntimeSwitches(cfg pinnerConfig) {
+	for _, sw := range []struct {
+		value *bool
+		flag  *bool
+	}{
+		{cfg.SkipPRCreation, &skipPRCreation},
+		{cfg.NoPush, &noPush},
+		{cfg.IgnorePRTemplates, &ignorePRTemplates},
+	} {
+		if sw.value != nil && *sw.value {
+			*sw.flag = true
+		}
+	}
+}
+
+var (
+	loadedIndex     *actionIndex
+	loadedIndexOnce sync.Once
+	indexHashRe     = regexp.MustCompile(`^[a-f0-9]{40}$`)
+)
+
+func getConfigDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = filepath.Join(os.TempDir(), ".config")
+	}
+	return filepath.Join(dir, "gha-pinner")
+}
+
+func getIndexPath() string {
+	return filepath.Join(getConfigDir(), "index.json")
+}
+
+// loadPinnerConfig reads the optional config file, with any --config-override
+// values applied; a missing file is not an error.
+func loadPinnerConfig() (pinnerConfig, error) {
+	var cfg pinnerConfig
+	content, err := os.ReadFile(filepath.Join(getConfigDir(), "config.yaml"))
+	if err != nil && !os.IsNotExist(err) {
+		return cfg, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err := yaml.Unmarshal(content, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	for _, o := range configOverrides {
+		if err := o.apply(&cfg); err != nil {
+			return cfg, err
+		}
+	}
+	return cfg, nil
+}
+
+// configOverride is a --config-override key=value pair, applied over the
+// config file in the order given.
+type configOverride struct {
+	key   string
+	value string
+}
+
+// parseConfigOverrides validates --config-override entries against the config
+// file keys. Unknown keys are warned about and dropped; a value that does not
+// fit its key's type is an error.
+func parseConfigOverrides(entries []string) ([]configOverride, error) {
+	var overrides []configOverride
+	for _, entry := range entries {
+		key, value, ok := strings.Cut(entry, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --config-override %q (expected key=value)", entry)
+		}
+		o := configOverride{key: key, value: strings.TrimSpace(value)}
+		var scratch pinnerConfig
+		if _, known := o.field(&scratch); !known {
+			fmt.Printf("⚠️  Ignoring --config-override %s: unknown config key\n", key)
+			continue
+		}
+		if err := o.apply(&scratch); err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, o)
+	}
+	return overrides, nil
+}
+
+// field returns the pinnerConfig field whose yaml key is o.key.
+func (o configOverride) field(cfg *pinnerConfig) (reflect.Value, bool) {
+	v := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("yaml"), ","); name == o.key {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// apply replaces the field for o.key with o.value, decoded as YAML would decode
+// the key in the file: "true" becomes a bool, a comma-separated list a
+// sequence, and maps take flow syntax such as {actions: mirror/actions}.
+func (o configOverride) apply(cfg *pinnerConfig) error {
+	field, ok := o.field(cfg)
+	if !ok {
+		return nil
+	}
+	var node yaml.Node
+	switch {
+	case field.Kind() == reflect.Slice && !strings.HasPrefix(o.value, "["):
+		node = yaml.Node{Kind: yaml.SequenceNode}
+		for _, item := range strings.Split(o.value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: item})
+			}
+		}
+	case field.Kind() == reflect.Slice || field.Kind() == reflect.Map:
+		var doc yaml.Node
+		if err := yaml.Unmarshal([]byte(o.value), &doc); err != nil || len(doc.Content) == 0 {
+			return fmt.Errorf("invalid --config-override value for %s: %q", o.key, o.value)
+		}
+		node = *doc.Content[0]
+	default:
+		node = yaml.Node{Kind: yaml.ScalarNode, Value: o.value}
+	}
+	decoded := reflect.New(field.Type())
+	if err := node.Decode(decoded.Interface()); err != nil {
+		return fmt.Errorf("invalid --config-override value for %s: %v", o.key, err)
+	}
+	field.Set(decoded.Elem())
+	return nil
+}
+
+func parseActionIndex(content []byte) (*actionIndex, error) {
+	var index actionIndex
+	if err := json.Unmarshal(content, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse action index: %w", err)
+	}
+	for ref, hash := range index.Actions {
+		if !indexHashRe.MatchString(hash) {
+			return nil, fmt.Errorf("invalid hash %q for %s in action index", hash, ref)
+		}
+	}
+	return &index, nil
+}
+
+// lookupActionIndex returns the indexed hash for action@version. The index is
+// loaded once per run; a missing or unreadable index simply disables lookups.
+func lookupActionIndex(action, version string) (string, bool) {
+	loadedIndexOnce.Do(func() {
+		content, err := os.ReadFile(getIndexPath())
+		if err != nil {
+			return
+		}
+		index, err := parseActionIndex(content)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Warning: ignoring action index %s: %v\n", getIndexPath(), err)
+			return
+		}
+		loadedIndex = index
+	})
+	if loadedIndex == nil {
+		return "", false
+	}
+	hash, ok := loadedIndex.Actions[action+"@"+version]
+	return hash, ok
+}
+
+// updateActionIndex downloads the index from sourceURL (or index_url from the
+// config file) and stores it at getIndexPath().
+func updateActionIndex(sourceURL string) error {
+	if sourceURL == "" {
+		cfg, err := loadPinnerConfig()
+		if err != nil {
+			return err
+		}
+		sourceURL = strings.TrimSpace(cfg.IndexURL)
+	}
+	if sourceURL == "" {
+		return fmt.Errorf("no index URL configured: pass --index-url or set index_url in %s", filepath.Join(getConfigDir(), "config.yaml"))
+	}
+
+	client := &http.Client{Timeout: 60 * time.Second}
+	resp, err := client.Get(sourceURL)
+	if err != nil {
+		return fmt.Errorf("failed to download action index: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to download action index: %s returned %d", sourceURL, resp.StatusCode)
+	}
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read action index: %w", err)
+	}
+
+	index, err := parseActionIndex(content)
+	if err != nil {
+		return err
+	}
+	if index.Source == "" {
+		index.Source = sourceURL
+	}
+	if index.GeneratedAt == "" {
+		index.GeneratedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode action index: %w", err)
+	}
+	if err := os.MkdirAll(getConfigDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(getIndexPath(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write action index: %w", err)
+	}
+
+	fmt.Printf("✅ Action index updated: %d entries (generated %s, source %s)\n", len(index.Actions), index.GeneratedAt, index.Source)
+	fmt.Printf("   • Location: %s\n", getIndexPath())
+	return nil
+}
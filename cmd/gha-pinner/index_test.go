@@ -0,0 +1,276 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseActionIndex(t *testing.T) {
+	index, err := parseActionIndex([]byte(`{
+		"generated_at": "2024-01-15T00:00:00Z",
+		"source": "https://example.com/index.json",
+		"actions": {"actions/checkout@v4": "0123456789abcdef0123456789abcdef01234567"}
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if index.Actions["actions/checkout@v4"] != "0123456789abcdef0123456789abcdef01234567" {
+		t.Fatalf("unexpected index: %+v", index)
+	}
+
+	if _, err := parseActionIndex([]byte(`{"actions": {"actions/checkout@v4": "abc"}}`)); err == nil {
+		t.Fatal("expected error for invalid hash")
+	}
+}
+
+func TestUpdateActionIndexAndLookup(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"actions": {"actions/checkout@v4": "0123456789abcdef0123456789abcdef01234567"}}`))
+	}))
+	defer server.Close()
+
+	if err := updateActionIndex(server.URL); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content, err := os.ReadFile(getIndexPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), `"source": "`+server.URL) || !strings.Contains(string(content), "generated_at") {
+		t.Fatalf("expected source and generated_at in stored index, got %s", content)
+	}
+
+	loadedIndex = nil
+	loadedIndexOnce = sync.Once{}
+	t.Cleanup(func() {
+		loadedIndex = nil
+		loadedIndexOnce = sync.Once{}
+	})
+	hash, ok := lookupActionIndex("actions/checkout", "v4")
+	if !ok || hash != "0123456789abcdef0123456789abcdef01234567" {
+		t.Fatalf("expected indexed hash, got %q ok=%v", hash, ok)
+	}
+	if _, ok := lookupActionIndex("actions/checkout", "v3"); ok {
+		t.Fatal("expected miss for unindexed version")
+	}
+}
+
+func TestUpdateActionIndex_NoURL(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+
+	if err := updateActionIndex(""); err == nil {
+		t.Fatal("expected error when no index URL is configured")
+	}
+}
+
+func TestValidateRuntimeConfig_TrustedOrgsFromConfig(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+	if err := os.MkdirAll(getConfigDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(getConfigDir(), "config.yaml"), []byte("trusted_orgs:\n  - github\n  - my-org\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	oldMode, oldToken, oldWorkers := saveAuthGlobals()
+	oldStars, oldOrgs := requireMinStars, trustedOrgs
+	t.Cleanup(func() {
+		restoreAuthGlobals(oldMode, oldToken, oldWorkers)
+		requireMinStars, trustedOrgs = oldStars, oldOrgs
+	})
+	authMode = "gh"
+	repoWorkers = 2
+	requireMinStars = 10
+
+	trustedOrgs = nil
+	if err := validateRuntimeConfig(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(trustedOrgs, []string{"github", "my-org"}) {
+		t.Errorf("expected trusted_orgs from config, got %v", trustedOrgs)
+	}
+
+	trustedOrgs = []string{"acme"}
+	if err := validateRuntimeConfig(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(trustedOrgs, []string{"acme"}) {
+		t.Errorf("expected --trusted-orgs to override the config, got %v", trustedOrgs)
+	}
+}
+
+func TestValidateRuntimeConfig_ActionPrefixMapFromConfig(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+	if err := os.MkdirAll(getConfigDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(getConfigDir(), "config.yaml"), []byte("action_prefix_map:\n  actions: internal-mirror\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	oldMode, oldToken, oldWorkers := saveAuthGlobals()
+	oldRaw, oldMap := actionPrefixMapRaw, actionPrefixMap
+	t.Cleanup(func() {
+		restoreAuthGlobals(oldMode, oldToken, oldWorkers)
+		actionPrefixMapRaw, actionPrefixMap = oldRaw, oldMap
+	})
+	authMode = "gh"
+	repoWorkers = 2
+
+	actionPrefixMapRaw = nil
+	if err := validateRuntimeConfig(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(actionPrefixMap, map[string]string{"actions": "internal-mirror"}) {
+		t.Errorf("expected action_prefix_map from config, got %v", actionPrefixMap)
+	}
+
+	actionPrefixMapRaw = []string{"actions=other-mirror"}
+	if err := validateRuntimeConfig(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(actionPrefixMap, map[string]string{"actions": "other-mirror"}) {
+		t.Errorf("expected --action-prefix-map to override the config, got %v", actionPrefixMap)
+	}
+}
+
+func TestParseConfigOverrides(t *testing.T) {
+	overrides, err := parseConfigOverrides([]string{"index_url=https://a.example/index.json", "skip_pr_creation=true", "no_such_key=1", "trusted_orgs = github, my-org"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []configOverride{{"index_url", "https://a.example/index.json"}, {"skip_pr_creation", "true"}, {"trusted_orgs", "github, my-org"}}
+	if !reflect.DeepEqual(overrides, want) {
+		t.Errorf("expected unknown keys to be dropped, got %v", overrides)
+	}
+
+	for _, entry := range []string{"index_url", "=value", "action_prefix_map=[not, a, map]", "skip_pr_creation=maybe"} {
+		if _, err := parseConfigOverrides([]string{entry}); err == nil {
+			t.Errorf("expected error for %q", entry)
+		}
+	}
+}
+
+func TestLoadPinnerConfig_Overrides(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+	if err := os.MkdirAll(getConfigDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(getConfigDir(), "config.yaml"), []byte("index_url: https://file.example/index.json\ntrusted_orgs:\n  - github\naction_prefix_map:\n  actions: mirror/actions\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	oldOverrides := configOverrides
+	t.Cleanup(func() { configOverrides = oldOverrides })
+
+	overrides, err := parseConfigOverrides([]string{
+		"index_url=https://a.example/index.json",
+		"index_url=https://b.example/index.json",
+		"trusted_orgs=acme,my-org",
+		"action_prefix_map={docker: mirror/docker}",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	configOverrides = overrides
+	cfg, err := loadPinnerConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := pinnerConfig{
+		IndexURL:        "https://b.example/index.json",
+		TrustedOrgs:     []string{"acme", "my-org"},
+		ActionPrefixMap: map[string]string{"docker": "mirror/docker"},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("got %+v, want %+v", cfg, want)
+	}
+
+	if err := os.Remove(filepath.Join(getConfigDir(), "config.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err := loadPinnerConfig(); err != nil || cfg.IndexURL != "https://b.example/index.json" {
+		t.Errorf("expected overrides without a config file, got %+v, %v", cfg, err)
+	}
+}
+
+func TestValidateRuntimeConfig_RuntimeSwitchOverrides(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+	oldMode, oldToken, oldWorkers := saveAuthGlobals()
+	oldRaw, oldOverrides := configOverridesRaw, configOverrides
+	oldSkip, oldNoPush, oldIgnore := skipPRCreation, noPush, ignorePRTemplates
+	t.Cleanup(func() {
+		restoreAuthGlobals(oldMode, oldToken, oldWorkers)
+		configOverridesRaw, configOverrides = oldRaw, oldOverrides
+		skipPRCreation, noPush, ignorePRTemplates = oldSkip, oldNoPush, oldIgnore
+	})
+	authMode, repoWorkers = "gh", 2
+	skipPRCreation, noPush, ignorePRTemplates = false, false, true
+
+	configOverridesRaw = []string{"skip_pr_creation=true", "no_push=false", "ignore_pr_templates=false"}
+	if err := validateRuntimeConfig(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !skipPRCreation {
+		t.Error("expected skip_pr_creation=true to set --no-pr")
+	}
+	if noPush {
+		t.Error("expected no_push=false to leave --no-push off")
+	}
+	if !ignorePRTemplates {
+		t.Error("expected --ignore-templates from the command line to stay on")
+	}
+}
+
+func TestValidateRuntimeConfig_MinActionVersionFromConfig(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+	if err := os.MkdirAll(getConfigDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(getConfigDir(), "config.yaml"), []byte("min_action_version:\n  actions/checkout: v3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	oldMode, oldToken, oldWorkers := saveAuthGlobals()
+	oldRaw, oldMin := minActionVersionRaw, minActionVersions
+	t.Cleanup(func() {
+		restoreAuthGlobals(oldMode, oldToken, oldWorkers)
+		minActionVersionRaw, minActionVersions = oldRaw, oldMin
+	})
+	authMode = "gh"
+	repoWorkers = 2
+
+	minActionVersionRaw = nil
+	if err := validateRuntimeConfig(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(minActionVersions, map[string]string{"actions/checkout": "v3"}) {
+		t.Errorf("expected min_action_version from config, got %v", minActionVersions)
+	}
+
+	minActionVersionRaw = []string{"actions/setup-node=v4"}
+	if err := validateRuntimeConfig(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(minActionVersions, map[string]string{"actions/setup-node": "v4"}) {
+		t.Errorf("expected --min-action-version to override the config, got %v", minActionVersions)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// ruleExpressionInjection identifies user-controlled context values interpolated into run: scripts.
+const ruleExpressionInjection = "GHA006"
+
+// untrustedExpressionRe matches ${{ }} expressions over GitHub context values
+// that an external contributor can control (titles, bodies, branch names, ...).
+var untrustedExpressionRe = regexp.MustCompile(`\$\{\{\s*(github\.head_ref|github\.event\.(?:` +
+	`issue\.(?:title|body)|pull_request\.(?:title|body)|comment\.body|review\.body|review_comment\.body|` +
+	`discussion\.(?:title|body)|pages\.[\w*\[\]]+\.page_name|commits\.[\w*\[\]]+\.(?:message|author\.(?:email|name))|` +
+	`head_commit\.(?:message|author\.(?:email|name))|pull_request\.head\.(?:ref|label|repo\.default_branch)|` +
+	`workflow_run\.(?:head_branch|head_commit\.message)))\s*\}\}`)
+
+// injectionFinding is a GHA006 result: a run: step in job that interpolates expression.
+type injectionFinding struct {
+	job        string
+	step       string
+	expression string
+}
+
+// findExpressionInjections scans every run: step of a parsed workflow or
+// composite action (job "composite") for untrusted expressions. Unlike
+// pinning, --ignore-jobs does not apply. Findings are sorted for stable output.
+func findExpressionInjections(workflow map[string]interface{}, isComposite bool) []injectionFinding {
+	stepsByJob := map[string][]interface{}{}
+	if isComposite {
+		if runs, ok := workflow["runs"].(map[string]interface{}); ok {
+			if steps, ok := runs["steps"].([]interface{}); ok {
+				stepsByJob["composite"] = steps
+			}
+		}
+	} else if jobs, ok := workflow["jobs"].(map[string]interface{}); ok {
+		for jobName, jobData := range jobs {
+			if job, ok := jobData.(map[string]interface{}); ok {
+				if steps, ok := job["steps"].([]interface{}); ok {
+					stepsByJob[jobName] = steps
+				}
+			}
+		}
+	}
+
+	var findings []injectionFinding
+	for job, steps := range stepsByJob {
+		for i, step := range toStepMaps(steps) {
+			script, ok := step["run"].(string)
+			if !ok {
+				continue
+			}
+			stepName, _ := step["name"].(string)
+			if stepName == "" {
+				stepName = fmt.Sprintf("#%d", i+1)
+			}
+			for _, m := range untrustedExpressionRe.FindAllString(script, -1) {
+				findings = append(findings, injectionFinding{job: job, step: stepName, expression: m})
+			}
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].job != findings[j].job {
+			return findings[i].job < findings[j].job
+		}
+		return findings[i].step < findings[j].step
+	})
+	return findings
+}
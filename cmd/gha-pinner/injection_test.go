@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestFindExpressionInjections(t *testing.T) {
+	content := `on: issues
+jobs:
+  triage:
+    runs-on: ubuntu-latest
+    steps:
+      - name: Echo title
+        run: echo "${{ github.event.issue.title }}"
+      - name: Safe
+        run: echo "${{ github.event.issue.number }} ${{ github.sha }}"
+      - name: Safe env
+        env:
+          TITLE: ${{ github.event.issue.title }}
+        run: echo "$TITLE"
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - run: |
+          git checkout ${{github.head_ref}}
+          echo "${{ github.event.pull_request.body }}"
+`
+	var workflow map[string]interface{}
+	if err := yaml.Unmarshal([]byte(content), &workflow); err != nil {
+		t.Fatal(err)
+	}
+	findings := findExpressionInjections(workflow, false)
+	want := []injectionFinding{
+		{job: "build", step: "#1", expression: "${{github.head_ref}}"},
+		{job: "build", step: "#1", expression: "${{ github.event.pull_request.body }}"},
+		{job: "triage", step: "Echo title", expression: "${{ github.event.issue.title }}"},
+	}
+	if len(findings) != len(want) {
+		t.Fatalf("got %d findings (%v), want %d", len(findings), findings, len(want))
+	}
+	for i := range want {
+		if findings[i] != want[i] {
+			t.Errorf("finding %d = %+v, want %+v", i, findings[i], want[i])
+		}
+	}
+}
+
+func TestFindExpressionInjections_Composite(t *testing.T) {
+	content := `runs:
+  using: composite
+  steps:
+    - run: echo "${{ github.event.head_commit.message }}"
+      shell: bash
+`
+	var workflow map[string]interface{}
+	if err := yaml.Unmarshal([]byte(content), &workflow); err != nil {
+		t.Fatal(err)
+	}
+	if findings := findExpressionInjections(workflow, true); len(findings) != 1 || findings[0].job != "composite" {
+		t.Fatalf("unexpected findings: %v", findings)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const pinningIssueTitle = "security: pin GitHub Actions to commit hashes"
+
+// trackingIssueTitlePrefix starts every --create-tracking-issue title; the
+// organization and run date follow it.
+const trackingIssueTitlePrefix = "GitHub Actions Pinning Status"
+
+// issueRecommendation pairs an unpinned uses: reference with its pinned form.
+// pinned is empty when the reference could not be resolved.
+type issueRecommendation struct {
+	uses   string
+	pinned string
+}
+
+// openPinningIssue implements --create-issues for repositories we cannot write
+// to: instead of forking, it clones repoName read-only, resolves every
+// unpinned action and opens an issue recommending the pinned forms. No issue
+// is opened when an open one with the same title already exists.
+func openPinningIssue(repoName, dirName string) error {
+	existing := listOpenIssues(repoName, pinningIssueTitle)
+	if existing.ExitCode == 0 && strings.TrimSpace(existing.Stdout) != "" && strings.TrimSpace(existing.Stdout) != "[]" {
+		fmt.Printf("ℹ️  Pinning issue already exists for repository: %s - skipping\n", repoName)
+		return nil
+	}
+
+	repoDir := getRepositoryDir(dirName, repoName)
+	if err := removeExistingRepoDir(repoDir); err != nil {
+		return err
+	}
+	if err := cloneRepository(repoName, repoDir, "--depth=1"); err != nil {
+		return fmt.Errorf("failed to clone repository: %v", err)
+	}
+
+	unpinned, err := collectUnpinnedActions(repoDir)
+	if err != nil {
+		return err
+	}
+	if len(unpinned) == 0 {
+		fmt.Printf("✅ No unpinned actions in repository: %s - no issue needed\n", repoName)
+		return nil
+	}
+
+	recommendations := make([]issueRecommendation, 0, len(unpinned))
+	for _, uses := range unpinned {
+		rec := issueRecommendation{uses: uses}
+		if action, version, err := parseActionReference(uses); err == nil {
+			if hash, resolvedVersion, err := getCommitHashFromVersion(action, version); err == nil {
+				rec.pinned = fmt.Sprintf("%s@%s # %s", action, hash, resolvedVersion)
+			} else if debug {
+				fmt.Printf("Warning: failed to resolve %s: %v\n", uses, err)
+			}
+		}
+		recommendations = append(recommendations, rec)
+	}
+
+	if skipPRCreation {
+		fmt.Printf("🔍 Would open an issue in %s for %d unpinned action(s) (--no-pr)\n", repoName, len(recommendations))
+		return nil
+	}
+
+	result := createIssue(repoName, pinningIssueTitle, buildPinningIssueBody(recommendations))
+	if result.ExitCode != 0 {
+		return fmt.Errorf("failed to create issue: %s", strings.TrimSpace(result.Stderr))
+	}
+	fmt.Printf("📝 Issue created: %s\n", strings.TrimSpace(result.Stdout))
+	return nil
+}
+
+// buildPinningIssueBody explains why pinning matters and lists each unpinned
+// reference with its recommended replacement.
+func buildPinningIssueBody(recommendations []issueRecommendation) string {
+	var sb strings.Builder
+	sb.WriteString("This repository's workflows reference GitHub Actions by mutable tags or branches. ")
+	sb.WriteString("A tag can be moved to different code at any time, so a compromised action repository could run arbitrary code in these workflows. ")
+	sb.WriteString("Pinning each action to a full commit SHA makes the reference immutable.\n\n")
+	sb.WriteString("## Actions to pin\n\n")
+	sb.WriteString("| Current reference | Recommended |\n")
+	sb.WriteString("| --- | --- |\n")
+	for _, rec := range recommendations {
+		pinned := "_could not be resolved automatically_"
+		if rec.pinned != "" {
+			pinned = "`" + rec.pinned + "`"
+		}
+		sb.WriteString(fmt.Sprintf("| `%s` | %s |\n", rec.uses, pinned))
+	}
+	sb.WriteString("\n## How to fix\n\n")
+	sb.WriteString("Replace each `uses:` value under `.github/` with the recommended form; the trailing comment keeps the original version readable. ")
+	sb.WriteString("Alternatively run `gha-pinner local-repository .` in a checkout of this repository to apply the changes automatically.\n\n")
+	sb.WriteString("_This issue was opened by gha-pinner because the account running it does not have write access to this repository._\n")
+	return sb.String()
+}
+
+// updateTrackingIssue implements --create-tracking-issue: it writes the status
+// of every repository in results to one issue in repo, editing the open
+// tracking issue for orgName from an earlier run instead of opening another.
+func updateTrackingIssue(repo, orgName string, results []RepoResult) error {
+	now := time.Now()
+	title := fmt.Sprintf("%s - %s - %s", trackingIssueTitlePrefix, orgName, now.Format("2006-01-02"))
+	body := buildTrackingIssueBody(orgName, results, now)
+
+	var existingURL string
+	prefix := fmt.Sprintf("%s - %s - ", trackingIssueTitlePrefix, orgName)
+	existing := listOpenIssues(repo, prefix)
+	if existing.ExitCode == 0 {
+		var issues []map[string]interface{}
+		if err := json.Unmarshal([]byte(existing.Stdout), &issues); err == nil {
+			for _, issue := range issues {
+				if issueTitle, _ := issue["title"].(string); strings.HasPrefix(issueTitle, prefix) {
+					existingURL, _ = issue["url"].(string)
+					break
+				}
+			}
+		}
+	}
+
+	if skipPRCreation {
+		if existingURL != "" {
+			fmt.Printf("🔍 Would update tracking issue %s with %d repositories (--no-pr)\n", existingURL, len(results))
+		} else {
+			fmt.Printf("🔍 Would open tracking issue %q in %s with %d repositories (--no-pr)\n", title, repo, len(results))
+		}
+		return nil
+	}
+
+	if existingURL != "" {
+		if result := editIssue(repo, existingURL, title, body); result.ExitCode != 0 {
+			return fmt.Errorf("failed to edit issue %s: %s", existingURL, strings.TrimSpace(result.Stderr))
+		}
+		fmt.Printf("📋 Tracking issue updated: %s\n", existingURL)
+		return nil
+	}
+	result := createIssue(repo, title, body)
+	if result.ExitCode != 0 {
+		return fmt.Errorf("failed to create issue: %s", strings.TrimSpace(result.Stderr))
+	}
+	fmt.Printf("📋 Tracking issue created: %s\n", strings.TrimSpace(result.Stdout))
+	return nil
+}
+
+// buildTrackingIssueBody renders one table row per repository in results.
+func buildTrackingIssueBody(orgName string, results []RepoResult, now time.Time) string {
+	date := now.Format("2006-01-02")
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Pinning status of the GitHub Actions in the `%s` organization, last updated by gha-pinner on %s.\n\n", orgName, date))
+	sb.WriteString("| Repo | Status | PR | Actions Pinned | Date |\n")
+	sb.WriteString("| --- | --- | --- | ---: | --- |\n")
+	for _, r := range results {
+		pr := prLinks(r.PRURLs)
+		sb.WriteString(fmt.Sprintf("| `%s` | %s | %s | %d | %s |\n", r.Repo, trackingIssueStatus(r), pr, r.ActionsPinned, date))
+	}
+	sb.WriteString("\n_This issue is updated in place on each `gha-pinner organization --create-tracking-issue` run._\n")
+	return sb.String()
+}
+
+// trackingIssueStatus describes a repository outcome for the tracking issue.
+func trackingIssueStatus(r RepoResult) string {
+	switch {
+	case r.Error != "":
+		return "❌ Failed"
+	case r.Status == repoStatusPinned && len(r.PRURLs) > 0:
+		return "🔄 PR open"
+	case r.Status == repoStatusPinned:
+		return "📌 Pinned"
+	case r.Status == repoStatusAlreadyPinned:
+		return "✅ Already pinned"
+	case r.Status == repoStatusNoWorkflows:
+		return "➖ No workflows"
+	}
+	return "❔ Unknown"
+}
+
+// listOpenIssues lists open issues in repo whose title contains title,
+// mirroring listOpenPRs for duplicate detection.
+func listOpenIssues(repo, title string) ExecResult {
+	if authMode == "gh" {
+		return execCommand("gh", "issue", "list", "--repo", repo, "--state", "open", "--search", title+" in:title", "--json", "title,url")
+	}
+
+	query := url.Values{}
+	query.Set("state", "open")
+	query.Set("per_page", "100")
+	result := githubAPI("GET", fmt.Sprintf("repos/%s/issues?%s", repo, query.Encode()), nil)
+	if result.ExitCode != 0 {
+		return result
+	}
+	var issues []map[string]interface{}
+	if err := json.Unmarshal([]byte(result.Stdout), &issues); err != nil {
+		return ExecResult{ExitCode: 1, Stderr: fmt.Sprintf("failed to parse issues: %v", err)}
+	}
+	filtered := make([]map[string]interface{}, 0, len(issues))
+	for _, issue := range issues {
+		// The issues endpoint also returns pull requests.
+		if _, isPR := issue["pull_request"]; isPR {
+			continue
+		}
+		issueTitle, _ := issue["title"].(string)
+		if !strings.Contains(strings.ToLower(issueTitle), strings.ToLower(title)) {
+			continue
+		}
+		filtered = append(filtered, map[string]interface{}{"title": issueTitle, "url": issue["html_url"]})
+	}
+	data, err := json.Marshal(filtered)
+	if err != nil {
+		return ExecResult{ExitCode: 1, Stderr: fmt.Sprintf("failed to encode issues: %v", err)}
+	}
+	return ExecResult{ExitCode: 0, Stdout: string(data)}
+}
+
+// editIssue replaces the title and body of the issue at issueURL in repo.
+func editIssue(repo, issueURL, title, body string) ExecResult {
+	if authMode == "gh" {
+		return execCommand("gh", "issue", "edit", issueURL, "--repo", repo, "--title", title, "--body", body)
+	}
+	number := issueURL[strings.LastIndex(issueURL, "/")+1:]
+	return githubAPI("PATCH", fmt.Sprintf("repos/%s/issues/%s", repo, number), map[string]interface{}{
+		"title": title,
+		"body":  body,
+	})
+}
+
+func createIssue(repo, title, body string) ExecResult {
+	if authMode == "gh" {
+		return execCommand("gh", "issue", "create", "--repo", repo, "--title", title, "--body", body)
+	}
+	result := githubAPI("POST", fmt.Sprintf("repos/%s/issues", repo), map[string]interface{}{
+		"title": title,
+		"body":  body,
+	})
+	if result.ExitCode != 0 {
+		return result
+	}
+	var created map[string]interface{}
+	if err := json.Unmarshal([]byte(result.Stdout), &created); err != nil {
+		return ExecResult{ExitCode: 1, Stderr: fmt.Sprintf("failed to parse created issue: %v", err)}
+	}
+	urlStr, _ := created["html_url"].(string)
+	return ExecResult{ExitCode: 0, Stdout: urlStr}
+}
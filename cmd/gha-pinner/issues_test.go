@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBuildPinningIssueBody(t *testing.T) {
+	body := buildPinningIssueBody([]issueRecommendation{
+		{uses: "actions/checkout@v4", pinned: "actions/checkout@" + testSHA + " # v4"},
+		{uses: "example/gone@v1"},
+	})
+	for _, want := range []string{
+		"| `actions/checkout@v4` | `actions/checkout@" + testSHA + " # v4` |",
+		"| `example/gone@v1` | _could not be resolved automatically_ |",
+		"gha-pinner local-repository .",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("issue body missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestBuildTrackingIssueBody(t *testing.T) {
+	body := buildTrackingIssueBody("acme", []RepoResult{
+		{Repo: "acme/api", Status: repoStatusPinned, ActionsPinned: 4, PRURLs: []string{"https://github.com/acme/api/pull/12"}},
+		{Repo: "acme/web", Status: repoStatusAlreadyPinned},
+		{Repo: "acme/broken", Error: "clone failed"},
+	}, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	for _, want := range []string{
+		"| Repo | Status | PR | Actions Pinned | Date |",
+		"| `acme/api` | 🔄 PR open | [#12](https://github.com/acme/api/pull/12) | 4 | 2024-05-01 |",
+		"| `acme/web` | ✅ Already pinned | - | 0 | 2024-05-01 |",
+		"| `acme/broken` | ❌ Failed | - | 0 | 2024-05-01 |",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("tracking issue body missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestUpdateTrackingIssue_EditsExistingIssue(t *testing.T) {
+	oldMode, oldToken, oldWorkers := saveAuthGlobals()
+	oldBase, oldNoCache := githubAPIBase, noCache
+	t.Cleanup(func() {
+		restoreAuthGlobals(oldMode, oldToken, oldWorkers)
+		githubAPIBase, noCache = oldBase, oldNoCache
+	})
+
+	var mu sync.Mutex
+	var issues []map[string]interface{}
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		var payload map[string]string
+		if r.Body != nil {
+			json.NewDecoder(r.Body).Decode(&payload)
+		}
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/repos/security-team/tracking/issues":
+			json.NewEncoder(w).Encode(issues)
+		case r.Method == "POST" && r.URL.Path == "/repos/security-team/tracking/issues":
+			issue := map[string]interface{}{"title": payload["title"], "body": payload["body"], "html_url": fmt.Sprintf("https://github.com/security-team/tracking/issues/%d", len(issues)+1)}
+			issues = append(issues, issue)
+			json.NewEncoder(w).Encode(issue)
+		case r.Method == "PATCH" && r.URL.Path == "/repos/security-team/tracking/issues/1":
+			issues[0]["title"], issues[0]["body"] = payload["title"], payload["body"]
+			json.NewEncoder(w).Encode(issues[0])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	githubAPIBase = server.URL
+	authMode, githubToken = "pat", "test-token"
+	noCache = true
+
+	if err := updateTrackingIssue("security-team/tracking", "acme", []RepoResult{{Repo: "acme/api", Status: repoStatusPinned, ActionsPinned: 2}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// An issue from an earlier day is edited, not duplicated.
+	issues[0]["title"] = trackingIssueTitlePrefix + " - acme - 2024-01-01"
+	if err := updateTrackingIssue("security-team/tracking", "acme", []RepoResult{{Repo: "acme/web", Status: repoStatusAlreadyPinned}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(issues) != 1 {
+		t.Fatalf("expected a single tracking issue, got %d (requests %v)", len(issues), requests)
+	}
+	wantTitle := trackingIssueTitlePrefix + " - acme - " + time.Now().Format("2006-01-02")
+	if issues[0]["title"] != wantTitle {
+		t.Errorf("title = %q, want %q", issues[0]["title"], wantTitle)
+	}
+	if body, _ := issues[0]["body"].(string); !strings.Contains(body, "`acme/web`") || strings.Contains(body, "`acme/api`") {
+		t.Errorf("expected the body to be replaced with the latest run:\n%s", body)
+	}
+}
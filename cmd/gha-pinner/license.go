@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ruleDisallowedLicense identifies actions whose repository license is not in
+// the --allowed-licenses allowlist.
+const ruleDisallowedLicense = "GHA009"
+
+// osiApprovedLicenses is the default allowlist: the SPDX IDs of commonly used
+// OSI-approved licenses, as reported by GitHub's license detection.
+var osiApprovedLicenses = []string{
+	"0BSD",
+	"AFL-3.0",
+	"AGPL-3.0",
+	"Apache-2.0",
+	"Artistic-2.0",
+	"BSD-2-Clause",
+	"BSD-3-Clause",
+	"BSD-3-Clause-Clear",
+	"BSL-1.0",
+	"CECILL-2.1",
+	"ECL-2.0",
+	"EPL-1.0",
+	"EPL-2.0",
+	"EUPL-1.1",
+	"EUPL-1.2",
+	"GPL-2.0",
+	"GPL-3.0",
+	"ISC",
+	"LGPL-2.1",
+	"LGPL-3.0",
+	"LPPL-1.3c",
+	"MIT",
+	"MIT-0",
+	"MPL-2.0",
+	"MS-PL",
+	"MS-RL",
+	"MulanPSL-2.0",
+	"NCSA",
+	"OFL-1.1",
+	"OSL-3.0",
+	"PostgreSQL",
+	"UPL-1.0",
+	"Unlicense",
+	"Zlib",
+}
+
+// checkActionLicense returns a GHA009 message when the license of action's
+// repository is missing or not in allowed (osiApprovedLicenses when empty).
+// The license comes from the cached repository metadata, so it costs no extra
+// API call when other metadata checks are enabled.
+func checkActionLicense(action string, allowed []string) (string, error) {
+	meta, err := getActionMetadata(actionRepo(action))
+	if err != nil {
+		return "", err
+	}
+	if len(allowed) == 0 {
+		allowed = osiApprovedLicenses
+	}
+	for _, id := range allowed {
+		if meta.License != "" && strings.EqualFold(id, meta.License) {
+			return "", nil
+		}
+	}
+	if meta.License == "" {
+		return fmt.Sprintf("%s: no license detected", action), nil
+	}
+	return fmt.Sprintf("%s: license %s is not in the allowed list", action, meta.License), nil
+}
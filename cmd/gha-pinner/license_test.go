@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseActionMetadata_License(t *testing.T) {
+	meta, err := parseActionMetadata(`{"license":{"key":"mit","spdx_id":"MIT"}}`, time.Now())
+	if err != nil || meta.License != "MIT" {
+		t.Fatalf("expected MIT, got %q, %v", meta.License, err)
+	}
+	meta, err = parseActionMetadata(`{"license":null}`, time.Now())
+	if err != nil || meta.License != "" {
+		t.Fatalf("expected no license, got %q, %v", meta.License, err)
+	}
+}
+
+func TestCheckActionLicense(t *testing.T) {
+	old := actionMetadataCache
+	t.Cleanup(func() { actionMetadataCache = old })
+	actionMetadataCache = newMetadataCache()
+	actionMetadataCache.put("example/mit", ActionMetadata{License: "MIT"})
+	actionMetadataCache.put("example/custom", ActionMetadata{License: "NOASSERTION"})
+	actionMetadataCache.put("example/unlicensed", ActionMetadata{})
+
+	tests := []struct {
+		action  string
+		allowed []string
+		want    string
+	}{
+		{"example/mit/sub-action@v1", nil, ""},
+		{"example/custom", nil, "example/custom: license NOASSERTION is not in the allowed list"},
+		{"example/unlicensed", nil, "example/unlicensed: no license detected"},
+		{"example/mit", []string{"Apache-2.0"}, "example/mit: license MIT is not in the allowed list"},
+		{"example/mit", []string{"apache-2.0", "mit"}, ""},
+	}
+	for _, tt := range tests {
+		got, err := checkActionLicense(tt.action, tt.allowed)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.action, err)
+		}
+		if got != tt.want {
+			t.Errorf("checkActionLicense(%q, %v) = %q, want %q", tt.action, tt.allowed, got, tt.want)
+		}
+	}
+}
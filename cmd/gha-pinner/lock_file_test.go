@@ -0,0 +1,861 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const testSHA = "0123456789abcdef0123456789abcdef01234567"
+
+func TestParseLock(t *testing.T) {
+	content := "# comment\n\nactions/checkout@v3 = " + testSHA + " # resolved 2024-01-15\nactions/setup-go@v5=" + testSHA + "\n" +
+		"actions/cache@v4 = " + testSHA + " # resolved 2024-01-15 as v4.2.0\nactions/setup-node@v4 = " + testSHA + " # as v4.1.0\n"
+	entries, err := parseLock(content)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(entries))
+	}
+	for key, want := range map[string]lockEntry{
+		"actions/checkout@v3":   {hash: testSHA, resolvedVersion: "v3", date: "2024-01-15"},
+		"actions/setup-go@v5":   {hash: testSHA, resolvedVersion: "v5"},
+		"actions/cache@v4":      {hash: testSHA, resolvedVersion: "v4.2.0", date: "2024-01-15"},
+		"actions/setup-node@v4": {hash: testSHA, resolvedVersion: "v4.1.0"},
+	} {
+		if entry := entries[key]; entry != want {
+			t.Errorf("%s: got %+v, want %+v", key, entry, want)
+		}
+	}
+}
+
+func TestParseLock_InvalidLine(t *testing.T) {
+	if _, err := parseLock("actions/checkout@v3 = not-a-sha\n"); err == nil {
+		t.Fatal("expected error for invalid hash")
+	}
+}
+
+func TestFormatLock_Sorted(t *testing.T) {
+	out := formatLock(map[string]lockEntry{
+		"z/action@v1": {hash: testSHA, date: "2024-01-15"},
+		"a/action@v2": {hash: testSHA},
+		"m/action@v3": {hash: testSHA, resolvedVersion: "v3.1.0", date: "2024-01-15"},
+		"n/action@v4": {hash: testSHA, resolvedVersion: "v4"},
+	})
+	expected := "a/action@v2 = " + testSHA + "\nm/action@v3 = " + testSHA + " # resolved 2024-01-15 as v3.1.0\nn/action@v4 = " + testSHA + "\nz/action@v1 = " + testSHA + " # resolved 2024-01-15\n"
+	if out != expected {
+		t.Fatalf("unexpected lock output:\n%s", out)
+	}
+}
+
+func TestActionHashCache_LockRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "actions.lock")
+	src := newActionHashCache()
+	src.put("actions/checkout", "v3", lockEntry{hash: testSHA, resolvedVersion: "v3", date: "2024-01-15"})
+	src.put("actions/cache", "v4", lockEntry{hash: testSHA, resolvedVersion: "v4.2.0", date: "2024-01-15"})
+	if err := src.exportLock(path); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+
+	dst := newActionHashCache()
+	count, err := dst.importLock(path)
+	if err != nil || count != 2 {
+		t.Fatalf("import failed: count=%d err=%v", count, err)
+	}
+	entry, ok := dst.get("actions/checkout", "v3")
+	if !ok || entry.hash != testSHA {
+		t.Fatalf("expected imported entry, got %+v ok=%v", entry, ok)
+	}
+	if entry, _ := dst.get("actions/cache", "v4"); entry.resolvedVersion != "v4.2.0" {
+		t.Fatalf("expected the resolved version to survive the round trip, got %+v", entry)
+	}
+}
+
+func TestGetCommitHashFromVersion_UsesCache(t *testing.T) {
+	old := hashCache
+	t.Cleanup(func() { hashCache = old })
+	hashCache = newActionHashCache()
+	hashCache.put("example/never-resolved", "v9", lockEntry{hash: testSHA, resolvedVersion: "v9"})
+
+	hash, version, err := getCommitHashFromVersion("example/never-resolved", "v9")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if hash != testSHA || version != "v9" {
+		t.Fatalf("unexpected result: %s %s", hash, version)
+	}
+}
+
+func TestExportLock_WritesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "actions.lock")
+	if err := newActionHashCache().exportLock(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(content)) != "" {
+		t.Fatalf("expected empty lock for empty cache, got %q", content)
+	}
+}
+
+func TestResolveVersions_PrintsInInputOrder(t *testing.T) {
+	old := hashCache
+	t.Cleanup(func() { hashCache = old })
+	hashCache = newActionHashCache()
+	versions := []string{"v3", "v4", "v2"}
+	for i, v := range versions {
+		hashCache.put("example/never-resolved", v, lockEntry{hash: strings.Repeat(string(rune('a'+i)), 40), resolvedVersion: v})
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	resolveErr := resolveVersions("example/never-resolved", versions)
+	os.Stdout = stdout
+	w.Close()
+	out, _ := io.ReadAll(r)
+
+	if resolveErr != nil {
+		t.Fatalf("unexpected error: %v", resolveErr)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected header plus one line per version, got %q", out)
+	}
+	for i, v := range versions {
+		fields := strings.Fields(lines[i+1])
+		if fields[0] != v || fields[1] != strings.Repeat(string(rune('a'+i)), 40) {
+			t.Errorf("line %d = %q, want version %s with its hash", i+1, lines[i+1], v)
+		}
+	}
+}
+
+func TestParseLatestCommitDate(t *testing.T) {
+	date, err := parseLatestCommitDate(`[{"sha":"abc","commit":{"committer":{"date":"2021-03-04T05:06:07Z"}}}]`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC); !date.Equal(want) {
+		t.Fatalf("got %v, want %v", date, want)
+	}
+	if _, err := parseLatestCommitDate(`[]`); err == nil {
+		t.Fatal("expected error for empty commit list")
+	}
+}
+
+func TestCheckActionActivity_UsesCache(t *testing.T) {
+	old := hashCache
+	t.Cleanup(func() { hashCache = old })
+	hashCache = newActionHashCache()
+	hashCache.putLastCommit("example/stale", time.Now().AddDate(-3, 0, 0))
+	hashCache.putLastCommit("example/fresh", time.Now().AddDate(0, 0, -10))
+
+	maxAge := 730 * 24 * time.Hour
+	if active, err := checkActionActivity("example/stale/sub-action", maxAge); err != nil || active {
+		t.Fatalf("expected stale action to be inactive, got active=%v err=%v", active, err)
+	}
+	if active, err := checkActionActivity("example/fresh", maxAge); err != nil || !active {
+		t.Fatalf("expected fresh action to be active, got active=%v err=%v", active, err)
+	}
+}
+
+func TestParseActionMetadata(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	meta, err := parseActionMetadata(`{"stargazers_count":42,"forks_count":5,"archived":true,"default_branch":"main","pushed_at":"2024-05-01T00:00:00Z"}`, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := ActionMetadata{
+		Stars:             42,
+		Forks:             5,
+		IsArchived:        true,
+		DefaultBranch:     "main",
+		PushedAt:          time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		HasRecentActivity: true,
+	}
+	if meta != want {
+		t.Fatalf("got %+v, want %+v", meta, want)
+	}
+	meta, err = parseActionMetadata(`{"stargazers_count":7,"pushed_at":"2020-01-01T00:00:00Z"}`, now)
+	if err != nil || meta.HasRecentActivity {
+		t.Fatalf("expected stale repository, got %+v, %v", meta, err)
+	}
+}
+
+func TestCheckActionTrust_UsesCache(t *testing.T) {
+	old := actionMetadataCache
+	t.Cleanup(func() { actionMetadataCache = old })
+	actionMetadataCache = newMetadataCache()
+	actionMetadataCache.put("example/obscure", ActionMetadata{Stars: 3})
+	actionMetadataCache.put("example/popular", ActionMetadata{Stars: 5000})
+
+	if reason, err := checkActionTrust("example/obscure/sub-action", 50); err != nil || reason != "low-trust action (<50 stars)" {
+		t.Fatalf("expected low-trust reason, got %q, %v", reason, err)
+	}
+	if reason, err := checkActionTrust("example/popular", 50); err != nil || reason != "" {
+		t.Fatalf("expected trusted action, got %q, %v", reason, err)
+	}
+	// actions/* is exempt and must not even need metadata.
+	if reason, err := checkActionTrust("actions/checkout", 50); err != nil || reason != "" {
+		t.Fatalf("expected actions/* to be exempt, got %q, %v", reason, err)
+	}
+}
+
+func TestShouldSkipTrustChecks(t *testing.T) {
+	orgs := []string{"github", "My-Org"}
+	tests := map[string]bool{
+		"github/codeql-action/init": true,
+		"my-org/deploy":             true,
+		"actions/checkout":          false,
+		"githubx/tool":              false,
+	}
+	for action, want := range tests {
+		if got := shouldSkipTrustChecks(action, orgs); got != want {
+			t.Errorf("shouldSkipTrustChecks(%q) = %v, want %v", action, got, want)
+		}
+	}
+	if shouldSkipTrustChecks("github/codeql-action", nil) {
+		t.Error("expected no trusted organizations by default")
+	}
+}
+
+func TestMetadataCache_MaxAge(t *testing.T) {
+	cache := newMetadataCache()
+	cache.put("example/action", ActionMetadata{Stars: 10})
+	if meta, ok := cache.get("example/action", 0); !ok || meta.Stars != 10 {
+		t.Fatalf("get() = %+v, %v", meta, ok)
+	}
+	if _, ok := cache.get("example/action", time.Hour); !ok {
+		t.Fatal("expected a fresh entry to be returned")
+	}
+
+	cache.entries["example/old"] = metadataEntry{meta: ActionMetadata{Stars: 1}, fetched: time.Now().Add(-2 * time.Hour)}
+	if _, ok := cache.get("example/old", time.Hour); ok {
+		t.Fatal("expected an entry older than maxAge to be treated as missing")
+	}
+	if _, ok := cache.get("example/old", 0); !ok {
+		t.Fatal("expected maxAge 0 to keep entries for the whole run")
+	}
+}
+
+func TestParseReleaseTag(t *testing.T) {
+	tag, err := parseReleaseTag(`{"tag_name":"v4.2.1","name":"v4.2.1"}`)
+	if err != nil || tag != "v4.2.1" {
+		t.Fatalf("parseReleaseTag() = %q, %v", tag, err)
+	}
+	if _, err := parseReleaseTag(`{}`); err == nil {
+		t.Fatal("expected error when tag_name is missing")
+	}
+}
+
+func TestPinActionsPass_LatestAnnotation(t *testing.T) {
+	old := hashCache
+	t.Cleanup(func() { hashCache = old })
+	hashCache = newActionHashCache()
+	hashCache.put("example/never-resolved", "latest", lockEntry{hash: testSHA, resolvedVersion: "v4.2.1"})
+
+	content := "jobs:\n  build:\n    steps:\n      - uses: example/never-resolved@latest\n"
+	var workflow map[string]interface{}
+	if err := yaml.Unmarshal([]byte(content), &workflow); err != nil {
+		t.Fatal(err)
+	}
+	updated, res, err := pinActionsPass(content, workflow, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.actionsPinned != 1 {
+		t.Fatalf("expected 1 pinned action, got %d", res.actionsPinned)
+	}
+	want := "uses: example/never-resolved@" + testSHA + " # @latest resolved to v4.2.1 on " + time.Now().Format("2006-01-02")
+	if !strings.Contains(updated, want) {
+		t.Fatalf("expected %q in:\n%s", want, updated)
+	}
+}
+
+func TestPinActionsPass_LeavesRunBlockScalarsUntouched(t *testing.T) {
+	old := hashCache
+	t.Cleanup(func() { hashCache = old })
+	hashCache = newActionHashCache()
+	hashCache.put("example/never-resolved", "v3", lockEntry{hash: testSHA, resolvedVersion: "v3"})
+
+	content := `jobs:
+  test:
+    steps:
+      - run: |
+          cat > wf.yml <<'YAML'
+          steps:
+            - uses: example/never-resolved@v3
+          YAML
+      - uses: example/never-resolved@v3
+`
+	var workflow map[string]interface{}
+	if err := yaml.Unmarshal([]byte(content), &workflow); err != nil {
+		t.Fatal(err)
+	}
+	updated, res, err := pinActionsPass(content, workflow, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.actionsPinned != 1 {
+		t.Fatalf("expected 1 pinned action, got %d", res.actionsPinned)
+	}
+	lines := strings.Split(updated, "\n")
+	if lines[6] != "            - uses: example/never-resolved@v3" {
+		t.Errorf("run: block scalar was modified: %q", lines[6])
+	}
+	if !strings.HasPrefix(lines[8], "      - uses: example/never-resolved@"+testSHA+" # v3") {
+		t.Errorf("step uses: was not pinned: %q", lines[8])
+	}
+}
+
+func TestPinActionsPass_DuplicateActionsPinnedEverywhere(t *testing.T) {
+	old := hashCache
+	t.Cleanup(func() { hashCache = old })
+	hashCache = newActionHashCache()
+	hashCache.put("example/never-resolved", "v3", lockEntry{hash: testSHA, resolvedVersion: "v3"})
+
+	content := `jobs:
+  build:
+    steps:
+      - uses: example/never-resolved@v3
+  test:
+    steps:
+      - uses: example/never-resolved@v3
+      - uses: example/never-resolved@v3
+`
+	var workflow map[string]interface{}
+	if err := yaml.Unmarshal([]byte(content), &workflow); err != nil {
+		t.Fatal(err)
+	}
+	updated, res, err := pinActionsPass(content, workflow, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.actionsPinned != 3 {
+		t.Fatalf("expected 3 pinned actions, got %d", res.actionsPinned)
+	}
+	if got := strings.Count(updated, "example/never-resolved@"+testSHA); got != 3 {
+		t.Errorf("expected every occurrence to be pinned, got %d in:\n%s", got, updated)
+	}
+}
+
+func TestCheckStrictSemver(t *testing.T) {
+	old := strictSemver
+	t.Cleanup(func() { strictSemver = old })
+
+	strictSemver = false
+	if err := checkStrictSemver("main"); err != nil {
+		t.Errorf("disabled: unexpected error: %v", err)
+	}
+
+	strictSemver = true
+	for _, version := range []string{"v3.2.1", "3.2.1", "v10.0.12"} {
+		if err := checkStrictSemver(version); err != nil {
+			t.Errorf("%s: unexpected error: %v", version, err)
+		}
+	}
+	for _, version := range []string{"v3", "v3.2", "main", "latest", "v3.2.1-beta"} {
+		if err := checkStrictSemver(version); !errors.Is(err, errNonSemverVersion) {
+			t.Errorf("%s: expected errNonSemverVersion, got %v", version, err)
+		}
+	}
+	if err := resolveVersion("example/never-resolved", "v3"); !errors.Is(err, errNonSemverVersion) {
+		t.Errorf("resolveVersion: expected errNonSemverVersion, got %v", err)
+	}
+}
+
+func TestPinActionsPass_StrictSemver(t *testing.T) {
+	oldCache, oldStrict := hashCache, strictSemver
+	t.Cleanup(func() { hashCache, strictSemver = oldCache, oldStrict })
+	hashCache = newActionHashCache()
+	hashCache.put("example/never-resolved", "v3", lockEntry{hash: testSHA, resolvedVersion: "v3"})
+	hashCache.put("example/never-resolved", "v3.2.1", lockEntry{hash: testSHA, resolvedVersion: "v3.2.1"})
+	strictSemver = true
+
+	content := `jobs:
+  build:
+    steps:
+      - uses: example/never-resolved@v3
+      - uses: example/never-resolved@v3
+      - uses: example/never-resolved@v3.2.1
+`
+	var workflow map[string]interface{}
+	if err := yaml.Unmarshal([]byte(content), &workflow); err != nil {
+		t.Fatal(err)
+	}
+	updated, res, err := pinActionsPass(content, workflow, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.actionsNonSemver != 2 || res.actionsPinned != 1 {
+		t.Fatalf("expected 2 non-semver and 1 pinned, got %+v", res)
+	}
+	lines := strings.Split(updated, "\n")
+	for _, i := range []int{3, 4} {
+		if lines[i] != "      - uses: example/never-resolved@v3 "+semverTodoComment {
+			t.Errorf("line %d not annotated: %q", i, lines[i])
+		}
+	}
+	if !strings.HasPrefix(lines[5], "      - uses: example/never-resolved@"+testSHA+" # v3.2.1") {
+		t.Errorf("semver tag was not pinned: %q", lines[5])
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"v2", "v3", -1},
+		{"v3", "v3.0.0", 0},
+		{"3.1", "v3", 1},
+		{"v3.9.9", "v4", -1},
+		{"v10.0.0", "v9.9.9", 1},
+		{"v4.0.0-beta.2", "v4", -1},
+		{"v4.0.0-beta.2", "v4.0.0-beta.10", -1},
+		{"v4.0.0-rc.1", "v4.0.0-beta", 1},
+		{"v4.0.0+build.5", "v4", 0},
+	}
+	for _, tt := range tests {
+		if got, ok := compareVersions(tt.a, tt.b); !ok || got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, %v; want %d", tt.a, tt.b, got, ok, tt.want)
+		}
+	}
+	if _, ok := compareVersions("main", "v3"); ok {
+		t.Error("expected a branch not to be comparable")
+	}
+}
+
+func TestPinActionsPass_MinActionVersion(t *testing.T) {
+	oldCache, oldMin := hashCache, minActionVersions
+	t.Cleanup(func() { hashCache, minActionVersions = oldCache, oldMin })
+	hashCache = newActionHashCache()
+	for _, version := range []string{"v2", "v3", "main"} {
+		hashCache.put("example/never-resolved", version, lockEntry{hash: testSHA, resolvedVersion: version})
+	}
+	minimums, err := parseMinActionVersions([]string{"Example/Never-Resolved=v3"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	minActionVersions = minimums
+
+	content := `jobs:
+  build:
+    steps:
+      - uses: example/never-resolved@v2
+      - uses: example/never-resolved/sub@v2
+      - uses: example/never-resolved@v3
+      - uses: example/never-resolved@main
+`
+	var workflow map[string]interface{}
+	if err := yaml.Unmarshal([]byte(content), &workflow); err != nil {
+		t.Fatal(err)
+	}
+	updated, res, err := pinActionsPass(content, workflow, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.actionsBelowMin != 2 || res.actionsPinned != 2 {
+		t.Fatalf("expected 2 below minimum and 2 pinned, got %+v", res)
+	}
+	lines := strings.Split(updated, "\n")
+	for i, uses := range map[int]string{3: "example/never-resolved@v2", 4: "example/never-resolved/sub@v2"} {
+		if want := "      - uses: " + uses + " # WARNING: version v2 is below minimum required v3"; lines[i] != want {
+			t.Errorf("line %d = %q, want %q", i, lines[i], want)
+		}
+	}
+	for _, i := range []int{5, 6} {
+		if !strings.HasPrefix(lines[i], "      - uses: example/never-resolved@"+testSHA) {
+			t.Errorf("line %d was not pinned: %q", i, lines[i])
+		}
+	}
+
+	for _, entry := range []string{"actions/checkout", "actions/checkout=main", "=v3"} {
+		if _, err := parseMinActionVersions([]string{entry}); err == nil {
+			t.Errorf("%q: expected an error", entry)
+		}
+	}
+}
+
+func TestRemapActionPrefix(t *testing.T) {
+	prefixMap, err := parseActionPrefixMap([]string{"actions=internal-mirror", "actions/checkout=mirror/checkout", " acme/ = acme-mirror "})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		action string
+		want   string
+	}{
+		{"actions/setup-go", "internal-mirror/setup-go"},
+		{"actions/checkout", "mirror/checkout"},
+		{"acme/deploy/sub", "acme-mirror/deploy/sub"},
+		{"actions-extra/tool", "actions-extra/tool"},
+		{"docker/login-action", "docker/login-action"},
+	}
+	for _, tt := range tests {
+		if got := remapActionPrefix(tt.action, prefixMap); got != tt.want {
+			t.Errorf("remapActionPrefix(%q) = %q, want %q", tt.action, got, tt.want)
+		}
+	}
+
+	for _, entry := range []string{"actions", "=mirror", "actions=", "actions=internal-mirror/actions", "actions/checkout=mirror", "a/b/c=x/y/z"} {
+		if _, err := parseActionPrefixMap([]string{entry}); err == nil {
+			t.Errorf("expected error for entry %q", entry)
+		}
+	}
+}
+
+func TestGetCommitHashFromVersion_QueriesMirror(t *testing.T) {
+	oldMode, oldToken, oldWorkers := saveAuthGlobals()
+	oldBase, oldResolution, oldNoCache := githubAPIBase, resolutionMode, noCache
+	oldCache, oldMap := hashCache, actionPrefixMap
+	t.Cleanup(func() {
+		restoreAuthGlobals(oldMode, oldToken, oldWorkers)
+		githubAPIBase, resolutionMode, noCache = oldBase, oldResolution, oldNoCache
+		hashCache, actionPrefixMap = oldCache, oldMap
+	})
+
+	var queried []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queried = append(queried, r.URL.Path)
+		if r.URL.Path == "/repos/internal-mirror/checkout/git/refs/tags/v4" {
+			io.WriteString(w, `{"object":{"sha":"`+testSHA+`"}}`)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(server.Close)
+	githubAPIBase = server.URL
+	authMode, githubToken, noCache = "pat", "test-token", true
+	resolutionMode = strategyAPIOnly
+	hashCache = newActionHashCache()
+
+	prefixMap, err := parseActionPrefixMap([]string{"actions=internal-mirror"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actionPrefixMap = prefixMap
+
+	hash, _, err := getCommitHashFromVersion("actions/checkout", "v4")
+	if err != nil || hash != testSHA {
+		t.Fatalf("got %q, %v; queried %v", hash, err, queried)
+	}
+	for _, path := range queried {
+		if !strings.HasPrefix(path, "/repos/internal-mirror/checkout/") {
+			t.Errorf("expected only the mirror repository to be queried, got %s", path)
+		}
+	}
+	if _, ok := hashCache.get("actions/checkout", "v4"); !ok {
+		t.Error("expected the hash to be cached under the original action name")
+	}
+}
+
+func TestAnnotateCachedCommit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("TMPDIR", t.TempDir())
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	dir := actionRepoCacheDir("example/action/sub")
+	if filepath.Base(dir) != "example_action" {
+		t.Fatalf("unexpected cache dir %s", dir)
+	}
+	for _, args := range [][]string{{"init", "-q", dir}, {"-C", dir, "commit", "-q", "--allow-empty", "-m", "init"}} {
+		if result := execCommand("git", args...); result.ExitCode != 0 {
+			t.Fatalf("git %v: %s", args, result.Stderr)
+		}
+	}
+	hash := strings.TrimSpace(execCommandWithDir(dir, "git", "rev-parse", "HEAD").Stdout)
+
+	annotateCachedCommit("example/action/sub", "example/action/sub", "v3", hash, "2024-01-15")
+	// Re-resolving replaces the note rather than failing on the existing one.
+	annotateCachedCommit("example/action/sub", "example/action/sub", "v3", hash, "2024-02-01")
+
+	got := strings.TrimSpace(execCommandWithDir(dir, "git", "notes", "show", hash).Stdout)
+	if want := "Resolved from v3 on 2024-02-01 for example/action/sub"; got != want {
+		t.Fatalf("git notes show = %q, want %q", got, want)
+	}
+}
+
+func TestResolveCommitHash_Strategies(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("TMPDIR", t.TempDir())
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	oldMode, oldToken, oldWorkers := saveAuthGlobals()
+	oldBase, oldResolution, oldNoCache := githubAPIBase, resolutionMode, noCache
+	t.Cleanup(func() {
+		restoreAuthGlobals(oldMode, oldToken, oldWorkers)
+		githubAPIBase, resolutionMode, noCache = oldBase, oldResolution, oldNoCache
+	})
+
+	apiRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiRequests++
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(server.Close)
+	githubAPIBase = server.URL
+	authMode, githubToken, noCache = "pat", "test-token", true
+
+	// A cached clone with a v1 tag; its origin does not exist, so refreshing
+	// it fails quietly and the local tag is used.
+	dir := actionRepoCacheDir("example/action")
+	for _, args := range [][]string{{"init", "-q", dir}, {"-C", dir, "commit", "-q", "--allow-empty", "-m", "init"}, {"-C", dir, "tag", "v1"}} {
+		if result := execCommand("git", args...); result.ExitCode != 0 {
+			t.Fatalf("git %v: %s", args, result.Stderr)
+		}
+	}
+	want := strings.TrimSpace(execCommandWithDir(dir, "git", "rev-parse", "HEAD").Stdout)
+
+	resolutionMode = strategyCloneOnly
+	if hash, _, err := resolveCommitHash("example/action", "v1"); err != nil || hash != want {
+		t.Fatalf("--clone-only: got %s, %v", hash, err)
+	}
+	if apiRequests != 0 {
+		t.Fatalf("--clone-only must not call the API, got %d requests", apiRequests)
+	}
+
+	resolutionMode = strategyPreferClone
+	if hash, _, err := resolveCommitHash("example/action", "v1"); err != nil || hash != want {
+		t.Fatalf("--prefer-clone: got %s, %v", hash, err)
+	}
+	if apiRequests != 0 {
+		t.Fatalf("--prefer-clone must not call the API when the clone resolves, got %d requests", apiRequests)
+	}
+
+	resolutionMode = strategyAPIOnly
+	if _, _, err := resolveCommitHash("example/action", "v1"); err == nil {
+		t.Fatal("--api-only must not fall back to the cached clone")
+	}
+	if apiRequests == 0 {
+		t.Fatal("--api-only should have called the API")
+	}
+}
+
+func TestValidateRuntimeConfig_ResolutionStrategy(t *testing.T) {
+	oldPreferAPI, oldPreferClone, oldAPIOnly, oldCloneOnly, oldForce, oldMode := preferAPI, preferClone, apiOnly, cloneOnly, forceClone, resolutionMode
+	t.Cleanup(func() {
+		preferAPI, preferClone, apiOnly, cloneOnly, forceClone, resolutionMode = oldPreferAPI, oldPreferClone, oldAPIOnly, oldCloneOnly, oldForce, oldMode
+	})
+	preferAPI, preferClone, apiOnly, cloneOnly, forceClone = false, false, false, true, false
+	if err := validateRuntimeConfig(); err != nil || resolutionMode != strategyCloneOnly {
+		t.Fatalf("expected --clone-only to be selected, got %v, %v", resolutionMode, err)
+	}
+	apiOnly = true
+	if err := validateRuntimeConfig(); err == nil || !strings.Contains(err.Error(), "cannot be combined") {
+		t.Fatalf("expected conflicting strategies to be rejected, got %v", err)
+	}
+}
+
+func TestResolveCommitHash_FixLatestCloneOnly(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("TMPDIR", t.TempDir())
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	oldMode, oldToken, oldWorkers := saveAuthGlobals()
+	oldBase, oldResolution, oldNoCache, oldFixLatest := githubAPIBase, resolutionMode, noCache, fixLatest
+	t.Cleanup(func() {
+		restoreAuthGlobals(oldMode, oldToken, oldWorkers)
+		githubAPIBase, resolutionMode, noCache, fixLatest = oldBase, oldResolution, oldNoCache, oldFixLatest
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/example/action/releases/latest" {
+			w.Write([]byte(`{"tag_name":"v2"}`))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(server.Close)
+	githubAPIBase = server.URL
+	authMode, githubToken, noCache = "pat", "test-token", true
+
+	// The latest release tag only exists in the cached clone, so it must be
+	// resolved there rather than through the API.
+	dir := actionRepoCacheDir("example/action")
+	for _, args := range [][]string{{"init", "-q", dir}, {"-C", dir, "commit", "-q", "--allow-empty", "-m", "v1"}, {"-C", dir, "tag", "v1"}, {"-C", dir, "commit", "-q", "--allow-empty", "-m", "v2"}, {"-C", dir, "tag", "v2"}} {
+		if result := execCommand("git", args...); result.ExitCode != 0 {
+			t.Fatalf("git %v: %s", args, result.Stderr)
+		}
+	}
+	want := strings.TrimSpace(execCommandWithDir(dir, "git", "rev-parse", "v2").Stdout)
+
+	resolutionMode, fixLatest = strategyCloneOnly, true
+	hash, resolvedVersion, err := resolveCommitHash("example/action", "latest")
+	if err != nil || hash != want || resolvedVersion != "v2" {
+		t.Fatalf("--clone-only --fix-latest: got %s (%s), %v; want %s (v2)", hash, resolvedVersion, err, want)
+	}
+}
+
+func TestLockActionRepo(t *testing.T) {
+	unlockA := lockActionRepo("/cache/a")
+
+	// Another clone is not blocked.
+	lockActionRepo("/cache/b")()
+
+	acquired := make(chan struct{})
+	go func() {
+		defer lockActionRepo("/cache/a")()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("second lock of the same clone acquired while the first is held")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlockA()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("second lock not acquired after unlock")
+	}
+}
+
+func TestResolveCommitHashViaClone_PartialVersion(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("TMPDIR", t.TempDir())
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	oldResolution, oldNoCache, oldCache := resolutionMode, noCache, hashCache
+	t.Cleanup(func() { resolutionMode, noCache, hashCache = oldResolution, oldNoCache, oldCache })
+	resolutionMode, noCache, hashCache = strategyCloneOnly, true, newActionHashCache()
+
+	dir := actionRepoCacheDir("example/action")
+	for _, args := range [][]string{{"init", "-q", dir}, {"-C", dir, "commit", "-q", "--allow-empty", "-m", "v3.1.0"}, {"-C", dir, "tag", "v3.1.0"}, {"-C", dir, "commit", "-q", "--allow-empty", "-m", "v3.2.0"}, {"-C", dir, "tag", "v3.2.0"}} {
+		if result := execCommand("git", args...); result.ExitCode != 0 {
+			t.Fatalf("git %v: %s", args, result.Stderr)
+		}
+	}
+	want := strings.TrimSpace(execCommandWithDir(dir, "git", "rev-parse", "v3.2.0").Stdout)
+
+	// The prefix match re-enters the resolver for v3.2.0, which must not
+	// deadlock on the clone lock.
+	hash, resolvedVersion, err := resolveCommitHashViaClone("example/action", "v3", false)
+	if err != nil || hash != want || resolvedVersion != "v3.2.0" {
+		t.Fatalf("got %s (%s), %v; want %s (v3.2.0)", hash, resolvedVersion, err, want)
+	}
+}
+
+func TestPinActionsPass_InactiveActionAnnotatedOnEveryStep(t *testing.T) {
+	oldCache, oldAge := hashCache, maxCommitAge
+	t.Cleanup(func() { hashCache, maxCommitAge = oldCache, oldAge })
+	hashCache = newActionHashCache()
+	hashCache.putLastCommit("foo/bar", time.Now().AddDate(0, 0, -10))
+	maxCommitAge = 24 * time.Hour
+
+	content := "jobs:\n  a:\n    steps:\n      - uses: foo/bar@v1\n  b:\n    steps:\n      - uses: foo/bar@v1\n"
+	var workflow map[string]interface{}
+	if err := yaml.Unmarshal([]byte(content), &workflow); err != nil {
+		t.Fatal(err)
+	}
+	updated, res, err := pinActionsPass(content, workflow, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "      - uses: foo/bar@v1 # WARNING: last commit > 1 days ago"
+	for _, i := range []int{3, 6} {
+		if line := strings.Split(updated, "\n")[i]; line != want {
+			t.Errorf("line %d = %q, want %q", i+1, line, want)
+		}
+	}
+	if res.actionsInactive != 1 {
+		t.Errorf("expected the inactive action to be counted once, got %d", res.actionsInactive)
+	}
+}
+
+func TestPinActionsPass_MissingActionAnnotatedOnEveryStep(t *testing.T) {
+	oldMode, oldToken, oldWorkers := saveAuthGlobals()
+	oldBase, oldResolution, oldNoCache, oldCache := githubAPIBase, resolutionMode, noCache, hashCache
+	t.Cleanup(func() {
+		restoreAuthGlobals(oldMode, oldToken, oldWorkers)
+		githubAPIBase, resolutionMode, noCache, hashCache = oldBase, oldResolution, oldNoCache, oldCache
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"Not Found"}`))
+	}))
+	t.Cleanup(server.Close)
+	githubAPIBase = server.URL
+	authMode, githubToken, noCache = "pat", "test-token", true
+	resolutionMode, hashCache = strategyPreferAPI, newActionHashCache()
+
+	content := "jobs:\n  a:\n    steps:\n      - uses: gone/action@v1\n  b:\n    steps:\n      - uses: gone/action@v1\n"
+	var workflow map[string]interface{}
+	if err := yaml.Unmarshal([]byte(content), &workflow); err != nil {
+		t.Fatal(err)
+	}
+	updated, res, err := pinActionsPass(content, workflow, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "      - uses: gone/action@v1 # WARNING: repository not found - verify this action still exists"
+	for _, i := range []int{3, 6} {
+		if line := strings.Split(updated, "\n")[i]; line != want {
+			t.Errorf("line %d = %q, want %q", i+1, line, want)
+		}
+	}
+	if res.actionsNotFound != 1 {
+		t.Errorf("expected the missing action to be counted once, got %d", res.actionsNotFound)
+	}
+}
+
+func TestPinActionsPass_LowTrustActionAnnotatedOnEveryStep(t *testing.T) {
+	oldCache, oldMeta, oldUseMeta, oldStars := hashCache, actionMetadataCache, useMetadataCache, requireMinStars
+	t.Cleanup(func() {
+		hashCache, actionMetadataCache, useMetadataCache, requireMinStars = oldCache, oldMeta, oldUseMeta, oldStars
+	})
+	hashCache, actionMetadataCache, useMetadataCache = newActionHashCache(), newMetadataCache(), true
+	actionMetadataCache.put("foo/obscure", ActionMetadata{Stars: 3})
+	requireMinStars = 50
+
+	content := "jobs:\n  a:\n    steps:\n      - uses: foo/obscure@v1\n  b:\n    steps:\n      - uses: foo/obscure@v1\n"
+	var workflow map[string]interface{}
+	if err := yaml.Unmarshal([]byte(content), &workflow); err != nil {
+		t.Fatal(err)
+	}
+	updated, res, err := pinActionsPass(content, workflow, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "      - uses: foo/obscure@v1 # TODO: low-trust action (<50 stars), verify and pin manually"
+	for _, i := range []int{3, 6} {
+		if line := strings.Split(updated, "\n")[i]; line != want {
+			t.Errorf("line %d = %q, want %q", i+1, line, want)
+		}
+	}
+	if res.actionsLowTrust != 1 {
+		t.Errorf("expected the low-trust action to be counted once, got %d", res.actionsLowTrust)
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,43 +14,211 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"text/tabwriter"
+	"text/template"
 	"time"
 
 	execute "github.com/alexellis/go-execute/v2"
+	"github.com/harekrishnarai/gha-pinner/i18n"
 	"github.com/spf13/cobra"
 	"go.uber.org/multierr"
 	"go.uber.org/zap"
 	"gopkg.in/yaml.v3"
 )
 
+const (
+	// orgTemplatesRepo is the organization repository that hosts org-level
+	// workflow templates in its workflowTemplatesDir.
+	orgTemplatesRepo     = ".github"
+	workflowTemplatesDir = "workflow-templates"
+	// ruleMissingPermissions identifies workflows without a top-level permissions: block.
+	ruleMissingPermissions = "GHA005"
+	// ruleNonSemverVersion identifies uses: references rejected by --strict-semver.
+	ruleNonSemverVersion = "GHA008"
+	semverTodoComment    = "# TODO: use a semver tag (e.g., v3.2.1)"
+	minVersionComment    = "# WARNING: version %s is below minimum required %s"
+	stalePRComment       = "Closing stale pinning PR. Run gha-pinner to recreate."
+	// defaultSecurityComment is the --add-security-comments header; the
+	// "pinned to commit hashes" phrase marks files that already have one.
+	defaultSecurityComment = "Action versions are pinned to commit hashes. See: https://docs.github.com/en/actions/security-guides/security-hardening-for-github-actions"
+)
+
 var (
 	debug                = false
 	ignorePRTemplates    = false
 	skipPRCreation       = false
+	noPush               = false
+	forceOverwrite       = false
+	failFast             = false
+	skipEmptyRepos       = false
+	noCleanup            = false
+	cleanupOnSuccessOnly = false
 	outputDir            = ""
+	outputDirStructure   = "flat"
+	syncForkStrategy     = "api"
+	forkVisibility       = ""
+	forkSyncAttempts     = 3
+	forkSyncDelay        = 5 * time.Second
 	authMode             = "gh"
 	githubToken          = ""
+	githubTokenEnv       = ""
+	githubAppID          = 0
+	githubAppKeyFile     = ""
 	repoWorkers          = 4
 	logger               = zap.NewNop().Sugar()
 	errUnresolvedVersion = errors.New("unresolved version")
 	errNeedsFork         = errors.New("needs fork")
+	errActionNotFound    = errors.New("action repository not found")
+	errActionInactive    = errors.New("action repository inactive")
+	errInterrupted       = errors.New("interrupted")
+	errFailFast          = errors.New("stopped after the first repository failure (--fail-fast)")
+	errForkNotSynced     = errors.New("fork does not contain the upstream commit")
+	runCtx               = context.Background() // cancelled on SIGINT/SIGTERM
 	skipActions          = []string{}
 	injectHardenRunner   = false
 	egressPolicy         = "audit"
 	pinRunners           = false
 	runnerMapRaw         = []string{}
+	actionPrefixMapRaw   = []string{}
+	actionPrefixMap      = map[string]string{}
+	configOverridesRaw   = []string{}
+	configOverrides      []configOverride
 	runnerMap            = map[string]string{}
+	verbose              = false
+	coAuthorsRaw         = []string{}
+	coAuthorshipFile     = ""
+	coAuthors            = []string{}
+	exportLockPath       = ""
+	importLockPath       = ""
+	hashCache            = newActionHashCache()
+	actionMetadataCache  = newMetadataCache()
+	useMetadataCache     = true
+	actionMetadataTTL    time.Duration
+	checkOnly            = false
+	listUnpinned         = false
+	listAlreadyPinned    = false
+	exportActionsPath    = ""
+	skipIfNoWorkflows    = false
+	onlyCheckPinned      = false
+	checkBaseRef         = ""
+	watchDebounce        = 2 * time.Second
+	outputFormat         = "text"
+	signCommits          = false
+	signingKey           = ""
+	baseBranch           = ""
+	prBodyFile           = ""
+	prBodyTemplate       *template.Template
+	prTemplateFile       = ""
+	prTemplate           *template.Template
+	messageTemplateRaw   = ""
+	commitTemplateFile   = ""
+	commitTemplate       *template.Template
+	targetRepos          = []string{}
+	noEnvExpand          = false
+	repoBatchSize        = 0
+	repoBatchCooldown    time.Duration
+	skipPrivateRepos     = false
+	onlyPrivateRepos     = false
+	repoTopicsFilter     []string
+	repoTopicsExclude    []string
+	trackingIssue        = false
+	trackingIssueRepo    = ""
+	skipPinnedRepos      = false
+	excludePatternsRaw   = []string{}
+	includePatternsRaw   = []string{}
+	excludePatterns      []*regexp.Regexp
+	includePatterns      []*regexp.Regexp
+	maxPRAgeRaw          = ""
+	maxPRAge             time.Duration
+	prCloseStaleRaw      = ""
+	maxFilesPerPR        = 0
+	maxWorkflowFiles     = 0
+	prCloseStale         time.Duration
+	ignoreJobs           = []string{}
+	ignoreCompositeRefs  = false
+	networkTimeout       time.Duration
+	maxRetries           = 0
+	notifySlackURL       = ""
+	summaryFile          = ""
+	githubEnvFile        = ""
+	groupByAction        = false
+	openPR               = false
+	indexURL             = ""
+	requirePermissions   = false
+	addPermissions       = false
+	detectInjection      = false
+	detectDispatchInputs = false
+	detectThirdParty     = false
+	verifyWorkflowSyntax = false
+	workflowSchemaURL    = defaultWorkflowSchemaURL
+	reportPermissions    = false
+	fixLatest            = false
+	prMilestone          = ""
+	prAssigneeFromBlame  = false
+	scorecardDelta       = false
+	prLabels             []string
+	prSearchLabel        = ""
+	createMilestone      = false
+	apiCacheDir          = ""
+	apiCacheTTL          = time.Hour
+	noCache              = false
+	forceClone           = false
+	preferAPI            = false
+	preferClone          = false
+	apiOnly              = false
+	cloneOnly            = false
+	resolutionMode       = strategyPreferAPI
+	tagAnnotation        = false
+	cloneDepth           = -1
+	commitVerification   = false
+	createIssues         = false
+	maxCommitAgeRaw      = ""
+	gitConfigFile        = ""
+	gitEnvVars           []string
+	maxCommitAge         time.Duration
+	requireMinStars      = 0
+	trustedOrgs          []string
+	minWorkflowSize      = 0
+	preserveMtime        = false
+	addSecurityComments  = false
+	securityCommentText  = defaultSecurityComment
+	checkSigstoreLog     = false
+	strictSemver         = false
+	minActionVersionRaw  []string
+	minActionVersions    = map[string]string{}
+	requireHashComment   = false
+	commentLanguage      = i18n.DefaultLanguage
+	checkLicense         = false
+	allowedLicenses      []string
+	ignoreUnresolvable   = false
+	failOnUnresolvable   = false
+	errNonSemverVersion  = errors.New("version is not a full semver tag")
+	errLowTrustAction    = errors.New("low-trust action")
+	errUnpinnedFound     = errors.New("unpinned actions found")
 )
 
 type Repository struct {
-	Name             string           `json:"name"`
-	URL              string           `json:"url"`
-	DefaultBranchRef DefaultBranchRef `json:"defaultBranchRef"`
+	Name             string            `json:"name"`
+	URL              string            `json:"url"`
+	DefaultBranchRef DefaultBranchRef  `json:"defaultBranchRef"`
+	Visibility       string            `json:"visibility"` // public, private or internal; upper-case from gh
+	RepositoryTopics []RepositoryTopic `json:"repositoryTopics"`
+}
+
+// RepositoryTopic is one entry of gh's repositoryTopics list.
+type RepositoryTopic struct {
+	Name string `json:"name"`
 }
 
 type DefaultBranchRef struct {
@@ -60,16 +231,179 @@ type ExecResult struct {
 	ExitCode int
 }
 
-// lastRunSummary holds the totals from the most recent patchLocalRepository call,
-// so buildDynamicPRBody can generate an accurate PR description.
-var lastRunSummary struct {
+// runReport aggregates results across every repository processed in this run.
+// It is safe for use by concurrent repository workers.
+var runReport = &runReportCollector{}
+
+type runReportCollector struct {
+	mu            sync.Mutex
+	actionsPinned int
+	prURLs        []string
+	failedRepos   []string
+	startedAt     time.Time
+	repoResults   []RepoResult
+}
+
+func (r *runReportCollector) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.actionsPinned = 0
+	r.prURLs = nil
+	r.failedRepos = nil
+	r.startedAt = time.Now()
+	r.repoResults = nil
+}
+
+// repoResult returns the per-repository entry for repoName, creating it on
+// first use. The caller must hold r.mu.
+func (r *runReportCollector) repoResult(repoName string) *RepoResult {
+	for i := range r.repoResults {
+		if r.repoResults[i].Repo == repoName {
+			return &r.repoResults[i]
+		}
+	}
+	r.repoResults = append(r.repoResults, RepoResult{Repo: repoName})
+	return &r.repoResults[len(r.repoResults)-1]
+}
+
+func (r *runReportCollector) addRepo(repoName string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.repoResult(repoName)
+}
+
+func (r *runReportCollector) setRepoStatus(repoName, status string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.repoResult(repoName).Status = status
+}
+
+// statusCount returns how many repositories finished with status.
+func (r *runReportCollector) statusCount(status string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	count := 0
+	for _, result := range r.repoResults {
+		if result.Status == status && result.Error == "" {
+			count++
+		}
+	}
+	return count
+}
+
+func (r *runReportCollector) addRepoPinned(repoName string, count int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.repoResult(repoName).ActionsPinned = count
+}
+
+func (r *runReportCollector) addPinnedByCategory(repoName string, counts map[ActionCategory]int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.repoResult(repoName).PinnedByCategory = counts
+}
+
+func (r *runReportCollector) addSyntaxIssues(repoName string, issues []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.repoResult(repoName).SyntaxIssues = issues
+}
+
+// results returns a copy of the per-repository results in processing order.
+func (r *runReportCollector) results() []RepoResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]RepoResult(nil), r.repoResults...)
+}
+
+func (r *runReportCollector) addPinned(count int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.actionsPinned += count
+}
+
+func (r *runReportCollector) addPR(repoName, prURL string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prURLs = append(r.prURLs, prURL)
+	result := r.repoResult(repoName)
+	result.PRURLs = append(result.PRURLs, prURL)
+}
+
+func (r *runReportCollector) hasFailures() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.failedRepos) > 0
+}
+
+func (r *runReportCollector) addFailure(repoName string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failedRepos = append(r.failedRepos, repoName)
+	r.repoResult(repoName).Error = err.Error()
+}
+
+// runSummary holds the totals of one patchLocalRepository call. It is
+// returned to the caller rather than kept in a global because repository
+// workers patch several repositories at the same time.
+type runSummary struct {
 	actionsPinned   int
 	alreadyPinned   int
 	hardenInjected  int
 	runnersReplaced int
 	withLatest      int
 	withoutTags     int
+	notFound        int
 	totalFound      int
+	changes         []actionChange
+	unresolvable    []string
+	files           []string
+	syntaxIssues    []string
+	// written lists the files rewritten on disk, for commitLocalChanges.
+	written []string
+	// byFile keeps each file's own result so that a subset of the files,
+	// such as one --max-files-per-pr batch, can be summarized on its own.
+	byFile map[string]patchResult
+}
+
+// add folds the result of patching file, a slash-separated path relative
+// to the repository root, into s.
+func (s *runSummary) add(file string, res patchResult) {
+	s.actionsPinned += res.actionsPinned
+	s.alreadyPinned += res.actionsAlreadyPinned
+	s.hardenInjected += res.hardenInjected
+	s.runnersReplaced += res.runnersReplaced
+	s.withLatest += res.actionsWithLatest
+	s.withoutTags += res.actionsWithoutTags
+	s.notFound += res.actionsNotFound
+	s.totalFound += res.totalActions
+	s.changes = append(s.changes, res.changes...)
+	s.unresolvable = append(s.unresolvable, res.unresolvable...)
+	if len(res.changes) > 0 {
+		s.files = append(s.files, file)
+	}
+	if res.written {
+		s.written = append(s.written, file)
+	}
+	for _, issue := range res.syntaxIssues {
+		s.syntaxIssues = append(s.syntaxIssues, file+": "+issue)
+	}
+	if s.byFile == nil {
+		s.byFile = make(map[string]patchResult)
+	}
+	s.byFile[file] = res
+}
+
+// forFiles returns the summary restricted to files. Files that were not
+// patched are ignored.
+func (s runSummary) forFiles(files []string) runSummary {
+	var subset runSummary
+	for _, file := range files {
+		if res, ok := s.byFile[file]; ok {
+			subset.add(file, res)
+		}
+	}
+	return subset
 }
 
 type patchResult struct {
@@ -78,9 +412,35 @@ type patchResult struct {
 	actionsSkipped       int
 	actionsWithLatest    int
 	actionsWithoutTags   int
+	actionsNotFound      int
+	actionsInactive      int
+	actionsLowTrust      int
 	totalActions         int
 	hardenInjected       int
 	runnersReplaced      int
+	missingPermissions   int
+	permissionsAdded     int
+	injectionFindings    int
+	dispatchFindings     int
+	permissionsIssues    int
+	filesTooSmall        int
+	actionsNonSemver     int
+	actionsBelowMin      int
+	licenseFindings      int
+	// written is set when the file was rewritten on disk.
+	written      bool
+	changes      []actionChange
+	unresolvable []string
+	syntaxIssues []string
+}
+
+// actionChange records a single rewritten uses: reference for --verbose output.
+type actionChange struct {
+	action string
+	before string
+	after  string
+	hash   string
+	date   string
 }
 
 type WorkflowPatcher struct {
@@ -88,14 +448,42 @@ type WorkflowPatcher struct {
 	egressPolicy       string
 	pinRunners         bool
 	runnerMap          map[string]string
+	requirePermissions bool
+	addPermissions     bool
+	detectInjection    bool
+	detectDispatch     bool
+	verifySyntax       bool
+	reportPermissions  bool
+	minWorkflowSize    int
+	preserveMtime      bool
+	// securityComment is the --add-security-comments header text, or empty
+	securityComment string
 	// cached harden-runner resolution (populated lazily on first use)
 	hardenRunnerTag string
 	hardenRunnerSHA string
 }
 
 func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	runCtx = ctx
+	go func() {
+		<-ctx.Done()
+		// Restore default signal handling so a second Ctrl+C aborts immediately.
+		stop()
+		fmt.Fprintf(os.Stderr, "\n⏹️  Interrupt received - finishing the current repository before exiting (press Ctrl+C again to abort)\n")
+	}()
+
 	root := newRootCmd()
-	if err := root.Execute(); err != nil {
+	if err := root.ExecuteContext(ctx); err != nil {
+		if errors.Is(err, errUnpinnedFound) {
+			// --check already reported the offending files; only the exit code matters.
+			os.Exit(1)
+		}
+		if errors.Is(err, errInterrupted) {
+			fmt.Fprintf(os.Stderr, "Interrupted: remaining repositories were not processed\n")
+			os.Exit(130)
+		}
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
@@ -107,7 +495,7 @@ func newRootCmd() *cobra.Command {
 		Short:         "Pin GitHub Actions to commit hashes for stronger supply-chain security",
 		SilenceUsage:  true,
 		SilenceErrors: true,
-		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			applyGlobalFlagsFromCmd(cmd)
 			if err := validateRuntimeConfig(); err != nil {
 				return err
@@ -115,75 +503,332 @@ func newRootCmd() *cobra.Command {
 			if err := initLogger(); err != nil {
 				return err
 			}
+			if githubAppID != 0 {
+				if err := initGitHubAppAuth(appInstallationOrg(cmd.Name(), args)); err != nil {
+					return err
+				}
+			}
 			logger.Infow("starting command", "command", cmd.Name(), "auth_mode", authMode, "repo_workers", repoWorkers)
+			if importLockPath != "" {
+				count, err := hashCache.importLock(importLockPath)
+				if err != nil {
+					return err
+				}
+				logger.Infow("imported action lock", "path", importLockPath, "entries", count)
+			}
 			return nil
 		},
+		PersistentPostRunE: func(_ *cobra.Command, _ []string) error {
+			if exportLockPath == "" {
+				return nil
+			}
+			return hashCache.exportLock(exportLockPath)
+		},
 	}
 
 	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "Enable debug output")
 	rootCmd.PersistentFlags().BoolVar(&ignorePRTemplates, "ignore-templates", false, "Ignore PR templates and use full PR body")
 	rootCmd.PersistentFlags().BoolVar(&skipPRCreation, "no-pr", false, "Skip PR creation, only fix repositories locally")
-	rootCmd.PersistentFlags().StringVar(&outputDir, "output", "", "Custom output directory for repositories (only with --no-pr)")
+	rootCmd.PersistentFlags().BoolVar(&noPush, "no-push", false, "Commit the pinning branch locally but do not push it or create a PR")
+	rootCmd.PersistentFlags().BoolVar(&forceOverwrite, "force-overwrite", false, "Remove an existing repository directory even if it is not a git repository")
+	rootCmd.PersistentFlags().BoolVar(&skipEmptyRepos, "ignore-missing-default-branch", false, "Skip empty repositories (no commits, no default branch) instead of failing them")
+	rootCmd.PersistentFlags().BoolVar(&failFast, "fail-fast", false, "Stop organization and file runs at the first repository failure and exit 1")
+	rootCmd.PersistentFlags().BoolVar(&noCleanup, "no-cleanup", false, "Keep temporary directories (cloned actions and repositories, PR body) after the run for debugging")
+	rootCmd.PersistentFlags().BoolVar(&cleanupOnSuccessOnly, "cleanup-on-success-only", false, "Keep temporary directories only when the run had errors")
+	rootCmd.PersistentFlags().StringVar(&outputDir, "output", "", "Custom output directory for repositories (only with --no-pr or --no-push)")
+	rootCmd.PersistentFlags().StringVar(&outputDirStructure, "output-dir-structure", "flat", "Layout of repositories in the output directory: flat or org/repo")
 	rootCmd.PersistentFlags().StringVar(&authMode, "auth-mode", "gh", "Authentication mode: gh or pat")
+	rootCmd.PersistentFlags().StringVar(&githubTokenEnv, "github-token-env", "", "Read the GitHub token from this environment variable instead of GITHUB_TOKEN/GH_TOKEN and pass it to gh and git as GH_TOKEN")
+	rootCmd.PersistentFlags().IntVar(&githubAppID, "github-app-id", 0, "Authenticate as this GitHub App using an installation token")
+	rootCmd.PersistentFlags().StringVar(&githubAppKeyFile, "github-app-key-file", "", "Path to the GitHub App private key (PEM) used with --github-app-id")
+	rootCmd.PersistentFlags().BoolVar(&skipIfNoWorkflows, "skip-if-no-workflows", false, "Do not report repositories without workflow files; they are only counted in the final summary")
 	rootCmd.PersistentFlags().IntVar(&repoWorkers, "repo-workers", 4, "Number of repositories to process in parallel for organization/file commands")
 	rootCmd.PersistentFlags().BoolVar(&injectHardenRunner, "inject-harden-runner", false, "Inject step-security/harden-runner as the first step in every job")
 	rootCmd.PersistentFlags().StringVar(&egressPolicy, "egress-policy", "audit", "Egress policy for injected harden-runner: audit or block")
+	rootCmd.PersistentFlags().StringSliceVar(&gitEnvVars, "workflow-env-vars", []string{}, "Comma-separated KEY=VALUE environment variables for git clone, fetch and push, e.g. HTTPS_PROXY=http://proxy:3128,GIT_SSL_CAINFO=/etc/certs/ca.crt")
+	rootCmd.PersistentFlags().StringVar(&gitConfigFile, "gitconfig-file", "", "Use this file as the global git config (GIT_CONFIG_GLOBAL) for all git operations; credentials and identity are written there")
+	rootCmd.PersistentFlags().BoolVar(&preferAPI, "prefer-api", false, "Resolve versions through the GitHub API and clone only when that fails (default)")
+	rootCmd.PersistentFlags().BoolVar(&preferClone, "prefer-clone", false, "Resolve versions by cloning the action and use the GitHub API only when that fails")
+	rootCmd.PersistentFlags().BoolVar(&apiOnly, "api-only", false, "Resolve versions through the GitHub API only, never cloning")
+	rootCmd.PersistentFlags().BoolVar(&cloneOnly, "clone-only", false, "Resolve versions by cloning only, never calling the GitHub API for resolution")
+	rootCmd.PersistentFlags().BoolVar(&forceClone, "force-clone", false, "Debugging aid: skip the GitHub API and resolve every action by cloning it (significantly slower)")
+	rootCmd.PersistentFlags().BoolVar(&tagAnnotation, "tag-annotation", false, "Record how each hash was resolved as a git note on the commit in the local action cache (see git notes show <hash>)")
+	rootCmd.PersistentFlags().IntVar(&cloneDepth, "clone-depth", -1, "History depth for action repository clones; 0 clones the full history (default: try depth 1, then 10, then a full clone)")
+	rootCmd.PersistentFlags().BoolVar(&requirePermissions, "require-permissions-block", false, "Warn about workflows without a top-level permissions: block (does not block pinning)")
+	rootCmd.PersistentFlags().BoolVar(&fixLatest, "fix-latest", false, "Resolve @latest to the repository's latest release tag and pin it (opt-in: @latest semantics differ from a tag)")
+	rootCmd.PersistentFlags().BoolVar(&reportPermissions, "report-permissions-issues", false, "Report permissions: write-all and write scopes no step appears to need (audit only)")
+	rootCmd.PersistentFlags().BoolVar(&detectInjection, "detect-injection", false, "Report user-controlled ${{ github.event.* }} expressions interpolated into run: steps")
+	rootCmd.PersistentFlags().BoolVar(&verifyWorkflowSyntax, "verify-workflow-syntax", false, "Validate each modified workflow against the GitHub Actions JSON Schema and warn about violations")
+	rootCmd.PersistentFlags().StringVar(&workflowSchemaURL, "workflow-schema-url", defaultWorkflowSchemaURL, "JSON Schema used by --verify-workflow-syntax")
+	rootCmd.PersistentFlags().BoolVar(&detectDispatchInputs, "detect-workflow-dispatch-defaults", false, "Report (rule GHA010, informational) workflow_dispatch triggers that declare no inputs:")
+	rootCmd.PersistentFlags().BoolVar(&detectThirdParty, "detect-third-party-actions", false, "Break down pinned actions by owner: official (actions, github), verified-creator and community")
+	rootCmd.PersistentFlags().BoolVar(&addPermissions, "add-permissions-block", false, "Insert a top-level permissions: read-all block into workflows that have none")
 	rootCmd.PersistentFlags().BoolVar(&pinRunners, "pin-runners", false, "Replace floating runner labels (e.g. ubuntu-latest) with versioned equivalents")
+	rootCmd.PersistentFlags().StringArrayVar(&configOverridesRaw, "config-override", []string{}, "Override a config file key for this run, e.g. --config-override index_url=https://example.com/index.json (repeatable)")
+	rootCmd.PersistentFlags().StringArrayVar(&actionPrefixMapRaw, "action-prefix-map", []string{}, "Resolve actions under this owner or owner/repo against a mirror, e.g. --action-prefix-map actions=internal-mirror (repeatable; default: action_prefix_map from the config file)")
 	rootCmd.PersistentFlags().StringArrayVar(&runnerMapRaw, "runner-map", []string{}, "Custom runner label mapping, e.g. --runner-map ubuntu-latest=ubuntu-24.04")
+	rootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "Show a before/after table of rewritten action references for each modified file")
+	rootCmd.PersistentFlags().StringArrayVar(&coAuthorsRaw, "co-author", []string{}, "Add a Co-authored-by trailer to commits, e.g. --co-author \"Name <email>\"")
+	rootCmd.PersistentFlags().StringVar(&coAuthorshipFile, "co-authorship-file", "", "File with one \"Name <email>\" co-author per line to add as commit trailers")
+	rootCmd.PersistentFlags().StringVar(&exportLockPath, "export-lock", "", "Write all resolved action hashes to a portable lock file")
+	rootCmd.PersistentFlags().StringVar(&importLockPath, "import-lock", "", "Pre-load action hashes from a lock file before resolving")
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "format", "text", "Output format for machine-readable listings: text or json")
+	rootCmd.PersistentFlags().BoolVar(&signCommits, "sign-commits", false, "Sign pinning commits with git commit -S")
+	rootCmd.PersistentFlags().StringVar(&signingKey, "signing-key", "", "Key ID to sign commits with (implies --sign-commits)")
+	rootCmd.PersistentFlags().BoolVar(&createIssues, "create-issues", false, "Open an issue listing the actions to pin instead of forking repositories without write access")
+	rootCmd.PersistentFlags().StringVar(&forkVisibility, "fork-visibility", "", "Only fork repositories without write access when the fork gets this visibility: public or private. GitHub forks take the upstream visibility, so other repositories fail instead of being forked")
+	rootCmd.PersistentFlags().StringVar(&syncForkStrategy, "sync-fork-strategy", "api", "How forks are synced with upstream before patching: api, gh-sync or none")
+	rootCmd.PersistentFlags().IntVar(&forkSyncAttempts, "retry-fork-sync", 3, "Attempts to sync a fork with upstream before continuing with a warning")
+	rootCmd.PersistentFlags().DurationVar(&forkSyncDelay, "fork-sync-delay", 5*time.Second, "Delay between fork sync attempts")
+	rootCmd.PersistentFlags().StringVar(&baseBranch, "base-branch", "", "Target branch for pinning PRs instead of the repository default branch")
+	rootCmd.PersistentFlags().StringVar(&summaryFile, "summary-file", "", "Write a Markdown summary of organization and file runs to this path")
+	rootCmd.PersistentFlags().StringVar(&githubEnvFile, "github-actions-env-file", "", "Append GHA_PINNER_TOTAL_PINNED, GHA_PINNER_TOTAL_REPOS and GHA_PINNER_SUCCESS to this file, e.g. $GITHUB_ENV or $GITHUB_OUTPUT")
+	rootCmd.PersistentFlags().BoolVar(&groupByAction, "group-by-action", false, "Summarize organization and file runs per action: how many repositories each was pinned or unresolvable in")
+	rootCmd.PersistentFlags().StringVar(&notifySlackURL, "notify-slack", "", "Slack incoming webhook URL to post a summary to after organization processing")
+	rootCmd.PersistentFlags().DurationVar(&networkTimeout, "network-timeout", 0, "Timeout for each GitHub API call, e.g. 30s (0 disables)")
+	rootCmd.PersistentFlags().StringVar(&apiCacheDir, "api-cache-dir", "", "Directory for cached GitHub API responses used during action resolution (default ~/.cache/gha-pinner/api)")
+	rootCmd.PersistentFlags().DurationVar(&apiCacheTTL, "api-cache-ttl", time.Hour, "How long cached GitHub API responses stay fresh")
+	rootCmd.PersistentFlags().BoolVar(&useMetadataCache, "action-metadata-cache", true, "Reuse action repository metadata (stars, archived, activity) across workflow files and repositories within a run")
+	rootCmd.PersistentFlags().DurationVar(&actionMetadataTTL, "action-metadata-ttl", 0, "How long cached action repository metadata stays fresh within a run, e.g. 10m (0 keeps it for the whole run)")
+	rootCmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "Disable the on-disk GitHub API response cache")
+	rootCmd.PersistentFlags().IntVar(&maxRetries, "max-retries", 0, "Retry a timed-out read-only GitHub API call up to this many times")
+	rootCmd.PersistentFlags().StringSliceVar(&ignoreJobs, "ignore-jobs", []string{}, "Comma-separated job IDs to leave untouched; supports wildcards, e.g. codeql-*")
+	rootCmd.PersistentFlags().BoolVar(&ignoreCompositeRefs, "ignore-composite-action-refs", false, "Only pin actions in workflow files; leave composite actions under .github/actions untouched")
+	rootCmd.PersistentFlags().BoolVar(&commitVerification, "commit-verification", false, "Confirm each API-resolved hash is a reachable commit in the action repository, falling back to a clone otherwise")
+	rootCmd.PersistentFlags().StringVar(&maxCommitAgeRaw, "max-commit-age", "", "Skip pinning actions whose latest commit is older than this age, e.g. 730d")
+	rootCmd.PersistentFlags().IntVar(&requireMinStars, "require-min-stars", 0, "Leave actions from repositories with fewer stars than this unpinned for manual review (actions/* is exempt)")
+	rootCmd.PersistentFlags().StringSliceVar(&trustedOrgs, "trusted-orgs", []string{}, "Comma-separated organizations whose actions skip --require-min-stars, --max-commit-age and --check-action-license (default: trusted_orgs from the config file)")
+	rootCmd.PersistentFlags().BoolVar(&ignoreUnresolvable, "ignore-unresolvable", false, "Leave actions that cannot be resolved unchanged without a TODO comment (logged with --debug)")
+	rootCmd.PersistentFlags().BoolVar(&failOnUnresolvable, "fail-on-unresolvable", false, "Fail the workflow file when any action cannot be resolved to a commit hash")
+	rootCmd.PersistentFlags().BoolVar(&checkLicense, "check-action-license", false, "Warn (rule GHA009) about actions whose repository license is not in the allowed list; never blocks pinning")
+	rootCmd.PersistentFlags().StringSliceVar(&allowedLicenses, "allowed-licenses", []string{}, "Comma-separated SPDX license IDs allowed by --check-action-license (default: OSI-approved licenses)")
+	rootCmd.PersistentFlags().BoolVar(&checkSigstoreLog, "check-sigstore", false, "Look up each pinned commit in the sigstore (Rekor) transparency log and note the result in the pin comment")
+	rootCmd.PersistentFlags().StringVar(&commentLanguage, "comment-language", i18n.DefaultLanguage, fmt.Sprintf("Language of the version comment added to pinned actions (%s)", strings.Join(i18n.Languages(), ", ")))
+	rootCmd.PersistentFlags().BoolVar(&requireHashComment, "require-hash-comment", false, "Treat actions pinned to a commit hash without a version comment as needing an update, and add the tag that points at the hash")
+	rootCmd.PersistentFlags().BoolVar(&strictSemver, "strict-semver", false, "Reject action versions that are not full semver tags such as v3.2.1 (rule GHA008) instead of pinning them")
+	rootCmd.PersistentFlags().StringSliceVar(&minActionVersionRaw, "min-action-version", []string{}, "Comma-separated action=version minimums, e.g. actions/checkout=v3,actions/setup-node=v4; older references are flagged instead of pinned (default: min_action_version from the config file)")
+	rootCmd.PersistentFlags().IntVar(&minWorkflowSize, "min-workflow-size", 0, "Skip workflow files with fewer than this many non-blank, non-comment lines (0 disables)")
+	rootCmd.PersistentFlags().BoolVar(&preserveMtime, "preserve-mtime", false, "Restore the original modification time of workflow files after patching them")
+	rootCmd.PersistentFlags().BoolVar(&addSecurityComments, "add-security-comments", false, "Add a comment above the first key of each modified workflow explaining why actions are pinned to commit hashes")
+	rootCmd.PersistentFlags().StringVar(&securityCommentText, "security-comment-text", defaultSecurityComment, "Comment text added by --add-security-comments")
+	rootCmd.PersistentFlags().StringVar(&maxPRAgeRaw, "max-pr-age", "", "Skip repositories with an open pinning PR newer than this age, e.g. 7d or 36h")
+	rootCmd.PersistentFlags().IntVar(&maxWorkflowFiles, "max-workflow-files", 0, "Process only the first N workflow files of each repository, in alphabetical order (0 means all)")
+	rootCmd.PersistentFlags().IntVar(&maxFilesPerPR, "max-files-per-pr", 0, "Split changes across several branches and PRs of at most this many files each (0 means a single PR)")
+	rootCmd.PersistentFlags().StringVar(&prCloseStaleRaw, "pr-close-stale", "", "Before processing, close open pinning PRs older than this age with a comment, e.g. 30d")
+	rootCmd.PersistentFlags().StringSliceVar(&prLabels, "label", []string{}, "Add this label to created PRs (repeatable)")
+	rootCmd.PersistentFlags().StringVar(&prSearchLabel, "pr-search-label", "", "Only treat open PRs carrying this label as existing pinning PRs, e.g. gha-pinner")
+	rootCmd.PersistentFlags().BoolVar(&prAssigneeFromBlame, "pr-assignee-from-blame", false, "Assign created PRs to the GitHub users who last committed to each modified workflow file")
+	rootCmd.PersistentFlags().BoolVar(&scorecardDelta, "annotate-pr-with-scorecard-delta", false, "Add the estimated OpenSSF Scorecard pinned-actions score before and after the changes to the PR body")
+	rootCmd.PersistentFlags().StringVar(&prMilestone, "pr-milestone", "", "Assign created PRs to this milestone (must exist unless --create-milestone is set)")
+	rootCmd.PersistentFlags().BoolVar(&createMilestone, "create-milestone", false, "Create the --pr-milestone milestone in the target repository if it does not exist")
+	rootCmd.PersistentFlags().StringVar(&prBodyFile, "pr-body-file", "", "Read the PR body from a file (supports {{.Repo}}, {{.PinnedCount}}, {{.ActionsList}})")
+	rootCmd.PersistentFlags().StringVar(&prTemplateFile, "pr-template-file", "", "Render the PR body from a Go template file (supports {{.Repo}}, {{.PinnedActions}}, {{.TotalPinned}}, {{.WorkflowFiles}})")
+	rootCmd.PersistentFlags().StringVar(&messageTemplateRaw, "message-template", "", "Go template for the commit message (supports {{.Repo}}, {{.PinnedCount}}, {{.Files}}, {{.Date}})")
+	rootCmd.PersistentFlags().StringVar(&commitTemplateFile, "commit-template-file", "", "Read the commit message template from a file (--message-template takes precedence)")
+
+	localRepoCmd := &cobra.Command{
+		Use:   "local-repository <path>",
+		Short: "Pin actions in a local repository",
+		Example: `  # Pin actions in place
+  gha-pinner local-repository ./my-repo
+
+  # Pre-commit hook: list files with unpinned actions and fail if there are any
+  gha-pinner local-repository . --check
+
+  # Resolve every unpinned action reference
+  gha-pinner local-repository . --list-unpinned | tr '@' ' ' | xargs -n2 gha-pinner action`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) (err error) {
+			if checkOnly {
+				return checkLocalRepository(args[0])
+			}
+			if onlyCheckPinned {
+				return checkPinnedRegressions(args[0])
+			}
+			if listUnpinned {
+				return listUnpinnedActions(args[0])
+			}
+			if listAlreadyPinned {
+				return listPinnedActions(args[0])
+			}
+			if exportActionsPath != "" {
+				return exportActionsList(args[0], exportActionsPath)
+			}
+			startTime := time.Now()
+			defer logExecutionTime(startTime)
+			defer func() { runCleanup(err) }()
+			summary, err := patchLocalRepository(args[0])
+			if err != nil {
+				return err
+			}
+			if commitTemplate != nil && !skipPRCreation {
+				return commitLocalChanges(args[0], summary)
+			}
+			return nil
+		},
+	}
+	localRepoCmd.Flags().BoolVar(&checkOnly, "check", false, "Read-only audit: print files with unpinned actions and exit 1 if any are found")
+	localRepoCmd.Flags().BoolVar(&onlyCheckPinned, "only-check-pinned", false, "Fail if an action pinned at the base commit has been de-pinned; makes no network calls")
+	localRepoCmd.Flags().StringVar(&checkBaseRef, "base-ref", "", "With --only-check-pinned, compare against the merge base of this ref and HEAD, e.g. origin/main (default HEAD)")
+	localRepoCmd.Flags().BoolVar(&listUnpinned, "list-unpinned", false, "Print the sorted, de-duplicated list of unpinned action references and exit 1 if any are found")
+	localRepoCmd.Flags().StringVar(&exportActionsPath, "export-actions-list", "", "Write the sorted, de-duplicated list of all action references, pinned or not, to this file")
+	localRepoCmd.Flags().BoolVar(&listAlreadyPinned, "list-already-pinned", false, "Print the sorted, de-duplicated inventory of actions already pinned to a commit hash")
+
+	repoCmd := &cobra.Command{
+		Use:   "repository <owner/repo>",
+		Short: "Pin actions in a remote repository",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) (err error) {
+			startTime := time.Now()
+			defer logExecutionTime(startTime)
+			defer func() { runCleanup(err) }()
+			return processRepository(args[0])
+		},
+	}
+	// --open-pr is deliberately only offered for single-repository runs so that
+	// organization or file runs cannot open dozens of browser tabs.
+	repoCmd.Flags().BoolVar(&openPR, "open-pr", false, "Open the created pull request in the browser")
+
+	migrateCmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Migrate existing update tooling configuration to pinned actions",
+	}
+	migrateCmd.AddCommand(&cobra.Command{
+		Use:   "dependabot <path>",
+		Short: "Pin actions in the directories covered by github-actions entries in .github/dependabot.yml",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) (err error) {
+			startTime := time.Now()
+			defer logExecutionTime(startTime)
+			defer func() { runCleanup(err) }()
+			return migrateDependabot(args[0])
+		},
+	})
 
-	rootCmd.AddCommand(
-		&cobra.Command{
-			Use:   "local-repository <path>",
-			Short: "Pin actions in a local repository",
-			Args:  cobra.ExactArgs(1),
-			RunE: func(_ *cobra.Command, args []string) error {
-				startTime := time.Now()
-				defer logExecutionTime(startTime)
-				defer runCleanup()
-				return patchLocalRepository(args[0])
-			},
+	watchCmd := &cobra.Command{
+		Use:   "watch <path>",
+		Short: "Pin actions in a local repository's workflows as they are created or modified",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			if watchDebounce < 0 {
+				return fmt.Errorf("--debounce must be >= 0")
+			}
+			defer func() { runCleanup(err) }()
+			return watchRepository(cmd.Context(), args[0], watchDebounce)
 		},
-		&cobra.Command{
-			Use:   "repository <owner/repo>",
-			Short: "Pin actions in a remote repository",
-			Args:  cobra.ExactArgs(1),
-			RunE: func(_ *cobra.Command, args []string) error {
-				startTime := time.Now()
-				defer logExecutionTime(startTime)
-				defer runCleanup()
-				return processRepository(args[0])
-			},
+	}
+	watchCmd.Flags().DurationVar(&watchDebounce, "debounce", 2*time.Second, "Wait until a workflow file has been unchanged this long before processing it")
+
+	var pruneOlderThan, pruneAction string
+	var pruneAll bool
+	pruneCacheCmd := &cobra.Command{
+		Use:   "prune-cache",
+		Short: "Remove stale entries from the on-disk GitHub API cache",
+		Example: `  gha-pinner prune-cache --older-than 30d
+  gha-pinner prune-cache --action actions/checkout
+  gha-pinner prune-cache --all`,
+		Args: cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if !pruneAll && pruneOlderThan == "" && pruneAction == "" {
+				return fmt.Errorf("prune-cache requires --older-than, --action or --all")
+			}
+			if pruneAll && (pruneOlderThan != "" || pruneAction != "") {
+				return fmt.Errorf("--all cannot be combined with --older-than or --action")
+			}
+			var olderThan time.Duration
+			if pruneOlderThan != "" {
+				age, err := parseDayDuration(pruneOlderThan)
+				if err != nil || age <= 0 {
+					return fmt.Errorf("invalid --older-than value %q (examples: 30d, 12h)", pruneOlderThan)
+				}
+				olderThan = age
+			}
+			dir := resolvedAPICacheDir()
+			removed, err := pruneAPICache(dir, olderThan, strings.TrimSpace(pruneAction), pruneAll, time.Now())
+			if err != nil {
+				return err
+			}
+			fmt.Printf("🧹 Removed %d cached API response(s) from %s\n", removed, dir)
+			return nil
 		},
-		&cobra.Command{
-			Use:   "organization <org>",
-			Short: "Pin actions in repositories across an organization",
-			Args:  cobra.ExactArgs(1),
-			RunE: func(_ *cobra.Command, args []string) error {
-				startTime := time.Now()
-				defer logExecutionTime(startTime)
-				defer runCleanup()
-				return processOrganization(args[0])
-			},
+	}
+	pruneCacheCmd.Flags().StringVar(&pruneOlderThan, "older-than", "", "Remove entries fetched longer ago than this age, e.g. 30d")
+	pruneCacheCmd.Flags().StringVar(&pruneAction, "action", "", "Remove entries for this action's repository, e.g. actions/checkout")
+	pruneCacheCmd.Flags().BoolVar(&pruneAll, "all", false, "Remove every cache entry")
+
+	updateIndexCmd := &cobra.Command{
+		Use:   "update-index",
+		Short: "Download the pre-built action hash index used before any API resolution",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			startTime := time.Now()
+			defer logExecutionTime(startTime)
+			return updateActionIndex(indexURL)
 		},
-		&cobra.Command{
-			Use:   "file <path-to-repos-file>",
-			Short: "Pin actions in repositories listed in a file",
-			Args:  cobra.ExactArgs(1),
-			RunE: func(_ *cobra.Command, args []string) error {
-				startTime := time.Now()
-				defer logExecutionTime(startTime)
-				defer runCleanup()
-				return processRepositoryFile(args[0])
-			},
+	}
+	updateIndexCmd.Flags().StringVar(&indexURL, "index-url", "", "URL to download the index from (defaults to index_url in the config file)")
+
+	fileCmd := &cobra.Command{
+		Use:   "file [path-to-repos-file]",
+		Short: "Pin actions in repositories listed in a file or passed with --target-repos",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(_ *cobra.Command, args []string) (err error) {
+			if len(args) == 0 && len(targetRepos) == 0 {
+				return fmt.Errorf("file requires a repos file path or --target-repos")
+			}
+			startTime := time.Now()
+			defer logExecutionTime(startTime)
+			defer func() { runCleanup(err) }()
+			if len(args) == 0 {
+				return processRepoList(strings.NewReader(strings.Join(targetRepos, "\n")), "--target-repos")
+			}
+			return processRepositoryFile(args[0])
+		},
+	}
+	fileCmd.Flags().BoolVar(&noEnvExpand, "no-env-expand", false, "Do not expand ${VAR} environment variable references in repository entries")
+	fileCmd.Flags().StringArrayVar(&excludePatternsRaw, "exclude-pattern", []string{}, "Skip repositories whose owner/repo name matches this Go regex (repeatable)")
+	fileCmd.Flags().StringArrayVar(&includePatternsRaw, "include-pattern", []string{}, "Only process repositories whose owner/repo name matches this Go regex (repeatable)")
+	fileCmd.Flags().IntVar(&repoBatchSize, "batch-size", 0, "Process repositories in sequential batches of this size, pausing --batch-cooldown between batches (0 = one batch)")
+	fileCmd.Flags().DurationVar(&repoBatchCooldown, "batch-cooldown", 0, "Pause between --batch-size batches, e.g. 60s")
+	fileCmd.Flags().StringSliceVar(&targetRepos, "target-repos", []string{}, "Comma-separated repositories to process, e.g. owner/repo1,owner/repo2 (repeatable)")
+
+	orgCmd := &cobra.Command{
+		Use:   "organization <org>",
+		Short: "Pin actions in repositories across an organization",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) (err error) {
+			startTime := time.Now()
+			defer logExecutionTime(startTime)
+			defer func() { runCleanup(err) }()
+			return processOrganization(args[0])
 		},
+	}
+	orgCmd.Flags().BoolVar(&skipPrivateRepos, "skip-private-repos", false, "Only process public repositories (skips private and internal ones)")
+	orgCmd.Flags().BoolVar(&onlyPrivateRepos, "only-private-repos", false, "Only process private and internal repositories")
+	orgCmd.Flags().StringSliceVar(&repoTopicsFilter, "repo-topics-filter", []string{}, "Only process repositories with one of these GitHub topics (repeatable or comma-separated)")
+	orgCmd.Flags().StringSliceVar(&repoTopicsExclude, "repo-topics-exclude", []string{}, "Skip repositories with any of these GitHub topics (repeatable or comma-separated)")
+	orgCmd.Flags().BoolVar(&skipPinnedRepos, "skip-already-pinned-repos", false, "Read each repository's workflow files through the API first and skip cloning repositories whose actions are all pinned")
+	orgCmd.Flags().BoolVar(&trackingIssue, "create-tracking-issue", false, "Create or update one issue in --tracking-issue-repo listing every processed repository, its pinning status and PR")
+	orgCmd.Flags().StringVar(&trackingIssueRepo, "tracking-issue-repo", "", "Repository (owner/repo) that holds the --create-tracking-issue issue")
+
+	rootCmd.AddCommand(
+		localRepoCmd,
+		repoCmd,
+		orgCmd,
+		fileCmd,
 		&cobra.Command{
-			Use:   "action <action-name> <version>",
-			Short: "Resolve an action version to a commit hash",
-			Args:  cobra.ExactArgs(2),
+			Use:   "action <action-name> <version> [version...]",
+			Short: "Resolve one or more action versions to commit hashes",
+			Example: `  gha-pinner action actions/checkout v4
+  gha-pinner action actions/checkout v3 v4 v2`,
+			Args: cobra.MinimumNArgs(2),
 			RunE: func(_ *cobra.Command, args []string) error {
 				startTime := time.Now()
 				defer logExecutionTime(startTime)
-				return resolveVersion(args[0], args[1])
+				if len(args) == 2 {
+					return resolveVersion(args[0], args[1])
+				}
+				return resolveVersions(args[0], args[1:])
 			},
 		},
 		&cobra.Command{
@@ -196,6 +841,10 @@ func newRootCmd() *cobra.Command {
 				return switchAccount(args[0])
 			},
 		},
+		migrateCmd,
+		watchCmd,
+		pruneCacheCmd,
+		updateIndexCmd,
 	)
 
 	return rootCmd
@@ -213,6 +862,41 @@ func applyGlobalFlagsFromCmd(cmd *cobra.Command) {
 			ignorePRTemplates = val
 		}
 	}
+	if flags.Lookup("no-push") != nil {
+		if val, err := flags.GetBool("no-push"); err == nil {
+			noPush = val
+		}
+	}
+	if flags.Lookup("skip-if-no-workflows") != nil {
+		if val, err := flags.GetBool("skip-if-no-workflows"); err == nil {
+			skipIfNoWorkflows = val
+		}
+	}
+	if flags.Lookup("no-cleanup") != nil {
+		if val, err := flags.GetBool("no-cleanup"); err == nil {
+			noCleanup = val
+		}
+	}
+	if flags.Lookup("cleanup-on-success-only") != nil {
+		if val, err := flags.GetBool("cleanup-on-success-only"); err == nil {
+			cleanupOnSuccessOnly = val
+		}
+	}
+	if flags.Lookup("ignore-missing-default-branch") != nil {
+		if val, err := flags.GetBool("ignore-missing-default-branch"); err == nil {
+			skipEmptyRepos = val
+		}
+	}
+	if flags.Lookup("fail-fast") != nil {
+		if val, err := flags.GetBool("fail-fast"); err == nil {
+			failFast = val
+		}
+	}
+	if flags.Lookup("force-overwrite") != nil {
+		if val, err := flags.GetBool("force-overwrite"); err == nil {
+			forceOverwrite = val
+		}
+	}
 	if flags.Lookup("no-pr") != nil {
 		if val, err := flags.GetBool("no-pr"); err == nil {
 			skipPRCreation = val
@@ -223,11 +907,31 @@ func applyGlobalFlagsFromCmd(cmd *cobra.Command) {
 			outputDir = val
 		}
 	}
+	if flags.Lookup("output-dir-structure") != nil {
+		if val, err := flags.GetString("output-dir-structure"); err == nil {
+			outputDirStructure = strings.ToLower(strings.TrimSpace(val))
+		}
+	}
 	if flags.Lookup("auth-mode") != nil {
 		if val, err := flags.GetString("auth-mode"); err == nil {
 			authMode = strings.ToLower(strings.TrimSpace(val))
 		}
 	}
+	if flags.Lookup("github-token-env") != nil {
+		if val, err := flags.GetString("github-token-env"); err == nil {
+			githubTokenEnv = strings.TrimSpace(val)
+		}
+	}
+	if flags.Lookup("github-app-id") != nil {
+		if val, err := flags.GetInt("github-app-id"); err == nil {
+			githubAppID = val
+		}
+	}
+	if flags.Lookup("github-app-key-file") != nil {
+		if val, err := flags.GetString("github-app-key-file"); err == nil {
+			githubAppKeyFile = strings.TrimSpace(val)
+		}
+	}
 	if flags.Lookup("repo-workers") != nil {
 		if val, err := flags.GetInt("repo-workers"); err == nil {
 			repoWorkers = val
@@ -243,6 +947,136 @@ func applyGlobalFlagsFromCmd(cmd *cobra.Command) {
 			egressPolicy = strings.ToLower(strings.TrimSpace(val))
 		}
 	}
+	if flags.Lookup("gitconfig-file") != nil {
+		if val, err := flags.GetString("gitconfig-file"); err == nil {
+			gitConfigFile = strings.TrimSpace(val)
+		}
+	}
+	if flags.Lookup("workflow-env-vars") != nil {
+		if vals, err := flags.GetStringSlice("workflow-env-vars"); err == nil {
+			gitEnvVars = nil
+			for _, v := range vals {
+				if v = strings.TrimSpace(v); v != "" {
+					gitEnvVars = append(gitEnvVars, v)
+				}
+			}
+		}
+	}
+	if flags.Lookup("prefer-api") != nil {
+		if val, err := flags.GetBool("prefer-api"); err == nil {
+			preferAPI = val
+		}
+	}
+	if flags.Lookup("prefer-clone") != nil {
+		if val, err := flags.GetBool("prefer-clone"); err == nil {
+			preferClone = val
+		}
+	}
+	if flags.Lookup("api-only") != nil {
+		if val, err := flags.GetBool("api-only"); err == nil {
+			apiOnly = val
+		}
+	}
+	if flags.Lookup("clone-only") != nil {
+		if val, err := flags.GetBool("clone-only"); err == nil {
+			cloneOnly = val
+		}
+	}
+	if flags.Lookup("force-clone") != nil {
+		if val, err := flags.GetBool("force-clone"); err == nil {
+			forceClone = val
+		}
+	}
+	if flags.Lookup("tag-annotation") != nil {
+		if val, err := flags.GetBool("tag-annotation"); err == nil {
+			tagAnnotation = val
+		}
+	}
+	if flags.Lookup("clone-depth") != nil {
+		if val, err := flags.GetInt("clone-depth"); err == nil {
+			cloneDepth = val
+		}
+	}
+	if flags.Lookup("label") != nil {
+		if val, err := flags.GetStringSlice("label"); err == nil {
+			prLabels = nil
+			for _, label := range val {
+				if label = strings.TrimSpace(label); label != "" {
+					prLabels = append(prLabels, label)
+				}
+			}
+		}
+	}
+	if flags.Lookup("pr-search-label") != nil {
+		if val, err := flags.GetString("pr-search-label"); err == nil {
+			prSearchLabel = strings.TrimSpace(val)
+		}
+	}
+	if flags.Lookup("pr-assignee-from-blame") != nil {
+		if val, err := flags.GetBool("pr-assignee-from-blame"); err == nil {
+			prAssigneeFromBlame = val
+		}
+	}
+	if flags.Lookup("annotate-pr-with-scorecard-delta") != nil {
+		if val, err := flags.GetBool("annotate-pr-with-scorecard-delta"); err == nil {
+			scorecardDelta = val
+		}
+	}
+	if flags.Lookup("pr-milestone") != nil {
+		if val, err := flags.GetString("pr-milestone"); err == nil {
+			prMilestone = strings.TrimSpace(val)
+		}
+	}
+	if flags.Lookup("create-milestone") != nil {
+		if val, err := flags.GetBool("create-milestone"); err == nil {
+			createMilestone = val
+		}
+	}
+	if flags.Lookup("fix-latest") != nil {
+		if val, err := flags.GetBool("fix-latest"); err == nil {
+			fixLatest = val
+		}
+	}
+	if flags.Lookup("report-permissions-issues") != nil {
+		if val, err := flags.GetBool("report-permissions-issues"); err == nil {
+			reportPermissions = val
+		}
+	}
+	if flags.Lookup("detect-injection") != nil {
+		if val, err := flags.GetBool("detect-injection"); err == nil {
+			detectInjection = val
+		}
+	}
+	if flags.Lookup("verify-workflow-syntax") != nil {
+		if val, err := flags.GetBool("verify-workflow-syntax"); err == nil {
+			verifyWorkflowSyntax = val
+		}
+	}
+	if flags.Lookup("workflow-schema-url") != nil {
+		if val, err := flags.GetString("workflow-schema-url"); err == nil {
+			workflowSchemaURL = strings.TrimSpace(val)
+		}
+	}
+	if flags.Lookup("detect-workflow-dispatch-defaults") != nil {
+		if val, err := flags.GetBool("detect-workflow-dispatch-defaults"); err == nil {
+			detectDispatchInputs = val
+		}
+	}
+	if flags.Lookup("detect-third-party-actions") != nil {
+		if val, err := flags.GetBool("detect-third-party-actions"); err == nil {
+			detectThirdParty = val
+		}
+	}
+	if flags.Lookup("add-permissions-block") != nil {
+		if val, err := flags.GetBool("add-permissions-block"); err == nil {
+			addPermissions = val
+		}
+	}
+	if flags.Lookup("require-permissions-block") != nil {
+		if val, err := flags.GetBool("require-permissions-block"); err == nil {
+			requirePermissions = val
+		}
+	}
 	if flags.Lookup("pin-runners") != nil {
 		if val, err := flags.GetBool("pin-runners"); err == nil {
 			pinRunners = val
@@ -260,1219 +1094,5476 @@ func applyGlobalFlagsFromCmd(cmd *cobra.Command) {
 			}
 		}
 	}
-}
-
-func validateRuntimeConfig() error {
-	if authMode != "gh" && authMode != "pat" {
-		return fmt.Errorf("invalid --auth-mode value %q (allowed: gh, pat)", authMode)
+	if flags.Lookup("action-prefix-map") != nil {
+		if vals, err := flags.GetStringArray("action-prefix-map"); err == nil {
+			actionPrefixMapRaw = vals
+		}
 	}
-
-	if authMode == "pat" {
-		githubToken = strings.TrimSpace(os.Getenv("GITHUB_TOKEN"))
-		if githubToken == "" {
-			githubToken = strings.TrimSpace(os.Getenv("GH_TOKEN"))
+	if flags.Lookup("config-override") != nil {
+		if vals, err := flags.GetStringArray("config-override"); err == nil {
+			configOverridesRaw = vals
 		}
-		if githubToken == "" {
-			return fmt.Errorf("--auth-mode pat requires GITHUB_TOKEN or GH_TOKEN environment variable")
+	}
+	if flags.Lookup("verbose") != nil {
+		if val, err := flags.GetBool("verbose"); err == nil {
+			verbose = val
 		}
 	}
-
-	if repoWorkers < 1 {
-		return fmt.Errorf("--repo-workers must be >= 1")
+	if flags.Lookup("co-author") != nil {
+		if vals, err := flags.GetStringArray("co-author"); err == nil {
+			coAuthorsRaw = vals
+		}
 	}
-
-	if injectHardenRunner && egressPolicy != "audit" && egressPolicy != "block" {
-		return fmt.Errorf("invalid --egress-policy value %q (allowed: audit, block)", egressPolicy)
+	if flags.Lookup("co-authorship-file") != nil {
+		if val, err := flags.GetString("co-authorship-file"); err == nil {
+			coAuthorshipFile = strings.TrimSpace(val)
+		}
 	}
-
-	return nil
-}
-
-func initLogger() error {
-	if logger != nil {
-		_ = logger.Sync()
+	if flags.Lookup("export-lock") != nil {
+		if val, err := flags.GetString("export-lock"); err == nil {
+			exportLockPath = strings.TrimSpace(val)
+		}
 	}
-
-	var l *zap.Logger
-	var err error
-	if debug {
-		l, err = zap.NewDevelopment()
-	} else {
-		l, err = zap.NewProduction()
+	if flags.Lookup("check") != nil {
+		if val, err := flags.GetBool("check"); err == nil {
+			checkOnly = val
+		}
 	}
-	if err != nil {
-		return fmt.Errorf("failed to initialize logger: %w", err)
+	if flags.Lookup("only-check-pinned") != nil {
+		if val, err := flags.GetBool("only-check-pinned"); err == nil {
+			onlyCheckPinned = val
+		}
 	}
-	logger = l.Sugar()
-	return nil
-}
-
-func logExecutionTime(startTime time.Time) {
-	if debug {
-		logger.Infow("command completed", "elapsed", time.Since(startTime).String())
-		fmt.Printf("Total execution time: %v\n", time.Since(startTime))
+	if flags.Lookup("base-ref") != nil {
+		if val, err := flags.GetString("base-ref"); err == nil {
+			checkBaseRef = strings.TrimSpace(val)
+		}
 	}
-}
-
-func runCleanup() {
-	if err := cleanup(); err != nil {
-		logger.Warnw("cleanup completed with errors", "error", err)
-		fmt.Fprintf(os.Stderr, "Warning: cleanup encountered errors: %v\n", err)
+	if flags.Lookup("debounce") != nil {
+		if val, err := flags.GetDuration("debounce"); err == nil {
+			watchDebounce = val
+		}
 	}
-}
-
-func switchAccount(username string) error {
-	if authMode == "pat" {
+	if flags.Lookup("list-unpinned") != nil {
+		if val, err := flags.GetBool("list-unpinned"); err == nil {
+			listUnpinned = val
+		}
+	}
+	if flags.Lookup("export-actions-list") != nil {
+		if val, err := flags.GetString("export-actions-list"); err == nil {
+			exportActionsPath = strings.TrimSpace(val)
+		}
+	}
+	if flags.Lookup("list-already-pinned") != nil {
+		if val, err := flags.GetBool("list-already-pinned"); err == nil {
+			listAlreadyPinned = val
+		}
+	}
+	if flags.Lookup("format") != nil {
+		if val, err := flags.GetString("format"); err == nil {
+			outputFormat = strings.ToLower(strings.TrimSpace(val))
+		}
+	}
+	if flags.Lookup("sign-commits") != nil {
+		if val, err := flags.GetBool("sign-commits"); err == nil {
+			signCommits = val
+		}
+	}
+	if flags.Lookup("signing-key") != nil {
+		if val, err := flags.GetString("signing-key"); err == nil {
+			signingKey = strings.TrimSpace(val)
+			if signingKey != "" {
+				signCommits = true
+			}
+		}
+	}
+	if flags.Lookup("create-issues") != nil {
+		if val, err := flags.GetBool("create-issues"); err == nil {
+			createIssues = val
+		}
+	}
+	if flags.Lookup("fork-visibility") != nil {
+		if val, err := flags.GetString("fork-visibility"); err == nil {
+			forkVisibility = strings.ToLower(strings.TrimSpace(val))
+		}
+	}
+	if flags.Lookup("sync-fork-strategy") != nil {
+		if val, err := flags.GetString("sync-fork-strategy"); err == nil {
+			syncForkStrategy = strings.ToLower(strings.TrimSpace(val))
+		}
+	}
+	if flags.Lookup("retry-fork-sync") != nil {
+		if val, err := flags.GetInt("retry-fork-sync"); err == nil {
+			forkSyncAttempts = val
+		}
+	}
+	if flags.Lookup("fork-sync-delay") != nil {
+		if val, err := flags.GetDuration("fork-sync-delay"); err == nil {
+			forkSyncDelay = val
+		}
+	}
+	if flags.Lookup("base-branch") != nil {
+		if val, err := flags.GetString("base-branch"); err == nil {
+			baseBranch = strings.TrimSpace(val)
+		}
+	}
+	if flags.Lookup("pr-template-file") != nil {
+		if val, err := flags.GetString("pr-template-file"); err == nil {
+			prTemplateFile = strings.TrimSpace(val)
+		}
+	}
+	if flags.Lookup("pr-body-file") != nil {
+		if val, err := flags.GetString("pr-body-file"); err == nil {
+			prBodyFile = strings.TrimSpace(val)
+		}
+	}
+	if flags.Lookup("message-template") != nil {
+		if val, err := flags.GetString("message-template"); err == nil {
+			messageTemplateRaw = val
+		}
+	}
+	if flags.Lookup("commit-template-file") != nil {
+		if val, err := flags.GetString("commit-template-file"); err == nil {
+			commitTemplateFile = strings.TrimSpace(val)
+		}
+	}
+	if flags.Lookup("skip-private-repos") != nil {
+		if val, err := flags.GetBool("skip-private-repos"); err == nil {
+			skipPrivateRepos = val
+		}
+	}
+	if flags.Lookup("only-private-repos") != nil {
+		if val, err := flags.GetBool("only-private-repos"); err == nil {
+			onlyPrivateRepos = val
+		}
+	}
+	if flags.Lookup("repo-topics-filter") != nil {
+		if vals, err := flags.GetStringSlice("repo-topics-filter"); err == nil {
+			repoTopicsFilter = vals
+		}
+	}
+	if flags.Lookup("repo-topics-exclude") != nil {
+		if vals, err := flags.GetStringSlice("repo-topics-exclude"); err == nil {
+			repoTopicsExclude = vals
+		}
+	}
+	if flags.Lookup("skip-already-pinned-repos") != nil {
+		if val, err := flags.GetBool("skip-already-pinned-repos"); err == nil {
+			skipPinnedRepos = val
+		}
+	}
+	if flags.Lookup("create-tracking-issue") != nil {
+		if val, err := flags.GetBool("create-tracking-issue"); err == nil {
+			trackingIssue = val
+		}
+	}
+	if flags.Lookup("tracking-issue-repo") != nil {
+		if val, err := flags.GetString("tracking-issue-repo"); err == nil {
+			trackingIssueRepo = strings.TrimSpace(val)
+		}
+	}
+	if flags.Lookup("target-repos") != nil {
+		if vals, err := flags.GetStringSlice("target-repos"); err == nil {
+			targetRepos = vals
+		}
+	}
+	if flags.Lookup("exclude-pattern") != nil {
+		if vals, err := flags.GetStringArray("exclude-pattern"); err == nil {
+			excludePatternsRaw = vals
+		}
+	}
+	if flags.Lookup("include-pattern") != nil {
+		if vals, err := flags.GetStringArray("include-pattern"); err == nil {
+			includePatternsRaw = vals
+		}
+	}
+	if flags.Lookup("batch-size") != nil {
+		if val, err := flags.GetInt("batch-size"); err == nil {
+			repoBatchSize = val
+		}
+	}
+	if flags.Lookup("batch-cooldown") != nil {
+		if val, err := flags.GetDuration("batch-cooldown"); err == nil {
+			repoBatchCooldown = val
+		}
+	}
+	if flags.Lookup("no-env-expand") != nil {
+		if val, err := flags.GetBool("no-env-expand"); err == nil {
+			noEnvExpand = val
+		}
+	}
+	if flags.Lookup("commit-verification") != nil {
+		if val, err := flags.GetBool("commit-verification"); err == nil {
+			commitVerification = val
+		}
+	}
+	if flags.Lookup("max-commit-age") != nil {
+		if val, err := flags.GetString("max-commit-age"); err == nil {
+			maxCommitAgeRaw = strings.TrimSpace(val)
+		}
+	}
+	if flags.Lookup("require-min-stars") != nil {
+		if val, err := flags.GetInt("require-min-stars"); err == nil {
+			requireMinStars = val
+		}
+	}
+	if flags.Lookup("ignore-unresolvable") != nil {
+		if val, err := flags.GetBool("ignore-unresolvable"); err == nil {
+			ignoreUnresolvable = val
+		}
+	}
+	if flags.Lookup("fail-on-unresolvable") != nil {
+		if val, err := flags.GetBool("fail-on-unresolvable"); err == nil {
+			failOnUnresolvable = val
+		}
+	}
+	if flags.Lookup("check-action-license") != nil {
+		if val, err := flags.GetBool("check-action-license"); err == nil {
+			checkLicense = val
+		}
+	}
+	if flags.Lookup("allowed-licenses") != nil {
+		if val, err := flags.GetStringSlice("allowed-licenses"); err == nil {
+			allowedLicenses = nil
+			for _, id := range val {
+				if id = strings.TrimSpace(id); id != "" {
+					allowedLicenses = append(allowedLicenses, id)
+				}
+			}
+		}
+	}
+	if flags.Lookup("comment-language") != nil {
+		if val, err := flags.GetString("comment-language"); err == nil {
+			commentLanguage = strings.ToLower(strings.TrimSpace(val))
+		}
+	}
+	if flags.Lookup("require-hash-comment") != nil {
+		if val, err := flags.GetBool("require-hash-comment"); err == nil {
+			requireHashComment = val
+		}
+	}
+	if flags.Lookup("strict-semver") != nil {
+		if val, err := flags.GetBool("strict-semver"); err == nil {
+			strictSemver = val
+		}
+	}
+	if flags.Lookup("min-action-version") != nil {
+		if vals, err := flags.GetStringSlice("min-action-version"); err == nil {
+			minActionVersionRaw = vals
+		}
+	}
+	if flags.Lookup("trusted-orgs") != nil {
+		if val, err := flags.GetStringSlice("trusted-orgs"); err == nil {
+			trustedOrgs = nil
+			for _, org := range val {
+				if org = strings.TrimSpace(org); org != "" {
+					trustedOrgs = append(trustedOrgs, org)
+				}
+			}
+		}
+	}
+	if flags.Lookup("min-workflow-size") != nil {
+		if val, err := flags.GetInt("min-workflow-size"); err == nil {
+			minWorkflowSize = val
+		}
+	}
+	if flags.Lookup("check-sigstore") != nil {
+		if val, err := flags.GetBool("check-sigstore"); err == nil {
+			checkSigstoreLog = val
+		}
+	}
+	if flags.Lookup("preserve-mtime") != nil {
+		if val, err := flags.GetBool("preserve-mtime"); err == nil {
+			preserveMtime = val
+		}
+	}
+	if flags.Lookup("add-security-comments") != nil {
+		if val, err := flags.GetBool("add-security-comments"); err == nil {
+			addSecurityComments = val
+		}
+	}
+	if flags.Lookup("security-comment-text") != nil {
+		if val, err := flags.GetString("security-comment-text"); err == nil {
+			securityCommentText = strings.TrimSpace(val)
+		}
+	}
+	if flags.Lookup("max-pr-age") != nil {
+		if val, err := flags.GetString("max-pr-age"); err == nil {
+			maxPRAgeRaw = strings.TrimSpace(val)
+		}
+	}
+	if flags.Lookup("max-workflow-files") != nil {
+		if val, err := flags.GetInt("max-workflow-files"); err == nil {
+			maxWorkflowFiles = val
+		}
+	}
+	if flags.Lookup("max-files-per-pr") != nil {
+		if val, err := flags.GetInt("max-files-per-pr"); err == nil {
+			maxFilesPerPR = val
+		}
+	}
+	if flags.Lookup("pr-close-stale") != nil {
+		if val, err := flags.GetString("pr-close-stale"); err == nil {
+			prCloseStaleRaw = strings.TrimSpace(val)
+		}
+	}
+	if flags.Lookup("ignore-jobs") != nil {
+		if vals, err := flags.GetStringSlice("ignore-jobs"); err == nil {
+			ignoreJobs = vals
+		}
+	}
+	if flags.Lookup("ignore-composite-action-refs") != nil {
+		if val, err := flags.GetBool("ignore-composite-action-refs"); err == nil {
+			ignoreCompositeRefs = val
+		}
+	}
+	if flags.Lookup("api-cache-dir") != nil {
+		if val, err := flags.GetString("api-cache-dir"); err == nil {
+			apiCacheDir = strings.TrimSpace(val)
+		}
+	}
+	if flags.Lookup("api-cache-ttl") != nil {
+		if val, err := flags.GetDuration("api-cache-ttl"); err == nil {
+			apiCacheTTL = val
+		}
+	}
+	if flags.Lookup("action-metadata-cache") != nil {
+		if val, err := flags.GetBool("action-metadata-cache"); err == nil {
+			useMetadataCache = val
+		}
+	}
+	if flags.Lookup("action-metadata-ttl") != nil {
+		if val, err := flags.GetDuration("action-metadata-ttl"); err == nil {
+			actionMetadataTTL = val
+		}
+	}
+	if flags.Lookup("no-cache") != nil {
+		if val, err := flags.GetBool("no-cache"); err == nil {
+			noCache = val
+		}
+	}
+	if flags.Lookup("network-timeout") != nil {
+		if val, err := flags.GetDuration("network-timeout"); err == nil {
+			networkTimeout = val
+		}
+	}
+	if flags.Lookup("max-retries") != nil {
+		if val, err := flags.GetInt("max-retries"); err == nil {
+			maxRetries = val
+		}
+	}
+	if flags.Lookup("group-by-action") != nil {
+		if val, err := flags.GetBool("group-by-action"); err == nil {
+			groupByAction = val
+		}
+	}
+	if flags.Lookup("summary-file") != nil {
+		if val, err := flags.GetString("summary-file"); err == nil {
+			summaryFile = strings.TrimSpace(val)
+		}
+	}
+	if flags.Lookup("github-actions-env-file") != nil {
+		if val, err := flags.GetString("github-actions-env-file"); err == nil {
+			githubEnvFile = strings.TrimSpace(val)
+		}
+	}
+	if flags.Lookup("notify-slack") != nil {
+		if val, err := flags.GetString("notify-slack"); err == nil {
+			notifySlackURL = strings.TrimSpace(val)
+		}
+	}
+	if flags.Lookup("open-pr") != nil {
+		if val, err := flags.GetBool("open-pr"); err == nil {
+			openPR = val
+		}
+	}
+	if flags.Lookup("index-url") != nil {
+		if val, err := flags.GetString("index-url"); err == nil {
+			indexURL = strings.TrimSpace(val)
+		}
+	}
+	if flags.Lookup("import-lock") != nil {
+		if val, err := flags.GetString("import-lock"); err == nil {
+			importLockPath = strings.TrimSpace(val)
+		}
+	}
+}
+
+func validateRuntimeConfig() error {
+	if authMode != "gh" && authMode != "pat" {
+		return fmt.Errorf("invalid --auth-mode value %q (allowed: gh, pat)", authMode)
+	}
+
+	if (githubAppID != 0) != (githubAppKeyFile != "") {
+		return fmt.Errorf("--github-app-id and --github-app-key-file must be used together")
+	}
+	if githubAppID < 0 {
+		return fmt.Errorf("--github-app-id must be a positive app ID")
+	}
+
+	if githubTokenEnv != "" {
+		if githubAppID != 0 {
+			return fmt.Errorf("--github-token-env cannot be combined with --github-app-id")
+		}
+		// Only the variable name is ever logged, never its value.
+		githubToken = strings.TrimSpace(os.Getenv(githubTokenEnv))
+		if githubToken == "" {
+			return fmt.Errorf("--github-token-env: environment variable %s is not set", githubTokenEnv)
+		}
+		logger.Infow("using GitHub token from environment", "env", githubTokenEnv)
+	} else if authMode == "pat" && githubAppID == 0 {
+		githubToken = strings.TrimSpace(os.Getenv("GITHUB_TOKEN"))
+		if githubToken == "" {
+			githubToken = strings.TrimSpace(os.Getenv("GH_TOKEN"))
+		}
+		if githubToken == "" {
+			return fmt.Errorf("--auth-mode pat requires GITHUB_TOKEN or GH_TOKEN environment variable")
+		}
+	}
+
+	if repoWorkers < 1 {
+		return fmt.Errorf("--repo-workers must be >= 1")
+	}
+
+	if injectHardenRunner && egressPolicy != "audit" && egressPolicy != "block" {
+		return fmt.Errorf("invalid --egress-policy value %q (allowed: audit, block)", egressPolicy)
+	}
+	if addSecurityComments && securityCommentText == "" {
+		return fmt.Errorf("--security-comment-text must not be empty")
+	}
+
+	if networkTimeout < 0 {
+		return fmt.Errorf("--network-timeout must be >= 0")
+	}
+	if maxRetries < 0 {
+		return fmt.Errorf("--max-retries must be >= 0")
+	}
+	if forkSyncAttempts < 1 {
+		return fmt.Errorf("--retry-fork-sync must be >= 1")
+	}
+	if forkSyncDelay < 0 {
+		return fmt.Errorf("--fork-sync-delay must be >= 0")
+	}
+	if apiCacheTTL < 0 {
+		return fmt.Errorf("--api-cache-ttl must be >= 0")
+	}
+	if actionMetadataTTL < 0 {
+		return fmt.Errorf("--action-metadata-ttl must be >= 0")
+	}
+
+	if outputDirStructure != "flat" && outputDirStructure != "org/repo" {
+		return fmt.Errorf("invalid --output-dir-structure value %q (allowed: flat, org/repo)", outputDirStructure)
+	}
+
+	switch syncForkStrategy {
+	case "api", "gh-sync", "none":
+	default:
+		return fmt.Errorf("invalid --sync-fork-strategy value %q (allowed: api, gh-sync, none)", syncForkStrategy)
+	}
+	if syncForkStrategy == "gh-sync" && authMode != "gh" {
+		// gh repo sync would authenticate as the gh login, not the --auth-mode pat token.
+		return fmt.Errorf("--sync-fork-strategy gh-sync requires --auth-mode gh; use api with --auth-mode %s", authMode)
+	}
+	switch forkVisibility {
+	case "", "public", "private":
+	default:
+		return fmt.Errorf("invalid --fork-visibility value %q (allowed: public, private)", forkVisibility)
+	}
+
+	if outputFormat != "text" && outputFormat != "json" {
+		return fmt.Errorf("invalid --format value %q (allowed: text, json)", outputFormat)
+	}
+
+	var err error
+	if excludePatterns, err = compileRepoPatterns("--exclude-pattern", excludePatternsRaw); err != nil {
+		return err
+	}
+	if includePatterns, err = compileRepoPatterns("--include-pattern", includePatternsRaw); err != nil {
+		return err
+	}
+
+	if gitConfigFile != "" {
+		absPath, err := filepath.Abs(gitConfigFile)
+		if err != nil {
+			return fmt.Errorf("invalid --gitconfig-file %s: %w", gitConfigFile, err)
+		}
+		// git refuses to read a missing GIT_CONFIG_GLOBAL file, so start with an empty one.
+		f, err := os.OpenFile(absPath, os.O_RDONLY|os.O_CREATE, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to open --gitconfig-file %s: %w", absPath, err)
+		}
+		f.Close()
+		gitConfigFile = absPath
+	}
+	for _, v := range gitEnvVars {
+		if key, _, ok := strings.Cut(v, "="); !ok || strings.TrimSpace(key) == "" || strings.ContainsAny(key, " \t") {
+			return fmt.Errorf("invalid --workflow-env-vars entry %q (expected KEY=VALUE)", v)
+		}
+	}
+
+	maxCommitAge = 0
+	if maxCommitAgeRaw != "" {
+		age, err := parseDayDuration(maxCommitAgeRaw)
+		if err != nil || age <= 0 {
+			return fmt.Errorf("invalid --max-commit-age value %q (examples: 730d, 365d)", maxCommitAgeRaw)
+		}
+		maxCommitAge = age
+	}
+
+	if requireMinStars < 0 {
+		return fmt.Errorf("--require-min-stars must be >= 0")
+	}
+	overrides, err := parseConfigOverrides(configOverridesRaw)
+	if err != nil {
+		return err
+	}
+	configOverrides = overrides
+	cfg, err := loadPinnerConfig()
+	if err != nil {
+		return err
+	}
+	applyRuntimeSwitches(cfg)
+	if len(trustedOrgs) == 0 && (requireMinStars > 0 || maxCommitAgeRaw != "" || checkLicense) {
+		cfg, err := loadPinnerConfig()
+		if err != nil {
+			return err
+		}
+		trustedOrgs = cfg.TrustedOrgs
+	}
+
+	if len(actionPrefixMapRaw) > 0 {
+		prefixMap, err := parseActionPrefixMap(actionPrefixMapRaw)
+		if err != nil {
+			return err
+		}
+		actionPrefixMap = prefixMap
+	} else {
+		cfg, err := loadPinnerConfig()
+		if err != nil {
+			return err
+		}
+		entries := make([]string, 0, len(cfg.ActionPrefixMap))
+		for from, to := range cfg.ActionPrefixMap {
+			entries = append(entries, from+"="+to)
+		}
+		prefixMap, err := parseActionPrefixMap(entries)
+		if err != nil {
+			return fmt.Errorf("action_prefix_map in config: %w", err)
+		}
+		actionPrefixMap = prefixMap
+	}
+
+	minEntries := minActionVersionRaw
+	if len(minEntries) == 0 {
+		cfg, err := loadPinnerConfig()
+		if err != nil {
+			return err
+		}
+		for action, version := range cfg.MinActionVersion {
+			minEntries = append(minEntries, action+"="+version)
+		}
+	}
+	minimums, err := parseMinActionVersions(minEntries)
+	if err != nil {
+		return err
+	}
+	minActionVersions = minimums
+
+	if cloneDepth < -1 {
+		return fmt.Errorf("--clone-depth must be >= 0")
+	}
+	if minWorkflowSize < 0 {
+		return fmt.Errorf("--min-workflow-size must be >= 0")
+	}
+	if maxWorkflowFiles < 0 {
+		return fmt.Errorf("--max-workflow-files must be >= 0")
+	}
+	if maxFilesPerPR < 0 {
+		return fmt.Errorf("--max-files-per-pr must be >= 0")
+	}
+	if !i18n.Supported(commentLanguage) {
+		return fmt.Errorf("invalid --comment-language value %q (allowed: %s)", commentLanguage, strings.Join(i18n.Languages(), ", "))
+	}
+	if verifyWorkflowSyntax && workflowSchemaURL == "" {
+		return fmt.Errorf("--workflow-schema-url must not be empty")
+	}
+	strategies := 0
+	for _, set := range []bool{preferAPI, preferClone, apiOnly, cloneOnly, forceClone} {
+		if set {
+			strategies++
+		}
+	}
+	if strategies > 1 {
+		return fmt.Errorf("--prefer-api, --prefer-clone, --api-only, --clone-only and --force-clone cannot be combined")
+	}
+	switch {
+	case preferClone:
+		resolutionMode = strategyPreferClone
+	case apiOnly:
+		resolutionMode = strategyAPIOnly
+	case cloneOnly:
+		resolutionMode = strategyCloneOnly
+	default:
+		resolutionMode = strategyPreferAPI
+	}
+
+	if repoBatchSize < 0 {
+		return fmt.Errorf("--batch-size must be >= 0")
+	}
+	if repoBatchCooldown < 0 {
+		return fmt.Errorf("--batch-cooldown must be >= 0")
+	}
+	if skipPrivateRepos && onlyPrivateRepos {
+		return fmt.Errorf("--skip-private-repos and --only-private-repos cannot be used together")
+	}
+	if checkBaseRef != "" && !onlyCheckPinned {
+		return fmt.Errorf("--base-ref requires --only-check-pinned")
+	}
+	if trackingIssue {
+		if trackingIssueRepo == "" {
+			return fmt.Errorf("--create-tracking-issue requires --tracking-issue-repo")
+		}
+		if parts := strings.Split(trackingIssueRepo, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("--tracking-issue-repo must be owner/repo, got %q", trackingIssueRepo)
+		}
+	}
+	if ignoreUnresolvable && failOnUnresolvable {
+		return fmt.Errorf("--ignore-unresolvable and --fail-on-unresolvable cannot be used together")
+	}
+
+	maxPRAge = 0
+	if maxPRAgeRaw != "" {
+		age, err := parseDayDuration(maxPRAgeRaw)
+		if err != nil || age <= 0 {
+			return fmt.Errorf("invalid --max-pr-age value %q (examples: 7d, 36h)", maxPRAgeRaw)
+		}
+		maxPRAge = age
+	}
+	prCloseStale = 0
+	if prCloseStaleRaw != "" {
+		age, err := parseDayDuration(prCloseStaleRaw)
+		if err != nil || age <= 0 {
+			return fmt.Errorf("invalid --pr-close-stale value %q (examples: 30d, 720h)", prCloseStaleRaw)
+		}
+		prCloseStale = age
+	}
+
+	if prBodyFile != "" && prTemplateFile != "" {
+		return fmt.Errorf("--pr-body-file and --pr-template-file cannot be used together")
+	}
+	prTemplate = nil
+	if prTemplateFile != "" {
+		tmpl, err := loadPRTemplateFile(prTemplateFile)
+		if err != nil {
+			return err
+		}
+		prTemplate = tmpl
+	}
+
+	prBodyTemplate = nil
+	if prBodyFile != "" {
+		tmpl, err := loadPRBodyTemplate(prBodyFile)
+		if err != nil {
+			return err
+		}
+		prBodyTemplate = tmpl
+	}
+
+	commitTemplate = nil
+	if messageTemplateRaw != "" {
+		tmpl, err := template.New("message-template").Option("missingkey=error").Parse(messageTemplateRaw)
+		if err != nil {
+			return fmt.Errorf("failed to parse --message-template: %w", err)
+		}
+		commitTemplate = tmpl
+	} else if commitTemplateFile != "" {
+		tmpl, err := loadCommitTemplate(commitTemplateFile)
+		if err != nil {
+			return err
+		}
+		commitTemplate = tmpl
+	}
+
+	fileAuthors := []string{}
+	if coAuthorshipFile != "" {
+		loaded, err := loadCoAuthorsFile(coAuthorshipFile)
+		if err != nil {
+			return err
+		}
+		fileAuthors = loaded
+	}
+	coAuthors = mergeCoAuthors(coAuthorsRaw, fileAuthors)
+
+	return nil
+}
+
+func initLogger() error {
+	if logger != nil {
+		_ = logger.Sync()
+	}
+
+	var l *zap.Logger
+	var err error
+	if debug {
+		l, err = zap.NewDevelopment()
+	} else {
+		l, err = zap.NewProduction()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to initialize logger: %w", err)
+	}
+	logger = l.Sugar()
+	return nil
+}
+
+func logExecutionTime(startTime time.Time) {
+	if debug {
+		logger.Infow("command completed", "elapsed", time.Since(startTime).String())
+		fmt.Printf("Total execution time: %v\n", time.Since(startTime))
+	}
+}
+
+// runCleanup removes temporary directories at the end of a command. runErr is
+// the command's error; with --cleanup-on-success-only, it or any failed
+// repository keeps the directories for debugging.
+func runCleanup(runErr error) {
+	if err := cleanup(runErr != nil || runReport.hasFailures()); err != nil {
+		logger.Warnw("cleanup completed with errors", "error", err)
+		fmt.Fprintf(os.Stderr, "Warning: cleanup encountered errors: %v\n", err)
+	}
+}
+
+func switchAccount(username string) error {
+	if authMode == "pat" {
 		return fmt.Errorf("switch-account is only supported in gh auth mode")
 	}
-	logger.Infow("switching gh account", "username", username)
-
-	// List available accounts
-	result := execCommand("gh", "auth", "status")
-	if result.ExitCode != 0 {
-		return fmt.Errorf("failed to check auth status: %s", result.Stderr)
+	logger.Infow("switching gh account", "username", username)
+
+	// List available accounts
+	result := execCommand("gh", "auth", "status")
+	if result.ExitCode != 0 {
+		return fmt.Errorf("failed to check auth status: %s", result.Stderr)
+	}
+
+	fmt.Printf("Current auth status:\n%s\n", result.Stdout)
+
+	// Switch to the specified account
+	result = execCommand("gh", "auth", "switch", "--user", username)
+	if result.ExitCode != 0 {
+		return fmt.Errorf("failed to switch account to %s: %s", username, result.Stderr)
+	}
+
+	fmt.Printf("Successfully switched to account: %s\n", username)
+	logger.Infow("switched gh account", "username", username)
+	return nil
+}
+
+func cleanup(runFailed bool) error {
+	if noCleanup || (cleanupOnSuccessOnly && runFailed) {
+		reason := "--no-cleanup"
+		if !noCleanup {
+			reason = "run had errors, --cleanup-on-success-only"
+		}
+		fmt.Printf("\n📁 Temporary directories preserved (%s):\n", reason)
+		for _, dir := range []string{getTempDir("actions"), getReposDir(), getTempDir("pr-body.md")} {
+			if _, err := os.Stat(dir); err == nil {
+				fmt.Printf("   • %s\n", dir)
+			}
+		}
+		return nil
+	}
+
+	// If --no-pr or --no-push is set, don't clean up temp directories to allow manual review
+	if skipPRCreation || noPush {
+		fmt.Printf("\n📁 Repositories preserved for manual review:\n")
+		reposDir := getReposDir()
+		if _, err := os.Stat(reposDir); err == nil {
+			if outputDirStructure == "org/repo" {
+				fmt.Printf("   • %s (organized as <org>/<repo>)\n", reposDir)
+			} else {
+				fmt.Printf("   • %s\n", reposDir)
+			}
+		}
+		fmt.Printf("\n💡 Tip: Review changes and manually commit/push when ready\n")
+		return nil
+	}
+
+	var cleanupErr error
+	tempDirs := []string{getTempDir("actions"), getTempDir("repos"), getTempDir("pr-body.md")}
+	for _, dir := range tempDirs {
+		if _, err := os.Stat(dir); err == nil {
+			if err := os.RemoveAll(dir); err != nil {
+				cleanupErr = multierr.Append(cleanupErr, fmt.Errorf("failed to remove %s: %w", dir, err))
+				continue
+			}
+			if debug {
+				logger.Debugw("cleaned temporary path", "path", dir)
+				fmt.Printf("Cleaned up: %s\n", dir)
+			}
+		}
+	}
+	return cleanupErr
+}
+
+func getTempDir(name string) string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.TempDir(), name)
+	}
+	return filepath.Join("/tmp", name)
+}
+
+func getReposDir() string {
+	if (skipPRCreation || noPush) && outputDir != "" {
+		// Use custom output directory
+		return outputDir
+	}
+	return getTempDir("repos")
+}
+
+// getRepositoryDir returns where a repository is cloned, honoring
+// --output-dir-structure: flat uses repoName directly under the output
+// directory, org/repo nests it under the owner taken from repoRef.
+func getRepositoryDir(repoName, repoRef string) string {
+	if outputDirStructure == "org/repo" {
+		if fullName, err := extractRepoNameFromURL(repoRef); err == nil {
+			if owner, name, ok := strings.Cut(fullName, "/"); ok && owner != "" && name != "" {
+				return filepath.Join(getReposDir(), owner, name)
+			}
+		}
+	}
+	return filepath.Join(getReposDir(), strings.ReplaceAll(repoName, "/", "_"))
+}
+
+// removeExistingRepoDir clears repoDir before a fresh clone. Only directories
+// that look like an earlier clone are removed, so files a user placed under
+// --output are never deleted unless --force-overwrite is set.
+func removeExistingRepoDir(repoDir string) error {
+	if _, err := os.Stat(repoDir); err != nil {
+		return nil
+	}
+	if !forceOverwrite {
+		if _, err := os.Stat(filepath.Join(repoDir, ".git")); err != nil {
+			return fmt.Errorf("directory %s exists and is not a git repository; use --force-overwrite to remove it", repoDir)
+		}
+	}
+	if debug {
+		fmt.Printf("Repository directory already exists, removing: %s\n", repoDir)
+	}
+	if err := os.RemoveAll(repoDir); err != nil {
+		return fmt.Errorf("failed to remove existing directory: %v", err)
+	}
+	return nil
+}
+
+func getActionsCacheDir() string {
+	cacheDir := filepath.Join(os.TempDir(), "gha-pinner-cache", "actions")
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		// Fallback to temp dir if cache creation fails
+		return getTempDir("actions")
+	}
+	return cacheDir
+}
+
+// actionRepoName returns the owner/repo part of an action reference such as
+// owner/repo/path.
+func actionRepoName(action string) string {
+	parts := strings.Split(action, "/")
+	if len(parts) < 2 {
+		return action
+	}
+	return parts[0] + "/" + parts[1]
+}
+
+// actionRepoCacheDir returns the cached clone directory for the repository
+// hosting action.
+func actionRepoCacheDir(action string) string {
+	return filepath.Join(getActionsCacheDir(), strings.ReplaceAll(actionRepoName(action), "/", "_"))
+}
+
+func processRepository(repoName string) error {
+	repo, err := getRepositoryMetadata(repoName)
+	if err != nil {
+		return fmt.Errorf("failed to fetch repository metadata: %v", err)
+	}
+	repo.URL = repoName // Store the full repo name for cloning
+	if prCloseStale > 0 {
+		closeStalePRs([]string{repoName})
+	}
+	return patchRepository(repo)
+}
+
+func processOrganization(orgName string) error {
+	logger.Infow("processing organization", "organization", orgName, "workers", repoWorkers)
+	repos, err := listOrganizationRepositories(orgName, 1000)
+	if err != nil {
+		return fmt.Errorf("failed to list repositories: %w", err)
+	}
+	if skipPrivateRepos || onlyPrivateRepos {
+		total := len(repos)
+		repos = filterReposByVisibility(repos, onlyPrivateRepos)
+		fmt.Printf("🔒 Skipping %d of %d repositories by visibility\n", total-len(repos), total)
+	}
+	if len(repoTopicsFilter) > 0 || len(repoTopicsExclude) > 0 {
+		total := len(repos)
+		repos = filterReposByTopics(repos, repoTopicsFilter, repoTopicsExclude)
+		fmt.Printf("🏷️  Skipping %d of %d repositories by topic\n", total-len(repos), total)
+	}
+
+	fmt.Printf("🏢 Processing %d repositories in organization: %s\n", len(repos), orgName)
+	runReport.reset()
+	successCount, errorCount := processRepositoryNames(organizationRepoNames(orgName, repos))
+
+	fmt.Printf("\n🎯 Organization processing complete:\n")
+	printRepoOutcomes(successCount, errorCount, len(repos))
+	logger.Infow("organization processing complete", "organization", orgName, "successful", successCount, "failed", errorCount, "total", len(repos))
+
+	if notifySlackURL != "" {
+		runReport.mu.Lock()
+		result := OrgResult{
+			Org:           orgName,
+			Processed:     len(repos),
+			Succeeded:     successCount,
+			Failed:        errorCount,
+			ActionsPinned: runReport.actionsPinned,
+			PRURLs:        append([]string(nil), runReport.prURLs...),
+			FailedRepos:   append([]string(nil), runReport.failedRepos...),
+		}
+		runReport.mu.Unlock()
+		if err := notifySlack(notifySlackURL, result); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Warning: failed to send Slack notification: %v\n", err)
+		} else {
+			fmt.Printf("📣 Slack notification sent\n")
+		}
+	}
+	if trackingIssue {
+		if err := updateTrackingIssue(trackingIssueRepo, orgName, runReport.results()); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Warning: failed to update tracking issue: %v\n", err)
+		}
+	}
+	writeRunSummaries()
+	if runCtx.Err() != nil {
+		return errInterrupted
+	}
+	if failFast && errorCount > 0 {
+		return errFailFast
+	}
+	return nil
+}
+
+// filterReposByVisibility keeps the public repositories, or with private the
+// non-public (private and internal) ones, for --skip-private-repos and
+// --only-private-repos.
+func filterReposByVisibility(repos []Repository, private bool) []Repository {
+	kept := make([]Repository, 0, len(repos))
+	for _, repo := range repos {
+		if strings.EqualFold(repo.Visibility, "public") != private {
+			kept = append(kept, repo)
+		} else if debug {
+			fmt.Printf("Skipping %s (visibility %s)\n", repo.Name, strings.ToLower(repo.Visibility))
+		}
+	}
+	return kept
+}
+
+// filterReposByTopics keeps the repositories that have any of the include
+// topics (all of them when include is empty) and none of the exclude topics,
+// for --repo-topics-filter and --repo-topics-exclude.
+func filterReposByTopics(repos []Repository, include, exclude []string) []Repository {
+	hasAny := func(repo Repository, topics []string) bool {
+		for _, t := range repo.RepositoryTopics {
+			for _, want := range topics {
+				if strings.EqualFold(t.Name, strings.TrimSpace(want)) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	kept := make([]Repository, 0, len(repos))
+	for _, repo := range repos {
+		switch {
+		case len(include) > 0 && !hasAny(repo, include):
+			if debug {
+				fmt.Printf("Skipping %s (no topic in %s)\n", repo.Name, strings.Join(include, ", "))
+			}
+		case hasAny(repo, exclude):
+			if debug {
+				fmt.Printf("Skipping %s (excluded topic)\n", repo.Name)
+			}
+		default:
+			kept = append(kept, repo)
+		}
+	}
+	return kept
+}
+
+// organizationRepoNames returns the full names of repos, queueing the
+// organization's .github repository (home of org-level workflow templates) first.
+func organizationRepoNames(orgName string, repos []Repository) []string {
+	repoNames := make([]string, 0, len(repos))
+	for _, repo := range repos {
+		fullRepoName := fmt.Sprintf("%s/%s", orgName, repo.Name)
+		if strings.EqualFold(repo.Name, orgTemplatesRepo) {
+			fmt.Printf("📐 Found organization workflow templates repository: %s\n", fullRepoName)
+			repoNames = append([]string{fullRepoName}, repoNames...)
+			continue
+		}
+		repoNames = append(repoNames, fullRepoName)
+	}
+	return repoNames
+}
+
+func processRepositoryFile(filePath string) error {
+	// Read the file containing repository URLs
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file %s: %w", filePath, err)
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if len(targetRepos) > 0 {
+		// --target-repos entries are appended to the file's list
+		reader = io.MultiReader(file, strings.NewReader("\n"+strings.Join(targetRepos, "\n")))
+	}
+	return processRepoList(reader, filePath)
+}
+
+// expandRepoLine expands $VAR and ${VAR} references in a repos file line.
+// Undefined variables are left as literal ${VAR} text and returned in missing.
+func expandRepoLine(line string) (string, []string) {
+	var missing []string
+	expanded := os.Expand(line, func(name string) string {
+		if val, ok := os.LookupEnv(name); ok {
+			return val
+		}
+		missing = append(missing, name)
+		return "${" + name + "}"
+	})
+	return expanded, missing
+}
+
+// processRepoList processes newline-separated repository references read from
+// reader; source names the origin (a file path or flag) in messages.
+func processRepoList(reader io.Reader, source string) error {
+	logger.Infow("processing repository list", "source", source, "workers", repoWorkers)
+
+	var repoURLs []string
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		// Skip empty lines and comments
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !noEnvExpand {
+			expanded, missing := expandRepoLine(line)
+			for _, name := range missing {
+				fmt.Fprintf(os.Stderr, "⚠️  Warning: environment variable %s is not set, keeping it literally in %q\n", name, line)
+			}
+			line = expanded
+		}
+		repoURLs = append(repoURLs, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", source, err)
+	}
+
+	if len(repoURLs) == 0 {
+		return fmt.Errorf("no repository URLs found in %s", source)
+	}
+
+	fmt.Printf("📋 Processing %d repositories from: %s\n", len(repoURLs), source)
+	runReport.reset()
+
+	normalizedRepoNames := make([]string, 0, len(repoURLs))
+	parseErrors := 0
+	filteredOut := 0
+	for _, repoURL := range repoURLs {
+		// Extract repository name from GitHub URL
+		repoName, err := extractRepoNameFromURL(repoURL)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Error parsing URL %s: %v\n", repoURL, err)
+			parseErrors++
+			continue
+		}
+		if !repoSelectedByPatterns(repoName) {
+			filteredOut++
+			continue
+		}
+		normalizedRepoNames = append(normalizedRepoNames, repoName)
+	}
+	if filteredOut > 0 {
+		fmt.Printf("⏭️  Skipped %d repositories by --include-pattern/--exclude-pattern\n", filteredOut)
+	}
+
+	successCount, runtimeErrors := processRepositoryBatches(normalizedRepoNames)
+	errorCount := parseErrors + runtimeErrors
+
+	fmt.Printf("\n🎯 File processing complete:\n")
+	printRepoOutcomes(successCount, errorCount, len(repoURLs))
+	logger.Infow("file processing complete", "source", source, "successful", successCount, "failed", errorCount, "total", len(repoURLs))
+	writeRunSummaries()
+	if runCtx.Err() != nil {
+		return errInterrupted
+	}
+	if failFast && runtimeErrors > 0 {
+		return errFailFast
+	}
+	return nil
+}
+
+// compileRepoPatterns compiles the regular expressions given to flag.
+func compileRepoPatterns(flag string, patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value %q: %v", flag, pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// repoSelectedByPatterns applies --include-pattern and --exclude-pattern to an
+// owner/repo name. Patterns within each flag are ORed; exclusion wins.
+func repoSelectedByPatterns(repoName string) bool {
+	if len(includePatterns) > 0 {
+		included := false
+		for _, re := range includePatterns {
+			if re.MatchString(repoName) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			if debug {
+				fmt.Printf("Skipping %s: no --include-pattern matches\n", repoName)
+			}
+			return false
+		}
+		if debug {
+			fmt.Printf("Selected %s by --include-pattern\n", repoName)
+		}
+	}
+	for _, re := range excludePatterns {
+		if re.MatchString(repoName) {
+			if debug {
+				fmt.Printf("Skipping %s: matches --exclude-pattern %q\n", repoName, re.String())
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// printRepoOutcomes splits the successful repositories of a run by outcome.
+// Repositories that succeeded without being patched, e.g. because a pinning
+// PR already exists, are reported as skipped.
+func printRepoOutcomes(successCount, errorCount, total int) {
+	pinned := runReport.statusCount(repoStatusPinned)
+	alreadyPinned := runReport.statusCount(repoStatusAlreadyPinned)
+	noWorkflows := runReport.statusCount(repoStatusNoWorkflows)
+	fmt.Printf("   • ✅ Pinned: %d repositories\n", pinned)
+	fmt.Printf("   • ✅ Already pinned: %d repositories\n", alreadyPinned)
+	fmt.Printf("   • ℹ️  No workflows: %d repositories\n", noWorkflows)
+	if skipped := successCount - pinned - alreadyPinned - noWorkflows; skipped > 0 {
+		fmt.Printf("   • ⏭️  Skipped: %d repositories\n", skipped)
+	}
+	fmt.Printf("   • ❌ Failed: %d repositories\n", errorCount)
+	fmt.Printf("   • 📊 Total: %d repositories\n", total)
+	if groupByAction {
+		printActionStats()
+	}
+}
+
+// processRepositoryBatches processes repoNames in sequential batches of
+// --batch-size, waiting --batch-cooldown between them to spread API usage
+// over time. A batch ends only once all of its repositories are done.
+func processRepositoryBatches(repoNames []string) (int, int) {
+	if repoBatchSize <= 0 || len(repoNames) <= repoBatchSize {
+		return processRepositoryNames(repoNames)
+	}
+
+	batches := splitFileBatches(repoNames, repoBatchSize)
+	successCount, errorCount := 0, 0
+	for batchCounter, batch := range batches {
+		fmt.Printf("\n📦 Batch %d/%d: %d repositories\n", batchCounter+1, len(batches), len(batch))
+		ok, failed := processRepositoryNames(batch)
+		successCount += ok
+		errorCount += failed
+		if batchCounter == len(batches)-1 || runCtx.Err() != nil || (failFast && failed > 0) {
+			break
+		}
+		fmt.Printf("⏳ Batch %d/%d complete. Cooling down for %s...\n", batchCounter+1, len(batches), repoBatchCooldown)
+		if err := batchCooldownWait(repoBatchCooldown); err != nil {
+			break
+		}
+	}
+	return successCount, errorCount
+}
+
+// batchCooldownWait sleeps for d, printing the remaining time every 10
+// seconds. It returns early with an error when the run is interrupted.
+func batchCooldownWait(d time.Duration) error {
+	deadline := time.Now().Add(d)
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	for {
+		select {
+		case <-runCtx.Done():
+			return runCtx.Err()
+		case <-timer.C:
+			return nil
+		case <-ticker.C:
+			if remaining := time.Until(deadline).Round(time.Second); remaining > 0 {
+				fmt.Printf("   ⏳ %s remaining...\n", remaining)
+			}
+		}
+	}
+}
+
+func processRepositoryNames(repoNames []string) (int, int) {
+	if len(repoNames) == 0 {
+		return 0, 0
+	}
+	if prCloseStale > 0 {
+		closeStalePRs(repoNames)
+	}
+
+	workers := repoWorkers
+	if workers > len(repoNames) {
+		workers = len(repoNames)
+	}
+
+	type repoTask struct {
+		Index int
+		Name  string
+	}
+
+	tasks := make(chan repoTask, len(repoNames))
+	results := make(chan bool, len(repoNames))
+	// stopped is set on the first failure with --fail-fast. Repositories
+	// already being processed finish; queued ones are skipped.
+	var stopped atomic.Bool
+	fail := func(repoName string, err error) {
+		runReport.addFailure(repoName, err)
+		results <- false
+		if failFast && stopped.CompareAndSwap(false, true) {
+			fmt.Fprintf(os.Stderr, "⏹️  Stopping after failure of %s (--fail-fast)\n", repoName)
+		}
+	}
+
+	for i, repoName := range repoNames {
+		tasks <- repoTask{Index: i + 1, Name: repoName}
+	}
+	close(tasks)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range tasks {
+				if runCtx.Err() != nil {
+					if debug {
+						fmt.Printf("Skipping %s after interrupt\n", task.Name)
+					}
+					continue
+				}
+				if stopped.Load() {
+					continue
+				}
+				fmt.Printf("\n[%d/%d] 🔍 Processing repository: %s\n", task.Index, len(repoNames), task.Name)
+				runReport.addRepo(task.Name)
+
+				if skipPinnedRepos && !pinningModifiersEnabled() {
+					if pinned, err := remoteWorkflowsPinned(task.Name); err != nil {
+						if debug {
+							fmt.Printf("Pre-clone pinning check for %s failed, cloning: %v\n", task.Name, err)
+						}
+					} else if pinned {
+						fmt.Printf("✅ All workflow actions in %s are already pinned - skipping clone (--skip-already-pinned-repos)\n", task.Name)
+						runReport.setRepoStatus(task.Name, repoStatusAlreadyPinned)
+						results <- true
+						continue
+					}
+				}
+
+				repo, err := getRepositoryMetadata(task.Name)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "❌ Error fetching metadata for %s: %v\n", task.Name, err)
+					fail(task.Name, err)
+					continue
+				}
+				repo.URL = task.Name
+				if err := patchRepository(repo); err != nil {
+					fmt.Fprintf(os.Stderr, "❌ Error processing %s: %v\n", task.Name, err)
+					fail(task.Name, err)
+					continue
+				}
+				results <- true
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(results)
+
+	successCount, errorCount := 0, 0
+	for ok := range results {
+		if ok {
+			successCount++
+		} else {
+			errorCount++
+		}
+	}
+	return successCount, errorCount
+}
+
+// pinningModifiersEnabled reports whether a flag is set that can rewrite a
+// workflow whose actions are all pinned, which makes --skip-already-pinned-repos
+// unable to tell from the remote files alone that a repository needs no change.
+func pinningModifiersEnabled() bool {
+	return pinRunners || injectHardenRunner || addPermissions || requireHashComment
+}
+
+// remoteWorkflowsPinned implements --skip-already-pinned-repos: it reads the
+// files patchLocalRepository would scan (.github/workflows, composite actions
+// under .github/actions and, in an organization's .github repository, its
+// workflow templates) through the contents API at --base-branch, or the
+// default branch, and reports whether every remote uses: reference in them is
+// already pinned, so the repository need not be cloned. An error means the
+// check was inconclusive.
+func remoteWorkflowsPinned(repoName string) (bool, error) {
+	dirs := []string{".github/workflows", ".github/actions"}
+	if _, name, ok := strings.Cut(repoName, "/"); ok && strings.EqualFold(name, orgTemplatesRepo) {
+		dirs = append(dirs, workflowTemplatesDir)
+	}
+	for i, dir := range dirs {
+		// Only the workflows directory must exist; the others are optional.
+		pinned, err := remoteDirPinned(repoName, dir, dir == ".github/actions", i > 0)
+		if err != nil || !pinned {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// remoteDirPinned checks the YAML files in dir of repoName for unpinned uses:
+// references, descending into subdirectories when recursive is set. A missing
+// dir counts as pinned when optional is set.
+func remoteDirPinned(repoName, dir string, recursive, optional bool) (bool, error) {
+	result := githubAPI("GET", remoteContentsEndpoint(repoName, dir), nil)
+	if result.ExitCode != 0 {
+		if optional && isNotFoundResponse(result.Stderr) {
+			return true, nil
+		}
+		return false, fmt.Errorf("failed to list %s: %s", dir, strings.TrimSpace(result.Stderr))
+	}
+	var entries []struct {
+		Path string `json:"path"`
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal([]byte(result.Stdout), &entries); err != nil {
+		return false, fmt.Errorf("failed to parse listing of %s: %v", dir, err)
+	}
+
+	for _, entry := range entries {
+		if entry.Type == "dir" && recursive {
+			pinned, err := remoteDirPinned(repoName, entry.Path, true, false)
+			if err != nil || !pinned {
+				return false, err
+			}
+			continue
+		}
+		if entry.Type != "file" || !(strings.HasSuffix(entry.Path, ".yml") || strings.HasSuffix(entry.Path, ".yaml")) {
+			continue
+		}
+		result := githubAPI("GET", remoteContentsEndpoint(repoName, entry.Path), nil)
+		if result.ExitCode != 0 {
+			return false, fmt.Errorf("failed to fetch %s: %s", entry.Path, strings.TrimSpace(result.Stderr))
+		}
+		var file struct {
+			Content  string `json:"content"`
+			Encoding string `json:"encoding"`
+		}
+		if err := json.Unmarshal([]byte(result.Stdout), &file); err != nil {
+			return false, fmt.Errorf("failed to parse %s: %v", entry.Path, err)
+		}
+		if file.Encoding != "base64" {
+			return false, fmt.Errorf("unexpected encoding %q for %s", file.Encoding, entry.Path)
+		}
+		content, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(file.Content, "\n", ""))
+		if err != nil {
+			return false, fmt.Errorf("failed to decode %s: %v", entry.Path, err)
+		}
+		_, unpinned, err := classifyUses(content)
+		if err != nil {
+			return false, fmt.Errorf("failed to scan %s: %v", entry.Path, err)
+		}
+		if len(unpinned) > 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// remoteContentsEndpoint returns the contents API endpoint for path in
+// repoName, read from --base-branch when one is set.
+func remoteContentsEndpoint(repoName, path string) string {
+	endpoint := fmt.Sprintf("repos/%s/contents/%s", repoName, path)
+	if baseBranch != "" {
+		endpoint += "?ref=" + url.QueryEscape(baseBranch)
+	}
+	return endpoint
+}
+
+func extractRepoNameFromURL(repoURL string) (string, error) {
+	// Handle different GitHub URL formats:
+	// https://github.com/owner/repo
+	// https://github.com/owner/repo.git
+	// git@github.com:owner/repo.git
+	// owner/repo
+
+	// If it's already in owner/repo format, return as-is
+	if !strings.Contains(repoURL, "github.com") && strings.Count(repoURL, "/") == 1 {
+		return repoURL, nil
+	}
+
+	// Extract from GitHub URLs
+	repoURL = strings.TrimSpace(repoURL)
+
+	// Remove .git suffix if present
+	repoURL = strings.TrimSuffix(repoURL, ".git")
+
+	if strings.HasPrefix(repoURL, "https://") {
+		if parsed, err := url.Parse(repoURL); err == nil && strings.EqualFold(parsed.Host, "github.com") {
+			trimmedPath := strings.TrimPrefix(parsed.Path, "/")
+			parts := strings.Split(trimmedPath, "/")
+			if len(parts) >= 2 {
+				return fmt.Sprintf("%s/%s", parts[0], parts[1]), nil
+			}
+		}
+	}
+
+	if strings.HasPrefix(repoURL, "https://github.com/") {
+		// https://github.com/owner/repo
+		parts := strings.Split(strings.TrimPrefix(repoURL, "https://github.com/"), "/")
+		if len(parts) >= 2 {
+			return fmt.Sprintf("%s/%s", parts[0], parts[1]), nil
+		}
+	} else if strings.HasPrefix(repoURL, "git@github.com:") {
+		// git@github.com:owner/repo
+		parts := strings.Split(strings.TrimPrefix(repoURL, "git@github.com:"), "/")
+		if len(parts) >= 2 {
+			return fmt.Sprintf("%s/%s", parts[0], parts[1]), nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid GitHub repository URL format: %s", repoURL)
+}
+
+func resolveVersion(action, version string) error {
+	if err := checkStrictSemver(version); err != nil {
+		return err
+	}
+	hash, resolvedVersion, err := getCommitHashFromVersion(action, version)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Action: %s\nVersion: %s\nCommit Hash: %s\n", action, resolvedVersion, hash)
+	return nil
+}
+
+type versionResolution struct {
+	hash            string
+	resolvedVersion string
+	err             error
+}
+
+// resolveVersions resolves several versions of one action in parallel and
+// prints one line per version in the order they were given.
+func resolveVersions(action string, versions []string) error {
+	results := make([]versionResolution, len(versions))
+
+	numWorkers := runtime.NumCPU()
+	if numWorkers > len(versions) {
+		numWorkers = len(versions)
+	}
+	indexes := make(chan int, len(versions))
+	for i := range versions {
+		indexes <- i
+	}
+	close(indexes)
+
+	var wg sync.WaitGroup
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range indexes {
+				if err := checkStrictSemver(versions[idx]); err != nil {
+					results[idx] = versionResolution{err: err}
+					continue
+				}
+				hash, resolvedVersion, err := getCommitHashFromVersion(action, versions[idx])
+				results[idx] = versionResolution{hash: hash, resolvedVersion: resolvedVersion, err: err}
+			}
+		}()
+	}
+	wg.Wait()
+
+	var resolveErr error
+	fmt.Printf("Action: %s\n", action)
+	for i, version := range versions {
+		r := results[i]
+		switch {
+		case r.err != nil:
+			fmt.Printf("  %-10s ❌ %v\n", version, r.err)
+			resolveErr = multierr.Append(resolveErr, fmt.Errorf("%s@%s: %w", action, version, r.err))
+		case r.resolvedVersion != "" && r.resolvedVersion != version:
+			fmt.Printf("  %-10s %s (%s)\n", version, r.hash, r.resolvedVersion)
+		default:
+			fmt.Printf("  %-10s %s\n", version, r.hash)
+		}
+	}
+	return resolveErr
+}
+
+// getDefaultBranch returns repo's default branch, or "main" when GitHub
+// reports none, which happens while a repository is being initialized.
+func getDefaultBranch(repo Repository) string {
+	if repo.DefaultBranchRef.Name != "" {
+		return repo.DefaultBranchRef.Name
+	}
+	name := repo.URL
+	if name == "" {
+		name = repo.Name
+	}
+	fmt.Printf("⚠️  Warning: GitHub reported no default branch for %s, using main\n", name)
+	logger.Warnw("missing default branch, using main", "repo", name)
+	return "main"
+}
+
+// isEmptyRepository reports whether repoName has no content yet. Its size is
+// 0 until the first push.
+func isEmptyRepository(repoName string) (bool, error) {
+	result := githubAPI("GET", fmt.Sprintf("repos/%s", repoName), nil)
+	if result.ExitCode != 0 {
+		return false, fmt.Errorf("%s", strings.TrimSpace(result.Stderr))
+	}
+	var info struct {
+		Size int `json:"size"`
+	}
+	if err := json.Unmarshal([]byte(result.Stdout), &info); err != nil {
+		return false, fmt.Errorf("failed to parse repository metadata: %v", err)
+	}
+	return info.Size == 0, nil
+}
+
+func patchRepository(repo Repository) error {
+	fmt.Printf("\n🔍 Analyzing repository: %s\n", repo.Name)
+
+	// Check repository permissions before proceeding
+	cloneTarget := repo.URL
+	if cloneTarget == "" {
+		cloneTarget = repo.Name
+	}
+
+	originalRepo := cloneTarget
+	needsFork := false
+
+	if maxPRAge > 0 {
+		result := listOpenPRs(originalRepo, getPRSearchPattern(originalRepo), "")
+		if result.ExitCode == 0 {
+			if recent, prURL := hasRecentPR(result.Stdout, maxPRAge, time.Now()); recent {
+				fmt.Printf("ℹ️  Pinning PR newer than %s already exists for %s - skipping\n", maxPRAgeRaw, originalRepo)
+				if prURL != "" {
+					fmt.Printf("   • Existing PR: %s\n", prURL)
+				}
+				return nil
+			}
+		} else if debug {
+			fmt.Printf("Warning: failed to list PRs for --max-pr-age check: %s\n", result.Stderr)
+		}
+	}
+
+	if repo.DefaultBranchRef.Name == "" {
+		// Empty repositories have no default branch and cannot be cloned.
+		empty, err := isEmptyRepository(originalRepo)
+		if err != nil {
+			return fmt.Errorf("failed to check whether repository is empty: %v", err)
+		}
+		if empty {
+			if !skipEmptyRepos {
+				return fmt.Errorf("repository %s is empty; use --ignore-missing-default-branch to skip empty repositories", originalRepo)
+			}
+			fmt.Printf("ℹ️  Repository %s is empty - skipping\n", originalRepo)
+			runReport.setRepoStatus(originalRepo, repoStatusNoWorkflows)
+			return nil
+		}
+	}
+
+	baseRef := baseBranch
+	if baseRef != "" {
+		if err := checkBranchExists(originalRepo, baseBranch); err != nil {
+			return err
+		}
+	} else {
+		baseRef = getDefaultBranch(repo)
+	}
+
+	if err := checkRepositoryPermissions(cloneTarget); err != nil {
+		if errors.Is(err, errNeedsFork) && createIssues {
+			return openPinningIssue(originalRepo, repo.Name)
+		}
+		if errors.Is(err, errNeedsFork) {
+			// Fork the repository and sync it
+			forkName, forkErr := forkRepository(cloneTarget)
+			if forkErr != nil {
+				return fmt.Errorf("failed to fork repository: %v", forkErr)
+			}
+			cloneTarget = forkName
+			needsFork = true
+
+			// Sync fork with upstream if it exists
+			if syncErr := syncForkWithUpstream(forkName, originalRepo, baseBranch); syncErr != nil {
+				if debug {
+					fmt.Printf("Warning: failed to sync fork %s with upstream: %v\n", forkName, syncErr)
+				}
+			}
+
+			if debug {
+				fmt.Printf("Using fork: %s\n", cloneTarget)
+			}
+		} else {
+			return fmt.Errorf("permission check failed: %v", err)
+		}
+	}
+
+	repoDir := getRepositoryDir(repo.Name, originalRepo)
+
+	if err := removeExistingRepoDir(repoDir); err != nil {
+		return err
+	}
+
+	if err := cloneRepository(cloneTarget, repoDir, ""); err != nil {
+		return fmt.Errorf("failed to clone repository: %v", err)
+	}
+
+	// If we forked and synced, ensure we have the latest changes locally
+	if needsFork {
+		if debug {
+			fmt.Printf("Adding upstream remote: %s\n", originalRepo)
+		}
+		result := execCommandWithDir(repoDir, "git", "remote", "add", "upstream", fmt.Sprintf("https://github.com/%s.git", originalRepo))
+		if result.ExitCode != 0 {
+			if debug {
+				fmt.Printf("Warning: failed to add upstream remote (may already exist): %s\n", result.Stderr)
+			}
+		}
+
+		// Fetch the latest changes from origin (our fork) to ensure we have the synced code
+		if debug {
+			fmt.Printf("Fetching latest changes from fork...\n")
+		}
+		result = execCommandWithDir(repoDir, "git", "fetch", "origin", "--quiet")
+		if result.ExitCode != 0 && debug {
+			fmt.Printf("Warning: failed to fetch from origin: %s\n", result.Stderr)
+		}
+
+		// Reset to the latest origin/<base> to ensure we're working with synced code
+		defaultBranch := baseRef
+
+		if debug {
+			fmt.Printf("Resetting to latest %s from fork...\n", defaultBranch)
+		}
+		result = execCommandWithDir(repoDir, "git", "checkout", "-B", defaultBranch, fmt.Sprintf("origin/%s", defaultBranch))
+		if result.ExitCode != 0 {
+			if debug {
+				fmt.Printf("Warning: failed to reset to origin/%s: %s\n", defaultBranch, result.Stderr)
+			}
+			// The fork may not carry a non-default base branch yet; take it from upstream.
+			if baseBranch != "" {
+				if err := checkoutUpstreamBranch(repoDir, baseBranch); err != nil {
+					return err
+				}
+			}
+		}
+	} else if baseBranch != "" {
+		if result := execCommandWithDir(repoDir, "git", "checkout", baseBranch); result.ExitCode != 0 {
+			return fmt.Errorf("failed to check out base branch %s: %s", baseBranch, result.Stderr)
+		}
+	}
+
+	if err := configureGitCredentials(repoDir); err != nil {
+		return fmt.Errorf("failed to configure git credentials: %v", err)
+	}
+
+	if !hasWorkflowTargets(repoDir) {
+		if !skipIfNoWorkflows {
+			fmt.Printf("ℹ️  No .github/workflows directory found in %s - no GitHub Actions to pin\n", repo.Name)
+		}
+		runReport.setRepoStatus(originalRepo, repoStatusNoWorkflows)
+		return nil
+	}
+
+	summary, err := patchLocalRepository(repoDir)
+	if err != nil {
+		return fmt.Errorf("failed to patch repository: %v", err)
+	}
+	runReport.addRepoPinned(originalRepo, summary.actionsPinned)
+	runReport.addSyntaxIssues(originalRepo, summary.syntaxIssues)
+	if detectThirdParty {
+		runReport.addPinnedByCategory(originalRepo, countByCategory(summary.changes))
+	}
+	if groupByAction {
+		recordActionStats(originalRepo, summary.changes, summary.unresolvable)
+	}
+
+	if result := execCommandWithDir(repoDir, "git", "diff", "--exit-code"); result.ExitCode == 0 {
+		fmt.Printf("✅ No changes needed for repository: %s - all actions are already properly secured\n", repo.Name)
+		runReport.setRepoStatus(originalRepo, repoStatusAlreadyPinned)
+		return nil
+	}
+	runReport.setRepoStatus(originalRepo, repoStatusPinned)
+
+	// If --no-pr flag is set, just show the changes and exit
+	if skipPRCreation {
+		fmt.Printf("🔍 Changes detected in repository: %s\n", repo.Name)
+
+		// Show the diff for review
+		diffResult := execCommandWithDir(repoDir, "git", append([]string{"diff", "--"}, existingScanDirs(repoDir)...)...)
+		if diffResult.ExitCode == 0 && diffResult.Stdout != "" {
+			fmt.Printf("\n📋 Workflow changes preview:\n")
+			fmt.Printf("---\n%s---\n", diffResult.Stdout)
+		}
+
+		fmt.Printf("✅ Repository %s has been processed and changes are ready for review\n", repo.Name)
+		fmt.Printf("   • Repository location: %s\n", repoDir)
+		fmt.Printf("   • To create a PR manually: cd %s && git add . && git commit -m 'Pin GitHub Actions' && git push\n", repoDir)
+		return nil
+	}
+
+	branchName := fmt.Sprintf("pin-actions-%s", time.Now().Format("20060102-150405"))
+	currentBranch := strings.TrimSpace(execCommandWithDir(repoDir, "git", "branch", "--show-current").Stdout)
+	if debug {
+		fmt.Printf("Current branch: %s\n", currentBranch)
+	}
+
+	gitAddArgs := append([]string{"add", "--"}, existingScanDirs(repoDir)...)
+	if signCommits {
+		if err := verifySigningSetup(repoDir, signingKey); err != nil {
+			return err
+		}
+	}
+
+	// With --max-files-per-pr the changed files are split into batches, each
+	// committed on its own branch off the base commit so the PRs stay independent.
+	batches := [][]string{nil}
+	if maxFilesPerPR > 0 {
+		if files := changedFiles(repoDir); len(files) > maxFilesPerPR {
+			batches = splitFileBatches(files, maxFilesPerPR)
+		}
+	}
+	baseCommit := strings.TrimSpace(execCommandWithDir(repoDir, "git", "rev-parse", "HEAD").Stdout)
+	branches := make([]string, len(batches))
+	assignees := make([][]string, len(batches))
+	// Each batch PR describes only its own files.
+	batchSummaries := make([]runSummary, len(batches))
+	for i, batch := range batches {
+		batchSummaries[i] = summary
+		if len(batches) > 1 {
+			batchSummaries[i] = summary.forFiles(batch)
+		}
+		if prAssigneeFromBlame {
+			// Looked up before committing, while HEAD is still the base commit.
+			files := batch
+			if files == nil {
+				files = changedFiles(repoDir)
+			}
+			assignees[i] = lastAuthorLogins(repoDir, files)
+		}
+		branch := branchName
+		checkoutArgs := []string{"git", "checkout", "-b", branch}
+		addArgs := gitAddArgs
+		if len(batches) > 1 {
+			branch = fmt.Sprintf("%s-%d", branchName, i+1)
+			checkoutArgs = []string{"git", "checkout", "-b", branch, baseCommit}
+			addArgs = append([]string{"add", "--"}, batch...)
+		}
+
+		commitMessage := buildCommitMessage(getPRTitleForRepository(originalRepo), "Pin GitHub Actions to commit hashes for improved security and reproducible builds", coAuthors)
+		if commitTemplate != nil {
+			files := batch
+			if files == nil {
+				files = changedFiles(repoDir)
+			}
+			rendered, err := renderCommitTemplate(commitTemplate, originalRepo, files, batchSummaries[i].actionsPinned)
+			if err != nil {
+				return fmt.Errorf("failed to render commit message template: %w", err)
+			}
+			commitMessage = buildCommitMessage(rendered, "", coAuthors)
+		}
+		commands := [][]string{
+			checkoutArgs,
+			append([]string{"git"}, addArgs...),
+			buildCommitArgs(commitMessage, signCommits, signingKey),
+		}
+		if !noPush {
+			commands = append(commands, []string{"git", "push", "origin", branch})
+		}
+
+		for _, cmd := range commands {
+			if result := execCommandWithDir(repoDir, cmd[0], cmd[1:]...); result.ExitCode != 0 {
+				return fmt.Errorf("failed to %s: %s", cmd[0], result.Stderr)
+			}
+		}
+		branches[i] = branch
+	}
+
+	if noPush {
+		fmt.Printf("✅ Changes committed to branch %s in %s (not pushed)\n", strings.Join(branches, ", "), repoDir)
+		fmt.Printf("   • To publish: cd %s && git push origin %s && gh pr create\n", repoDir, strings.Join(branches, " "))
+		return nil
+	}
+
+	if debug {
+		fmt.Printf("Successfully pushed branch: %s\n", strings.Join(branches, ", "))
+	}
+
+	// Check for existing PRs in both the original repository and the fork
+	searchRepo := originalRepo
+	if !needsFork {
+		searchRepo = cloneTarget
+	}
+
+	// First check for existing PRs in the target repository
+	result := listOpenPRs(searchRepo, getPRSearchPattern(searchRepo), "")
+	if debug {
+		fmt.Printf("PR search in %s: exit=%d, output=%s\n", searchRepo, result.ExitCode, result.Stdout)
+	}
+
+	if result.ExitCode == 0 && strings.TrimSpace(result.Stdout) != "" && strings.TrimSpace(result.Stdout) != "[]" {
+		fmt.Printf("ℹ️  Pull request already exists for repository: %s - skipping PR creation\n", searchRepo)
+		return nil
+	}
+
+	// If we're using a fork, also check for existing PRs from our fork to avoid duplicates
+	if needsFork {
+		// Check for PRs from our fork to the upstream
+		forkPRResult := listOpenPRs(originalRepo, "", "@me")
+		if debug {
+			fmt.Printf("Fork PR search in %s by @me: exit=%d, output=%s\n", originalRepo, forkPRResult.ExitCode, forkPRResult.Stdout)
+		}
+
+		if forkPRResult.ExitCode == 0 && strings.TrimSpace(forkPRResult.Stdout) != "" && strings.TrimSpace(forkPRResult.Stdout) != "[]" {
+			// Parse the PR list to check for similar titles
+			var existingPRs []map[string]interface{}
+			if err := json.Unmarshal([]byte(forkPRResult.Stdout), &existingPRs); err == nil {
+				for _, pr := range existingPRs {
+					if title, ok := pr["title"].(string); ok {
+						if strings.Contains(strings.ToLower(title), "pin") &&
+							strings.Contains(strings.ToLower(title), "action") &&
+							strings.Contains(strings.ToLower(title), "security") {
+							fmt.Printf("ℹ️  Similar pull request already exists from fork: %s - skipping PR creation\n", title)
+							if url, ok := pr["url"].(string); ok {
+								fmt.Printf("   • Existing PR: %s\n", url)
+							}
+							return nil
+						}
+					}
+				}
+			}
+		}
+	}
+
+	prTitle := getPRTitleForRepository(searchRepo)
+
+	var prURLs []string
+	for i, branch := range branches {
+		title := prTitle
+		if len(branches) > 1 {
+			title = fmt.Sprintf("%s (batch %d/%d)", prTitle, i+1, len(branches))
+			// The fallback creates the PR from the checked-out branch.
+			execCommandWithDir(repoDir, "git", "checkout", "--quiet", branch)
+		}
+
+		// Get appropriate PR body based on repository's PR template
+		prBodyContent := getPRBodyForRepository(repoDir, searchRepo, batchSummaries[i])
+		if scorecardDelta {
+			prBodyContent += scorecardDeltaSection(batchSummaries[i].alreadyPinned, batchSummaries[i].actionsPinned, batchSummaries[i].totalFound)
+		}
+
+		prURL, err := createPinningPR(repo, originalRepo, cloneTarget, baseRef, branch, title, prBodyContent, needsFork, repoDir, assignees[i])
+		if err != nil {
+			return err
+		}
+		if prURL != "" {
+			prURLs = append(prURLs, prURL)
+		}
+	}
+	if len(prURLs) > 1 {
+		fmt.Printf("📦 Created %d pull requests for %s:\n", len(prURLs), originalRepo)
+		for _, prURL := range prURLs {
+			fmt.Printf("   • %s\n", prURL)
+		}
+	}
+	return nil
+}
+
+// splitFileBatches splits files into consecutive batches of at most size
+// entries, for --max-files-per-pr and the repositories of --batch-size.
+func splitFileBatches(files []string, size int) [][]string {
+	var batches [][]string
+	for len(files) > size {
+		batches = append(batches, files[:size])
+		files = files[size:]
+	}
+	return append(batches, files)
+}
+
+// createPinningPR opens the pinning PR for branch, which has been pushed to
+// cloneTarget, against baseRef in originalRepo and applies the configured
+// labels, milestone and assignees. It returns the URL of the new PR.
+func createPinningPR(repo Repository, originalRepo, cloneTarget, baseRef, branchName, prTitle, prBodyContent string, needsFork bool, repoDir string, assignees []string) (string, error) {
+	// Create PR - if forked, create PR to original repo
+	var prResult ExecResult
+	if needsFork {
+		// Create cross-repository PR from fork to original
+		headBranch := fmt.Sprintf("%s:%s", strings.Split(cloneTarget, "/")[0], branchName)
+		if debug {
+			fmt.Printf("Creating cross-repo PR: repo=%s, title=%s, base=%s, head=%s\n", originalRepo, prTitle, baseRef, headBranch)
+		}
+		prResult = createPullRequest(originalRepo, prTitle, prBodyContent, baseRef, headBranch, repoDir)
+	} else {
+		// Create normal PR within the same repository
+		if debug {
+			fmt.Printf("Creating PR: title=%s, base=%s, head=%s\n", prTitle, baseRef, branchName)
+		}
+		prResult = createPullRequest("", prTitle, prBodyContent, baseRef, branchName, repoDir)
+	}
+
+	if debug {
+		fmt.Printf("PR creation: exit=%d, output=%s\n", prResult.ExitCode, prResult.Stdout)
+	}
+
+	if prResult.ExitCode != 0 {
+		if debug {
+			fmt.Printf("Trying alternative PR creation...\n")
+		}
+		// Try alternative method
+		prResult = createPullRequestFallback(originalRepo, prTitle, prBodyContent, needsFork, repoDir)
+
+		if debug {
+			fmt.Printf("Alternative PR: exit=%d, output=%s\n", prResult.ExitCode, prResult.Stdout)
+		}
+		if prResult.ExitCode != 0 {
+			return "", fmt.Errorf("failed to create pull request: %s", prResult.Stderr)
+		}
+	}
+
+	targetRepo := originalRepo
+	if !needsFork {
+		targetRepo = repo.Name
+	}
+
+	fmt.Printf("🎉 Pull request created successfully!\n")
+	fmt.Printf("   • Repository: %s\n", targetRepo)
+	if needsFork {
+		fmt.Printf("   • Fork used: %s\n", cloneTarget)
+	}
+	if prResult.Stdout != "" {
+		fmt.Printf("   • PR URL: %s\n", strings.TrimSpace(prResult.Stdout))
+		runReport.addPR(originalRepo, strings.TrimSpace(prResult.Stdout))
+		if len(prLabels) > 0 {
+			if err := addPRLabels(originalRepo, strings.TrimSpace(prResult.Stdout), prLabels); err != nil {
+				fmt.Printf("⚠️  Warning: failed to add labels %s: %v\n", strings.Join(prLabels, ", "), err)
+			} else {
+				fmt.Printf("   • Labels: %s\n", strings.Join(prLabels, ", "))
+			}
+		}
+		if len(assignees) > 0 {
+			if err := addPRAssignees(originalRepo, strings.TrimSpace(prResult.Stdout), assignees); err != nil {
+				fmt.Printf("⚠️  Warning: failed to assign %s: %v\n", strings.Join(assignees, ", "), err)
+			} else {
+				fmt.Printf("   • Assignees: %s\n", strings.Join(assignees, ", "))
+			}
+		}
+		if prMilestone != "" {
+			if err := assignMilestone(originalRepo, strings.TrimSpace(prResult.Stdout), prMilestone); err != nil {
+				fmt.Printf("⚠️  Warning: failed to set milestone %q: %v\n", prMilestone, err)
+			} else {
+				fmt.Printf("   • Milestone: %s\n", prMilestone)
+			}
+		}
+		if openPR {
+			if err := openInBrowser(strings.TrimSpace(prResult.Stdout)); err != nil {
+				fmt.Printf("⚠️  Warning: failed to open PR in browser: %v\n", err)
+			}
+		}
+	}
+	return strings.TrimSpace(prResult.Stdout), nil
+}
+
+// addPRLabels adds labels to the PR at prURL in repoName.
+func addPRLabels(repoName, prURL string, labels []string) error {
+	var result ExecResult
+	if authMode == "gh" {
+		result = execCommand("gh", "pr", "edit", prURL, "--add-label", strings.Join(labels, ","))
+	} else {
+		prNumber := prURL[strings.LastIndex(prURL, "/")+1:]
+		raw, _ := json.Marshal(map[string][]string{"labels": labels})
+		result = withNetworkRetry("POST", func() ExecResult {
+			return githubRESTRequest("POST", fmt.Sprintf("repos/%s/issues/%s/labels", repoName, prNumber), raw, true)
+		})
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("%s", strings.TrimSpace(result.Stderr))
+	}
+	return nil
+}
+
+// addPRAssignees assigns the PR at prURL in repoName to logins.
+func addPRAssignees(repoName, prURL string, logins []string) error {
+	var result ExecResult
+	if authMode == "gh" {
+		result = execCommand("gh", "pr", "edit", prURL, "--add-assignee", strings.Join(logins, ","))
+	} else {
+		prNumber := prURL[strings.LastIndex(prURL, "/")+1:]
+		raw, _ := json.Marshal(map[string][]string{"assignees": logins})
+		result = withNetworkRetry("POST", func() ExecResult {
+			return githubRESTRequest("POST", fmt.Sprintf("repos/%s/issues/%s/assignees", repoName, prNumber), raw, true)
+		})
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("%s", strings.TrimSpace(result.Stderr))
+	}
+	return nil
+}
+
+// lastAuthorLogins returns the GitHub users who last committed to files in
+// repoDir, for --pr-assignee-from-blame: unique, in file order. Authors whose
+// email cannot be matched to a user are skipped.
+func lastAuthorLogins(repoDir string, files []string) []string {
+	var logins []string
+	seen := map[string]bool{}
+	for _, file := range files {
+		result := execCommandWithDir(repoDir, "git", "log", "-1", "--format=%ae", "--", file)
+		email := strings.TrimSpace(result.Stdout)
+		if result.ExitCode != 0 || email == "" {
+			continue
+		}
+		login, err := resolveGitHubUserByEmail(email)
+		if err != nil {
+			if debug {
+				fmt.Printf("No assignee for %s: %v\n", file, err)
+			}
+			continue
+		}
+		if !seen[login] {
+			seen[login] = true
+			logins = append(logins, login)
+		}
+	}
+	return logins
+}
+
+// githubUsersByEmail caches resolveGitHubUserByEmail lookups, including
+// failed ones, for the rest of the run.
+var (
+	githubUsersByEmail   = map[string]string{}
+	githubUsersByEmailMu sync.Mutex
+)
+
+// noreplyEmailRe matches GitHub's noreply commit emails, which carry the
+// login: 12345+octocat@users.noreply.github.com or octocat@users.noreply.github.com.
+var noreplyEmailRe = regexp.MustCompile(`^(?:\d+\+)?([A-Za-z0-9-]+)@users\.noreply\.github\.com$`)
+
+// resolveGitHubUserByEmail returns the login of the GitHub user with the given
+// public or commit email, searching users by email. Noreply addresses are
+// resolved without an API call.
+func resolveGitHubUserByEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if m := noreplyEmailRe.FindStringSubmatch(email); m != nil {
+		return m[1], nil
+	}
+
+	githubUsersByEmailMu.Lock()
+	defer githubUsersByEmailMu.Unlock()
+	if login, ok := githubUsersByEmail[email]; ok {
+		if login == "" {
+			return "", fmt.Errorf("no GitHub user found for %s", email)
+		}
+		return login, nil
+	}
+
+	result := githubAPI("GET", "search/users?q="+url.QueryEscape(email+" in:email"), nil)
+	if result.ExitCode != 0 {
+		return "", fmt.Errorf("failed to search users: %s", strings.TrimSpace(result.Stderr))
+	}
+	var search struct {
+		Items []struct {
+			Login string `json:"login"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(result.Stdout), &search); err != nil {
+		return "", fmt.Errorf("failed to parse user search: %v", err)
+	}
+	login := ""
+	if len(search.Items) > 0 {
+		login = search.Items[0].Login
+	}
+	githubUsersByEmail[email] = login
+	if login == "" {
+		return "", fmt.Errorf("no GitHub user found for %s", email)
+	}
+	return login, nil
+}
+
+// assignMilestone sets the milestone titled title on the PR at prURL in
+// repoName, creating the milestone first when --create-milestone is set.
+func assignMilestone(repoName, prURL, title string) error {
+	number, err := findMilestone(repoName, title)
+	if err != nil {
+		return err
+	}
+	if number == 0 {
+		if !createMilestone {
+			return fmt.Errorf("milestone does not exist in %s (use --create-milestone to create it)", repoName)
+		}
+		result := githubAPI("POST", fmt.Sprintf("repos/%s/milestones", repoName), map[string]interface{}{"title": title})
+		if result.ExitCode != 0 {
+			return fmt.Errorf("failed to create milestone: %s", strings.TrimSpace(result.Stderr))
+		}
+		var created struct {
+			Number int `json:"number"`
+		}
+		if err := json.Unmarshal([]byte(result.Stdout), &created); err != nil {
+			return fmt.Errorf("failed to parse created milestone: %v", err)
+		}
+		number = created.Number
+		fmt.Printf("📌 Created milestone %q in %s\n", title, repoName)
+	}
+
+	var result ExecResult
+	if authMode == "gh" {
+		result = execCommand("gh", "pr", "edit", prURL, "--milestone", title)
+	} else {
+		prNumber := prURL[strings.LastIndex(prURL, "/")+1:]
+		raw, _ := json.Marshal(map[string]int{"milestone": number})
+		result = withNetworkRetry("PATCH", func() ExecResult {
+			return githubRESTRequest("PATCH", fmt.Sprintf("repos/%s/issues/%s", repoName, prNumber), raw, true)
+		})
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("%s", strings.TrimSpace(result.Stderr))
+	}
+	return nil
+}
+
+// findMilestone returns the number of the milestone titled title in
+// repoName, or 0 when there is none.
+func findMilestone(repoName, title string) (int, error) {
+	result := githubAPI("GET", fmt.Sprintf("repos/%s/milestones?state=all&per_page=100", repoName), nil)
+	if result.ExitCode != 0 {
+		return 0, fmt.Errorf("failed to list milestones: %s", strings.TrimSpace(result.Stderr))
+	}
+	return parseMilestoneNumber(result.Stdout, title)
+}
+
+func parseMilestoneNumber(body, title string) (int, error) {
+	var milestones []struct {
+		Number int    `json:"number"`
+		Title  string `json:"title"`
+	}
+	if err := json.Unmarshal([]byte(body), &milestones); err != nil {
+		return 0, fmt.Errorf("failed to parse milestones: %v", err)
+	}
+	for _, m := range milestones {
+		if m.Title == title {
+			return m.Number, nil
+		}
+	}
+	return 0, nil
+}
+
+// openInBrowser opens prURL via gh when available, falling back to the
+// platform's URL opener in pat mode.
+func openInBrowser(prURL string) error {
+	var result ExecResult
+	if authMode == "gh" {
+		result = execCommand("gh", "pr", "view", "--web", prURL)
+	} else {
+		name, args := browserOpenCommand(runtime.GOOS, prURL)
+		result = execCommand(name, args...)
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("%s", strings.TrimSpace(result.Stderr))
+	}
+	return nil
+}
+
+// browserOpenCommand returns the command that opens a URL on goos.
+func browserOpenCommand(goos, target string) (string, []string) {
+	switch goos {
+	case "darwin":
+		return "open", []string{target}
+	case "windows":
+		return "rundll32", []string{"url.dll,FileProtocolHandler", target}
+	default:
+		return "xdg-open", []string{target}
+	}
+}
+
+// buildCommitMessage joins the commit title and body and appends one
+// Co-authored-by trailer per co-author, separated by a blank line per git convention.
+func buildCommitMessage(title, body string, coAuthors []string) string {
+	var sb strings.Builder
+	sb.WriteString(title)
+	if body != "" {
+		sb.WriteString("\n\n")
+		sb.WriteString(body)
+	}
+	if len(coAuthors) > 0 {
+		sb.WriteString("\n")
+		for _, author := range coAuthors {
+			sb.WriteString("\nCo-authored-by: ")
+			sb.WriteString(author)
+		}
+	}
+	return sb.String()
+}
+
+type commitMessageData struct {
+	Repo        string
+	PinnedCount int
+	Files       string
+	Date        string
+}
+
+// loadCommitTemplate reads and parses a --commit-template-file so that
+// template errors surface at startup rather than after cloning.
+func loadCommitTemplate(path string) (*template.Template, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read --commit-template-file %s: %w", path, err)
+	}
+	tmpl, err := template.New(filepath.Base(path)).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse --commit-template-file %s: %w", path, err)
+	}
+	return tmpl, nil
+}
+
+// renderCommitTemplate renders the commit message; trailing whitespace is
+// trimmed so co-author trailers stay separated by exactly one blank line.
+func renderCommitTemplate(tmpl *template.Template, repoName string, files []string, pinnedCount int) (string, error) {
+	data := commitMessageData{
+		Repo:        repoName,
+		PinnedCount: pinnedCount,
+		Files:       strings.Join(files, ", "),
+		Date:        time.Now().Format("2006-01-02"),
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(buf.String(), " \t\r\n"), nil
+}
+
+// changedFiles lists the modified paths in repoDir's working tree, relative
+// to repoDir.
+func changedFiles(repoDir string) []string {
+	result := execCommandWithDir(repoDir, "git", "diff", "--name-only", "--relative")
+	if result.ExitCode != 0 {
+		return nil
+	}
+	var files []string
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, line)
+		}
+	}
+	return files
+}
+
+// commitLocalChanges commits the files patchLocalRepository rewrote in a local
+// repository using the configured commit message template. Other modified or
+// staged files are left out of the commit. Nothing is pushed.
+func commitLocalChanges(repoDir string, summary runSummary) error {
+	files := summary.written
+	if len(files) == 0 {
+		return nil
+	}
+	rewritten := map[string]bool{}
+	for _, file := range files {
+		rewritten[file] = true
+	}
+	// Diffing against HEAD covers staged as well as unstaged changes.
+	var others []string
+	for _, file := range strings.Split(execCommandWithDir(repoDir, "git", "diff", "--name-only", "--relative", "HEAD").Stdout, "\n") {
+		if file = strings.TrimSpace(file); file != "" && !rewritten[file] {
+			others = append(others, file)
+		}
+	}
+	if len(others) > 0 {
+		fmt.Printf("⚠️  Warning: leaving %d other modified file(s) out of the commit: %s\n", len(others), strings.Join(others, ", "))
+	}
+
+	repoName := filepath.Base(repoDir)
+	if abs, err := filepath.Abs(repoDir); err == nil {
+		repoName = filepath.Base(abs)
+	}
+	if remote := execCommandWithDir(repoDir, "git", "remote", "get-url", "origin"); remote.ExitCode == 0 {
+		if name, err := extractRepoNameFromURL(strings.TrimSpace(remote.Stdout)); err == nil {
+			repoName = name
+		}
+	}
+
+	rendered, err := renderCommitTemplate(commitTemplate, repoName, files, summary.actionsPinned)
+	if err != nil {
+		return fmt.Errorf("failed to render commit message template: %w", err)
+	}
+	if signCommits {
+		if err := verifySigningSetup(repoDir, signingKey); err != nil {
+			return err
+		}
+	}
+
+	// Naming the paths after -- commits only those files, even if others are staged.
+	commitArgs := append(buildCommitArgs(buildCommitMessage(rendered, "", coAuthors), signCommits, signingKey), "--")
+	commands := [][]string{
+		append([]string{"git", "add", "--"}, files...),
+		append(commitArgs, files...),
+	}
+	for _, cmd := range commands {
+		if result := execCommandWithDir(repoDir, cmd[0], cmd[1:]...); result.ExitCode != 0 {
+			return fmt.Errorf("failed to %s: %s", strings.Join(cmd[:2], " "), result.Stderr)
+		}
+	}
+	fmt.Printf("📝 Committed %d changed file(s) in %s\n", len(files), repoDir)
+	return nil
+}
+
+// buildCommitArgs returns the git commit command line, adding -S (or
+// --gpg-sign=<key> when a key is given) for signed commits.
+func buildCommitArgs(message string, sign bool, key string) []string {
+	args := []string{"git", "commit"}
+	if sign {
+		if key != "" {
+			args = append(args, "--gpg-sign="+key)
+		} else {
+			args = append(args, "-S")
+		}
+	}
+	return append(args, "-m", message)
+}
+
+// verifySigningSetup checks that the signing program configured for repoDir is
+// available before committing, so a missing gpg fails with an actionable error
+// instead of an opaque git error. x509 (e.g. gitsign/sigstore) and ssh formats
+// are honored via git config.
+func verifySigningSetup(repoDir, key string) error {
+	format := strings.TrimSpace(execCommandWithDir(repoDir, "git", "config", "--get", "gpg.format").Stdout)
+	if format == "" {
+		format = "openpgp"
+	}
+
+	program := strings.TrimSpace(execCommandWithDir(repoDir, "git", "config", "--get", fmt.Sprintf("gpg.%s.program", format)).Stdout)
+	if program == "" && format == "openpgp" {
+		program = strings.TrimSpace(execCommandWithDir(repoDir, "git", "config", "--get", "gpg.program").Stdout)
+	}
+	if program == "" {
+		switch format {
+		case "x509":
+			program = "gpgsm"
+		case "ssh":
+			program = "ssh-keygen"
+		default:
+			program = "gpg"
+		}
+	}
+	if _, err := exec.LookPath(program); err != nil {
+		return fmt.Errorf("--sign-commits requires %q (gpg.format=%s) but it was not found in PATH; install it or set gpg.%s.program", program, format, format)
+	}
+
+	if key == "" {
+		key = strings.TrimSpace(execCommandWithDir(repoDir, "git", "config", "--get", "user.signingkey").Stdout)
+	}
+	if format == "openpgp" && key != "" {
+		if result := execCommandWithDir(repoDir, program, "--list-secret-keys", key); result.ExitCode != 0 {
+			return fmt.Errorf("signing key %q could not be loaded by %s: %s", key, program, strings.TrimSpace(result.Stderr))
+		}
+	}
+	return nil
+}
+
+// loadCoAuthorsFile reads "Name <email>" entries from path, skipping blank lines and # comments.
+func loadCoAuthorsFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open co-authorship file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var authors []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if coAuthorEmail(line) == "" {
+			return nil, fmt.Errorf("invalid co-author entry %q in %s (expected \"Name <email>\")", line, path)
+		}
+		authors = append(authors, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read co-authorship file %s: %w", path, err)
+	}
+	return authors, nil
+}
+
+// mergeCoAuthors combines co-author lists, keeping the first entry for each email (case-insensitive).
+func mergeCoAuthors(lists ...[]string) []string {
+	merged := []string{}
+	seen := map[string]bool{}
+	for _, list := range lists {
+		for _, author := range list {
+			author = strings.TrimSpace(author)
+			if author == "" {
+				continue
+			}
+			key := strings.ToLower(coAuthorEmail(author))
+			if key == "" {
+				key = strings.ToLower(author)
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			merged = append(merged, author)
+		}
+	}
+	return merged
+}
+
+// coAuthorEmail extracts the address between angle brackets, or "" if there is none.
+func coAuthorEmail(author string) string {
+	start := strings.LastIndex(author, "<")
+	end := strings.LastIndex(author, ">")
+	if start == -1 || end <= start+1 {
+		return ""
+	}
+	return strings.TrimSpace(author[start+1 : end])
+}
+
+// parseDayDuration parses a Go duration, additionally accepting a whole number
+// of days with a "d" suffix (e.g. "7d").
+func parseDayDuration(value string) (time.Duration, error) {
+	if strings.HasSuffix(value, "d") {
+		var days int
+		if _, err := fmt.Sscanf(strings.TrimSuffix(value, "d"), "%d", &days); err != nil {
+			return 0, fmt.Errorf("invalid day duration %q", value)
+		}
+		if fmt.Sprintf("%dd", days) != value {
+			return 0, fmt.Errorf("invalid day duration %q", value)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// hasRecentPR reports whether the PR list JSON contains a PR created after
+// now-maxAge, returning its URL.
+func hasRecentPR(prListJSON string, maxAge time.Duration, now time.Time) (bool, string) {
+	var prs []struct {
+		URL       string    `json:"url"`
+		CreatedAt time.Time `json:"createdAt"`
+	}
+	if err := json.Unmarshal([]byte(prListJSON), &prs); err != nil {
+		return false, ""
+	}
+	cutoff := now.Add(-maxAge)
+	for _, pr := range prs {
+		if pr.CreatedAt.After(cutoff) {
+			return true, pr.URL
+		}
+	}
+	return false, ""
+}
+
+// stalePRURLs returns the URLs of PRs in the PR list JSON created before
+// now-maxAge.
+func stalePRURLs(prListJSON string, maxAge time.Duration, now time.Time) []string {
+	var prs []struct {
+		URL       string    `json:"url"`
+		CreatedAt time.Time `json:"createdAt"`
+	}
+	if err := json.Unmarshal([]byte(prListJSON), &prs); err != nil {
+		return nil
+	}
+	cutoff := now.Add(-maxAge)
+	var urls []string
+	for _, pr := range prs {
+		if pr.CreatedAt.Before(cutoff) {
+			urls = append(urls, pr.URL)
+		}
+	}
+	return urls
+}
+
+// closeStalePRs closes the open pinning PRs in repoNames older than
+// --pr-close-stale, leaving stalePRComment on each, and returns how many were
+// closed. Failures are warnings: the run goes on to recreate PRs either way.
+func closeStalePRs(repoNames []string) int {
+	closed := 0
+	for _, repoName := range repoNames {
+		if runCtx.Err() != nil {
+			break
+		}
+		result := listOpenPRs(repoName, getPRSearchPattern(repoName), "")
+		if result.ExitCode != 0 {
+			fmt.Printf("⚠️  Warning: failed to list PRs in %s for --pr-close-stale: %s\n", repoName, strings.TrimSpace(result.Stderr))
+			continue
+		}
+		for _, prURL := range stalePRURLs(result.Stdout, prCloseStale, time.Now()) {
+			if err := closePullRequest(repoName, prURL, stalePRComment); err != nil {
+				fmt.Printf("⚠️  Warning: failed to close stale PR %s: %v\n", prURL, err)
+				continue
+			}
+			if debug {
+				fmt.Printf("Closed stale PR %s\n", prURL)
+			}
+			closed++
+		}
+	}
+	fmt.Printf("🧹 Closed %d stale pinning PR(s) older than %s\n", closed, prCloseStaleRaw)
+	return closed
+}
+
+// closePullRequest comments on and closes the PR at prURL in repoName.
+func closePullRequest(repoName, prURL, comment string) error {
+	if authMode == "gh" {
+		if result := execCommand("gh", "pr", "close", prURL, "--comment", comment); result.ExitCode != 0 {
+			return fmt.Errorf("%s", strings.TrimSpace(result.Stderr))
+		}
+		return nil
+	}
+
+	prNumber := prURL[strings.LastIndex(prURL, "/")+1:]
+	raw, _ := json.Marshal(map[string]string{"body": comment})
+	result := withNetworkRetry("POST", func() ExecResult {
+		return githubRESTRequest("POST", fmt.Sprintf("repos/%s/issues/%s/comments", repoName, prNumber), raw, true)
+	})
+	if result.ExitCode != 0 {
+		return fmt.Errorf("failed to comment: %s", strings.TrimSpace(result.Stderr))
+	}
+	raw, _ = json.Marshal(map[string]string{"state": "closed"})
+	result = withNetworkRetry("PATCH", func() ExecResult {
+		return githubRESTRequest("PATCH", fmt.Sprintf("repos/%s/pulls/%s", repoName, prNumber), raw, true)
+	})
+	if result.ExitCode != 0 {
+		return fmt.Errorf("%s", strings.TrimSpace(result.Stderr))
+	}
+	return nil
+}
+
+func forkRepository(repoName string) (string, error) {
+	// Check if fork already exists
+	parts := strings.Split(repoName, "/")
+	if len(parts) != 2 {
+		return "", fmt.Errorf("invalid repository name format: %s", repoName)
+	}
+
+	// Get current username
+	username, err := getCurrentUserLogin()
+	if err != nil {
+		return "", fmt.Errorf("failed to get current user: %v", err)
+	}
+
+	forkName := fmt.Sprintf("%s/%s", username, parts[1])
+
+	// Check if fork already exists
+	if _, err := getRepositoryMetadata(forkName); err == nil {
+		if debug {
+			fmt.Printf("Fork already exists: %s\n", forkName)
+		}
+		return forkName, nil
+	}
+
+	// Create fork
+	if debug {
+		fmt.Printf("Creating fork of %s...\n", repoName)
+	}
+	if forkVisibility != "" {
+		if err := checkForkVisibility(repoName); err != nil {
+			return "", err
+		}
+	}
+
+	if err := createFork(repoName); err != nil {
+		return "", fmt.Errorf("failed to fork repository: %v", err)
+	}
+
+	if debug {
+		fmt.Printf("Successfully forked %s to %s\n", repoName, forkName)
+	}
+
+	return forkName, nil
+}
+
+// syncForkWithUpstream brings branch of the fork up to date with upstream
+// using --sync-fork-strategy. An empty branch means the upstream default branch.
+func syncForkWithUpstream(forkName, upstreamName, branch string) error {
+	if syncForkStrategy == "none" {
+		if debug {
+			fmt.Printf("Skipping sync of fork %s (--sync-fork-strategy none)\n", forkName)
+		}
+		return nil
+	}
+	if debug {
+		fmt.Printf("Checking if fork %s needs to be synced with upstream %s...\n", forkName, upstreamName)
+	}
+
+	defaultBranch := branch
+	if defaultBranch == "" {
+		// Get the default branch of the upstream repository
+		upstreamRepo, err := getRepositoryMetadata(upstreamName)
+		if err != nil {
+			return fmt.Errorf("failed to get upstream repository info: %v", err)
+		}
+		defaultBranch = getDefaultBranch(upstreamRepo)
+	}
+
+	// Get the latest commit SHA from upstream
+	upstreamCommitResult := githubAPI("GET", fmt.Sprintf("repos/%s/commits/%s", upstreamName, defaultBranch), nil)
+	if upstreamCommitResult.ExitCode != 0 {
+		return fmt.Errorf("failed to get upstream commit: %s", upstreamCommitResult.Stderr)
+	}
+
+	var upstreamCommit map[string]interface{}
+	if err := json.Unmarshal([]byte(upstreamCommitResult.Stdout), &upstreamCommit); err != nil {
+		return fmt.Errorf("failed to parse upstream commit: %v", err)
+	}
+
+	upstreamSHA, ok := upstreamCommit["sha"].(string)
+	if !ok {
+		return fmt.Errorf("failed to get upstream commit SHA")
+	}
+
+	// Get the latest commit SHA from fork
+	forkCommitResult := githubAPI("GET", fmt.Sprintf("repos/%s/commits/%s", forkName, defaultBranch), nil)
+	if forkCommitResult.ExitCode != 0 {
+		return fmt.Errorf("failed to get fork commit: %s", forkCommitResult.Stderr)
+	}
+
+	var forkCommit map[string]interface{}
+	if err := json.Unmarshal([]byte(forkCommitResult.Stdout), &forkCommit); err != nil {
+		return fmt.Errorf("failed to parse fork commit: %v", err)
+	}
+
+	forkSHA, ok := forkCommit["sha"].(string)
+	if !ok {
+		return fmt.Errorf("failed to get fork commit SHA")
+	}
+
+	// Check if fork is behind upstream
+	if upstreamSHA == forkSHA {
+		if debug {
+			fmt.Printf("Fork %s is up-to-date with upstream %s\n", forkName, upstreamName)
+		}
+		return nil
+	}
+
+	if debug {
+		fmt.Printf("Fork %s is behind upstream %s, syncing...\n", forkName, upstreamName)
+		fmt.Printf("  Fork SHA: %s\n", forkSHA)
+		fmt.Printf("  Upstream SHA: %s\n", upstreamSHA)
+	}
+
+	// A freshly created fork may not have propagated yet, so both the sync and
+	// its verification are retried.
+	var syncErr error
+	for attempt := 1; attempt <= forkSyncAttempts; attempt++ {
+		if attempt > 1 {
+			if debug {
+				fmt.Printf("Retrying sync of fork %s (attempt %d/%d): %v\n", forkName, attempt, forkSyncAttempts, syncErr)
+			}
+			select {
+			case <-runCtx.Done():
+				return runCtx.Err()
+			case <-time.After(forkSyncDelay):
+			}
+		}
+
+		var syncResult ExecResult
+		if syncForkStrategy == "gh-sync" {
+			syncResult = execCommand("gh", "repo", "sync", forkName, "--branch", defaultBranch)
+		} else {
+			syncResult = githubAPI("POST", fmt.Sprintf("repos/%s/merge-upstream", forkName), map[string]interface{}{
+				"branch": defaultBranch,
+			})
+		}
+		if syncResult.ExitCode != 0 {
+			syncErr = fmt.Errorf("failed to sync fork with upstream: %s", syncResult.Stderr)
+			continue
+		}
+		if !verifySyncSuccess(forkName+":"+defaultBranch, upstreamSHA) {
+			syncErr = errForkNotSynced
+			continue
+		}
+		if debug {
+			fmt.Printf("Successfully synced fork %s with upstream %s\n", forkName, upstreamName)
+		}
+		return nil
+	}
+
+	if errors.Is(syncErr, errForkNotSynced) {
+		// The sync was accepted; patching a slightly stale fork is better than
+		// failing the repository.
+		fmt.Printf("⚠️  Warning: fork %s does not contain upstream commit %s after %d attempt(s); continuing\n", forkName, upstreamSHA, forkSyncAttempts)
+		return nil
+	}
+	return syncErr
+}
+
+// verifySyncSuccess reports whether the fork branch now contains upstreamSHA.
+// forkName may carry a branch as "owner/repo:branch"; without one the fork's
+// default branch is checked. A merge-upstream that produced a merge commit
+// leaves the fork ahead of upstream, which also counts as success.
+func verifySyncSuccess(forkName, upstreamSHA string) bool {
+	ref := "HEAD"
+	if idx := strings.LastIndex(forkName, ":"); idx != -1 {
+		forkName, ref = forkName[:idx], forkName[idx+1:]
+	}
+	result := githubAPI("GET", fmt.Sprintf("repos/%s/commits/%s", forkName, ref), nil)
+	if result.ExitCode != 0 {
+		return false
+	}
+	forkSHA := parseCommitSHA(result.Stdout)
+	if forkSHA == "" {
+		return false
+	}
+	if forkSHA == upstreamSHA {
+		return true
+	}
+	compare := githubAPI("GET", fmt.Sprintf("repos/%s/compare/%s...%s", forkName, upstreamSHA, forkSHA), nil)
+	if compare.ExitCode != 0 {
+		return false
+	}
+	return compareContainsBase(compare.Stdout)
+}
+
+// compareContainsBase reports whether a compare API response says head
+// already includes base, i.e. the status is "identical" or "ahead".
+func compareContainsBase(body string) bool {
+	var comparison struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal([]byte(body), &comparison); err != nil {
+		return false
+	}
+	return comparison.Status == "identical" || comparison.Status == "ahead"
+}
+
+// checkBranchExists returns an error if branch does not exist in repoName.
+func checkBranchExists(repoName, branch string) error {
+	result := githubAPI("GET", fmt.Sprintf("repos/%s/branches/%s", repoName, branch), nil)
+	if result.ExitCode != 0 {
+		return fmt.Errorf("base branch %q not found in %s: %s", branch, repoName, strings.TrimSpace(result.Stderr))
+	}
+	return nil
+}
+
+// checkoutUpstreamBranch checks out branch from the upstream remote of a fork clone.
+func checkoutUpstreamBranch(repoDir, branch string) error {
+	if result := execCommandWithDir(repoDir, "git", "fetch", "upstream", branch, "--quiet"); result.ExitCode != 0 {
+		return fmt.Errorf("failed to fetch base branch %s from upstream: %s", branch, result.Stderr)
+	}
+	if result := execCommandWithDir(repoDir, "git", "checkout", "-B", branch, "FETCH_HEAD"); result.ExitCode != 0 {
+		return fmt.Errorf("failed to check out base branch %s: %s", branch, result.Stderr)
+	}
+	return nil
+}
+
+func checkRepositoryPermissions(repoName string) error {
+	// Check if the current user has write access to the repository
+	result := githubAPI("GET", fmt.Sprintf("repos/%s", repoName), nil)
+	if result.ExitCode != 0 {
+		return fmt.Errorf("failed to check repository permissions: %s", result.Stderr)
+	}
+
+	var repoInfo map[string]interface{}
+	if err := json.Unmarshal([]byte(result.Stdout), &repoInfo); err != nil {
+		return fmt.Errorf("failed to parse repository info: %v", err)
+	}
+
+	permissions, ok := repoInfo["permissions"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("unable to determine repository permissions")
+	}
+
+	canPush, ok := permissions["push"].(bool)
+	if !ok || !canPush {
+		// No push access, need to fork
+		if debug {
+			fmt.Printf("No push access to %s, will fork repository\n", repoName)
+		}
+		return errNeedsFork
+	}
+
+	if defaultBranch, ok := repoInfo["default_branch"].(string); ok && defaultBranch != "" {
+		checkBranchProtection(repoName, defaultBranch)
+	}
+
+	if debug {
+		fmt.Printf("Repository permissions verified for: %s\n", repoName)
+	}
+
+	return nil
+}
+
+type branchProtection struct {
+	RequiredPullRequestReviews *struct{} `json:"required_pull_request_reviews"`
+	RequiredStatusChecks       *struct {
+		Strict bool `json:"strict"`
+	} `json:"required_status_checks"`
+	Restrictions *struct {
+		Users []struct {
+			Login string `json:"login"`
+		} `json:"users"`
+	} `json:"restrictions"`
+}
+
+// checkBranchProtection inspects the default branch protection. Required
+// reviews are expected since pinning always goes through a PR, but push
+// restrictions that exclude the current user are worth a warning because
+// permissions.push alone does not reveal them. Protection is only readable
+// with admin access, so lookup failures are silently ignored.
+func checkBranchProtection(repoName, branch string) {
+	result := githubAPI("GET", fmt.Sprintf("repos/%s/branches/%s/protection", repoName, branch), nil)
+	if result.ExitCode != 0 {
+		if debug {
+			fmt.Printf("Branch protection for %s@%s not available: %s\n", repoName, branch, strings.TrimSpace(result.Stderr))
+		}
+		return
+	}
+	login, err := getCurrentUserLogin()
+	if err != nil {
+		login = ""
+	}
+	for _, warning := range branchProtectionWarnings(result.Stdout, login) {
+		fmt.Printf("⚠️  Warning: %s (%s@%s)\n", warning, repoName, branch)
+	}
+}
+
+// branchProtectionWarnings returns the warnings for a branches/<branch>/protection response.
+func branchProtectionWarnings(body, login string) []string {
+	var protection branchProtection
+	if err := json.Unmarshal([]byte(body), &protection); err != nil {
+		return nil
+	}
+	if debug {
+		fmt.Printf("Branch protection: required reviews=%v, strict status checks=%v\n",
+			protection.RequiredPullRequestReviews != nil,
+			protection.RequiredStatusChecks != nil && protection.RequiredStatusChecks.Strict)
+	}
+	if protection.Restrictions == nil {
+		return nil
+	}
+	for _, user := range protection.Restrictions.Users {
+		if login != "" && strings.EqualFold(user.Login, login) {
+			return nil
+		}
+	}
+	return []string{"branch has push restrictions and you are not in the allowed users list - pushing the pinning branch may fail even though push permission is granted"}
+}
+
+func configureGitCredentials(repoDir string) error {
+	if authMode == "pat" {
+		username, err := getCurrentUserLogin()
+		if err != nil {
+			username = "gha-pinner"
+		}
+		setGitConfig(repoDir, "user.name", username)
+		setGitConfig(repoDir, "user.email", username+"@users.noreply.github.com")
+		return nil
+	}
+
+	execCommandWithDir(repoDir, "git", append(gitConfigScope(), "--unset", "credential.helper")...)
+	if result := setGitConfig(repoDir, "credential.helper", "!gh auth git-credential"); result.ExitCode != 0 {
+		return fmt.Errorf("failed to configure git credentials: %s", result.Stderr)
+	}
+
+	// Also set the git user identity from gh auth status
+	result := execCommandWithDir(repoDir, "gh", "auth", "status", "--hostname", "github.com")
+	if result.ExitCode == 0 && strings.Contains(result.Stdout, "Logged in to github.com account") {
+		// Extract the username from the auth status
+		lines := strings.Split(result.Stdout, "\n")
+		for _, line := range lines {
+			if strings.Contains(line, "Logged in to github.com account") && strings.Contains(line, "Active account: true") {
+				// Extract username from the line format: "✓ Logged in to github.com account username (keyring)"
+				parts := strings.Fields(line)
+				for i, part := range parts {
+					if part == "account" && i+1 < len(parts) {
+						username := strings.TrimSuffix(parts[i+1], " (keyring)")
+						username = strings.TrimSuffix(username, " (oauth_token)")
+						if debug {
+							fmt.Printf("Setting git user identity to: %s\n", username)
+						}
+						setGitConfig(repoDir, "user.name", username)
+						setGitConfig(repoDir, "user.email", username+"@users.noreply.github.com")
+						break
+					}
+				}
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// gitConfigScope returns the git config arguments selecting where settings
+// are written: the --gitconfig-file if given, otherwise the repository config.
+func gitConfigScope() []string {
+	if gitConfigFile != "" {
+		return []string{"config", "--file", gitConfigFile}
+	}
+	return []string{"config"}
+}
+
+func setGitConfig(repoDir, key, value string) ExecResult {
+	return execCommandWithDir(repoDir, "git", append(gitConfigScope(), key, value)...)
+}
+
+// hasWorkflowTargets reports whether repoDir has a .github/workflows directory
+// or workflow templates, i.e. whether patchLocalRepository has anything to do.
+// Errors are reported as true so that patchLocalRepository surfaces them.
+func hasWorkflowTargets(repoDir string) bool {
+	if _, err := os.Stat(filepath.Join(repoDir, ".github", "workflows")); !os.IsNotExist(err) {
+		return true
+	}
+	ignore, err := loadIgnoreFile(repoDir)
+	if err != nil {
+		return true
+	}
+	templates, err := listWorkflowTemplates(repoDir, ignore)
+	return err != nil || len(templates) > 0
+}
+
+func patchLocalRepository(repoDir string) (runSummary, error) {
+	ignore, err := loadIgnoreFile(repoDir)
+	if err != nil {
+		return runSummary{}, err
+	}
+
+	templateFiles, err := listWorkflowTemplates(repoDir, ignore)
+	if err != nil {
+		return runSummary{}, err
+	}
+
+	workflowsDir := filepath.Join(repoDir, ".github", "workflows")
+	if _, err := os.Stat(workflowsDir); os.IsNotExist(err) && len(templateFiles) == 0 {
+		if !skipIfNoWorkflows {
+			fmt.Printf("ℹ️  No .github/workflows directory found - no GitHub Actions to pin\n")
+		}
+		return runSummary{}, nil
+	}
+
+	files, err := os.ReadDir(workflowsDir)
+	if err != nil && !os.IsNotExist(err) {
+		return runSummary{}, fmt.Errorf("failed to read workflows directory: %v", err)
+	}
+
+	workflowFiles := []string{}
+	for _, file := range files {
+		if !file.IsDir() && (strings.HasSuffix(file.Name(), ".yml") || strings.HasSuffix(file.Name(), ".yaml")) {
+			if ignore.matches(filepath.ToSlash(filepath.Join(".github", "workflows", file.Name()))) {
+				fmt.Printf("⏭️  Skipping %s (listed in %s)\n", file.Name(), ignoreFileName)
+				continue
+			}
+			workflowFiles = append(workflowFiles, file.Name())
+		}
+	}
+
+	if len(workflowFiles) == 0 && len(templateFiles) == 0 {
+		fmt.Printf("ℹ️  No workflow files found in .github/workflows directory\n")
+		return runSummary{}, nil
+	}
+
+	// Composite actions in .github/actions/ are patched like workflows.
+	var compositeFiles []string
+	compositeFilesIgnored := 0
+	actionsBaseDir := filepath.Join(repoDir, ".github", "actions")
+	if _, statErr := os.Stat(actionsBaseDir); statErr == nil {
+		walkErr := filepath.WalkDir(actionsBaseDir, func(path string, d os.DirEntry, walkEntryErr error) error {
+			if walkEntryErr != nil {
+				return walkEntryErr
+			}
+			if d.IsDir() {
+				return nil
+			}
+			if !strings.HasSuffix(path, ".yml") && !strings.HasSuffix(path, ".yaml") {
+				return nil
+			}
+			if rel, relErr := filepath.Rel(repoDir, path); relErr == nil && ignore.matches(filepath.ToSlash(rel)) {
+				if debug {
+					fmt.Printf("Skipping composite action %s (listed in %s)\n", rel, ignoreFileName)
+				}
+				return nil
+			}
+			if ignoreCompositeRefs {
+				compositeFilesIgnored++
+				return nil
+			}
+			compositeFiles = append(compositeFiles, path)
+			return nil
+		})
+		if walkErr != nil && debug {
+			fmt.Printf("Warning: error walking actions directory: %v\n", walkErr)
+		}
+	}
+
+	// os.ReadDir and filepath.WalkDir sort by name, so the same files are
+	// picked on every run; composite actions get what the workflows leave.
+	eligibleFiles := len(workflowFiles) + len(compositeFiles)
+	if maxWorkflowFiles > 0 {
+		if len(workflowFiles) > maxWorkflowFiles {
+			workflowFiles = workflowFiles[:maxWorkflowFiles]
+		}
+		if remaining := maxWorkflowFiles - len(workflowFiles); len(compositeFiles) > remaining {
+			compositeFiles = compositeFiles[:remaining]
+		}
+	}
+
+	if len(workflowFiles) > 0 {
+		fmt.Printf("🔍 Found %d workflow file(s): %s\n", len(workflowFiles), strings.Join(workflowFiles, ", "))
+	}
+	if len(templateFiles) > 0 {
+		templateNames := make([]string, 0, len(templateFiles))
+		for _, path := range templateFiles {
+			templateNames = append(templateNames, filepath.Base(path))
+		}
+		fmt.Printf("📐 Found %d workflow template(s): %s\n", len(templateFiles), strings.Join(templateNames, ", "))
+	}
+
+	totalActionsPinned := 0
+	totalActionsAlreadyPinned := 0
+	totalActionsSkipped := 0
+	totalActionsWithLatest := 0
+	totalActionsWithoutTags := 0
+	totalActionsNotFound := 0
+	totalActionsInactive := 0
+	totalActionsLowTrust := 0
+	totalActionsFound := 0
+	var summary runSummary
+	totalHardenInjected := 0
+	totalRunnersReplaced := 0
+	totalMissingPermissions := 0
+	totalPermissionsAdded := 0
+	totalInjectionFindings := 0
+	totalDispatchFindings := 0
+	totalPermissionsIssues := 0
+	totalFilesTooSmall := 0
+	totalActionsNonSemver := 0
+	totalActionsBelowMin := 0
+	totalLicenseFindings := 0
+	compositeFilesProcessed := 0
+
+	patcher := &WorkflowPatcher{
+		injectHardenRunner: injectHardenRunner,
+		egressPolicy:       egressPolicy,
+		pinRunners:         pinRunners,
+		runnerMap:          runnerMap,
+		requirePermissions: requirePermissions,
+		addPermissions:     addPermissions,
+		detectInjection:    detectInjection,
+		detectDispatch:     detectDispatchInputs,
+		verifySyntax:       verifyWorkflowSyntax,
+		reportPermissions:  reportPermissions,
+		minWorkflowSize:    minWorkflowSize,
+		preserveMtime:      preserveMtime,
+		securityComment:    patcherSecurityComment(),
+	}
+
+	targets := make([]string, 0, len(workflowFiles)+len(templateFiles))
+	for _, name := range workflowFiles {
+		targets = append(targets, filepath.Join(workflowsDir, name))
+	}
+	targets = append(targets, templateFiles...)
+
+	// add counts the findings of one patched file, workflow or composite action.
+	add := func(path string, res patchResult) {
+		totalActionsPinned += res.actionsPinned
+		totalActionsAlreadyPinned += res.actionsAlreadyPinned
+		totalActionsSkipped += res.actionsSkipped
+		totalActionsWithLatest += res.actionsWithLatest
+		totalActionsWithoutTags += res.actionsWithoutTags
+		totalActionsNotFound += res.actionsNotFound
+		totalActionsInactive += res.actionsInactive
+		totalActionsLowTrust += res.actionsLowTrust
+		totalActionsNonSemver += res.actionsNonSemver
+		totalActionsBelowMin += res.actionsBelowMin
+		totalLicenseFindings += res.licenseFindings
+		totalActionsFound += res.totalActions
+		if rel, relErr := filepath.Rel(repoDir, path); relErr == nil {
+			summary.add(filepath.ToSlash(rel), res)
+		}
+		totalHardenInjected += res.hardenInjected
+		totalRunnersReplaced += res.runnersReplaced
+		totalMissingPermissions += res.missingPermissions
+		totalPermissionsAdded += res.permissionsAdded
+		totalInjectionFindings += res.injectionFindings
+		totalDispatchFindings += res.dispatchFindings
+		totalPermissionsIssues += res.permissionsIssues
+		totalFilesTooSmall += res.filesTooSmall
+	}
+
+	for _, path := range targets {
+		res, err := patcher.patchFile(path)
+		if err != nil {
+			return runSummary{}, fmt.Errorf("failed to process workflow file %s: %v", filepath.Base(path), err)
+		}
+		add(path, res)
+	}
+	for _, path := range compositeFiles {
+		res, err := patcher.patchFile(path)
+		if err != nil {
+			fmt.Printf("⚠️  Warning: failed to process composite action %s: %v\n", path, err)
+			continue
+		}
+		if res.filesTooSmall == 0 {
+			compositeFilesProcessed++
+		}
+		add(path, res)
+	}
+
+	runReport.addPinned(totalActionsPinned)
+
+	// Summary of actions processed
+	fmt.Printf("\n📊 Summary:\n")
+	fmt.Printf("   • Total actions found: %d\n", totalActionsFound)
+	fmt.Printf("   • Actions pinned: %d\n", totalActionsPinned)
+	if processed := len(workflowFiles) + len(compositeFiles); processed < eligibleFiles {
+		fmt.Printf("   • Processed %d/%d workflow and composite action files (limited by --max-workflow-files)\n", processed, eligibleFiles)
+	}
+	if compositeFilesProcessed > 0 {
+		fmt.Printf("   • Composite action files processed: %d\n", compositeFilesProcessed)
+	}
+	if compositeFilesIgnored > 0 {
+		fmt.Printf("   • Composite action files skipped (--ignore-composite-action-refs): %d\n", compositeFilesIgnored)
+	}
+	fmt.Printf("   • Actions already pinned: %d\n", totalActionsAlreadyPinned)
+	if detectThirdParty {
+		fmt.Printf("   • Pinned by category: %s\n", formatCategoryCounts(countByCategory(summary.changes)))
+	}
+	if injectHardenRunner {
+		fmt.Printf("   • Harden-runner injected: %d job(s)\n", totalHardenInjected)
+	}
+	if pinRunners {
+		fmt.Printf("   • Runner labels pinned: %d\n", totalRunnersReplaced)
+	}
+	if requirePermissions {
+		fmt.Printf("   • Workflows without permissions block (%s): %d\n", ruleMissingPermissions, totalMissingPermissions)
+	}
+	if addPermissions {
+		fmt.Printf("   • Permissions blocks added: %d\n", totalPermissionsAdded)
+	}
+	if detectInjection {
+		fmt.Printf("   • Expression injection findings (%s): %d\n", ruleExpressionInjection, totalInjectionFindings)
+	}
+	if detectDispatchInputs {
+		fmt.Printf("   • workflow_dispatch triggers without inputs (%s): %d\n", ruleDispatchWithoutInputs, totalDispatchFindings)
+	}
+	if verifyWorkflowSyntax {
+		fmt.Printf("   • Workflow schema violations: %d\n", len(summary.syntaxIssues))
+	}
+	if checkLicense {
+		fmt.Printf("   • Actions with disallowed licenses (%s): %d\n", ruleDisallowedLicense, totalLicenseFindings)
+	}
+	if reportPermissions {
+		fmt.Printf("   • Overly broad permissions findings (%s): %d\n", rulePermissionsTooBroad, totalPermissionsIssues)
+	}
+	fmt.Printf("   • Actions with @latest: %d\n", totalActionsWithLatest)
+	fmt.Printf("   • Actions without tag/ref: %d\n", totalActionsWithoutTags)
+	fmt.Printf("   • Actions not found: %d\n", totalActionsNotFound)
+	if maxCommitAge > 0 {
+		fmt.Printf("   • Actions skipped as inactive (> %s): %d\n", maxCommitAgeRaw, totalActionsInactive)
+	}
+	if requireMinStars > 0 {
+		fmt.Printf("   • Actions skipped as low-trust (< %d stars): %d\n", requireMinStars, totalActionsLowTrust)
+	}
+	if strictSemver {
+		fmt.Printf("   • Non-semver versions left unpinned (%s): %d\n", ruleNonSemverVersion, totalActionsNonSemver)
+	}
+	if len(minActionVersions) > 0 {
+		fmt.Printf("   • Versions below --min-action-version left unpinned: %d\n", totalActionsBelowMin)
+	}
+	fmt.Printf("   • Actions skipped: %d\n", totalActionsSkipped)
+	if minWorkflowSize > 0 {
+		fmt.Printf("   • Files skipped (too small): %d\n", totalFilesTooSmall)
 	}
 
-	fmt.Printf("Current auth status:\n%s\n", result.Stdout)
+	if totalActionsPinned == 0 && totalActionsAlreadyPinned > 0 {
+		fmt.Printf("✅ All GitHub Actions are already properly pinned to commit hashes\n")
+	} else if totalActionsPinned == 0 && totalActionsAlreadyPinned == 0 && totalActionsSkipped > 0 {
+		fmt.Printf("ℹ️  No GitHub Actions found that need pinning (only local actions or already pinned)\n")
+	} else if totalActionsPinned == 0 {
+		fmt.Printf("ℹ️  No GitHub Actions found in workflow files\n")
+	} else {
+		fmt.Printf("✅ Successfully pinned %d GitHub Action(s) to commit hashes\n", totalActionsPinned)
+		if skipPRCreation {
+			fmt.Printf("   • Repository location: %s\n", repoDir)
+			fmt.Printf("   • Changes are ready for review and manual commit\n")
+		}
+	}
 
-	// Switch to the specified account
-	result = execCommand("gh", "auth", "switch", "--user", username)
-	if result.ExitCode != 0 {
-		return fmt.Errorf("failed to switch account to %s: %s", username, result.Stderr)
+	if totalActionsWithLatest > 0 {
+		fmt.Printf("⚠️  Warning: %d action(s) using @latest tag detected - these should be pinned for better security\n", totalActionsWithLatest)
+	}
+	if totalActionsNotFound > 0 {
+		fmt.Printf("🚨 Warning: %d action(s) reference a repository that no longer exists - verify these actions\n", totalActionsNotFound)
+	}
+	if totalActionsWithoutTags > 0 {
+		fmt.Printf("🚨 Security Warning: %d action(s) found without any tag/ref - these are insecure as they default to the mutable default branch\n", totalActionsWithoutTags)
 	}
 
-	fmt.Printf("Successfully switched to account: %s\n", username)
-	logger.Infow("switched gh account", "username", username)
-	return nil
+	printContextualTips(injectHardenRunner, pinRunners)
+	return summary, nil
 }
 
-func cleanup() error {
-	// If --no-pr is set, don't clean up temp directories to allow manual review
-	if skipPRCreation {
-		fmt.Printf("\n📁 Repositories preserved for manual review:\n")
-		reposDir := getReposDir()
-		if _, err := os.Stat(reposDir); err == nil {
-			fmt.Printf("   • %s\n", reposDir)
+// existingScanDirs returns the repository-relative directories gha-pinner may
+// modify that exist in repoDir, for use as git pathspecs.
+func existingScanDirs(repoDir string) []string {
+	var dirs []string
+	candidates := []string{".github/workflows", ".github/actions"}
+	if isOrgTemplatesRepo(repoDir) {
+		candidates = append(candidates, workflowTemplatesDir)
+	}
+	for _, dir := range candidates {
+		if _, err := os.Stat(filepath.Join(repoDir, filepath.FromSlash(dir))); err == nil {
+			dirs = append(dirs, dir)
 		}
-		fmt.Printf("\n💡 Tip: Review changes and manually commit/push when ready\n")
-		return nil
 	}
+	return dirs
+}
 
-	var cleanupErr error
-	tempDirs := []string{getTempDir("actions"), getTempDir("repos"), getTempDir("pr-body.md")}
-	for _, dir := range tempDirs {
-		if _, err := os.Stat(dir); err == nil {
-			if err := os.RemoveAll(dir); err != nil {
-				cleanupErr = multierr.Append(cleanupErr, fmt.Errorf("failed to remove %s: %w", dir, err))
-				continue
-			}
-			if debug {
-				logger.Debugw("cleaned temporary path", "path", dir)
-				fmt.Printf("Cleaned up: %s\n", dir)
-			}
+// isOrgTemplatesRepo reports whether repoDir is a clone of an organization's
+// .github repository, judged by its directory name (as laid out by
+// getRepositoryDir) or, failing that, its origin remote.
+func isOrgTemplatesRepo(repoDir string) bool {
+	if abs, err := filepath.Abs(repoDir); err == nil {
+		base := strings.ToLower(filepath.Base(abs))
+		if base == orgTemplatesRepo || strings.HasSuffix(base, "_"+orgTemplatesRepo) {
+			return true
 		}
 	}
-	return cleanupErr
-}
-
-func getTempDir(name string) string {
-	if runtime.GOOS == "windows" {
-		return filepath.Join(os.TempDir(), name)
+	result := execCommandWithDir(repoDir, "git", "config", "--get", "remote.origin.url")
+	if result.ExitCode != 0 {
+		return false
 	}
-	return filepath.Join("/tmp", name)
+	fullName, err := extractRepoNameFromURL(strings.TrimSpace(result.Stdout))
+	if err != nil {
+		return false
+	}
+	_, name, ok := strings.Cut(fullName, "/")
+	return ok && strings.EqualFold(name, orgTemplatesRepo)
 }
 
-func getReposDir() string {
-	if skipPRCreation && outputDir != "" {
-		// Use custom output directory
-		return outputDir
+// listWorkflowTemplates returns the YAML files in the workflow-templates
+// directory of an organization's .github repository; other repositories have
+// none, whatever their tree contains.
+func listWorkflowTemplates(repoDir string, ignore *ignoreMatcher) ([]string, error) {
+	if !isOrgTemplatesRepo(repoDir) {
+		return nil, nil
+	}
+	files, err := os.ReadDir(filepath.Join(repoDir, workflowTemplatesDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workflow templates directory: %v", err)
 	}
-	return getTempDir("repos")
-}
 
-func getActionsCacheDir() string {
-	cacheDir := filepath.Join(os.TempDir(), "gha-pinner-cache", "actions")
-	if err := os.MkdirAll(cacheDir, 0755); err != nil {
-		// Fallback to temp dir if cache creation fails
-		return getTempDir("actions")
+	var templates []string
+	for _, file := range files {
+		if file.IsDir() || !(strings.HasSuffix(file.Name(), ".yml") || strings.HasSuffix(file.Name(), ".yaml")) {
+			continue
+		}
+		if ignore.matches(filepath.ToSlash(filepath.Join(workflowTemplatesDir, file.Name()))) {
+			continue
+		}
+		templates = append(templates, filepath.Join(repoDir, workflowTemplatesDir, file.Name()))
 	}
-	return cacheDir
+	return templates, nil
 }
 
-func processRepository(repoName string) error {
-	repo, err := getRepositoryMetadata(repoName)
+// listScanTargets returns the workflow files in .github/workflows followed by
+// any YAML files under .github/actions (composite actions, unless
+// --ignore-composite-action-refs is set) and workflow-templates.
+func listScanTargets(repoDir string) ([]string, error) {
+	ignore, err := loadIgnoreFile(repoDir)
 	if err != nil {
-		return fmt.Errorf("failed to fetch repository metadata: %v", err)
+		return nil, err
 	}
-	repo.URL = repoName // Store the full repo name for cloning
-	return patchRepository(repo)
-}
 
-func processOrganization(orgName string) error {
-	logger.Infow("processing organization", "organization", orgName, "workers", repoWorkers)
-	repos, err := listOrganizationRepositories(orgName, 1000)
-	if err != nil {
-		return fmt.Errorf("failed to list repositories: %w", err)
+	var targets []string
+	workflowsDir := filepath.Join(repoDir, ".github", "workflows")
+	if files, err := os.ReadDir(workflowsDir); err == nil {
+		for _, file := range files {
+			if !file.IsDir() && (strings.HasSuffix(file.Name(), ".yml") || strings.HasSuffix(file.Name(), ".yaml")) {
+				if ignore.matches(filepath.ToSlash(filepath.Join(".github", "workflows", file.Name()))) {
+					continue
+				}
+				targets = append(targets, filepath.Join(workflowsDir, file.Name()))
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read workflows directory: %v", err)
 	}
 
-	fmt.Printf("🏢 Processing %d repositories in organization: %s\n", len(repos), orgName)
-	repoNames := make([]string, 0, len(repos))
-	for _, repo := range repos {
-		fullRepoName := fmt.Sprintf("%s/%s", orgName, repo.Name)
-		repoNames = append(repoNames, fullRepoName)
+	actionsBaseDir := filepath.Join(repoDir, ".github", "actions")
+	if _, statErr := os.Stat(actionsBaseDir); statErr == nil && !ignoreCompositeRefs {
+		walkErr := filepath.WalkDir(actionsBaseDir, func(path string, d os.DirEntry, walkEntryErr error) error {
+			if walkEntryErr != nil {
+				return walkEntryErr
+			}
+			if !d.IsDir() && (strings.HasSuffix(path, ".yml") || strings.HasSuffix(path, ".yaml")) {
+				if rel, relErr := filepath.Rel(repoDir, path); relErr == nil && ignore.matches(filepath.ToSlash(rel)) {
+					return nil
+				}
+				targets = append(targets, path)
+			}
+			return nil
+		})
+		if walkErr != nil {
+			return nil, fmt.Errorf("failed to walk actions directory: %v", walkErr)
+		}
 	}
-	successCount, errorCount := processRepositoryNames(repoNames)
 
-	fmt.Printf("\n🎯 Organization processing complete:\n")
-	fmt.Printf("   • ✅ Successful: %d repositories\n", successCount)
-	fmt.Printf("   • ❌ Failed: %d repositories\n", errorCount)
-	fmt.Printf("   • 📊 Total: %d repositories\n", len(repos))
-	logger.Infow("organization processing complete", "organization", orgName, "successful", successCount, "failed", errorCount, "total", len(repos))
-	return nil
+	templates, err := listWorkflowTemplates(repoDir, ignore)
+	if err != nil {
+		return nil, err
+	}
+	return append(targets, templates...), nil
 }
 
-func processRepositoryFile(filePath string) error {
-	logger.Infow("processing repository file", "path", filePath, "workers", repoWorkers)
-	// Read the file containing repository URLs
-	file, err := os.Open(filePath)
+// findUnpinnedActions statically parses a workflow or composite action file and
+// returns every uses: reference that is not pinned to a commit hash. No network access.
+func findUnpinnedActions(filePath string) ([]string, error) {
+	content, err := os.ReadFile(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to open file %s: %w", filePath, err)
+		return nil, fmt.Errorf("failed to read file: %v", err)
 	}
-	defer file.Close()
+	_, unpinned, err := classifyUses(content)
+	return unpinned, err
+}
 
-	var repoURLs []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		// Skip empty lines and comments
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-		repoURLs = append(repoURLs, line)
+// classifyUses splits the remote uses: references of workflow or composite
+// action content into pinned and unpinned ones.
+func classifyUses(content []byte) ([]string, []string, error) {
+	var workflow map[string]interface{}
+	if err := yaml.Unmarshal(content, &workflow); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse YAML: %v", err)
 	}
-
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("failed to read file %s: %w", filePath, err)
+	_, hasJobs := workflow["jobs"]
+	_, hasRuns := workflow["runs"]
+	if !hasJobs && !hasRuns {
+		return nil, nil, nil
 	}
 
-	if len(repoURLs) == 0 {
-		return fmt.Errorf("no repository URLs found in file %s", filePath)
+	var pinned, unpinned []string
+	for _, steps := range collectJobSteps(workflow, !hasJobs && hasRuns) {
+		for _, step := range steps {
+			uses, ok := step["uses"].(string)
+			if !ok || uses == "" || shouldSkipAction(uses) || strings.HasPrefix(uses, "docker://") {
+				continue
+			}
+			if isPinnedReference(uses) {
+				pinned = append(pinned, uses)
+			} else {
+				unpinned = append(unpinned, uses)
+			}
+		}
 	}
+	return pinned, unpinned, nil
+}
 
-	fmt.Printf("📋 Processing %d repositories from file: %s\n", len(repoURLs), filePath)
+// checkPinnedRegressions implements --only-check-pinned: an action that was
+// pinned in a file at the base commit must still be pinned in that file now.
+// The base is HEAD, or the merge base of --base-ref and HEAD so that a CI job
+// checking out a pull request compares against the branch it targets.
+// De-pinned references are printed as "path: uses" and errUnpinnedFound is
+// returned. Files that did not exist at the base are skipped.
+func checkPinnedRegressions(repoDir string) error {
+	base := "HEAD"
+	if checkBaseRef != "" {
+		result := execCommandWithDir(repoDir, "git", "merge-base", checkBaseRef, "HEAD")
+		if result.ExitCode != 0 {
+			return fmt.Errorf("failed to find the merge base of %s and HEAD: %s", checkBaseRef, strings.TrimSpace(result.Stderr))
+		}
+		base = strings.TrimSpace(result.Stdout)
+	}
 
-	normalizedRepoNames := make([]string, 0, len(repoURLs))
-	parseErrors := 0
-	for _, repoURL := range repoURLs {
-		// Extract repository name from GitHub URL
-		repoName, err := extractRepoNameFromURL(repoURL)
+	targets, err := listScanTargets(repoDir)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, path := range targets {
+		rel, err := filepath.Rel(repoDir, path)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "❌ Error parsing URL %s: %v\n", repoURL, err)
-			parseErrors++
 			continue
 		}
-		normalizedRepoNames = append(normalizedRepoNames, repoName)
+		before := execCommandWithDir(repoDir, "git", "show", base+":./"+filepath.ToSlash(rel))
+		if before.ExitCode != 0 {
+			continue
+		}
+		pinnedBefore, _, err := classifyUses([]byte(before.Stdout))
+		if err != nil || len(pinnedBefore) == 0 {
+			continue
+		}
+		wasPinned := map[string]bool{}
+		for _, uses := range pinnedBefore {
+			wasPinned[strings.SplitN(uses, "@", 2)[0]] = true
+		}
+		unpinned, err := findUnpinnedActions(path)
+		if err != nil {
+			return fmt.Errorf("failed to check %s: %v", path, err)
+		}
+		for _, uses := range unpinned {
+			if wasPinned[strings.SplitN(uses, "@", 2)[0]] {
+				fmt.Printf("%s: %s\n", path, uses)
+				found = true
+			}
+		}
+	}
+	if found {
+		return errUnpinnedFound
 	}
-
-	successCount, runtimeErrors := processRepositoryNames(normalizedRepoNames)
-	errorCount := parseErrors + runtimeErrors
-
-	fmt.Printf("\n🎯 File processing complete:\n")
-	fmt.Printf("   • ✅ Successful: %d repositories\n", successCount)
-	fmt.Printf("   • ❌ Failed: %d repositories\n", errorCount)
-	fmt.Printf("   • 📊 Total: %d repositories\n", len(repoURLs))
-	logger.Infow("file processing complete", "path", filePath, "successful", successCount, "failed", errorCount, "total", len(repoURLs))
 	return nil
 }
 
-func processRepositoryNames(repoNames []string) (int, int) {
-	if len(repoNames) == 0 {
-		return 0, 0
+// checkLocalRepository implements --check: it prints the path of every file
+// containing unpinned actions (like gofmt -l) and returns errUnpinnedFound if any exist.
+func checkLocalRepository(repoDir string) error {
+	targets, err := listScanTargets(repoDir)
+	if err != nil {
+		return err
 	}
-
-	workers := repoWorkers
-	if workers > len(repoNames) {
-		workers = len(repoNames)
+	found := false
+	for _, path := range targets {
+		unpinned, err := findUnpinnedActions(path)
+		if err != nil {
+			return fmt.Errorf("failed to check %s: %v", path, err)
+		}
+		if len(unpinned) > 0 {
+			fmt.Println(path)
+			found = true
+		}
 	}
-
-	type repoTask struct {
-		Index int
-		Name  string
+	if found {
+		return errUnpinnedFound
 	}
+	return nil
+}
 
-	tasks := make(chan repoTask, len(repoNames))
-	results := make(chan bool, len(repoNames))
-
-	for i, repoName := range repoNames {
-		tasks <- repoTask{Index: i + 1, Name: repoName}
+// collectUnpinnedActions returns the sorted, de-duplicated unpinned references across a repository.
+func collectUnpinnedActions(repoDir string) ([]string, error) {
+	targets, err := listScanTargets(repoDir)
+	if err != nil {
+		return nil, err
 	}
-	close(tasks)
-
-	var wg sync.WaitGroup
-	for i := 0; i < workers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for task := range tasks {
-				fmt.Printf("\n[%d/%d] 🔍 Processing repository: %s\n", task.Index, len(repoNames), task.Name)
-
-				repo, err := getRepositoryMetadata(task.Name)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "❌ Error fetching metadata for %s: %v\n", task.Name, err)
-					results <- false
-					continue
-				}
-				repo.URL = task.Name
-				if err := patchRepository(repo); err != nil {
-					fmt.Fprintf(os.Stderr, "❌ Error processing %s: %v\n", task.Name, err)
-					results <- false
-					continue
-				}
-				results <- true
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, path := range targets {
+		unpinned, err := findUnpinnedActions(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %v", path, err)
+		}
+		for _, uses := range unpinned {
+			if !seen[uses] {
+				seen[uses] = true
+				unique = append(unique, uses)
 			}
-		}()
+		}
 	}
+	sort.Strings(unique)
+	return unique, nil
+}
 
-	wg.Wait()
-	close(results)
-
-	successCount, errorCount := 0, 0
-	for ok := range results {
-		if ok {
-			successCount++
-		} else {
-			errorCount++
+// listUnpinnedActions implements --list-unpinned, printing one reference per line
+// (or a JSON array with --format json) and returning errUnpinnedFound if any exist.
+func listUnpinnedActions(repoDir string) error {
+	unpinned, err := collectUnpinnedActions(repoDir)
+	if err != nil {
+		return err
+	}
+	if outputFormat == "json" {
+		data, err := json.Marshal(unpinned)
+		if err != nil {
+			return fmt.Errorf("failed to encode unpinned actions: %v", err)
+		}
+		fmt.Println(string(data))
+	} else {
+		for _, uses := range unpinned {
+			fmt.Println(uses)
 		}
 	}
-	return successCount, errorCount
+	if len(unpinned) > 0 {
+		return errUnpinnedFound
+	}
+	return nil
 }
 
-func extractRepoNameFromURL(repoURL string) (string, error) {
-	// Handle different GitHub URL formats:
-	// https://github.com/owner/repo
-	// https://github.com/owner/repo.git
-	// git@github.com:owner/repo.git
-	// owner/repo
+// PinnedAction is an inventory entry for --list-already-pinned. Version and
+// PinnedOn come from the trailing comment and are empty when it has none.
+type PinnedAction struct {
+	Action   string   `json:"action"`
+	Version  string   `json:"version"`
+	Hash     string   `json:"hash"`
+	PinnedOn string   `json:"pinnedOn"`
+	Files    []string `json:"files"`
+}
 
-	// If it's already in owner/repo format, return as-is
-	if !strings.Contains(repoURL, "github.com") && strings.Count(repoURL, "/") == 1 {
-		return repoURL, nil
+func (p PinnedAction) String() string {
+	line := fmt.Sprintf("%s@%s", p.Action, p.Hash)
+	if p.Version != "" {
+		line += " # " + p.Version
+		if p.PinnedOn != "" {
+			line += " on " + p.PinnedOn
+		}
 	}
+	return line
+}
 
-	// Extract from GitHub URLs
-	repoURL = strings.TrimSpace(repoURL)
+var (
+	pinCommentRe       = regexp.MustCompile(`^([^\s、]+)(?:\D*?(\d{4}-\d{2}-\d{2}))?`)
+	latestPinCommentRe = regexp.MustCompile(`^@latest resolved to (\S+) on (\d{4}-\d{2}-\d{2})`)
+)
 
-	// Remove .git suffix if present
-	repoURL = strings.TrimSuffix(repoURL, ".git")
+// parseExistingPin parses a pinned uses: value together with its trailing
+// comment, e.g. "actions/checkout@<sha> # v4 on 2024-01-15", as written by
+// gha-pinner in any --comment-language or by hand ("# v4").
+func parseExistingPin(uses string) (PinnedAction, error) {
+	ref, comment := uses, ""
+	if idx := strings.Index(uses, " #"); idx != -1 {
+		ref, comment = uses[:idx], uses[idx+2:]
+	}
+	ref = strings.Trim(strings.TrimSpace(ref), `"'`)
+	if !isPinnedReference(ref) {
+		return PinnedAction{}, fmt.Errorf("%s is not pinned to a commit hash", ref)
+	}
+	action, hash, err := parseActionReference(ref)
+	if err != nil {
+		return PinnedAction{}, err
+	}
+	pin := PinnedAction{Action: action, Hash: hash}
+	comment = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(comment), "#"))
+	if m := latestPinCommentRe.FindStringSubmatch(comment); m != nil {
+		pin.Version, pin.PinnedOn = m[1], m[2]
+	} else if m := pinCommentRe.FindStringSubmatch(comment); m != nil {
+		pin.Version, pin.PinnedOn = m[1], m[2]
+	}
+	return pin, nil
+}
 
-	if strings.HasPrefix(repoURL, "https://") {
-		if parsed, err := url.Parse(repoURL); err == nil && strings.EqualFold(parsed.Host, "github.com") {
-			trimmedPath := strings.TrimPrefix(parsed.Path, "/")
-			parts := strings.Split(trimmedPath, "/")
-			if len(parts) >= 2 {
-				return fmt.Sprintf("%s/%s", parts[0], parts[1]), nil
+// scanUsesValues calls visit with the repository-relative path and raw value,
+// comment included, of every step uses: line in the repository's scan targets,
+// file by file. Comments are not part of the parsed YAML, so the raw lines are
+// read instead.
+func scanUsesValues(repoDir string, visit func(rel, value string)) error {
+	targets, err := listScanTargets(repoDir)
+	if err != nil {
+		return err
+	}
+	for _, path := range targets {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to scan %s: %v", path, err)
+		}
+		text := strings.ReplaceAll(string(content), "\r\n", "\n")
+		rel, err := filepath.Rel(repoDir, path)
+		if err != nil {
+			rel = path
+		}
+		lines := strings.Split(text, "\n")
+		editable := editableUsesLines(text)
+		for i, line := range lines {
+			if !editable[i] {
+				continue
+			}
+			if value, ok := usesLineValue(line); ok {
+				visit(filepath.ToSlash(rel), value)
 			}
 		}
 	}
+	return nil
+}
+
+// appendFile adds rel to files unless it is already the last entry; scan
+// targets are visited file by file, so this keeps files de-duplicated.
+func appendFile(files []string, rel string) []string {
+	if len(files) > 0 && files[len(files)-1] == rel {
+		return files
+	}
+	return append(files, rel)
+}
 
-	if strings.HasPrefix(repoURL, "https://github.com/") {
-		// https://github.com/owner/repo
-		parts := strings.Split(strings.TrimPrefix(repoURL, "https://github.com/"), "/")
-		if len(parts) >= 2 {
-			return fmt.Sprintf("%s/%s", parts[0], parts[1]), nil
+// collectPinnedActions returns the pinned references across a repository,
+// sorted and merged by action, hash, version and date.
+func collectPinnedActions(repoDir string) ([]PinnedAction, error) {
+	byKey := map[string]*PinnedAction{}
+	err := scanUsesValues(repoDir, func(rel, value string) {
+		pin, err := parseExistingPin(value)
+		if err != nil {
+			return
 		}
-	} else if strings.HasPrefix(repoURL, "git@github.com:") {
-		// git@github.com:owner/repo
-		parts := strings.Split(strings.TrimPrefix(repoURL, "git@github.com:"), "/")
-		if len(parts) >= 2 {
-			return fmt.Sprintf("%s/%s", parts[0], parts[1]), nil
+		key := pin.String()
+		if existing, ok := byKey[key]; ok {
+			existing.Files = appendFile(existing.Files, rel)
+			return
 		}
+		pin.Files = []string{rel}
+		byKey[key] = &pin
+	})
+	if err != nil {
+		return nil, err
 	}
-
-	return "", fmt.Errorf("invalid GitHub repository URL format: %s", repoURL)
+	keys := make([]string, 0, len(byKey))
+	for key := range byKey {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pins := make([]PinnedAction, 0, len(keys))
+	for _, key := range keys {
+		pins = append(pins, *byKey[key])
+	}
+	return pins, nil
 }
 
-func resolveVersion(action, version string) error {
-	hash, resolvedVersion, err := getCommitHashFromVersion(action, version)
+// listPinnedActions implements --list-already-pinned.
+func listPinnedActions(repoDir string) error {
+	pins, err := collectPinnedActions(repoDir)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("Action: %s\nVersion: %s\nCommit Hash: %s\n", action, resolvedVersion, hash)
+	if outputFormat == "json" {
+		data, err := json.Marshal(pins)
+		if err != nil {
+			return fmt.Errorf("failed to encode pinned actions: %v", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	for _, pin := range pins {
+		fmt.Println(pin.String())
+	}
 	return nil
 }
 
-func patchRepository(repo Repository) error {
-	fmt.Printf("\n🔍 Analyzing repository: %s\n", repo.Name)
+// ActionReference is an entry of the --export-actions-list inventory. For a
+// pinned reference Version comes from its trailing comment, if any.
+type ActionReference struct {
+	Action   string   `json:"action"`
+	Version  string   `json:"version"`
+	IsPinned bool     `json:"isPinned"`
+	Hash     string   `json:"hash,omitempty"`
+	Files    []string `json:"files"`
+}
 
-	// Check repository permissions before proceeding
-	cloneTarget := repo.URL
-	if cloneTarget == "" {
-		cloneTarget = repo.Name
+// String returns the reference as written in uses:, without its comment.
+func (r ActionReference) String() string {
+	switch {
+	case r.IsPinned:
+		return r.Action + "@" + r.Hash
+	case r.Version != "":
+		return r.Action + "@" + r.Version
+	default:
+		return r.Action
 	}
+}
 
-	originalRepo := cloneTarget
-	needsFork := false
-
-	if err := checkRepositoryPermissions(cloneTarget); err != nil {
-		if errors.Is(err, errNeedsFork) {
-			// Fork the repository and sync it
-			forkName, forkErr := forkRepository(cloneTarget)
-			if forkErr != nil {
-				return fmt.Errorf("failed to fork repository: %v", forkErr)
-			}
-			cloneTarget = forkName
-			needsFork = true
-
-			// Sync fork with upstream if it exists
-			if syncErr := syncForkWithUpstream(forkName, originalRepo); syncErr != nil {
-				if debug {
-					fmt.Printf("Warning: failed to sync fork %s with upstream: %v\n", forkName, syncErr)
-				}
-			}
-
-			if debug {
-				fmt.Printf("Using fork: %s\n", cloneTarget)
-			}
+// collectActionReferences returns every remote action reference in a
+// repository, pinned or not, sorted and de-duplicated by reference.
+func collectActionReferences(repoDir string) ([]ActionReference, error) {
+	byRef := map[string]*ActionReference{}
+	err := scanUsesValues(repoDir, func(rel, value string) {
+		ref := value
+		if idx := strings.Index(ref, " #"); idx != -1 {
+			ref = ref[:idx]
+		}
+		ref = strings.Trim(strings.TrimSpace(ref), `"'`)
+		if ref == "" || shouldSkipAction(ref) || strings.HasPrefix(ref, "docker://") {
+			return
+		}
+		if existing, ok := byRef[ref]; ok {
+			existing.Files = appendFile(existing.Files, rel)
+			return
+		}
+		entry := ActionReference{Files: []string{rel}}
+		if pin, err := parseExistingPin(value); err == nil {
+			entry.Action, entry.Version, entry.Hash, entry.IsPinned = pin.Action, pin.Version, pin.Hash, true
 		} else {
-			return fmt.Errorf("permission check failed: %v", err)
+			entry.Action, entry.Version, _ = parseActionReference(ref)
 		}
+		byRef[ref] = &entry
+	})
+	if err != nil {
+		return nil, err
 	}
+	refs := make([]string, 0, len(byRef))
+	for ref := range byRef {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	entries := make([]ActionReference, 0, len(refs))
+	for _, ref := range refs {
+		entries = append(entries, *byRef[ref])
+	}
+	return entries, nil
+}
 
-	repoDir := filepath.Join(getReposDir(), strings.ReplaceAll(repo.Name, "/", "_"))
-
-	if _, err := os.Stat(repoDir); err == nil {
-		if debug {
-			fmt.Printf("Repository directory already exists, removing: %s\n", repoDir)
+// exportActionsList implements --export-actions-list, writing one reference
+// per line, or a JSON array with --format json, to path.
+func exportActionsList(repoDir, path string) error {
+	entries, err := collectActionReferences(repoDir)
+	if err != nil {
+		return err
+	}
+	var content []byte
+	if outputFormat == "json" {
+		content, err = json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode action references: %v", err)
 		}
-		if err := os.RemoveAll(repoDir); err != nil {
-			return fmt.Errorf("failed to remove existing directory: %v", err)
+		content = append(content, '\n')
+	} else {
+		var sb strings.Builder
+		for _, entry := range entries {
+			sb.WriteString(entry.String() + "\n")
 		}
+		content = []byte(sb.String())
 	}
-
-	if err := cloneRepository(cloneTarget, repoDir, ""); err != nil {
-		return fmt.Errorf("failed to clone repository: %v", err)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("failed to write actions list %s: %w", path, err)
 	}
+	fmt.Fprintf(os.Stderr, "📋 Exported %d action reference(s) to %s\n", len(entries), path)
+	return nil
+}
 
-	// If we forked and synced, ensure we have the latest changes locally
-	if needsFork {
-		if debug {
-			fmt.Printf("Adding upstream remote: %s\n", originalRepo)
+// countSignificantLines counts the lines of content that are neither blank nor
+// YAML comments, for --min-workflow-size.
+func countSignificantLines(content string) int {
+	count := 0
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			count++
 		}
-		result := execCommandWithDir(repoDir, "git", "remote", "add", "upstream", fmt.Sprintf("https://github.com/%s.git", originalRepo))
-		if result.ExitCode != 0 {
+	}
+	return count
+}
+
+func (p *WorkflowPatcher) patchFile(filePath string) (patchResult, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return patchResult{}, fmt.Errorf("failed to read file: %v", err)
+	}
+	raw := string(content)
+	// Remember whether the file uses Windows line endings so we can restore them
+	// on write. All internal processing uses LF-only strings.
+	hasCRLF := strings.Contains(raw, "\r\n")
+	originalContent := strings.ReplaceAll(raw, "\r\n", "\n")
+
+	if p.minWorkflowSize > 0 {
+		if lines := countSignificantLines(originalContent); lines < p.minWorkflowSize {
 			if debug {
-				fmt.Printf("Warning: failed to add upstream remote (may already exist): %s\n", result.Stderr)
+				fmt.Printf("ℹ️  Skipped %s (too small: %d lines < %d)\n", filepath.Base(filePath), lines, p.minWorkflowSize)
 			}
+			return patchResult{filesTooSmall: 1}, nil
 		}
+	}
 
-		// Fetch the latest changes from origin (our fork) to ensure we have the synced code
+	var workflow map[string]interface{}
+	if err := yaml.Unmarshal(content, &workflow); err != nil {
+		return patchResult{}, fmt.Errorf("failed to parse YAML: %v", err)
+	}
+
+	_, hasJobs := workflow["jobs"]
+	_, hasRuns := workflow["runs"]
+	if !hasJobs && !hasRuns {
+		return patchResult{}, nil
+	}
+	isComposite := !hasJobs && hasRuns
+
+	current, res, err := pinActionsPass(originalContent, workflow, isComposite)
+	if err != nil {
+		return patchResult{}, err
+	}
+
+	// Checked against the original workflow, so our own edits never affect it.
+	missingPermissions := !isComposite && !hasPermissionsBlock(workflow)
+	if p.requirePermissions && missingPermissions {
+		fmt.Printf("⚠️  Workflow %s has no permissions block — defaulting to read-all is dangerous\n", filepath.Base(filePath))
 		if debug {
-			fmt.Printf("Fetching latest changes from fork...\n")
-		}
-		result = execCommandWithDir(repoDir, "git", "fetch", "origin", "--quiet")
-		if result.ExitCode != 0 && debug {
-			fmt.Printf("Warning: failed to fetch from origin: %s\n", result.Stderr)
+			fmt.Printf("Rule %s triggered for %s\n", ruleMissingPermissions, filePath)
 		}
+		res.missingPermissions = 1
+	}
 
-		// Reset to the latest origin/main to ensure we're working with synced code
-		defaultBranch := repo.DefaultBranchRef.Name
-		if defaultBranch == "" {
-			defaultBranch = "main"
+	if p.injectHardenRunner && !isComposite {
+		updated, count, injErr := p.injectHardenRunnerPass(current, workflow)
+		if injErr != nil {
+			fmt.Printf("⚠️  Warning: harden-runner injection failed for %s: %v\n", filePath, injErr)
+		} else {
+			current = updated
+			res.hardenInjected = count
 		}
+	}
 
-		if debug {
-			fmt.Printf("Resetting to latest %s from fork...\n", defaultBranch)
-		}
-		result = execCommandWithDir(repoDir, "git", "reset", "--hard", fmt.Sprintf("origin/%s", defaultBranch))
-		if result.ExitCode != 0 && debug {
-			fmt.Printf("Warning: failed to reset to origin/%s: %s\n", defaultBranch, result.Stderr)
+	if p.pinRunners && !isComposite {
+		updated, count := pinRunnersPass(current, p.runnerMap)
+		current = updated
+		res.runnersReplaced = count
+	}
+
+	if p.detectInjection {
+		for _, f := range findExpressionInjections(workflow, isComposite) {
+			fmt.Printf("🚨 %s %s: job %s, step %s interpolates %s into run: (expression injection risk)\n", ruleExpressionInjection, filepath.Base(filePath), f.job, f.step, f.expression)
+			res.injectionFindings++
 		}
 	}
 
-	if err := configureGitCredentials(repoDir); err != nil {
-		return fmt.Errorf("failed to configure git credentials: %v", err)
+	if p.detectDispatch && !isComposite && hasDispatchWithoutInputs(workflow) {
+		fmt.Printf("ℹ️  %s %s: workflow_dispatch declares no inputs; add explicit inputs: with types and defaults\n", ruleDispatchWithoutInputs, filepath.Base(filePath))
+		res.dispatchFindings++
 	}
 
-	if err := patchLocalRepository(repoDir); err != nil {
-		return fmt.Errorf("failed to patch repository: %v", err)
+	if p.reportPermissions && !isComposite {
+		for _, f := range findPermissionsIssues(workflow) {
+			scope := "workflow"
+			if f.job != "" {
+				scope = "job " + f.job
+			}
+			fmt.Printf("⚠️  %s %s: %s %s\n", rulePermissionsTooBroad, filepath.Base(filePath), scope, f.message)
+			res.permissionsIssues++
+		}
 	}
 
-	if result := execCommandWithDir(repoDir, "git", "diff", "--exit-code"); result.ExitCode == 0 {
-		fmt.Printf("✅ No changes needed for repository: %s - all actions are already properly secured\n", repo.Name)
-		return nil
+	if p.addPermissions && missingPermissions {
+		if updated, ok := insertPermissionsBlock(current); ok {
+			current = updated
+			res.permissionsAdded = 1
+		} else if debug {
+			fmt.Printf("Could not locate top-level jobs: in %s, permissions block not added\n", filePath)
+		}
 	}
 
-	// If --no-pr flag is set, just show the changes and exit
-	if skipPRCreation {
-		fmt.Printf("🔍 Changes detected in repository: %s\n", repo.Name)
+	if verbose && len(res.changes) > 0 {
+		fmt.Printf("\n📝 %s\n%s", filePath, formatActionChangeTable(res.changes))
+	}
 
-		// Show the diff for review
-		diffResult := execCommandWithDir(repoDir, "git", "diff", ".github/workflows", ".github/actions")
-		if diffResult.ExitCode == 0 && diffResult.Stdout != "" {
-			fmt.Printf("\n📋 Workflow changes preview:\n")
-			fmt.Printf("---\n%s---\n", diffResult.Stdout)
+	if p.securityComment != "" && !isComposite && current != originalContent {
+		current = insertSecurityComment(current, p.securityComment)
+	}
+
+	if current != originalContent {
+		out := current
+		if hasCRLF {
+			out = strings.ReplaceAll(current, "\n", "\r\n")
+		}
+		write := func() error { return safeWriteFile(filePath, raw, out) }
+		if p.preserveMtime {
+			err = preserveFileTime(filePath, write)
+		} else {
+			err = write()
+		}
+		if err != nil {
+			return patchResult{}, fmt.Errorf("failed to write updated file: %v", err)
+		}
+		res.written = true
+		// gha-pinner only edits uses: values, so violations are warnings.
+		if p.verifySyntax && !isComposite {
+			issues, err := validateWorkflowSyntax(current, workflowSchemaURL)
+			if err != nil {
+				fmt.Printf("⚠️  Warning: could not validate %s: %v\n", filepath.Base(filePath), err)
+			}
+			for _, issue := range issues {
+				fmt.Printf("⚠️  %s does not match the workflow schema: %s\n", filepath.Base(filePath), issue)
+			}
+			res.syntaxIssues = issues
 		}
+	}
+	return res, nil
+}
 
-		fmt.Printf("✅ Repository %s has been processed and changes are ready for review\n", repo.Name)
-		fmt.Printf("   • Repository location: %s\n", repoDir)
-		fmt.Printf("   • To create a PR manually: cd %s && git add . && git commit -m 'Pin GitHub Actions' && git push\n", repoDir)
-		return nil
+// preserveFileTime runs fn, which rewrites path, and then restores the
+// modification time path had before, for --preserve-mtime. Builds that use
+// mtimes for cache invalidation then do not see pinned files as changed.
+func preserveFileTime(path string, fn func() error) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fn()
+	}
+	if err := fn(); err != nil {
+		return err
 	}
+	return os.Chtimes(path, time.Now(), info.ModTime())
+}
 
-	branchName := fmt.Sprintf("pin-actions-%s", time.Now().Format("20060102-150405"))
-	currentBranch := strings.TrimSpace(execCommandWithDir(repoDir, "git", "branch", "--show-current").Stdout)
-	if debug {
-		fmt.Printf("Current branch: %s\n", currentBranch)
+// safeWriteFile replaces path with updated atomically: the content is written
+// to a temporary file in the same directory and renamed over path, so an
+// interrupted run never leaves a half-written workflow. If the rename fails
+// the original content is restored.
+func safeWriteFile(path, original, updated string) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+
+	if _, err := tmp.WriteString(updated); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, mode); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		if restoreErr := os.WriteFile(path, []byte(original), mode); restoreErr != nil {
+			return multierr.Append(err, fmt.Errorf("failed to restore %s: %w", path, restoreErr))
+		}
+		return err
 	}
+	return nil
+}
+
+const permissionsBlockComment = "# Added by gha-pinner for security hardening"
 
-	gitAddArgs := []string{"add", ".github/workflows"}
-	if _, err := os.Stat(filepath.Join(repoDir, ".github", "actions")); err == nil {
-		gitAddArgs = append(gitAddArgs, ".github/actions")
+// insertPermissionsBlock inserts "permissions: read-all" as a top-level key
+// directly before jobs:, which is located through the YAML AST (including any
+// comment lines attached above it) so the rest of the file is left untouched.
+func insertPermissionsBlock(content string) (string, bool) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(content), &doc); err != nil || len(doc.Content) == 0 {
+		return content, false
 	}
-	commands := [][]string{
-		{"git", "checkout", "-b", branchName},
-		append([]string{"git"}, gitAddArgs...),
-		{"git", "commit", "-m", getPRTitleForRepository(originalRepo) + "\n\nPin GitHub Actions to commit hashes for improved security and reproducible builds"},
-		{"git", "push", "origin", branchName},
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return content, false
 	}
-
-	for _, cmd := range commands {
-		if result := execCommandWithDir(repoDir, cmd[0], cmd[1:]...); result.ExitCode != 0 {
-			return fmt.Errorf("failed to %s: %s", cmd[0], result.Stderr)
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key := root.Content[i]
+		if key.Value == "permissions" {
+			return content, false
 		}
+		if key.Value != "jobs" {
+			continue
+		}
+		insertAt := key.Line - 1
+		if key.HeadComment != "" {
+			insertAt -= strings.Count(key.HeadComment, "\n") + 1
+		}
+		indent := strings.Repeat(" ", key.Column-1)
+		lines := strings.Split(content, "\n")
+		if insertAt < 0 || insertAt > len(lines) {
+			return content, false
+		}
+		block := []string{indent + permissionsBlockComment, indent + "permissions: read-all", ""}
+		lines = append(lines[:insertAt], append(block, lines[insertAt:]...)...)
+		return strings.Join(lines, "\n"), true
 	}
+	return content, false
+}
 
-	if debug {
-		fmt.Printf("Successfully pushed branch: %s\n", branchName)
+// patcherSecurityComment returns the header text for WorkflowPatcher, empty
+// unless --add-security-comments is set.
+func patcherSecurityComment() string {
+	if !addSecurityComments {
+		return ""
 	}
+	return securityCommentText
+}
 
-	// Check for existing PRs in both the original repository and the fork
-	searchRepo := originalRepo
-	if !needsFork {
-		searchRepo = cloneTarget
+// insertSecurityComment adds text as a comment block directly above the first
+// top-level key, located through the YAML AST like insertPermissionsBlock.
+// Files with "pinned to commit hashes" or text itself in their first 5 lines
+// already carry the comment and are returned unchanged.
+func insertSecurityComment(content, text string) string {
+	var block []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "#"))
+		if line == "" {
+			block = append(block, "#")
+		} else {
+			block = append(block, "# "+line)
+		}
 	}
 
-	// First check for existing PRs in the target repository
-	result := listOpenPRs(searchRepo, getPRSearchPattern(searchRepo), "")
-	if debug {
-		fmt.Printf("PR search in %s: exit=%d, output=%s\n", searchRepo, result.ExitCode, result.Stdout)
+	lines := strings.Split(content, "\n")
+	head := strings.Join(lines[:min(5, len(lines))], "\n")
+	if strings.Contains(head, "pinned to commit hashes") || strings.Contains(head, strings.Join(block, "\n")) {
+		return content
 	}
 
-	if result.ExitCode == 0 && strings.TrimSpace(result.Stdout) != "" && strings.TrimSpace(result.Stdout) != "[]" {
-		fmt.Printf("ℹ️  Pull request already exists for repository: %s - skipping PR creation\n", searchRepo)
-		return nil
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(content), &doc); err != nil || len(doc.Content) == 0 {
+		return content
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode || len(root.Content) == 0 {
+		return content
+	}
+	key := root.Content[0]
+	insertAt := key.Line - 1
+	if key.HeadComment != "" {
+		insertAt -= strings.Count(key.HeadComment, "\n") + 1
+	}
+	if insertAt < 0 || insertAt > len(lines) {
+		return content
 	}
+	lines = append(lines[:insertAt], append(block, lines[insertAt:]...)...)
+	return strings.Join(lines, "\n")
+}
 
-	// If we're using a fork, also check for existing PRs from our fork to avoid duplicates
-	if needsFork {
-		// Check for PRs from our fork to the upstream
-		forkPRResult := listOpenPRs(originalRepo, "", "@me")
-		if debug {
-			fmt.Printf("Fork PR search in %s by @me: exit=%d, output=%s\n", originalRepo, forkPRResult.ExitCode, forkPRResult.Stdout)
-		}
+// hasPermissionsBlock reports whether a parsed workflow declares a top-level permissions: key.
+func hasPermissionsBlock(workflow map[string]interface{}) bool {
+	_, ok := workflow["permissions"]
+	return ok
+}
 
-		if forkPRResult.ExitCode == 0 && strings.TrimSpace(forkPRResult.Stdout) != "" && strings.TrimSpace(forkPRResult.Stdout) != "[]" {
-			// Parse the PR list to check for similar titles
-			var existingPRs []map[string]interface{}
-			if err := json.Unmarshal([]byte(forkPRResult.Stdout), &existingPRs); err == nil {
-				for _, pr := range existingPRs {
-					if title, ok := pr["title"].(string); ok {
-						if strings.Contains(strings.ToLower(title), "pin") &&
-							strings.Contains(strings.ToLower(title), "action") &&
-							strings.Contains(strings.ToLower(title), "security") {
-							fmt.Printf("ℹ️  Similar pull request already exists from fork: %s - skipping PR creation\n", title)
-							if url, ok := pr["url"].(string); ok {
-								fmt.Printf("   • Existing PR: %s\n", url)
-							}
-							return nil
+func pinActionsPass(content string, workflow map[string]interface{}, isComposite bool) (string, patchResult, error) {
+	var res patchResult
+
+	allJobSteps := collectJobSteps(workflow, isComposite)
+
+	// Each distinct action@version is resolved once; the apply loop below maps
+	// the result back onto every step that uses it.
+	var actionsToPin []actionPin
+	queued := map[string]bool{}
+	annotated := map[string]bool{}
+	uncommented := map[string]bool{}
+	var uncommentedPins []string
+	usesLines := editableUsesLines(content)
+	for _, steps := range allJobSteps {
+		for _, step := range steps {
+			if uses, ok := step["uses"].(string); ok && uses != "" {
+				res.totalActions++
+				if shouldSkipAction(uses) {
+					res.actionsSkipped++
+					continue
+				}
+				if isPinnedReference(uses) {
+					if requireHashComment && hasUncommentedPin(content, uses, usesLines) {
+						if !uncommented[uses] {
+							uncommented[uses] = true
+							uncommentedPins = append(uncommentedPins, uses)
+						}
+						continue
+					}
+					res.actionsAlreadyPinned++
+					continue
+				}
+				if strings.Contains(uses, "@latest") {
+					res.actionsWithLatest++
+				}
+				if action, version, err := parseActionReference(uses); err == nil {
+					if semverErr := checkStrictSemver(version); semverErr != nil {
+						// Annotated here, never resolved: a rejected version must not be pinned.
+						res.actionsNonSemver++
+						if debug {
+							fmt.Printf("Rule %s triggered for %s\n", ruleNonSemverVersion, uses)
+						}
+						if !annotated[uses] {
+							annotated[uses] = true
+							content = annotateUsesLines(content, uses, semverTodoComment, usesLines)
+						}
+						continue
+					}
+					if minimum, below := belowMinActionVersion(action, version); below {
+						res.actionsBelowMin++
+						if debug {
+							fmt.Printf("%s is below the minimum version %s\n", uses, minimum)
 						}
+						if !annotated[uses] {
+							annotated[uses] = true
+							content = annotateUsesLines(content, uses, fmt.Sprintf(minVersionComment, version, minimum), usesLines)
+						}
+						continue
+					}
+					if key := action + "@" + version; !queued[key] {
+						queued[key] = true
+						actionsToPin = append(actionsToPin, actionPin{action: action, version: version})
 					}
+				} else if strings.Contains(err.Error(), "action without tag/ref") {
+					res.actionsWithoutTags++
 				}
 			}
 		}
 	}
 
-	prTitle := getPRTitleForRepository(searchRepo)
+	currentDate := time.Now().Format("2006-01-02")
+	for _, uses := range uncommentedPins {
+		action, hash, _ := parseActionReference(uses)
+		tag, err := findTagForCommit(action, hash)
+		if err != nil {
+			if debug {
+				fmt.Printf("Leaving %s without a version comment: %v\n", uses, err)
+			}
+			res.actionsAlreadyPinned++
+			continue
+		}
+		var count int
+		content, count = addVersionComment(content, uses, pinComment(tag, currentDate), usesLines)
+		res.actionsPinned += count
+		res.changes = append(res.changes, actionChange{
+			action: action,
+			before: shortHash(hash),
+			after:  fmt.Sprintf("%s (%s, %s)", shortHash(hash), tag, currentDate),
+			hash:   hash,
+			date:   currentDate,
+		})
+	}
+
+	if len(actionsToPin) == 0 {
+		return content, res, nil
+	}
 
-	// Get appropriate PR body based on repository's PR template
-	prBodyContent := getPRBodyForRepository(repoDir)
+	fmt.Printf("🔄 Processing %d unique action(s) for pinning...\n", len(actionsToPin))
 
-	// Create PR - if forked, create PR to original repo
-	var prResult ExecResult
-	if needsFork {
-		// Create cross-repository PR from fork to original
-		headBranch := fmt.Sprintf("%s:%s", strings.Split(cloneTarget, "/")[0], branchName)
-		if debug {
-			fmt.Printf("Creating cross-repo PR: repo=%s, title=%s, base=%s, head=%s\n", originalRepo, prTitle, repo.DefaultBranchRef.Name, headBranch)
-		}
-		prResult = createPullRequest(originalRepo, prTitle, prBodyContent, repo.DefaultBranchRef.Name, headBranch, repoDir)
-	} else {
-		// Create normal PR within the same repository
-		if debug {
-			fmt.Printf("Creating PR: title=%s, base=%s, head=%s\n", prTitle, repo.DefaultBranchRef.Name, branchName)
-		}
-		prResult = createPullRequest("", prTitle, prBodyContent, repo.DefaultBranchRef.Name, branchName, repoDir)
+	numWorkers := runtime.NumCPU()
+	if numWorkers > len(actionsToPin) {
+		numWorkers = len(actionsToPin)
 	}
 
-	if debug {
-		fmt.Printf("PR creation: exit=%d, output=%s\n", prResult.ExitCode, prResult.Stdout)
+	actionsChan := make(chan actionPin, len(actionsToPin))
+	resultsChan := make(chan actionPin, len(actionsToPin))
+	var wg sync.WaitGroup
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go pinActionsWorker(actionsChan, resultsChan, &wg)
+	}
+	for _, a := range actionsToPin {
+		actionsChan <- a
 	}
+	close(actionsChan)
+	go func() {
+		wg.Wait()
+		close(resultsChan)
+	}()
 
-	if prResult.ExitCode != 0 {
-		if debug {
-			fmt.Printf("Trying alternative PR creation...\n")
+	pinnedActions := make(map[string]actionPin)
+	for result := range resultsChan {
+		key := fmt.Sprintf("%s@%s", result.action, result.version)
+		pinnedActions[key] = result
+		if result.licenseWarning != "" {
+			fmt.Printf("⚠️  %s %s\n", ruleDisallowedLicense, result.licenseWarning)
+			res.licenseFindings++
 		}
-		// Try alternative method
-		prResult = createPullRequestFallback(originalRepo, prTitle, prBodyContent, needsFork, repoDir)
+	}
 
-		if debug {
-			fmt.Printf("Alternative PR: exit=%d, output=%s\n", prResult.ExitCode, prResult.Stdout)
-		}
-		if prResult.ExitCode != 0 {
-			return fmt.Errorf("failed to create pull request: %s", prResult.Stderr)
+	updated := content
+	var resolveErr error
+	unresolved := map[string]bool{}
+	for _, steps := range allJobSteps {
+		for _, step := range steps {
+			if uses, ok := step["uses"].(string); ok && uses != "" && !shouldSkipAction(uses) {
+				if action, version, err := parseActionReference(uses); err == nil {
+					if checkStrictSemver(version) != nil {
+						continue
+					}
+					key := fmt.Sprintf("%s@%s", action, version)
+					if pinned, exists := pinnedActions[key]; exists {
+						if pinned.err != nil && !errors.Is(pinned.err, errActionInactive) && !errors.Is(pinned.err, errLowTrustAction) {
+							res.unresolvable = append(res.unresolvable, key)
+						}
+						if pinned.err == nil {
+							pinnedUses := fmt.Sprintf("%s@%s %s", action, pinned.hash, pinComment(pinned.resolvedVersion, currentDate))
+							if version == "latest" && pinned.resolvedVersion != version {
+								pinnedUses = fmt.Sprintf("%s@%s # @latest resolved to %s on %s", action, pinned.hash, pinned.resolvedVersion, currentDate)
+							}
+							if pinned.sigstoreNote != "" {
+								pinnedUses += fmt.Sprintf(" (%s)", pinned.sigstoreNote)
+							}
+							updated = replaceUsesOnLines(updated, fmt.Sprintf("uses: %s", uses), fmt.Sprintf("uses: %s", pinnedUses), usesLines)
+							res.actionsPinned++
+							res.changes = append(res.changes, actionChange{
+								action: action,
+								before: version,
+								after:  fmt.Sprintf("%s (%s, %s)", shortHash(pinned.hash), pinned.resolvedVersion, currentDate),
+								hash:   pinned.hash,
+								date:   currentDate,
+							})
+							if debug {
+								fmt.Printf("Pinned %s@%s to %s\n", action, version, pinned.hash)
+							}
+						} else if errors.Is(pinned.err, errActionInactive) {
+							// Every step using the action is annotated at once, so it is counted once.
+							if !annotated[uses] {
+								annotated[uses] = true
+								warning := fmt.Sprintf("# WARNING: last commit > %d days ago", int(maxCommitAge.Hours()/24))
+								updated = annotateUsesLines(updated, uses, warning, usesLines)
+								res.actionsInactive++
+							}
+						} else if errors.Is(pinned.err, errLowTrustAction) {
+							if !annotated[uses] {
+								annotated[uses] = true
+								todoComment := fmt.Sprintf("# TODO: %s, verify and pin manually", pinned.skippedReason)
+								updated = annotateUsesLines(updated, uses, todoComment, usesLines)
+								res.actionsLowTrust++
+							}
+						} else if errors.Is(pinned.err, errActionNotFound) {
+							if !annotated[uses] {
+								annotated[uses] = true
+								updated = annotateUsesLines(updated, uses, "# WARNING: repository not found - verify this action still exists", usesLines)
+								res.actionsNotFound++
+							}
+						} else if failOnUnresolvable {
+							if !unresolved[key] {
+								unresolved[key] = true
+								resolveErr = multierr.Append(resolveErr, fmt.Errorf("failed to resolve %s: %w", uses, pinned.err))
+							}
+						} else if ignoreUnresolvable {
+							if debug {
+								fmt.Printf("Leaving %s unchanged (--ignore-unresolvable): %v\n", uses, pinned.err)
+							}
+						} else if errors.Is(pinned.err, errUnresolvedVersion) {
+							todoComment := fmt.Sprintf("# %s on %s, TODO: Pin to a commit hash", version, currentDate)
+							updated = replaceUsesOnLines(updated, fmt.Sprintf("uses: %s", uses), fmt.Sprintf("uses: %s %s", uses, todoComment), usesLines)
+						}
+					}
+				}
+			}
 		}
 	}
 
-	targetRepo := originalRepo
-	if !needsFork {
-		targetRepo = repo.Name
+	if resolveErr != nil {
+		return content, res, resolveErr
 	}
+	return updated, res, nil
+}
 
-	fmt.Printf("🎉 Pull request created successfully!\n")
-	fmt.Printf("   • Repository: %s\n", targetRepo)
-	if needsFork {
-		fmt.Printf("   • Fork used: %s\n", cloneTarget)
+// collectJobSteps returns the steps of every job in a workflow, or the single
+// runs.steps list of a composite action, grouped per job.
+func collectJobSteps(workflow map[string]interface{}, isComposite bool) [][]map[string]interface{} {
+	var allJobSteps [][]map[string]interface{}
+	if isComposite {
+		if runs, ok := workflow["runs"].(map[string]interface{}); ok {
+			if steps, ok := runs["steps"].([]interface{}); ok {
+				allJobSteps = append(allJobSteps, toStepMaps(steps))
+			}
+		}
+		return allJobSteps
 	}
-	if prResult.Stdout != "" {
-		fmt.Printf("   • PR URL: %s\n", strings.TrimSpace(prResult.Stdout))
+	if jobs, ok := workflow["jobs"].(map[string]interface{}); ok {
+		for jobName, jobData := range jobs {
+			if isIgnoredJob(jobName) {
+				if debug {
+					fmt.Printf("Ignoring job %s (--ignore-jobs)\n", jobName)
+				}
+				continue
+			}
+			if job, ok := jobData.(map[string]interface{}); ok {
+				if steps, ok := job["steps"].([]interface{}); ok {
+					allJobSteps = append(allJobSteps, toStepMaps(steps))
+				}
+			}
+		}
 	}
-	return nil
+	return allJobSteps
 }
 
-func forkRepository(repoName string) (string, error) {
-	// Check if fork already exists
-	parts := strings.Split(repoName, "/")
-	if len(parts) != 2 {
-		return "", fmt.Errorf("invalid repository name format: %s", repoName)
+// isIgnoredJob reports whether jobName matches an --ignore-jobs entry (filepath.Match syntax).
+func isIgnoredJob(jobName string) bool {
+	for _, pattern := range ignoreJobs {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if matched, err := filepath.Match(pattern, jobName); err == nil && matched {
+			return true
+		}
 	}
+	return false
+}
 
-	// Get current username
-	username, err := getCurrentUserLogin()
-	if err != nil {
-		return "", fmt.Errorf("failed to get current user: %v", err)
+// ignoredJobLineRanges returns the [start, end) line ranges occupied by jobs
+// excluded with --ignore-jobs, so text replacements can avoid them.
+func ignoredJobLineRanges(content string) [][2]int {
+	if len(ignoreJobs) == 0 {
+		return nil
 	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(content), &doc); err != nil || len(doc.Content) == 0 {
+		return nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil
+	}
+	totalLines := strings.Count(content, "\n") + 1
 
-	forkName := fmt.Sprintf("%s/%s", username, parts[1])
-
-	// Check if fork already exists
-	if _, err := getRepositoryMetadata(forkName); err == nil {
-		if debug {
-			fmt.Printf("Fork already exists: %s\n", forkName)
+	var ranges [][2]int
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "jobs" || root.Content[i+1].Kind != yaml.MappingNode {
+			continue
+		}
+		jobs := root.Content[i+1].Content
+		// A job ends where the next top-level key after "jobs" starts, or at EOF.
+		end := totalLines
+		if i+2 < len(root.Content) {
+			end = root.Content[i+2].Line - 1
+		}
+		for j := 0; j+1 < len(jobs); j += 2 {
+			if !isIgnoredJob(jobs[j].Value) {
+				continue
+			}
+			jobEnd := end
+			if j+2 < len(jobs) {
+				jobEnd = jobs[j+2].Line - 1
+			}
+			ranges = append(ranges, [2]int{jobs[j].Line - 1, jobEnd})
 		}
-		return forkName, nil
 	}
+	return ranges
+}
 
-	// Create fork
-	if debug {
-		fmt.Printf("Creating fork of %s...\n", repoName)
+// editableUsesLines returns the 0-based numbers of the lines holding a step's
+// uses: key (jobs.<id>.steps[] or a composite action's runs.steps[]), minus
+// jobs excluded with --ignore-jobs. Pinning only edits these lines, so
+// look-alike text inside run: block scalars or other strings is never
+// modified. It returns nil when content cannot be parsed.
+func editableUsesLines(content string) map[int]bool {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(content), &doc); err != nil || len(doc.Content) == 0 {
+		return nil
 	}
-
-	if err := createFork(repoName); err != nil {
-		return "", fmt.Errorf("failed to fork repository: %v", err)
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil
 	}
 
-	if debug {
-		fmt.Printf("Successfully forked %s to %s\n", repoName, forkName)
+	lines := map[int]bool{}
+	addSteps := func(container *yaml.Node) {
+		steps := mappingValue(container, "steps")
+		if steps == nil || steps.Kind != yaml.SequenceNode {
+			return
+		}
+		for _, step := range steps.Content {
+			if step.Kind != yaml.MappingNode {
+				continue
+			}
+			for i := 0; i+1 < len(step.Content); i += 2 {
+				if step.Content[i].Value == "uses" {
+					lines[step.Content[i].Line-1] = true
+				}
+			}
+		}
 	}
-
-	return forkName, nil
-}
-
-func syncForkWithUpstream(forkName, upstreamName string) error {
-	if debug {
-		fmt.Printf("Checking if fork %s needs to be synced with upstream %s...\n", forkName, upstreamName)
+	if jobs := mappingValue(root, "jobs"); jobs != nil && jobs.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(jobs.Content); i += 2 {
+			if jobs.Content[i+1].Kind == yaml.MappingNode {
+				addSteps(jobs.Content[i+1])
+			}
+		}
 	}
-
-	// Get the default branch of the upstream repository
-	upstreamRepo, err := getRepositoryMetadata(upstreamName)
-	if err != nil {
-		return fmt.Errorf("failed to get upstream repository info: %v", err)
+	if runs := mappingValue(root, "runs"); runs != nil && runs.Kind == yaml.MappingNode {
+		addSteps(runs)
 	}
 
-	defaultBranch := upstreamRepo.DefaultBranchRef.Name
-	if defaultBranch == "" {
-		defaultBranch = "main" // fallback
+	for _, r := range ignoredJobLineRanges(content) {
+		for line := r[0]; line < r[1]; line++ {
+			delete(lines, line)
+		}
 	}
+	return lines
+}
 
-	// Get the latest commit SHA from upstream
-	upstreamCommitResult := githubAPI("GET", fmt.Sprintf("repos/%s/commits/%s", upstreamName, defaultBranch), nil)
-	if upstreamCommitResult.ExitCode != 0 {
-		return fmt.Errorf("failed to get upstream commit: %s", upstreamCommitResult.Stderr)
+// mappingValue returns the value node for key in a mapping node, or nil.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
 	}
-
-	var upstreamCommit map[string]interface{}
-	if err := json.Unmarshal([]byte(upstreamCommitResult.Stdout), &upstreamCommit); err != nil {
-		return fmt.Errorf("failed to parse upstream commit: %v", err)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
 	}
+	return nil
+}
 
-	upstreamSHA, ok := upstreamCommit["sha"].(string)
-	if !ok {
-		return fmt.Errorf("failed to get upstream commit SHA")
+// replaceUsesOnLines replaces the first occurrence of old with replacement on
+// one of the given lines (see editableUsesLines). A nil lines set, used when
+// the file could not be parsed, allows any line.
+func replaceUsesOnLines(content, old, replacement string, lines map[int]bool) string {
+	if lines == nil {
+		return strings.Replace(content, old, replacement, 1)
+	}
+	split := strings.Split(content, "\n")
+	for i, line := range split {
+		if lines[i] && strings.Contains(line, old) {
+			split[i] = strings.Replace(line, old, replacement, 1)
+			return strings.Join(split, "\n")
+		}
 	}
+	return content
+}
 
-	// Get the latest commit SHA from fork
-	forkCommitResult := githubAPI("GET", fmt.Sprintf("repos/%s/commits/%s", forkName, defaultBranch), nil)
-	if forkCommitResult.ExitCode != 0 {
-		return fmt.Errorf("failed to get fork commit: %s", forkCommitResult.Stderr)
+// annotateUsesLines appends comment to every editable line whose uses: value is
+// exactly uses, unlike replaceUsesOnLines which rewrites a single occurrence.
+func annotateUsesLines(content, uses, comment string, lines map[int]bool) string {
+	split := strings.Split(content, "\n")
+	for i, line := range split {
+		if lines != nil && !lines[i] {
+			continue
+		}
+		value, ok := usesLineValue(line)
+		if !ok {
+			continue
+		}
+		if idx := strings.Index(value, " #"); idx != -1 {
+			value = strings.TrimSpace(value[:idx])
+		}
+		if strings.Trim(value, `"'`) == uses && !strings.Contains(line, comment) {
+			split[i] = line + " " + comment
+		}
 	}
+	return strings.Join(split, "\n")
+}
 
-	var forkCommit map[string]interface{}
-	if err := json.Unmarshal([]byte(forkCommitResult.Stdout), &forkCommit); err != nil {
-		return fmt.Errorf("failed to parse fork commit: %v", err)
-	}
+// pinComment returns the "# v3 on 2024-01-15" comment written after a pinned
+// hash, in --comment-language.
+func pinComment(version, date string) string {
+	return "# " + i18n.T(commentLanguage, i18n.PinComment, version, date)
+}
 
-	forkSHA, ok := forkCommit["sha"].(string)
+// versionCommentRe matches a version in a pin comment, e.g. "# v4.1.1 on
+// 2024-01-02" or "# tag=v4".
+var versionCommentRe = regexp.MustCompile(`(?:^|[\s=@])(?:v\d+(?:\.\d+)*|\d+\.\d+(?:\.\d+)*)\b`)
+
+// hasVersionComment reports whether a uses: line carries a trailing comment
+// naming the version its hash stands for.
+func hasVersionComment(line string) bool {
+	value, ok := usesLineValue(line)
 	if !ok {
-		return fmt.Errorf("failed to get fork commit SHA")
+		value = line
 	}
-
-	// Check if fork is behind upstream
-	if upstreamSHA == forkSHA {
-		if debug {
-			fmt.Printf("Fork %s is up-to-date with upstream %s\n", forkName, upstreamName)
-		}
-		return nil
+	idx := strings.Index(value, " #")
+	if idx == -1 {
+		return false
 	}
+	return versionCommentRe.MatchString(strings.TrimSpace(value[idx+2:]))
+}
 
-	if debug {
-		fmt.Printf("Fork %s is behind upstream %s, syncing...\n", forkName, upstreamName)
-		fmt.Printf("  Fork SHA: %s\n", forkSHA)
-		fmt.Printf("  Upstream SHA: %s\n", upstreamSHA)
+// usesValueWithoutComment strips the trailing comment and quotes from a uses:
+// line value.
+func usesValueWithoutComment(value string) string {
+	if idx := strings.Index(value, " #"); idx != -1 {
+		value = strings.TrimSpace(value[:idx])
 	}
+	return strings.Trim(value, `"'`)
+}
 
-	// Sync the fork using GitHub API
-	syncResult := githubAPI("POST", fmt.Sprintf("repos/%s/merge-upstream", forkName), map[string]interface{}{
-		"branch": defaultBranch,
-	})
-	if syncResult.ExitCode != 0 {
-		return fmt.Errorf("failed to sync fork with upstream: %s", syncResult.Stderr)
+// hasUncommentedPin reports whether any editable line pinning uses lacks a
+// version comment (--require-hash-comment).
+func hasUncommentedPin(content, uses string, lines map[int]bool) bool {
+	for i, line := range strings.Split(content, "\n") {
+		if lines != nil && !lines[i] {
+			continue
+		}
+		if value, ok := usesLineValue(line); ok && usesValueWithoutComment(value) == uses && !hasVersionComment(line) {
+			return true
+		}
 	}
+	return false
+}
 
-	if debug {
-		fmt.Printf("Successfully synced fork %s with upstream %s\n", forkName, upstreamName)
+// addVersionComment appends comment to the editable lines pinning uses that
+// lack a version comment, returning the new content and the number of lines
+// changed.
+func addVersionComment(content, uses, comment string, lines map[int]bool) (string, int) {
+	split := strings.Split(content, "\n")
+	count := 0
+	for i, line := range split {
+		if lines != nil && !lines[i] {
+			continue
+		}
+		if value, ok := usesLineValue(line); ok && usesValueWithoutComment(value) == uses && !hasVersionComment(line) {
+			split[i] = line + " " + comment
+			count++
+		}
 	}
-
-	return nil
+	return strings.Join(split, "\n"), count
 }
 
-func checkRepositoryPermissions(repoName string) error {
-	// Check if the current user has write access to the repository
-	result := githubAPI("GET", fmt.Sprintf("repos/%s", repoName), nil)
+// findTagForCommit returns the most specific tag of an action's repository
+// that points at hash, e.g. v4.1.1 rather than v4. Only the 100 most recent
+// tags are considered.
+func findTagForCommit(action, hash string) (string, error) {
+	repoName := actionRepoName(remapActionPrefix(action, actionPrefixMap))
+	result := cachedGitHubAPI(fmt.Sprintf("repos/%s/tags?per_page=100", repoName))
 	if result.ExitCode != 0 {
-		return fmt.Errorf("failed to check repository permissions: %s", result.Stderr)
+		return "", fmt.Errorf("failed to list tags of %s: %s", repoName, strings.TrimSpace(result.Stderr))
 	}
-
-	var repoInfo map[string]interface{}
-	if err := json.Unmarshal([]byte(result.Stdout), &repoInfo); err != nil {
-		return fmt.Errorf("failed to parse repository info: %v", err)
+	var tags []struct {
+		Name   string `json:"name"`
+		Commit struct {
+			SHA string `json:"sha"`
+		} `json:"commit"`
+	}
+	if err := json.Unmarshal([]byte(result.Stdout), &tags); err != nil {
+		return "", fmt.Errorf("failed to parse tags of %s: %v", repoName, err)
+	}
+	best := ""
+	for _, tag := range tags {
+		if !strings.EqualFold(tag.Commit.SHA, hash) {
+			continue
+		}
+		if best == "" || strings.Count(tag.Name, ".") > strings.Count(best, ".") {
+			best = tag.Name
+		}
+	}
+	if best == "" {
+		return "", fmt.Errorf("no tag of %s points at %s", repoName, shortHash(hash))
 	}
+	return best, nil
+}
 
-	permissions, ok := repoInfo["permissions"].(map[string]interface{})
-	if !ok {
-		return fmt.Errorf("unable to determine repository permissions")
+// usesLineValue returns the raw value of a "uses:" or "- uses:" line, including
+// any trailing comment.
+func usesLineValue(line string) (string, bool) {
+	value := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "-"))
+	if !strings.HasPrefix(value, "uses:") {
+		return "", false
 	}
+	return strings.TrimSpace(strings.TrimPrefix(value, "uses:")), true
+}
 
-	canPush, ok := permissions["push"].(bool)
-	if !ok || !canPush {
-		// No push access, need to fork
-		if debug {
-			fmt.Printf("No push access to %s, will fork repository\n", repoName)
+func toStepMaps(steps []interface{}) []map[string]interface{} {
+	var jobSteps []map[string]interface{}
+	for _, s := range steps {
+		if step, ok := s.(map[string]interface{}); ok {
+			jobSteps = append(jobSteps, step)
 		}
-		return errNeedsFork
 	}
+	return jobSteps
+}
 
-	if debug {
-		fmt.Printf("Repository permissions verified for: %s\n", repoName)
-	}
+var (
+	pinnedRefRe = regexp.MustCompile(`@[a-f0-9]{40}$`)
+	shortSHARe  = regexp.MustCompile(`^[a-f0-9]{7,39}$`)
+)
 
-	return nil
+// isPinnedReference reports whether a uses: value is pinned to a full 40-character
+// commit hash. Abbreviated SHAs are ambiguous and therefore not considered pinned.
+func isPinnedReference(uses string) bool {
+	return pinnedRefRe.MatchString(strings.TrimSpace(uses))
 }
 
-func configureGitCredentials(repoDir string) error {
-	if authMode == "pat" {
-		username, err := getCurrentUserLogin()
-		if err != nil {
-			username = "gha-pinner"
-		}
-		execCommandWithDir(repoDir, "git", "config", "user.name", username)
-		execCommandWithDir(repoDir, "git", "config", "user.email", username+"@users.noreply.github.com")
-		return nil
+// isShortSHA reports whether version looks like an abbreviated commit hash.
+func isShortSHA(version string) bool {
+	return shortSHARe.MatchString(version)
+}
+
+// shortHash abbreviates a commit hash to 7 characters for display.
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
 	}
+	return hash
+}
 
-	execCommandWithDir(repoDir, "git", "config", "--unset", "credential.helper")
-	if result := execCommandWithDir(repoDir, "git", "config", "credential.helper", "!gh auth git-credential"); result.ExitCode != 0 {
-		return fmt.Errorf("failed to configure git credentials: %s", result.Stderr)
+// formatActionChangeTable renders an aligned Action | Before | After table.
+func formatActionChangeTable(changes []actionChange) string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "   Action\tBefore\tAfter")
+	for _, c := range changes {
+		fmt.Fprintf(w, "   %s\t%s\t%s\n", c.action, c.before, c.after)
 	}
+	_ = w.Flush()
+	return buf.String()
+}
 
-	// Also set the git user identity from gh auth status
-	result := execCommandWithDir(repoDir, "gh", "auth", "status", "--hostname", "github.com")
-	if result.ExitCode == 0 && strings.Contains(result.Stdout, "Logged in to github.com account") {
-		// Extract the username from the auth status
-		lines := strings.Split(result.Stdout, "\n")
-		for _, line := range lines {
-			if strings.Contains(line, "Logged in to github.com account") && strings.Contains(line, "Active account: true") {
-				// Extract username from the line format: "✓ Logged in to github.com account username (keyring)"
-				parts := strings.Fields(line)
-				for i, part := range parts {
-					if part == "account" && i+1 < len(parts) {
-						username := strings.TrimSuffix(parts[i+1], " (keyring)")
-						username = strings.TrimSuffix(username, " (oauth_token)")
-						if debug {
-							fmt.Printf("Setting git user identity to: %s\n", username)
-						}
-						execCommandWithDir(repoDir, "git", "config", "user.name", username)
-						execCommandWithDir(repoDir, "git", "config", "user.email", username+"@users.noreply.github.com")
-						break
-					}
-				}
-				break
-			}
+func shouldSkipAction(uses string) bool {
+	// Skip local actions (relative paths)
+	if strings.HasPrefix(uses, "./") {
+		return true
+	}
+	// Skip certain action patterns if configured
+	for _, skip := range skipActions {
+		if strings.Contains(uses, skip) {
+			return true
 		}
 	}
-
-	return nil
+	return false
 }
 
-func patchLocalRepository(repoDir string) error {
-	workflowsDir := filepath.Join(repoDir, ".github", "workflows")
-	if _, err := os.Stat(workflowsDir); os.IsNotExist(err) {
-		fmt.Printf("ℹ️  No .github/workflows directory found - no GitHub Actions to pin\n")
-		return nil
+func parseActionReference(uses string) (string, string, error) {
+	parts := strings.Split(uses, "@")
+	if len(parts) == 1 {
+		// Action without tag/ref - this is insecure as it defaults to default branch
+		return parts[0], "", fmt.Errorf("action without tag/ref")
+	}
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("invalid action reference format")
 	}
+	return parts[0], parts[1], nil
+}
 
-	files, err := os.ReadDir(workflowsDir)
-	if err != nil {
-		return fmt.Errorf("failed to read workflows directory: %v", err)
+var semverTagRe = regexp.MustCompile(`^v?\d+\.\d+\.\d+$`)
+
+// checkStrictSemver rejects versions that are not full semver tags when
+// --strict-semver is set. It is separate from parseActionReference so that
+// lock files and read-only scans keep accepting any reference.
+func checkStrictSemver(version string) error {
+	if strictSemver && !semverTagRe.MatchString(version) {
+		return fmt.Errorf("%w: %q (use a tag such as v3.2.1)", errNonSemverVersion, version)
 	}
+	return nil
+}
 
-	workflowFiles := []string{}
-	for _, file := range files {
-		if !file.IsDir() && (strings.HasSuffix(file.Name(), ".yml") || strings.HasSuffix(file.Name(), ".yaml")) {
-			workflowFiles = append(workflowFiles, file.Name())
+// parseMinActionVersions parses --min-action-version action=version entries,
+// keyed by lower-cased action name.
+func parseMinActionVersions(entries []string) (map[string]string, error) {
+	minimums := map[string]string{}
+	for _, entry := range entries {
+		action, version, ok := strings.Cut(entry, "=")
+		action, version = strings.ToLower(strings.Trim(strings.TrimSpace(action), "/")), strings.TrimSpace(version)
+		if !ok || action == "" || version == "" {
+			return nil, fmt.Errorf("invalid --min-action-version entry %q (expected action=version, e.g. actions/checkout=v3)", entry)
 		}
+		if _, valid := parseVersionParts(version); !valid {
+			return nil, fmt.Errorf("invalid --min-action-version entry %q: %q is not a version such as v3 or v3.2.1", entry, version)
+		}
+		minimums[action] = version
 	}
+	return minimums, nil
+}
 
-	if len(workflowFiles) == 0 {
-		fmt.Printf("ℹ️  No workflow files found in .github/workflows directory\n")
-		return nil
+// belowMinActionVersion reports whether version is older than the minimum
+// configured for action, matched on the full action path or its repository.
+// Branches and other references that are not versions are never below.
+func belowMinActionVersion(action, version string) (string, bool) {
+	minimum, ok := minActionVersions[strings.ToLower(action)]
+	if !ok {
+		minimum, ok = minActionVersions[strings.ToLower(actionRepoName(action))]
+	}
+	if !ok {
+		return "", false
 	}
+	cmp, comparable := compareVersions(version, minimum)
+	return minimum, comparable && cmp < 0
+}
 
-	fmt.Printf("🔍 Found %d workflow file(s): %s\n", len(workflowFiles), strings.Join(workflowFiles, ", "))
+var versionPartsRe = regexp.MustCompile(`^v?(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:-([0-9A-Za-z.-]+))?(?:\+[0-9A-Za-z.-]+)?$`)
 
-	totalActionsPinned := 0
-	totalActionsAlreadyPinned := 0
-	totalActionsSkipped := 0
-	totalActionsWithLatest := 0
-	totalActionsWithoutTags := 0
-	totalActionsFound := 0
-	totalHardenInjected := 0
-	totalRunnersReplaced := 0
+// versionParts is a parsed semver version; missing minor and patch numbers
+// are zero, so v3 orders as v3.0.0.
+type versionParts struct {
+	numbers    [3]int
+	prerelease string
+}
 
-	patcher := &WorkflowPatcher{
-		injectHardenRunner: injectHardenRunner,
-		egressPolicy:       egressPolicy,
-		pinRunners:         pinRunners,
-		runnerMap:          runnerMap,
+func parseVersionParts(version string) (versionParts, bool) {
+	m := versionPartsRe.FindStringSubmatch(version)
+	if m == nil {
+		return versionParts{}, false
 	}
-
-	for _, file := range files {
-		if !file.IsDir() && (strings.HasSuffix(file.Name(), ".yml") || strings.HasSuffix(file.Name(), ".yaml")) {
-			res, err := patcher.patchFile(filepath.Join(workflowsDir, file.Name()))
+	var v versionParts
+	for i := 0; i < 3; i++ {
+		if m[i+1] != "" {
+			n, err := strconv.Atoi(m[i+1])
 			if err != nil {
-				return fmt.Errorf("failed to process workflow file %s: %v", file.Name(), err)
+				return versionParts{}, false
 			}
-			totalActionsPinned += res.actionsPinned
-			totalActionsAlreadyPinned += res.actionsAlreadyPinned
-			totalActionsSkipped += res.actionsSkipped
-			totalActionsWithLatest += res.actionsWithLatest
-			totalActionsWithoutTags += res.actionsWithoutTags
-			totalActionsFound += res.totalActions
-			totalHardenInjected += res.hardenInjected
-			totalRunnersReplaced += res.runnersReplaced
+			v.numbers[i] = n
 		}
 	}
-	// Scan composite action files in .github/actions/
-	actionsBaseDir := filepath.Join(repoDir, ".github", "actions")
-	if _, statErr := os.Stat(actionsBaseDir); statErr == nil {
-		walkErr := filepath.WalkDir(actionsBaseDir, func(path string, d os.DirEntry, walkEntryErr error) error {
-			if walkEntryErr != nil {
-				return walkEntryErr
-			}
-			if d.IsDir() {
-				return nil
-			}
-			if !strings.HasSuffix(path, ".yml") && !strings.HasSuffix(path, ".yaml") {
-				return nil
-			}
-			res, err := patcher.patchFile(path)
-			if err != nil {
-				fmt.Printf("⚠️  Warning: failed to process composite action %s: %v\n", path, err)
-				return nil
+	v.prerelease = m[4]
+	return v, true
+}
+
+// compareVersions orders a and b by semver precedence, returning -1, 0 or 1.
+// The second result is false when either is not a version.
+func compareVersions(a, b string) (int, bool) {
+	va, okA := parseVersionParts(a)
+	vb, okB := parseVersionParts(b)
+	if !okA || !okB {
+		return 0, false
+	}
+	for i := 0; i < 3; i++ {
+		if va.numbers[i] != vb.numbers[i] {
+			if va.numbers[i] < vb.numbers[i] {
+				return -1, true
 			}
-			totalActionsPinned += res.actionsPinned
-			totalActionsAlreadyPinned += res.actionsAlreadyPinned
-			totalActionsSkipped += res.actionsSkipped
-			totalActionsWithLatest += res.actionsWithLatest
-			totalActionsWithoutTags += res.actionsWithoutTags
-			totalActionsFound += res.totalActions
-			return nil
-		})
-		if walkErr != nil && debug {
-			fmt.Printf("Warning: error walking actions directory: %v\n", walkErr)
+			return 1, true
 		}
 	}
+	return comparePrerelease(va.prerelease, vb.prerelease), true
+}
 
-	// Capture totals for dynamic PR body generation
-	lastRunSummary.actionsPinned = totalActionsPinned
-	lastRunSummary.alreadyPinned = totalActionsAlreadyPinned
-	lastRunSummary.hardenInjected = totalHardenInjected
-	lastRunSummary.runnersReplaced = totalRunnersReplaced
-	lastRunSummary.withLatest = totalActionsWithLatest
-	lastRunSummary.withoutTags = totalActionsWithoutTags
-	lastRunSummary.totalFound = totalActionsFound
+// comparePrerelease applies semver precedence to pre-release suffixes: a
+// release outranks any pre-release, numeric identifiers compare numerically
+// and rank below alphanumeric ones.
+func comparePrerelease(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		if pa[i] == pb[i] {
+			continue
+		}
+		na, errA := strconv.Atoi(pa[i])
+		nb, errB := strconv.Atoi(pb[i])
+		switch {
+		case errA == nil && errB == nil:
+			if na < nb {
+				return -1
+			}
+			return 1
+		case errA == nil:
+			return -1
+		case errB == nil:
+			return 1
+		case pa[i] < pb[i]:
+			return -1
+		default:
+			return 1
+		}
+	}
+	if len(pa) < len(pb) {
+		return -1
+	}
+	return 1
+}
 
-	// Summary of actions processed
-	fmt.Printf("\n📊 Summary:\n")
-	fmt.Printf("   • Total actions found: %d\n", totalActionsFound)
-	fmt.Printf("   • Actions pinned: %d\n", totalActionsPinned)
-	fmt.Printf("   • Actions already pinned: %d\n", totalActionsAlreadyPinned)
-	if injectHardenRunner {
-		fmt.Printf("   • Harden-runner injected: %d job(s)\n", totalHardenInjected)
+// getCommitHashFromVersion resolves action@version, consulting the in-memory
+// hash cache (pre-populated by --import-lock) and the pre-built action index
+// before any network access.
+func getCommitHashFromVersion(action, version string) (string, string, error) {
+	if entry, ok := hashCache.get(action, version); ok {
+		if debug {
+			fmt.Printf("Resolved %s@%s from hash cache\n", action, version)
+		}
+		return entry.hash, entry.resolvedVersion, nil
 	}
-	if pinRunners {
-		fmt.Printf("   • Runner labels pinned: %d\n", totalRunnersReplaced)
+	// The cache and lock file are keyed by the uses: name; only resolution
+	// goes to the --action-prefix-map mirror.
+	resolveName := remapActionPrefix(action, actionPrefixMap)
+	if debug && resolveName != action {
+		fmt.Printf("Resolving %s@%s via mirror %s\n", action, version, resolveName)
 	}
-	fmt.Printf("   • Actions with @latest: %d\n", totalActionsWithLatest)
-	fmt.Printf("   • Actions without tag/ref: %d\n", totalActionsWithoutTags)
-	fmt.Printf("   • Actions skipped: %d\n", totalActionsSkipped)
+	if hash, ok := lookupActionIndex(resolveName, version); ok && !forceClone {
+		if debug {
+			fmt.Printf("Resolved %s@%s from action index\n", action, version)
+		}
+		hashCache.put(action, version, lockEntry{hash: hash, resolvedVersion: version, date: time.Now().Format("2006-01-02")})
+		return hash, version, nil
+	}
+	hash, resolvedVersion, err := resolveCommitHash(resolveName, version)
+	if err != nil {
+		return "", "", err
+	}
+	date := time.Now().Format("2006-01-02")
+	if tagAnnotation {
+		annotateCachedCommit(resolveName, action, version, hash, date)
+	}
+	hashCache.put(action, version, lockEntry{hash: hash, resolvedVersion: resolvedVersion, date: date})
+	return hash, resolvedVersion, nil
+}
 
-	if totalActionsPinned == 0 && totalActionsAlreadyPinned > 0 {
-		fmt.Printf("✅ All GitHub Actions are already properly pinned to commit hashes\n")
-	} else if totalActionsPinned == 0 && totalActionsAlreadyPinned == 0 && totalActionsSkipped > 0 {
-		fmt.Printf("ℹ️  No GitHub Actions found that need pinning (only local actions or already pinned)\n")
-	} else if totalActionsPinned == 0 {
-		fmt.Printf("ℹ️  No GitHub Actions found in workflow files\n")
-	} else {
-		fmt.Printf("✅ Successfully pinned %d GitHub Action(s) to commit hashes\n", totalActionsPinned)
-		if skipPRCreation {
-			fmt.Printf("   • Repository location: %s\n", repoDir)
-			fmt.Printf("   • Changes are ready for review and manual commit\n")
+// annotateCachedCommit records how hash was resolved as a git note on the
+// commit in the cached clone of repoAction, for --tag-annotation. Notes live
+// under refs/notes, so they survive the fetches that refresh the cache. Hashes
+// resolved through the API have no clone to annotate and are skipped.
+func annotateCachedCommit(repoAction, action, version, hash, date string) {
+	actionDir := actionRepoCacheDir(repoAction)
+	defer lockActionRepo(actionDir)()
+	if _, err := os.Stat(actionDir); err != nil {
+		if debug {
+			fmt.Printf("No cached clone of %s to annotate %s\n", repoAction, hash)
+		}
+		return
+	}
+	note := fmt.Sprintf("Resolved from %s on %s for %s", version, date, action)
+	if result := execCommandWithDir(actionDir, "git", "notes", "add", "-f", "-m", note, hash); result.ExitCode != 0 && debug {
+		fmt.Printf("Warning: failed to annotate %s in %s: %s\n", hash, actionDir, strings.TrimSpace(result.Stderr))
+	}
+}
+
+// remapActionPrefix rewrites the leading path segments of action according to
+// prefixMap, e.g. "actions" => "internal-mirror" turns actions/checkout into
+// internal-mirror/checkout. Keys match whole segments and the longest matching
+// key wins; unmatched actions are returned unchanged.
+func remapActionPrefix(action string, prefixMap map[string]string) string {
+	best := ""
+	for prefix := range prefixMap {
+		if (action == prefix || strings.HasPrefix(action, prefix+"/")) && len(prefix) > len(best) {
+			best = prefix
 		}
 	}
-
-	if totalActionsWithLatest > 0 {
-		fmt.Printf("⚠️  Warning: %d action(s) using @latest tag detected - these should be pinned for better security\n", totalActionsWithLatest)
+	if best == "" {
+		return action
 	}
-	if totalActionsWithoutTags > 0 {
-		fmt.Printf("🚨 Security Warning: %d action(s) found without any tag/ref - these are insecure as they default to the mutable default branch\n", totalActionsWithoutTags)
+	return prefixMap[best] + strings.TrimPrefix(action, best)
+}
+
+// parseActionPrefixMap parses --action-prefix-map from=to entries. Both sides
+// must be an owner or both an owner/repo, so that a remapped action still
+// names an owner/repo on GitHub.
+func parseActionPrefixMap(entries []string) (map[string]string, error) {
+	prefixMap := map[string]string{}
+	for _, entry := range entries {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.Trim(strings.TrimSpace(parts[0]), "/") == "" || strings.Trim(strings.TrimSpace(parts[1]), "/") == "" {
+			return nil, fmt.Errorf("invalid --action-prefix-map entry %q (expected from=to, e.g. actions=internal-mirror)", entry)
+		}
+		from, to := strings.Trim(strings.TrimSpace(parts[0]), "/"), strings.Trim(strings.TrimSpace(parts[1]), "/")
+		fromSegments, toSegments := len(strings.Split(from, "/")), len(strings.Split(to, "/"))
+		if fromSegments > 2 || fromSegments != toSegments {
+			return nil, fmt.Errorf("invalid --action-prefix-map entry %q: map an owner to an owner (actions=internal-mirror) or an owner/repo to an owner/repo (actions/checkout=mirror/checkout)", entry)
+		}
+		prefixMap[from] = to
 	}
+	return prefixMap, nil
+}
 
-	printContextualTips(injectHardenRunner, pinRunners)
-	return nil
+// resolutionStrategy selects how resolveCommitHash turns a version into a
+// commit hash.
+type resolutionStrategy int
+
+const (
+	// strategyPreferAPI asks the API first and clones when that fails: fast,
+	// and still works for refs the API cannot resolve.
+	strategyPreferAPI resolutionStrategy = iota
+	// strategyPreferClone clones first and asks the API when that fails: slower,
+	// but reads tags straight from git where the API is blocked or stale.
+	strategyPreferClone
+	// strategyAPIOnly never clones: fastest and needs no git egress, but refs
+	// the API cannot resolve fail.
+	strategyAPIOnly
+	// strategyCloneOnly never asks the API: slowest, for environments where
+	// only git traffic is allowed. --force-clone selects it as well.
+	strategyCloneOnly
+)
+
+// activeResolutionStrategy returns the strategy chosen with --prefer-api,
+// --prefer-clone, --api-only, --clone-only or --force-clone.
+func activeResolutionStrategy() resolutionStrategy {
+	if forceClone {
+		return strategyCloneOnly
+	}
+	return resolutionMode
 }
 
-func (p *WorkflowPatcher) patchFile(filePath string) (patchResult, error) {
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return patchResult{}, fmt.Errorf("failed to read file: %v", err)
+func resolveCommitHash(action, version string) (string, string, error) {
+	if debug {
+		start := time.Now()
+		defer func() {
+			fmt.Printf("Action %s@%s resolved in %v\n", action, version, time.Since(start))
+		}()
 	}
-	raw := string(content)
-	// Remember whether the file uses Windows line endings so we can restore them
-	// on write. All internal processing uses LF-only strings.
-	hasCRLF := strings.Contains(raw, "\r\n")
-	originalContent := strings.ReplaceAll(raw, "\r\n", "\n")
 
-	var workflow map[string]interface{}
-	if err := yaml.Unmarshal(content, &workflow); err != nil {
-		return patchResult{}, fmt.Errorf("failed to parse YAML: %v", err)
+	// With --fix-latest, @latest means the latest release: resolve its tag
+	// first so that every strategy pins the same commit. Git has no notion of
+	// a release, so this asks the API even with --clone-only.
+	if version == "latest" && fixLatest {
+		tag, err := getLatestReleaseTag(actionRepoName(action))
+		if err != nil {
+			return "", "", err
+		}
+		if debug {
+			fmt.Printf("Resolved %s@latest to release %s\n", action, tag)
+		}
+		version = tag
+	}
+
+	switch activeResolutionStrategy() {
+	case strategyAPIOnly:
+		return resolveCommitHashViaAPI(action, version)
+	case strategyCloneOnly:
+		return resolveCommitHashViaClone(action, version, true)
+	case strategyPreferClone:
+		hash, resolvedVersion, err := resolveCommitHashViaClone(action, version, true)
+		if err == nil {
+			return hash, resolvedVersion, nil
+		}
+		if debug {
+			fmt.Printf("Resolving %s@%s by cloning failed, trying the API: %v\n", action, version, err)
+		}
+		if hash, resolvedVersion, apiErr := resolveCommitHashViaAPI(action, version); apiErr == nil {
+			return hash, resolvedVersion, nil
+		}
+		return "", "", err
 	}
 
-	_, hasJobs := workflow["jobs"]
-	_, hasRuns := workflow["runs"]
-	if !hasJobs && !hasRuns {
-		return patchResult{}, nil
+	// The API is the fast path; cloning is the fallback.
+	if hash, resolvedVersion, err := resolveCommitHashViaAPI(action, version); err == nil {
+		return hash, resolvedVersion, nil
 	}
-	isComposite := !hasJobs && hasRuns
+	// The API fast path failed; make sure the repository exists before paying
+	// for a clone that would fail anyway.
+	if err := verifyActionExists(action); err != nil {
+		return "", "", err
+	}
+	return resolveCommitHashViaClone(action, version, false)
+}
 
-	current, res, err := pinActionsPass(originalContent, workflow, isComposite)
+// resolveCommitHashViaAPI resolves version without cloning, confirming the
+// commit exists with --commit-verification.
+func resolveCommitHashViaAPI(action, version string) (string, string, error) {
+	hash, resolvedVersion, err := getCommitHashViaAPI(action, version)
 	if err != nil {
-		return patchResult{}, err
+		return "", "", err
+	}
+	if commitVerification && !verifyCommitExists(action, hash) {
+		return "", "", fmt.Errorf("commit %s of %s could not be verified", hash, action)
 	}
+	if debug {
+		fmt.Printf("Resolved %s@%s via API (no cloning needed)\n", action, version)
+	}
+	return hash, resolvedVersion, nil
+}
 
-	if p.injectHardenRunner && !isComposite {
-		updated, count, injErr := p.injectHardenRunnerPass(current, workflow)
-		if injErr != nil {
-			fmt.Printf("⚠️  Warning: harden-runner injection failed for %s: %v\n", filePath, injErr)
-		} else {
-			current = updated
-			res.hardenInjected = count
-		}
+// resolveCommitHashViaClone resolves version in a cached clone of the action's
+// repository, cloning it first if needed. refresh fetches the latest tags into
+// an existing clone, which matters when cloning is the primary strategy.
+func resolveCommitHashViaClone(action, version string, refresh bool) (string, string, error) {
+	unlock := lockActionRepo(actionRepoCacheDir(action))
+	hash, resolvedVersion, err := resolveInActionClone(action, version, refresh)
+	unlock()
+	if err == nil && hash == "" {
+		// version only prefixes tags: resolve the latest of them, which may
+		// take the clone lock again.
+		return getCommitHashFromVersion(action, resolvedVersion)
+	}
+	return hash, resolvedVersion, err
+}
+
+// actionRepoLocks serializes the clones, fetches and lookups of each cached
+// action repository, keyed by actionRepoCacheDir: parallel resolutions of one
+// action (resolve-versions, concurrent repository workers) would otherwise
+// clone into and fetch the same directory at once.
+var (
+	actionRepoLocks   = map[string]*sync.Mutex{}
+	actionRepoLocksMu sync.Mutex
+)
+
+// lockActionRepo locks the cached clone at actionDir and returns the function
+// that unlocks it.
+func lockActionRepo(actionDir string) func() {
+	actionRepoLocksMu.Lock()
+	mu, ok := actionRepoLocks[actionDir]
+	if !ok {
+		mu = &sync.Mutex{}
+		actionRepoLocks[actionDir] = mu
 	}
+	actionRepoLocksMu.Unlock()
+	mu.Lock()
+	return mu.Unlock
+}
 
-	if p.pinRunners && !isComposite {
-		updated, count := pinRunnersPass(current, p.runnerMap)
-		current = updated
-		res.runnersReplaced = count
+// resolveInActionClone does the work of resolveCommitHashViaClone with the
+// clone locked. When version only prefixes tags it returns an empty hash and
+// the latest matching tag, for the caller to resolve once the lock is released.
+func resolveInActionClone(action, version string, refresh bool) (string, string, error) {
+	repoName := actionRepoName(action)
+	actionDir := actionRepoCacheDir(action)
+	if err := os.MkdirAll(filepath.Dir(actionDir), 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create actions cache directory: %v", err)
 	}
 
-	if current != originalContent {
-		out := current
-		if hasCRLF {
-			out = strings.ReplaceAll(current, "\n", "\r\n")
+	if _, err := os.Stat(actionDir); os.IsNotExist(err) {
+		if debug {
+			fmt.Printf("Cloning action repository: %s (this may take a moment for large repos)\n", repoName)
 		}
-		if err := os.WriteFile(filePath, []byte(out), 0644); err != nil {
-			return patchResult{}, fmt.Errorf("failed to write updated file: %v", err)
+		var cloneErr error
+		for i, depthArg := range actionCloneDepths() {
+			if i > 0 && debug {
+				fmt.Printf("Clone failed, retrying %s with %s\n", repoName, describeDepthArg(depthArg))
+			}
+			if cloneErr = cloneRepository(repoName, actionDir, depthArg); cloneErr == nil {
+				break
+			}
+		}
+		if cloneErr != nil {
+			return "", "", fmt.Errorf("failed to clone action repository: %v", cloneErr)
+		}
+	} else if debug || refresh {
+		if debug {
+			fmt.Printf("Using cached action repository: %s\n", repoName)
+		}
+		// Update the cached repository to get latest refs/tags
+		if result := execCommandWithDir(actionDir, "git", "fetch", "origin", "--tags", "--quiet"); result.ExitCode != 0 && debug {
+			fmt.Printf("Warning: Failed to update cached repository %s: %s\n", repoName, result.Stderr)
 		}
 	}
-	return res, nil
-}
 
-func pinActionsPass(content string, workflow map[string]interface{}, isComposite bool) (string, patchResult, error) {
-	var res patchResult
+	// Abbreviated SHAs are expanded with rev-parse; a shallow clone may not contain
+	// the commit, so unshallow once before giving up.
+	if isShortSHA(version) {
+		if hash, err := expandShortSHA(actionDir, version); err == nil {
+			return hash, version, nil
+		}
+		execCommandWithDir(actionDir, "git", "fetch", "origin", "--unshallow", "--tags", "--quiet")
+		hash, err := expandShortSHA(actionDir, version)
+		if err != nil {
+			return "", "", err
+		}
+		return hash, version, nil
+	}
 
-	var allJobSteps [][]map[string]interface{}
-	if isComposite {
-		if runs, ok := workflow["runs"].(map[string]interface{}); ok {
-			if steps, ok := runs["steps"].([]interface{}); ok {
-				var jobSteps []map[string]interface{}
-				for _, s := range steps {
-					if step, ok := s.(map[string]interface{}); ok {
-						jobSteps = append(jobSteps, step)
-					}
-				}
-				allJobSteps = append(allJobSteps, jobSteps)
+	// First try to resolve the version directly
+	if result := execCommandWithDir(actionDir, "git", "rev-list", "-n", "1", version); result.ExitCode == 0 {
+		return strings.TrimSpace(result.Stdout), version, nil
+	}
+
+	// If direct resolution fails, try to fetch the specific ref if it looks like a tag/branch
+	if matched, _ := regexp.MatchString(`^[a-f0-9]{40}$`, version); !matched {
+		if result := execCommandWithDir(actionDir, "git", "fetch", "origin", "+refs/tags/"+version+":refs/tags/"+version, "--quiet"); result.ExitCode == 0 {
+			if result := execCommandWithDir(actionDir, "git", "rev-list", "-n", "1", version); result.ExitCode == 0 {
+				return strings.TrimSpace(result.Stdout), version, nil
 			}
 		}
-	} else {
-		if jobs, ok := workflow["jobs"].(map[string]interface{}); ok {
-			for _, jobData := range jobs {
-				if job, ok := jobData.(map[string]interface{}); ok {
-					if steps, ok := job["steps"].([]interface{}); ok {
-						var jobSteps []map[string]interface{}
-						for _, s := range steps {
-							if step, ok := s.(map[string]interface{}); ok {
-								jobSteps = append(jobSteps, step)
-							}
-						}
-						allJobSteps = append(allJobSteps, jobSteps)
-					}
-				}
+		// Also try fetching as a branch
+		if result := execCommandWithDir(actionDir, "git", "fetch", "origin", "+refs/heads/"+version+":refs/remotes/origin/"+version, "--quiet"); result.ExitCode == 0 {
+			if result := execCommandWithDir(actionDir, "git", "rev-list", "-n", "1", "origin/"+version); result.ExitCode == 0 {
+				return strings.TrimSpace(result.Stdout), version, nil
 			}
 		}
 	}
 
-	var actionsToPin []actionPin
-	for _, steps := range allJobSteps {
-		for _, step := range steps {
-			if uses, ok := step["uses"].(string); ok && uses != "" {
-				res.totalActions++
-				if shouldSkipAction(uses) {
-					res.actionsSkipped++
-					continue
-				}
-				if matched, _ := regexp.MatchString(`@[a-f0-9]{40}`, uses); matched {
-					res.actionsAlreadyPinned++
-					continue
-				}
-				if strings.Contains(uses, "@latest") {
-					res.actionsWithLatest++
-				}
-				if action, version, err := parseActionReference(uses); err == nil {
-					actionsToPin = append(actionsToPin, actionPin{action: action, version: version})
-				} else if strings.Contains(err.Error(), "action without tag/ref") {
-					res.actionsWithoutTags++
-				}
-			}
-		}
+	if matched, _ := regexp.MatchString(`^v?\d+\.\d+\.\d+$`, version); matched {
+		return "", "", errUnresolvedVersion
 	}
 
-	if len(actionsToPin) == 0 {
-		return content, res, nil
+	// Fallback to pattern matching for partial versions
+	if result := execCommandWithDir(actionDir, "git", "tag", "-l", version+"*"); result.ExitCode == 0 && strings.TrimSpace(result.Stdout) != "" {
+		tags := strings.Split(strings.TrimSpace(result.Stdout), "\n")
+		if len(tags) > 0 {
+			return "", tags[len(tags)-1], nil
+		}
 	}
 
-	fmt.Printf("🔄 Processing %d action(s) for pinning...\n", len(actionsToPin))
+	return "", "", fmt.Errorf("version not found: %s", version)
+}
 
-	numWorkers := runtime.NumCPU()
-	if numWorkers > len(actionsToPin) {
-		numWorkers = len(actionsToPin)
+// expandShortSHA resolves an abbreviated commit hash to its full 40-character form.
+// git rev-parse fails when the prefix is ambiguous, which is the desired behavior.
+func expandShortSHA(actionDir, shortSHA string) (string, error) {
+	result := execCommandWithDir(actionDir, "git", "rev-parse", "--verify", "--quiet", shortSHA+"^{commit}")
+	if result.ExitCode != 0 {
+		return "", fmt.Errorf("short SHA %s not found or ambiguous", shortSHA)
 	}
+	return strings.TrimSpace(result.Stdout), nil
+}
 
-	actionsChan := make(chan actionPin, len(actionsToPin))
-	resultsChan := make(chan actionPin, len(actionsToPin))
-	var wg sync.WaitGroup
-	for i := 0; i < numWorkers; i++ {
-		wg.Add(1)
-		go pinActionsWorker(actionsChan, resultsChan, &wg)
+// lockEntry is a resolved action reference as stored in the hash cache and lock files.
+type lockEntry struct {
+	hash            string
+	resolvedVersion string
+	date            string
+}
+
+// actionHashCache holds resolved action@version hashes for the lifetime of a run.
+// It is shared by the concurrent pinning workers.
+type actionHashCache struct {
+	mu      sync.Mutex
+	entries map[string]lockEntry
+	// lastCommits records each action repository's latest commit date for --max-commit-age.
+	lastCommits map[string]time.Time
+}
+
+func newActionHashCache() *actionHashCache {
+	return &actionHashCache{entries: map[string]lockEntry{}, lastCommits: map[string]time.Time{}}
+}
+
+// metadataCache holds action repository metadata for the lifetime of a run so
+// that trust checks across workflow files and repositories share one lookup.
+// Each entry remembers when it was fetched, so expiry never depends on what
+// other repository workers are doing.
+type metadataCache struct {
+	mu      sync.RWMutex
+	entries map[string]metadataEntry
+}
+
+type metadataEntry struct {
+	meta    ActionMetadata
+	fetched time.Time
+}
+
+func newMetadataCache() *metadataCache {
+	return &metadataCache{entries: map[string]metadataEntry{}}
+}
+
+// get returns the metadata of repoName unless it is older than maxAge; a
+// maxAge of 0 accepts entries of any age.
+func (c *metadataCache) get(repoName string, maxAge time.Duration) (ActionMetadata, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, ok := c.entries[repoName]
+	if !ok || (maxAge > 0 && time.Since(entry.fetched) > maxAge) {
+		return ActionMetadata{}, false
 	}
-	for _, a := range actionsToPin {
-		actionsChan <- a
+	return entry.meta, true
+}
+
+func (c *metadataCache) put(repoName string, meta ActionMetadata) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[repoName] = metadataEntry{meta: meta, fetched: time.Now()}
+}
+
+func (c *actionHashCache) getLastCommit(repoName string) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	date, ok := c.lastCommits[repoName]
+	return date, ok
+}
+
+func (c *actionHashCache) putLastCommit(repoName string, date time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastCommits[repoName] = date
+}
+
+func (c *actionHashCache) get(action, version string) (lockEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[action+"@"+version]
+	return entry, ok
+}
+
+func (c *actionHashCache) put(action, version string, entry lockEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[action+"@"+version] = entry
+}
+
+var lockLineRe = regexp.MustCompile(`^(\S+@\S+)\s*=\s*([a-f0-9]{40})\s*(?:#\s*(?:resolved\s+(\S+))?\s*(?:as\s+(\S+))?)?\s*$`)
+
+// parseLock parses lock file content of the form
+// "actions/checkout@v3 = <sha> # resolved 2024-01-15 as v3.6.0", ignoring
+// blank lines and # comments. Without "as", the reference resolved to the
+// version it names.
+func parseLock(content string) (map[string]lockEntry, error) {
+	entries := map[string]lockEntry{}
+	for i, rawLine := range strings.Split(content, "\n") {
+		line := strings.TrimSpace(rawLine)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		m := lockLineRe.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("invalid lock entry on line %d: %q", i+1, line)
+		}
+		_, version, err := parseActionReference(m[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid action reference on line %d: %q", i+1, m[1])
+		}
+		if m[4] != "" {
+			version = m[4]
+		}
+		entries[m[1]] = lockEntry{hash: m[2], resolvedVersion: version, date: m[3]}
 	}
-	close(actionsChan)
-	go func() {
-		wg.Wait()
-		close(resultsChan)
-	}()
+	return entries, nil
+}
 
-	pinnedActions := make(map[string]actionPin)
-	for result := range resultsChan {
-		key := fmt.Sprintf("%s@%s", result.action, result.version)
-		pinnedActions[key] = result
+// formatLock renders entries as a sorted, line-based lock file. The resolved
+// version is written only when it differs from the requested one, so that
+// importing the file keeps the version comments of pinned workflows stable.
+func formatLock(entries map[string]lockEntry) string {
+	keys := make([]string, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
 	}
+	sort.Strings(keys)
 
-	updated := content
-	currentDate := time.Now().Format("2006-01-02")
-	for _, steps := range allJobSteps {
-		for _, step := range steps {
-			if uses, ok := step["uses"].(string); ok && uses != "" && !shouldSkipAction(uses) {
-				if action, version, err := parseActionReference(uses); err == nil {
-					key := fmt.Sprintf("%s@%s", action, version)
-					if pinned, exists := pinnedActions[key]; exists {
-						if pinned.err == nil {
-							pinnedUses := fmt.Sprintf("%s@%s # %s on %s", action, pinned.hash, pinned.resolvedVersion, currentDate)
-							updated = strings.Replace(updated, fmt.Sprintf("uses: %s", uses), fmt.Sprintf("uses: %s", pinnedUses), 1)
-							res.actionsPinned++
-							if debug {
-								fmt.Printf("Pinned %s@%s to %s\n", action, version, pinned.hash)
-							}
-						} else if errors.Is(pinned.err, errUnresolvedVersion) {
-							todoComment := fmt.Sprintf("# %s on %s, TODO: Pin to a commit hash", version, currentDate)
-							updated = strings.Replace(updated, fmt.Sprintf("uses: %s", uses), fmt.Sprintf("uses: %s %s", uses, todoComment), 1)
-						}
-					}
-				}
-			}
+	var sb strings.Builder
+	for _, k := range keys {
+		entry := entries[k]
+		sb.WriteString(fmt.Sprintf("%s = %s", k, entry.hash))
+		var notes []string
+		if entry.date != "" {
+			notes = append(notes, "resolved "+entry.date)
 		}
+		if _, version, err := parseActionReference(k); err == nil && entry.resolvedVersion != "" && entry.resolvedVersion != version {
+			notes = append(notes, "as "+entry.resolvedVersion)
+		}
+		if len(notes) > 0 {
+			sb.WriteString(" # " + strings.Join(notes, " "))
+		}
+		sb.WriteString("\n")
 	}
-
-	return updated, res, nil
+	return sb.String()
 }
 
-func shouldSkipAction(uses string) bool {
-	// Skip local actions (relative paths)
-	if strings.HasPrefix(uses, "./") {
-		return true
+func (c *actionHashCache) importLock(path string) (int, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read lock file %s: %w", path, err)
 	}
-	// Skip certain action patterns if configured
-	for _, skip := range skipActions {
-		if strings.Contains(uses, skip) {
-			return true
-		}
+	entries, err := parseLock(string(content))
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse lock file %s: %w", path, err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, v := range entries {
+		c.entries[k] = v
+	}
+	return len(entries), nil
+}
+
+// exportLock writes the cache to path and prints the SHA-256 of the written
+// content to stderr so the file can be verified on the receiving side.
+func (c *actionHashCache) exportLock(path string) error {
+	c.mu.Lock()
+	content := formatLock(c.entries)
+	count := len(c.entries)
+	c.mu.Unlock()
+
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write lock file %s: %w", path, err)
 	}
-	return false
+	sum := sha256.Sum256([]byte(content))
+	fmt.Fprintf(os.Stderr, "🔒 Exported %d action hash(es) to %s (sha256: %s)\n", count, path, hex.EncodeToString(sum[:]))
+	return nil
 }
 
-func parseActionReference(uses string) (string, string, error) {
-	parts := strings.Split(uses, "@")
-	if len(parts) == 1 {
-		// Action without tag/ref - this is insecure as it defaults to default branch
-		return parts[0], "", fmt.Errorf("action without tag/ref")
+// verifyActionExists checks that the action's repository exists. It returns an
+// error wrapping errActionNotFound for a 404 and nil for any other outcome, so
+// transient API failures still fall through to normal resolution.
+func verifyActionExists(action string) error {
+	repoName := action
+	if parts := strings.Split(action, "/"); len(parts) >= 2 {
+		repoName = fmt.Sprintf("%s/%s", parts[0], parts[1])
 	}
-	if len(parts) != 2 {
-		return "", "", fmt.Errorf("invalid action reference format")
+	if _, err := getActionMetadata(repoName); errors.Is(err, errActionNotFound) {
+		return err
 	}
-	return parts[0], parts[1], nil
+	return nil
 }
 
-func getCommitHashFromVersion(action, version string) (string, string, error) {
+// verifyCommitExists implements --commit-verification: it confirms that sha
+// names a commit reachable in the action's repository. A tag ref can point at
+// an annotated tag object instead, whose SHA the commits API resolves to a
+// different commit, so the returned SHA must match.
+func verifyCommitExists(action, sha string) bool {
+	repoName := action
+	if parts := strings.Split(action, "/"); len(parts) >= 2 {
+		repoName = fmt.Sprintf("%s/%s", parts[0], parts[1])
+	}
+	result := cachedGitHubAPI(fmt.Sprintf("repos/%s/commits/%s", repoName, sha))
+	ok := result.ExitCode == 0 && parseCommitSHA(result.Stdout) == sha
 	if debug {
-		start := time.Now()
-		defer func() {
-			fmt.Printf("Action %s@%s resolved in %v\n", action, version, time.Since(start))
-		}()
+		if ok {
+			fmt.Printf("Verified %s@%s is a reachable commit\n", repoName, sha)
+		} else {
+			fmt.Printf("Commit verification failed for %s@%s, falling back to clone\n", repoName, sha)
+		}
 	}
+	return ok
+}
 
-	// First try GitHub API approach (fastest, no cloning)
-	if hash, resolvedVersion, err := getCommitHashViaAPI(action, version); err == nil {
-		if debug {
-			fmt.Printf("Resolved %s@%s via API (no cloning needed)\n", action, version)
-		}
-		return hash, resolvedVersion, nil
+// parseCommitSHA returns the sha field of a repos/<repo>/commits/<ref> response.
+func parseCommitSHA(body string) string {
+	var commit struct {
+		SHA string `json:"sha"`
 	}
+	if err := json.Unmarshal([]byte(body), &commit); err != nil {
+		return ""
+	}
+	return commit.SHA
+}
 
+// checkActionActivity reports whether the action's repository has a commit
+// newer than maxAge. Latest commit dates are cached per repository.
+func checkActionActivity(action string, maxAge time.Duration) (bool, error) {
 	repoName := action
-	if strings.Contains(action, "/") {
-		parts := strings.Split(action, "/")
-		if len(parts) >= 2 {
-			repoName = fmt.Sprintf("%s/%s", parts[0], parts[1])
+	if parts := strings.Split(action, "/"); len(parts) >= 2 {
+		repoName = fmt.Sprintf("%s/%s", parts[0], parts[1])
+	}
+	date, ok := hashCache.getLastCommit(repoName)
+	if !ok {
+		result := githubAPI("GET", fmt.Sprintf("repos/%s/commits?per_page=1", repoName), nil)
+		if result.ExitCode != 0 {
+			return false, fmt.Errorf("failed to fetch latest commit: %s", result.Stderr)
+		}
+		parsed, err := parseLatestCommitDate(result.Stdout)
+		if err != nil {
+			return false, err
 		}
+		date = parsed
+		hashCache.putLastCommit(repoName, date)
 	}
+	return time.Since(date) <= maxAge, nil
+}
 
-	actionDir := filepath.Join(getActionsCacheDir(), strings.ReplaceAll(repoName, "/", "_"))
-	if err := os.MkdirAll(filepath.Dir(actionDir), 0755); err != nil {
-		return "", "", fmt.Errorf("failed to create actions cache directory: %v", err)
-	}
+// recentActivityWindow is how recently an action repository must have been
+// pushed to for ActionMetadata.HasRecentActivity.
+const recentActivityWindow = 365 * 24 * time.Hour
+
+// ActionMetadata holds the trust signals of an action's repository.
+type ActionMetadata struct {
+	Stars             int
+	Forks             int
+	IsArchived        bool
+	IsDisabled        bool
+	IsFork            bool
+	DefaultBranch     string
+	PushedAt          time.Time
+	HasRecentActivity bool
+	License           string
+}
 
-	if _, err := os.Stat(actionDir); os.IsNotExist(err) {
-		if debug {
-			fmt.Printf("Cloning action repository: %s (this may take a moment for large repos)\n", repoName)
-		}
-		// Use very shallow clone for fastest cloning - we only need recent history
-		if err := cloneRepository(repoName, actionDir, "--depth=1"); err != nil {
-			// Fallback to deeper clone if very shallow clone fails
+// getActionMetadata returns the trust signals of repoName. Results are kept in
+// actionMetadataCache for the rest of the run, or for --action-metadata-ttl,
+// unless --action-metadata-cache=false.
+// A missing repository is reported as an error wrapping errActionNotFound.
+func getActionMetadata(repoName string) (ActionMetadata, error) {
+	if useMetadataCache {
+		if meta, ok := actionMetadataCache.get(repoName, actionMetadataTTL); ok {
 			if debug {
-				fmt.Printf("Very shallow clone failed, trying deeper clone for %s\n", repoName)
-			}
-			if err = cloneRepository(repoName, actionDir, "--depth=10"); err != nil {
-				// Final fallback to full clone
-				if debug {
-					fmt.Printf("Shallow clone failed, trying full clone for %s\n", repoName)
-				}
-				if err = cloneRepository(repoName, actionDir, ""); err != nil {
-					return "", "", fmt.Errorf("failed to clone action repository: %v", err)
-				}
+				fmt.Printf("Metadata cache hit for %s\n", repoName)
 			}
+			return meta, nil
 		}
-	} else if debug {
-		fmt.Printf("Using cached action repository: %s\n", repoName)
-		// Update the cached repository to get latest refs/tags
-		if result := execCommandWithDir(actionDir, "git", "fetch", "origin", "--tags", "--quiet"); result.ExitCode != 0 && debug {
-			fmt.Printf("Warning: Failed to update cached repository %s: %s\n", repoName, result.Stderr)
+	}
+	result := githubAPI("GET", fmt.Sprintf("repos/%s", repoName), nil)
+	if result.ExitCode != 0 {
+		if isNotFoundResponse(result.Stderr) {
+			return ActionMetadata{}, fmt.Errorf("%w: %s", errActionNotFound, repoName)
 		}
+		return ActionMetadata{}, fmt.Errorf("failed to fetch repository metadata: %s", result.Stderr)
+	}
+	meta, err := parseActionMetadata(result.Stdout, time.Now())
+	if err != nil {
+		return ActionMetadata{}, err
 	}
+	if useMetadataCache {
+		actionMetadataCache.put(repoName, meta)
+	}
+	return meta, nil
+}
 
-	// First try to resolve the version directly
-	if result := execCommandWithDir(actionDir, "git", "rev-list", "-n", "1", version); result.ExitCode == 0 {
-		return strings.TrimSpace(result.Stdout), version, nil
+// parseActionMetadata extracts ActionMetadata from a repos/<repo> response.
+func parseActionMetadata(body string, now time.Time) (ActionMetadata, error) {
+	var repo struct {
+		StargazersCount int       `json:"stargazers_count"`
+		ForksCount      int       `json:"forks_count"`
+		Archived        bool      `json:"archived"`
+		Disabled        bool      `json:"disabled"`
+		Fork            bool      `json:"fork"`
+		DefaultBranch   string    `json:"default_branch"`
+		PushedAt        time.Time `json:"pushed_at"`
+		License         *struct {
+			SPDXID string `json:"spdx_id"`
+		} `json:"license"`
+	}
+	if err := json.Unmarshal([]byte(body), &repo); err != nil {
+		return ActionMetadata{}, fmt.Errorf("failed to parse repository response: %v", err)
+	}
+	license := ""
+	if repo.License != nil {
+		license = repo.License.SPDXID
+	}
+	return ActionMetadata{
+		Stars:             repo.StargazersCount,
+		Forks:             repo.ForksCount,
+		IsArchived:        repo.Archived,
+		IsDisabled:        repo.Disabled,
+		IsFork:            repo.Fork,
+		DefaultBranch:     repo.DefaultBranch,
+		PushedAt:          repo.PushedAt,
+		HasRecentActivity: !repo.PushedAt.IsZero() && now.Sub(repo.PushedAt) <= recentActivityWindow,
+		License:           license,
+	}, nil
+}
+
+// checkActionTrust returns a skipped reason when the action's repository has
+// fewer than minStars stars. Official actions/* actions are always trusted.
+func checkActionTrust(action string, minStars int) (string, error) {
+	repoName := action
+	if parts := strings.Split(action, "/"); len(parts) >= 2 {
+		repoName = fmt.Sprintf("%s/%s", parts[0], parts[1])
+	}
+	if strings.HasPrefix(repoName, "actions/") {
+		return "", nil
+	}
+	meta, err := getActionMetadata(repoName)
+	if err != nil {
+		return "", err
 	}
+	if meta.Stars < minStars {
+		return fmt.Sprintf("low-trust action (<%d stars)", minStars), nil
+	}
+	return "", nil
+}
 
-	// If direct resolution fails, try to fetch the specific ref if it looks like a tag/branch
-	if matched, _ := regexp.MatchString(`^[a-f0-9]{40}$`, version); !matched {
-		if result := execCommandWithDir(actionDir, "git", "fetch", "origin", "+refs/tags/"+version+":refs/tags/"+version, "--quiet"); result.ExitCode == 0 {
-			if result := execCommandWithDir(actionDir, "git", "rev-list", "-n", "1", version); result.ExitCode == 0 {
-				return strings.TrimSpace(result.Stdout), version, nil
-			}
-		}
-		// Also try fetching as a branch
-		if result := execCommandWithDir(actionDir, "git", "fetch", "origin", "+refs/heads/"+version+":refs/remotes/origin/"+version, "--quiet"); result.ExitCode == 0 {
-			if result := execCommandWithDir(actionDir, "git", "rev-list", "-n", "1", "origin/"+version); result.ExitCode == 0 {
-				return strings.TrimSpace(result.Stdout), version, nil
-			}
+// shouldSkipTrustChecks reports whether the owner of action is one of
+// trustedOrgs (case-insensitive), exempting it from star, activity and
+// license checks.
+func shouldSkipTrustChecks(action string, trustedOrgs []string) bool {
+	owner, _, _ := strings.Cut(action, "/")
+	for _, org := range trustedOrgs {
+		if strings.EqualFold(owner, strings.TrimSpace(org)) {
+			return true
 		}
 	}
+	return false
+}
 
-	if matched, _ := regexp.MatchString(`^v?\d+\.\d+\.\d+$`, version); matched {
-		return "", "", errUnresolvedVersion
+// parseLatestCommitDate extracts the committer date of the first entry of a
+// repos/<repo>/commits response.
+func parseLatestCommitDate(body string) (time.Time, error) {
+	var commits []struct {
+		Commit struct {
+			Committer struct {
+				Date time.Time `json:"date"`
+			} `json:"committer"`
+		} `json:"commit"`
 	}
-
-	// Fallback to pattern matching for partial versions
-	if result := execCommandWithDir(actionDir, "git", "tag", "-l", version+"*"); result.ExitCode == 0 && strings.TrimSpace(result.Stdout) != "" {
-		tags := strings.Split(strings.TrimSpace(result.Stdout), "\n")
-		if len(tags) > 0 {
-			return getCommitHashFromVersion(action, tags[len(tags)-1])
-		}
+	if err := json.Unmarshal([]byte(body), &commits); err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse commits response: %v", err)
+	}
+	if len(commits) == 0 {
+		return time.Time{}, fmt.Errorf("repository has no commits")
 	}
+	return commits[0].Commit.Committer.Date, nil
+}
 
-	return "", "", fmt.Errorf("version not found: %s", version)
+// isNotFoundResponse reports whether a failed gh api / REST call was a 404.
+func isNotFoundResponse(stderr string) bool {
+	return strings.Contains(stderr, "HTTP 404") || strings.Contains(stderr, `"Not Found"`) || strings.Contains(stderr, "Not Found (HTTP 404)")
 }
 
 // Try GitHub API approach for faster resolution (no cloning needed)
@@ -1486,7 +6577,7 @@ func getCommitHashViaAPI(action, version string) (string, string, error) {
 	}
 
 	// Try to get commit hash from GitHub API for tags/branches
-	result := githubAPI("GET", fmt.Sprintf("repos/%s/git/refs/tags/%s", repoName, version), nil)
+	result := cachedGitHubAPI(fmt.Sprintf("repos/%s/git/refs/tags/%s", repoName, version))
 	if result.ExitCode == 0 {
 		var tagRef map[string]interface{}
 		if err := json.Unmarshal([]byte(result.Stdout), &tagRef); err == nil {
@@ -1498,7 +6589,8 @@ func getCommitHashViaAPI(action, version string) (string, string, error) {
 		}
 	}
 
-	// Try as a branch
+	// Try as a branch. Branches move with every push, so their refs always
+	// come from GitHub rather than the API cache.
 	result = githubAPI("GET", fmt.Sprintf("repos/%s/git/refs/heads/%s", repoName, version), nil)
 	if result.ExitCode == 0 {
 		var branchRef map[string]interface{}
@@ -1516,7 +6608,7 @@ func getCommitHashViaAPI(action, version string) (string, string, error) {
 
 func getRepositoryMetadata(repoName string) (Repository, error) {
 	if authMode == "gh" {
-		result := execCommand("gh", "repo", "view", repoName, "--json", "name,url,defaultBranchRef")
+		result := execCommand("gh", "repo", "view", repoName, "--json", "name,url,defaultBranchRef,visibility")
 		if result.ExitCode != 0 {
 			return Repository{}, fmt.Errorf("%s", result.Stderr)
 		}
@@ -1535,6 +6627,7 @@ func getRepositoryMetadata(repoName string) (Repository, error) {
 		Name          string `json:"name"`
 		HTMLURL       string `json:"html_url"`
 		DefaultBranch string `json:"default_branch"`
+		Visibility    string `json:"visibility"`
 	}
 	if err := json.Unmarshal([]byte(result.Stdout), &repoAPI); err != nil {
 		return Repository{}, fmt.Errorf("failed to parse repository metadata: %v", err)
@@ -1545,12 +6638,13 @@ func getRepositoryMetadata(repoName string) (Repository, error) {
 		DefaultBranchRef: DefaultBranchRef{
 			Name: repoAPI.DefaultBranch,
 		},
+		Visibility: repoAPI.Visibility,
 	}, nil
 }
 
 func listOrganizationRepositories(orgName string, limit int) ([]Repository, error) {
 	if authMode == "gh" {
-		result := execCommand("gh", "repo", "list", orgName, "--json", "name,url,defaultBranchRef", "--limit", fmt.Sprintf("%d", limit))
+		result := execCommand("gh", "repo", "list", orgName, "--json", "name,url,defaultBranchRef,visibility,repositoryTopics", "--limit", fmt.Sprintf("%d", limit))
 		if result.ExitCode != 0 {
 			return nil, fmt.Errorf("%s", result.Stderr)
 		}
@@ -1580,11 +6674,22 @@ func listOrganizationRepositories(orgName string, limit int) ([]Repository, erro
 		for _, item := range items {
 			name, _ := item["name"].(string)
 			defaultBranch, _ := item["default_branch"].(string)
+			visibility, _ := item["visibility"].(string)
+			var topics []RepositoryTopic
+			if names, ok := item["topics"].([]interface{}); ok {
+				for _, n := range names {
+					if topic, ok := n.(string); ok {
+						topics = append(topics, RepositoryTopic{Name: topic})
+					}
+				}
+			}
 			repos = append(repos, Repository{
 				Name: name,
 				DefaultBranchRef: DefaultBranchRef{
 					Name: defaultBranch,
 				},
+				Visibility:       visibility,
+				RepositoryTopics: topics,
 			})
 			if len(repos) >= limit {
 				break
@@ -1595,6 +6700,28 @@ func listOrganizationRepositories(orgName string, limit int) ([]Repository, erro
 	return repos, nil
 }
 
+// actionCloneDepths returns the depth arguments to try, in order, when cloning
+// an action repository. By default a very shallow clone is tried first, then a
+// deeper one, then the full history; --clone-depth replaces the escalation
+// with a single attempt, where 0 means a full clone.
+func actionCloneDepths() []string {
+	switch {
+	case cloneDepth < 0:
+		return []string{"--depth=1", "--depth=10", ""}
+	case cloneDepth == 0:
+		return []string{""}
+	default:
+		return []string{fmt.Sprintf("--depth=%d", cloneDepth)}
+	}
+}
+
+func describeDepthArg(depthArg string) string {
+	if depthArg == "" {
+		return "a full clone"
+	}
+	return depthArg
+}
+
 func cloneRepository(repoName, dir, depthArg string) error {
 	if authMode == "gh" {
 		args := []string{"repo", "clone", repoName, dir}
@@ -1623,12 +6750,18 @@ func cloneRepository(repoName, dir, depthArg string) error {
 	return nil
 }
 
+// listOpenPRs lists open PRs in repo matching search and author. With
+// --pr-search-label only PRs carrying that label are returned, so PRs from
+// other tools with similar titles are not mistaken for existing pinning PRs.
 func listOpenPRs(repo, search, author string) ExecResult {
 	if authMode == "gh" {
-		args := []string{"pr", "list", "--repo", repo, "--state", "open", "--json", "title,url,headRefName"}
+		args := []string{"pr", "list", "--repo", repo, "--state", "open", "--json", "title,url,headRefName,createdAt"}
 		if search != "" {
 			args = append(args, "--search", search)
 		}
+		if prSearchLabel != "" {
+			args = append(args, "--label", prSearchLabel)
+		}
 		if author != "" {
 			args = append(args, "--author", author)
 		}
@@ -1656,6 +6789,9 @@ func listOpenPRs(repo, search, author string) ExecResult {
 		if searchNeedle != "" && !strings.Contains(strings.ToLower(title), searchNeedle) {
 			continue
 		}
+		if prSearchLabel != "" && !pullRequestHasLabel(pr, prSearchLabel) {
+			continue
+		}
 		if author != "" {
 			user, _ := pr["user"].(map[string]interface{})
 			login, _ := user["login"].(string)
@@ -1676,6 +6812,7 @@ func listOpenPRs(repo, search, author string) ExecResult {
 			"title":       title,
 			"url":         pr["html_url"],
 			"headRefName": headRef,
+			"createdAt":   pr["created_at"],
 		})
 	}
 
@@ -1686,6 +6823,18 @@ func listOpenPRs(repo, search, author string) ExecResult {
 	return ExecResult{ExitCode: 0, Stdout: string(data)}
 }
 
+// pullRequestHasLabel reports whether a REST pull request object carries label.
+func pullRequestHasLabel(pr map[string]interface{}, label string) bool {
+	labels, _ := pr["labels"].([]interface{})
+	for _, l := range labels {
+		obj, _ := l.(map[string]interface{})
+		if name, _ := obj["name"].(string); strings.EqualFold(name, label) {
+			return true
+		}
+	}
+	return false
+}
+
 func createPullRequest(repo, title, body, base, head, repoDir string) ExecResult {
 	if authMode == "gh" {
 		args := []string{"pr", "create", "--title", title, "--body", body, "--base", base, "--head", head}
@@ -1750,6 +6899,30 @@ func createFork(repoName string) error {
 	return nil
 }
 
+// checkForkVisibility implements --fork-visibility. Neither gh nor the REST
+// API can choose a fork's visibility: a fork of a public repository is public
+// and any other fork is private. So instead of forking with the wrong
+// visibility, repoName is refused when its fork would not match the flag.
+func checkForkVisibility(repoName string) error {
+	upstream, err := getRepositoryMetadata(repoName)
+	if err != nil {
+		return fmt.Errorf("failed to read visibility of %s for --fork-visibility: %v", repoName, err)
+	}
+	if got := forkVisibilityOf(upstream.Visibility); got != forkVisibility {
+		return fmt.Errorf("a fork of %s would be %s, not %s: GitHub forks take the upstream repository's visibility (--fork-visibility %s)", repoName, got, forkVisibility, forkVisibility)
+	}
+	return nil
+}
+
+// forkVisibilityOf returns the visibility GitHub gives a fork of a repository
+// with the given visibility.
+func forkVisibilityOf(upstreamVisibility string) string {
+	if strings.EqualFold(upstreamVisibility, "public") {
+		return "public"
+	}
+	return "private"
+}
+
 func getCurrentUserLogin() (string, error) {
 	result := githubAPI("GET", "user", nil)
 	if result.ExitCode != 0 {
@@ -1778,30 +6951,52 @@ func githubAPI(method, endpoint string, payload map[string]interface{}) ExecResu
 		return execCommand("gh", args...)
 	}
 
-	body := io.Reader(nil)
+	var raw []byte
 	if payload != nil {
-		raw, err := json.Marshal(payload)
+		encoded, err := json.Marshal(payload)
 		if err != nil {
 			return ExecResult{ExitCode: 1, Stderr: fmt.Sprintf("failed to encode API payload: %v", err)}
 		}
-		body = bytes.NewReader(raw)
+		raw = encoded
 	}
 
 	endpoint = strings.TrimPrefix(endpoint, "/")
-	req, err := http.NewRequest(method, "https://api.github.com/"+endpoint, body)
+	return withNetworkRetry(method, func() ExecResult {
+		return githubRESTRequest(method, endpoint, raw, payload != nil)
+	})
+}
+
+// githubRESTRequest performs a single REST call, bounded by --network-timeout
+// when set. A timeout yields ExitCode -1 so withNetworkRetry can retry it.
+func githubRESTRequest(method, endpoint string, raw []byte, hasPayload bool) ExecResult {
+	ctx := context.Background()
+	timeout := 30 * time.Second
+	if networkTimeout > 0 {
+		timeout = networkTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var body io.Reader
+	if hasPayload {
+		body = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, githubAPIBase+"/"+endpoint, body)
 	if err != nil {
 		return ExecResult{ExitCode: 1, Stderr: err.Error()}
 	}
 	req.Header.Set("Accept", "application/vnd.github+json")
-	req.Header.Set("Authorization", "Bearer "+githubToken)
+	req.Header.Set("Authorization", "Bearer "+currentGitHubToken())
 	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
-	if payload != nil {
+	if hasPayload {
 		req.Header.Set("Content-Type", "application/json")
 	}
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return timeoutResult(timeout)
+		}
 		return ExecResult{ExitCode: 1, Stderr: err.Error()}
 	}
 	defer resp.Body.Close()
@@ -1821,7 +7016,8 @@ func getAuthenticatedCloneURL(repoName string) (string, error) {
 	if authMode == "gh" {
 		return "", fmt.Errorf("authenticated clone URL only applies in pat mode")
 	}
-	if githubToken == "" {
+	token := currentGitHubToken()
+	if token == "" {
 		return "", fmt.Errorf("missing GitHub token")
 	}
 	u := &url.URL{
@@ -1829,7 +7025,7 @@ func getAuthenticatedCloneURL(repoName string) (string, error) {
 		Host:   "github.com",
 		Path:   "/" + strings.TrimPrefix(repoName, "/") + ".git",
 	}
-	u.User = url.UserPassword("x-access-token", githubToken)
+	u.User = url.UserPassword("x-access-token", token)
 	return u.String(), nil
 }
 
@@ -1838,10 +7034,107 @@ func execCommand(name string, args ...string) ExecResult {
 }
 
 func execCommandWithDir(dir, name string, args ...string) ExecResult {
-	res, err := execute.ExecTask{Command: name, Args: args, Cwd: dir}.Execute(context.Background())
+	if networkTimeout > 0 && isNetworkCommand(name, args) {
+		method := "POST"
+		if isReadOnlyGhCommand(args) {
+			method = "GET"
+		}
+		return withNetworkRetry(method, func() ExecResult {
+			ctx, cancel := context.WithTimeout(context.Background(), networkTimeout)
+			defer cancel()
+			return execCommandContext(ctx, dir, name, args...)
+		})
+	}
+	return execCommandContext(context.Background(), dir, name, args...)
+}
+
+// isNetworkCommand reports whether a command is a short GitHub API round-trip
+// that --network-timeout applies to. Clones are excluded since their duration
+// scales with repository size.
+func isNetworkCommand(name string, args []string) bool {
+	if name != "gh" || len(args) == 0 {
+		return false
+	}
+	if len(args) > 1 && args[0] == "repo" && (args[1] == "clone" || args[1] == "fork") {
+		return false
+	}
+	return args[0] != "auth"
+}
+
+// isReadOnlyGhCommand reports whether a gh invocation only reads data, so that
+// repeating it cannot create or change anything twice. gh api sends a POST as
+// soon as fields are given, so those count as writes unless -X GET is set.
+func isReadOnlyGhCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	if args[0] == "api" {
+		method := ""
+		hasFields := false
+		for i, arg := range args {
+			switch {
+			case (arg == "-X" || arg == "--method") && i+1 < len(args):
+				method = args[i+1]
+			case strings.HasPrefix(arg, "--method="):
+				method = strings.TrimPrefix(arg, "--method=")
+			case arg == "-f" || arg == "-F" || arg == "--input" || strings.HasPrefix(arg, "--field") || strings.HasPrefix(arg, "--raw-field") || strings.HasPrefix(arg, "--input="):
+				hasFields = true
+			}
+		}
+		if method == "" {
+			return !hasFields
+		}
+		return strings.EqualFold(method, "GET")
+	}
+	if len(args) < 2 {
+		return false
+	}
+	switch args[1] {
+	case "list", "view", "status", "diff", "checks":
+		return true
+	}
+	return false
+}
+
+// withNetworkRetry runs call, retrying up to --max-retries times while
+// retryableResult allows it for method.
+func withNetworkRetry(method string, call func() ExecResult) ExecResult {
+	result := call()
+	for attempt := 1; attempt <= maxRetries && retryableResult(method, result); attempt++ {
+		if debug {
+			fmt.Printf("Retrying %s (attempt %d/%d): %s\n", method, attempt, maxRetries, result.Stderr)
+		}
+		time.Sleep(time.Duration(attempt) * time.Second)
+		result = call()
+	}
+	return result
+}
+
+// retryableResult reports whether a failed call may be repeated. Timed-out
+// GET requests are retried. A timed-out write may already have been applied
+// by GitHub, so writes are only retried when the connection was refused and
+// the request never reached it.
+func retryableResult(method string, result ExecResult) bool {
+	if result.ExitCode == 0 {
+		return false
+	}
+	if strings.Contains(result.Stderr, "connection refused") {
+		return true
+	}
+	return result.ExitCode == -1 && method == "GET"
+}
+
+func timeoutResult(timeout time.Duration) ExecResult {
+	return ExecResult{ExitCode: -1, Stderr: fmt.Sprintf("network timeout after %s", timeout)}
+}
+
+func execCommandContext(ctx context.Context, dir, name string, args ...string) ExecResult {
+	res, err := execute.ExecTask{Command: name, Args: args, Cwd: dir, Env: commandEnv(name)}.Execute(ctx)
 	result := ExecResult{Stdout: res.Stdout, Stderr: res.Stderr, ExitCode: res.ExitCode}
 
-	if err != nil && result.ExitCode == 0 {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		result = timeoutResult(networkTimeout)
+	} else if err != nil && result.ExitCode == 0 {
 		result.ExitCode = 1
 		if result.Stderr == "" {
 			result.Stderr = err.Error()
@@ -1872,11 +7165,55 @@ func execCommandWithDir(dir, name string, args ...string) ExecResult {
 	return result
 }
 
+// commandEnv returns extra environment variables for a command. git, and gh
+// (which shells out to git for clones), read --gitconfig-file as their global config.
+// With a GitHub App, both also get the installation token as GH_TOKEN, which gh
+// and its git credential helper prefer over the stored login; likewise the
+// token read from --github-token-env. git alone also
+// gets --workflow-env-vars; gh API calls keep the process environment.
+func commandEnv(name string) []string {
+	if name != "git" && name != "gh" {
+		return nil
+	}
+	var env []string
+	if gitConfigFile != "" {
+		env = append(env, "GIT_CONFIG_GLOBAL="+gitConfigFile)
+	}
+	if githubApp != nil || githubTokenEnv != "" {
+		env = append(env, "GH_TOKEN="+currentGitHubToken())
+	}
+	if name == "git" && len(gitEnvVars) > 0 {
+		return buildGitEnv(append(env, gitEnvVars...))
+	}
+	return env
+}
+
+// buildGitEnv merges extraVars (KEY=VALUE) into the process environment. Each
+// key appears once, keeping its first position; later values win, so
+// extraVars override inherited variables and each other.
+func buildGitEnv(extraVars []string) []string {
+	index := map[string]int{}
+	var env []string
+	for _, v := range append(os.Environ(), extraVars...) {
+		key, _, _ := strings.Cut(v, "=")
+		if i, ok := index[key]; ok {
+			env[i] = v
+			continue
+		}
+		index[key] = len(env)
+		env = append(env, v)
+	}
+	return env
+}
+
 type actionPin struct {
 	action          string
 	version         string
 	hash            string
 	resolvedVersion string
+	skippedReason   string
+	licenseWarning  string
+	sigstoreNote    string
 	err             error
 }
 
@@ -1910,12 +7247,61 @@ func printContextualTips(hardenRunnerActive, pinRunnersActive bool) {
 func pinActionsWorker(actions <-chan actionPin, results chan<- actionPin, wg *sync.WaitGroup) {
 	defer wg.Done()
 	for action := range actions {
+		trusted := shouldSkipTrustChecks(action.action, trustedOrgs)
+		if trusted && debug {
+			fmt.Printf("Skipping trust checks for %s (--trusted-orgs)\n", action.action)
+		}
+		if maxCommitAge > 0 && !trusted {
+			active, err := checkActionActivity(action.action, maxCommitAge)
+			if err != nil && debug {
+				fmt.Printf("Warning: failed to check activity of %s: %v\n", action.action, err)
+			}
+			if err == nil && !active {
+				action.err = fmt.Errorf("%w: %s", errActionInactive, action.action)
+				results <- action
+				continue
+			}
+		}
+		if requireMinStars > 0 && !trusted {
+			reason, err := checkActionTrust(action.action, requireMinStars)
+			if err != nil && debug {
+				fmt.Printf("Warning: failed to check trust of %s: %v\n", action.action, err)
+			}
+			if reason != "" {
+				action.skippedReason = reason
+				action.err = fmt.Errorf("%w: %s", errLowTrustAction, action.action)
+				results <- action
+				continue
+			}
+		}
 		if hash, resolvedVersion, err := getCommitHashFromVersion(action.action, action.version); err == nil {
 			action.hash = hash
 			action.resolvedVersion = resolvedVersion
 		} else {
 			action.err = err
 		}
+		if checkLicense && !trusted {
+			// Informational only: a failed or negative check never affects pinning.
+			warning, err := checkActionLicense(action.action, allowedLicenses)
+			if err != nil && debug {
+				fmt.Printf("Warning: failed to check license of %s: %v\n", action.action, err)
+			}
+			action.licenseWarning = warning
+		}
+		if checkSigstoreLog && action.err == nil {
+			// Informational only: the action is pinned whatever the log says.
+			found, err := checkSigstore(action.hash)
+			switch {
+			case err != nil:
+				if debug {
+					fmt.Printf("Warning: failed to check sigstore for %s@%s: %v\n", action.action, action.hash, err)
+				}
+			case found:
+				action.sigstoreNote = sigstoreVerifiedNote
+			default:
+				action.sigstoreNote = sigstoreNotFoundNote
+			}
+		}
 		results <- action
 	}
 }
@@ -2005,12 +7391,22 @@ func injectHardenRunnerStep(content, sha, version, egressPolicy string) (string,
 
 // getLatestHardenRunnerTag fetches the latest release tag of step-security/harden-runner.
 func getLatestHardenRunnerTag() (string, error) {
-	result := githubAPI("GET", "repos/step-security/harden-runner/releases/latest", nil)
+	return getLatestReleaseTag("step-security/harden-runner")
+}
+
+// getLatestReleaseTag fetches the tag_name of repoName's latest release.
+func getLatestReleaseTag(repoName string) (string, error) {
+	result := githubAPI("GET", fmt.Sprintf("repos/%s/releases/latest", repoName), nil)
 	if result.ExitCode != 0 {
-		return "", fmt.Errorf("failed to fetch harden-runner release: %s", result.Stderr)
+		return "", fmt.Errorf("failed to fetch latest release of %s: %s", repoName, result.Stderr)
 	}
+	return parseReleaseTag(result.Stdout)
+}
+
+// parseReleaseTag extracts tag_name from a releases API response.
+func parseReleaseTag(body string) (string, error) {
 	var release map[string]interface{}
-	if err := json.Unmarshal([]byte(result.Stdout), &release); err != nil {
+	if err := json.Unmarshal([]byte(body), &release); err != nil {
 		return "", fmt.Errorf("failed to parse release response: %v", err)
 	}
 	tag, ok := release["tag_name"].(string)
@@ -2087,10 +7483,26 @@ func pinRunnersPass(content string, customMap map[string]string) (string, int) {
 	return updated, replaced
 }
 
-func getPRBodyForRepository(repoDir string) string {
+func getPRBodyForRepository(repoDir, repoName string, summary runSummary) string {
+	// A --pr-template-file or --pr-body-file takes precedence over both repository templates and the dynamic body
+	if prTemplate != nil {
+		body, err := renderPRTemplate(prTemplate, repoName, summary.changes, summary.files)
+		if err == nil {
+			return body
+		}
+		fmt.Printf("⚠️  Warning: failed to render --pr-template-file for %s, using default body: %v\n", repoName, err)
+	}
+	if prBodyTemplate != nil {
+		body, err := renderPRBodyTemplate(prBodyTemplate, repoName, summary)
+		if err == nil {
+			return body
+		}
+		fmt.Printf("⚠️  Warning: failed to render --pr-body-file for %s, using default body: %v\n", repoName, err)
+	}
+
 	// If user wants to ignore PR templates, use dynamic body directly
 	if ignorePRTemplates {
-		return buildDynamicPRBody()
+		return buildDynamicPRBody(summary)
 	}
 
 	// Check for PR templates in the repository
@@ -2103,6 +7515,10 @@ func getPRBodyForRepository(repoDir string) string {
 		"PULL_REQUEST_TEMPLATE.md",
 	}
 
+	if dirTemplate := selectPRTemplateFromDir(repoDir); dirTemplate != "" {
+		templatePaths = append(templatePaths, dirTemplate)
+	}
+
 	for _, templatePath := range templatePaths {
 		fullPath := filepath.Join(repoDir, templatePath)
 		if content, err := os.ReadFile(fullPath); err == nil {
@@ -2115,28 +7531,184 @@ func getPRBodyForRepository(repoDir string) string {
 	}
 
 	// No template found, use dynamic body
-	return buildDynamicPRBody()
+	return buildDynamicPRBody(summary)
+}
+
+// selectPRTemplateFromDir picks a template from GitHub's multiple-template
+// directory, .github/PULL_REQUEST_TEMPLATE/: security.md when present, since
+// pinning is a security change, otherwise the alphabetically first template.
+// It returns the path relative to repoDir, or "" when there is none.
+func selectPRTemplateFromDir(repoDir string) string {
+	matches, err := filepath.Glob(filepath.Join(repoDir, ".github", "PULL_REQUEST_TEMPLATE", "*.md"))
+	if err != nil || len(matches) == 0 {
+		return ""
+	}
+	sort.Strings(matches)
+	selected := matches[0]
+	for _, match := range matches {
+		if strings.EqualFold(filepath.Base(match), "security.md") {
+			selected = match
+			break
+		}
+	}
+	rel, err := filepath.Rel(repoDir, selected)
+	if err != nil {
+		return ""
+	}
+	return rel
+}
+
+// prBodyData is the data available to --pr-body-file templates.
+type prBodyData struct {
+	Repo        string
+	PinnedCount int
+	ActionsList string
+}
+
+// loadPRBodyTemplate reads and parses a --pr-body-file. A missing or invalid
+// file is a hard error so a typo never silently falls back to the default body.
+func loadPRBodyTemplate(path string) (*template.Template, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read --pr-body-file %s: %w", path, err)
+	}
+	tmpl, err := template.New(filepath.Base(path)).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse --pr-body-file %s: %w", path, err)
+	}
+	return tmpl, nil
+}
+
+func renderPRBodyTemplate(tmpl *template.Template, repoName string, summary runSummary) (string, error) {
+	data := prBodyData{
+		Repo:        repoName,
+		PinnedCount: summary.actionsPinned,
+		ActionsList: formatActionsList(summary.changes),
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// PRTemplateAction is one pinned uses: reference in --pr-template-file data.
+type PRTemplateAction struct {
+	Action  string
+	OldRef  string
+	NewHash string
+	Date    string
+}
+
+// prTemplateData is the data available to --pr-template-file templates.
+// Unlike prBodyData it exposes pinned actions as structs so templates can
+// lay them out freely, e.g. as a table.
+type prTemplateData struct {
+	Repo          string
+	PinnedActions []PRTemplateAction
+	TotalPinned   int
+	WorkflowFiles []string
+}
+
+// loadPRTemplateFile reads and parses a --pr-template-file. As with
+// --pr-body-file, a missing or invalid file is a hard error.
+func loadPRTemplateFile(path string) (*template.Template, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read --pr-template-file %s: %w", path, err)
+	}
+	tmpl, err := template.New(filepath.Base(path)).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse --pr-template-file %s: %w", path, err)
+	}
+	return tmpl, nil
+}
+
+// renderPRTemplate renders a --pr-template-file for repoName with the actions
+// pinned in it and the files they were pinned in.
+func renderPRTemplate(tmpl *template.Template, repoName string, changes []actionChange, files []string) (string, error) {
+	data := prTemplateData{
+		Repo:          repoName,
+		PinnedActions: make([]PRTemplateAction, 0, len(changes)),
+		TotalPinned:   len(changes),
+		WorkflowFiles: files,
+	}
+	for _, c := range changes {
+		data.PinnedActions = append(data.PinnedActions, PRTemplateAction{Action: c.action, OldRef: c.before, NewHash: c.hash, Date: c.date})
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// formatActionsList renders pinned actions as a Markdown bullet list.
+func formatActionsList(changes []actionChange) string {
+	var sb strings.Builder
+	for _, c := range changes {
+		sb.WriteString(fmt.Sprintf("- `%s@%s` → %s\n", c.action, c.before, c.after))
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// computeScorecardScore estimates the OpenSSF Scorecard Pinned-Dependencies
+// score for GitHub Actions: the share of uses: references pinned to a hash,
+// on Scorecard's 0-10 scale. A repository without actions scores 10.
+func computeScorecardScore(pinned, total int) int {
+	if total <= 0 {
+		return 10
+	}
+	if pinned > total {
+		pinned = total
+	}
+	return pinned * 10 / total
+}
+
+// scorecardBadge renders a score as "🟢 Scorecard: 10/10".
+func scorecardBadge(score int) string {
+	icon := "🔴"
+	switch {
+	case score >= 10:
+		icon = "🟢"
+	case score >= 5:
+		icon = "🟡"
+	}
+	return fmt.Sprintf("%s Scorecard: %d/10", icon, score)
+}
+
+// scorecardDeltaSection returns the --annotate-pr-with-scorecard-delta PR body
+// section comparing the score before and after pinning, or "" when the score
+// does not improve.
+func scorecardDeltaSection(alreadyPinned, newlyPinned, total int) string {
+	before := computeScorecardScore(alreadyPinned, total)
+	after := computeScorecardScore(alreadyPinned+newlyPinned, total)
+	if after <= before {
+		return ""
+	}
+	return fmt.Sprintf("\n\n## OpenSSF Scorecard\n\nEstimated `Pinned-Dependencies` score for GitHub Actions (%d of %d `uses:` references pinned):\n\nBefore: `%s` → After: `%s`\n",
+		alreadyPinned+newlyPinned, total, scorecardBadge(before), scorecardBadge(after))
 }
 
-func buildDynamicPRBody() string {
+func buildDynamicPRBody(summary runSummary) string {
 	var sb strings.Builder
 
 	sb.WriteString("## Summary\n\n")
 	sb.WriteString("This PR applies the following supply-chain hardening to your GitHub Actions workflows:\n\n")
 
-	sb.WriteString(fmt.Sprintf("- **Action pinning**: %d `uses:` reference(s) pinned to immutable commit SHAs", lastRunSummary.actionsPinned))
-	if lastRunSummary.alreadyPinned > 0 {
-		sb.WriteString(fmt.Sprintf(" (%d already pinned)", lastRunSummary.alreadyPinned))
+	sb.WriteString(fmt.Sprintf("- **Action pinning**: %d `uses:` reference(s) pinned to immutable commit SHAs", summary.actionsPinned))
+	if summary.alreadyPinned > 0 {
+		sb.WriteString(fmt.Sprintf(" (%d already pinned)", summary.alreadyPinned))
 	}
 	sb.WriteString("\n")
 
 	if injectHardenRunner {
 		sb.WriteString(fmt.Sprintf("- **Harden Runner**: `step-security/harden-runner` injected into %d job(s) (egress-policy: `%s`)\n",
-			lastRunSummary.hardenInjected, egressPolicy))
+			summary.hardenInjected, egressPolicy))
 	}
 	if pinRunners {
 		sb.WriteString(fmt.Sprintf("- **Runner pinning**: %d runner label(s) replaced with versioned equivalents\n",
-			lastRunSummary.runnersReplaced))
+			summary.runnersReplaced))
 	}
 
 	sb.WriteString("\n## Benefits\n\n")
@@ -2153,13 +7725,17 @@ func buildDynamicPRBody() string {
 	sb.WriteString("\n## Review Notes\n\n")
 	sb.WriteString("- All pinned actions maintain their original functionality\n")
 	sb.WriteString("- No workflow behavior changes are expected\n")
-	if lastRunSummary.withLatest > 0 {
+	if summary.withLatest > 0 {
 		sb.WriteString(fmt.Sprintf("- Warning: %d action(s) using `@latest` detected — consider pinning these manually\n",
-			lastRunSummary.withLatest))
+			summary.withLatest))
 	}
-	if lastRunSummary.withoutTags > 0 {
+	if summary.withoutTags > 0 {
 		sb.WriteString(fmt.Sprintf("- Warning: %d action(s) found without any tag or ref — these default to the mutable default branch\n",
-			lastRunSummary.withoutTags))
+			summary.withoutTags))
+	}
+	if summary.notFound > 0 {
+		sb.WriteString(fmt.Sprintf("- Warning: %d action(s) reference a repository that could not be found — verify these actions still exist\n",
+			summary.notFound))
 	}
 
 	return sb.String()
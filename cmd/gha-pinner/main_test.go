@@ -1,11 +1,18 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 func TestParseActionReference(t *testing.T) {
@@ -66,6 +73,142 @@ func TestShouldSkipAction(t *testing.T) {
 	}
 }
 
+func TestIsPinnedReference(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"actions/checkout@0123456789abcdef0123456789abcdef01234567", true},
+		{"actions/checkout@abc12345", false}, // 8-char short SHA is ambiguous
+		{"actions/checkout@0123456789abcdef0123456789abcdef012345678", false},
+		{"actions/checkout@v4", false},
+	}
+
+	for _, test := range tests {
+		if got := isPinnedReference(test.input); got != test.expected {
+			t.Errorf("Expected %v for input %s, got %v", test.expected, test.input, got)
+		}
+	}
+}
+
+func TestHasVersionComment(t *testing.T) {
+	pin := "actions/checkout@0123456789abcdef0123456789abcdef01234567"
+	tests := []struct {
+		line     string
+		expected bool
+	}{
+		{"      - uses: " + pin + " # v4.1.1 on 2024-01-02", true},
+		{"      - uses: " + pin + " # v4", true},
+		{"      - uses: " + pin + " # tag=v4.1.1", true},
+		{"      - uses: " + pin + " # 2.0.0", true},
+		{"      - uses: " + pin, false},
+		{"      - uses: " + pin + " # pinned for security", false},
+		{"      - uses: " + pin + " # review2024", false},
+	}
+
+	for _, test := range tests {
+		if got := hasVersionComment(test.line); got != test.expected {
+			t.Errorf("hasVersionComment(%q) = %v, expected %v", test.line, got, test.expected)
+		}
+	}
+}
+
+func TestPinActionsPass_RequireHashComment(t *testing.T) {
+	oldMode, oldToken, oldWorkers := saveAuthGlobals()
+	oldBase, oldRequire, oldNoCache := githubAPIBase, requireHashComment, noCache
+	t.Cleanup(func() {
+		restoreAuthGlobals(oldMode, oldToken, oldWorkers)
+		githubAPIBase, requireHashComment, noCache = oldBase, oldRequire, oldNoCache
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/actions/checkout/tags":
+			fmt.Fprintf(w, `[{"name": "v4", "commit": {"sha": %q}}, {"name": "v4.1.1", "commit": {"sha": %q}}]`, testSHA, testSHA)
+		case "/repos/example/untagged/tags":
+			fmt.Fprint(w, `[]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	githubAPIBase = server.URL
+	authMode, githubToken = "pat", "test-token"
+	noCache = true
+
+	content := `jobs:
+  build:
+    steps:
+      - uses: actions/checkout@` + testSHA + `
+      - uses: actions/setup-go@` + testSHA + ` # v5.0.0
+      - uses: example/untagged@` + testSHA + `
+`
+	var workflow map[string]interface{}
+	if err := yaml.Unmarshal([]byte(content), &workflow); err != nil {
+		t.Fatal(err)
+	}
+
+	requireHashComment = false
+	if updated, res, err := pinActionsPass(content, workflow, false); err != nil || updated != content || res.actionsAlreadyPinned != 3 {
+		t.Fatalf("expected hash pins to be left alone without the flag, got %d already pinned, err %v:\n%s", res.actionsAlreadyPinned, err, updated)
+	}
+
+	requireHashComment = true
+	updated, res, err := pinActionsPass(content, workflow, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(updated, "actions/checkout@"+testSHA+" # v4.1.1 on ") {
+		t.Errorf("expected the most specific tag to be added:\n%s", updated)
+	}
+	if !strings.Contains(updated, "actions/setup-go@"+testSHA+" # v5.0.0\n") {
+		t.Errorf("expected an existing version comment to be kept:\n%s", updated)
+	}
+	if !strings.Contains(updated, "example/untagged@"+testSHA+"\n") {
+		t.Errorf("expected a hash without a tag to be left alone:\n%s", updated)
+	}
+	if res.actionsPinned != 1 || res.actionsAlreadyPinned != 2 {
+		t.Errorf("expected 1 pinned and 2 already pinned, got %d and %d", res.actionsPinned, res.actionsAlreadyPinned)
+	}
+}
+
+func TestIsShortSHA(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"abc12345", true},
+		{"abc1234", true},
+		{"0123456789abcdef0123456789abcdef01234567", false},
+		{"v4", false},
+		{"main", false},
+	}
+
+	for _, test := range tests {
+		if got := isShortSHA(test.input); got != test.expected {
+			t.Errorf("Expected %v for input %s, got %v", test.expected, test.input, got)
+		}
+	}
+}
+
+func TestIsNotFoundResponse(t *testing.T) {
+	tests := []struct {
+		stderr   string
+		expected bool
+	}{
+		{"gh: Not Found (HTTP 404)", true},
+		{`github api GET repos/gone/away failed: {"message":"Not Found","documentation_url":"..."}`, true},
+		{"gh: API rate limit exceeded (HTTP 403)", false},
+		{"dial tcp: i/o timeout", false},
+	}
+
+	for _, test := range tests {
+		if got := isNotFoundResponse(test.stderr); got != test.expected {
+			t.Errorf("Expected %v for stderr %q, got %v", test.expected, test.stderr, got)
+		}
+	}
+}
+
 func TestGetTempDir(t *testing.T) {
 	result := getTempDir("test")
 
@@ -80,7 +223,7 @@ func TestGetTempDir(t *testing.T) {
 }
 
 func TestGeneratePRBody(t *testing.T) {
-	body := buildDynamicPRBody()
+	body := buildDynamicPRBody(runSummary{})
 
 	expectedContains := []string{
 		"commit SHAs",
@@ -203,7 +346,7 @@ func TestCleanupFunction(t *testing.T) {
 	}
 
 	// Run cleanup
-	cleanup()
+	cleanup(false)
 
 	// Verify directories are removed
 	for _, dir := range testDirs {
@@ -241,6 +384,144 @@ jobs:
 	}
 }
 
+// TestWorkflowPatcher_PatchFile_Idempotent guards re-runs on an already
+// pinned repository: the files must come back byte-for-byte identical, so that
+// "No changes needed" is reported and no PR is opened.
+func TestWorkflowPatcher_PatchFile_Idempotent(t *testing.T) {
+	content := `name: CI
+on: [push, pull_request]
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@b4ffde65f46336ab88eb53be808477a3936bae11 # v4.1.1 on 2024-01-15
+      - name: Set up Go
+        uses: actions/setup-go@0c52d547c9bc32b1aa3301fd7a9cb496313a4491 # v5
+        with:
+          go-version: "1.21"
+  lint:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@b4ffde65f46336ab88eb53be808477a3936bae11
+      - uses: ./.github/actions/local
+      - uses: docker://alpine:3.19
+      - run: make lint
+`
+	for name, text := range map[string]string{
+		"lf":   content,
+		"crlf": strings.ReplaceAll(content, "\n", "\r\n"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "ci.yml")
+			if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			p := &WorkflowPatcher{egressPolicy: "audit"}
+			for run := 1; run <= 2; run++ {
+				res, err := p.patchFile(path)
+				if err != nil {
+					t.Fatalf("run %d: unexpected error: %v", run, err)
+				}
+				if res.actionsPinned != 0 || res.actionsAlreadyPinned != 3 {
+					t.Fatalf("run %d: expected 0 pinned and 3 already pinned, got %d and %d", run, res.actionsPinned, res.actionsAlreadyPinned)
+				}
+				got, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != text {
+					t.Fatalf("run %d: file changed:\n%s", run, got)
+				}
+			}
+		})
+	}
+}
+
+func TestWorkflowPatcher_PatchFile_MinWorkflowSize(t *testing.T) {
+	tempDir := t.TempDir()
+	// Six significant lines; blank and comment lines are not counted.
+	content := `# stub workflow
+
+name: Test
+on: [push]
+jobs:
+  build:
+    steps:
+      - uses: actions/checkout@b4ffde65f46336ab88eb53be808477a3936bae11 # v4
+`
+	path := filepath.Join(tempDir, "stub.yml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := countSignificantLines(content); got != 6 {
+		t.Fatalf("countSignificantLines = %d, want 6", got)
+	}
+
+	p := &WorkflowPatcher{minWorkflowSize: 7}
+	res, err := p.patchFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.filesTooSmall != 1 || res.totalActions != 0 {
+		t.Errorf("expected file to be skipped, got %+v", res)
+	}
+
+	p.minWorkflowSize = 6
+	res, err = p.patchFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.filesTooSmall != 0 || res.actionsAlreadyPinned != 1 {
+		t.Errorf("expected file to be processed, got %+v", res)
+	}
+}
+
+func TestWorkflowPatcher_PatchFile_NonStringUses(t *testing.T) {
+	tempDir := t.TempDir()
+	// Steps without uses:, a boolean uses: (YAML true) and a plain run step must
+	// be skipped without panicking in any pass.
+	content := `name: Test
+on: [push]
+permissions:
+  contents: write
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - name: No uses key
+        run: echo ${{ github.event.issue.title }}
+      - uses: true
+      - uses:
+      - uses: actions/checkout@b4ffde65f46336ab88eb53be808477a3936bae11 # v4
+`
+	path := filepath.Join(tempDir, "test.yml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &WorkflowPatcher{
+		requirePermissions: true,
+		detectInjection:    true,
+		reportPermissions:  true,
+	}
+	res, err := p.patchFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.totalActions != 1 || res.actionsAlreadyPinned != 1 {
+		t.Errorf("expected only the string uses: to be counted, got %+v", res)
+	}
+
+	pinned, unpinned, err := classifyUses([]byte(content))
+	if err != nil {
+		t.Fatalf("classifyUses: unexpected error: %v", err)
+	}
+	if len(pinned) != 1 || len(unpinned) != 0 {
+		t.Errorf("classifyUses = %v, %v", pinned, unpinned)
+	}
+}
+
 func TestTipsCount_TriggerConditions(t *testing.T) {
 	// We test the conditions that trigger tips rather than stdout content.
 	tests := []struct {
@@ -261,3 +542,137 @@ func TestTipsCount_TriggerConditions(t *testing.T) {
 		}
 	}
 }
+
+func TestSafeWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ci.yml")
+	if err := os.WriteFile(path, []byte("original"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := safeWriteFile(path, "original", "updated"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "updated" {
+		t.Fatalf("expected updated content, got %q", content)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("expected file mode to be preserved, got %v (err %v)", info.Mode().Perm(), err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected no temporary files to be left behind, got %d entries", len(entries))
+	}
+}
+
+func TestProcessRepositoryNames_SkipsAfterInterrupt(t *testing.T) {
+	old := runCtx
+	t.Cleanup(func() { runCtx = old })
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	runCtx = ctx
+
+	success, failed := processRepositoryNames([]string{"owner/one", "owner/two"})
+	if success != 0 || failed != 0 {
+		t.Fatalf("expected no repositories to be processed after interrupt, got success=%d failed=%d", success, failed)
+	}
+}
+
+func TestCleanup_NoCleanupFlags(t *testing.T) {
+	oldNoCleanup, oldOnSuccess := noCleanup, cleanupOnSuccessOnly
+	t.Cleanup(func() { noCleanup, cleanupOnSuccessOnly = oldNoCleanup, oldOnSuccess })
+
+	dir := getTempDir("actions")
+	tests := []struct {
+		name         string
+		noCleanup    bool
+		onSuccess    bool
+		runFailed    bool
+		wantRetained bool
+	}{
+		{"no-cleanup", true, false, false, true},
+		{"on-success-only after failure", false, true, true, true},
+		{"on-success-only after success", false, true, false, false},
+		{"default after failure", false, false, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { os.RemoveAll(dir) })
+			noCleanup, cleanupOnSuccessOnly = tt.noCleanup, tt.onSuccess
+
+			if err := cleanup(tt.runFailed); err != nil {
+				t.Fatal(err)
+			}
+			_, err := os.Stat(dir)
+			if retained := err == nil; retained != tt.wantRetained {
+				t.Errorf("retained = %v, want %v", retained, tt.wantRetained)
+			}
+		})
+	}
+}
+
+func TestWorkflowPatcher_PatchFile_PreserveMtime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ci.yml")
+	content := "on: [push]\njobs:\n  build:\n    runs-on: ubuntu-latest\n    steps:\n      - run: echo hi\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	original := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(path, original, original); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &WorkflowPatcher{egressPolicy: "audit", addPermissions: true, preserveMtime: true}
+	if _, err := p.patchFile(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	updated, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(updated) == content {
+		t.Fatal("expected the file to be patched")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(original) {
+		t.Fatalf("expected mtime %v to be preserved, got %v", original, info.ModTime())
+	}
+}
+
+func TestPinActionsPass_CommentLanguage(t *testing.T) {
+	oldCache, oldLang := hashCache, commentLanguage
+	t.Cleanup(func() { hashCache, commentLanguage = oldCache, oldLang })
+	hashCache = newActionHashCache()
+	hashCache.put("actions/checkout", "v3", lockEntry{hash: testSHA, resolvedVersion: "v3"})
+	commentLanguage = "ja"
+
+	content := `jobs:
+  build:
+    steps:
+      - uses: actions/checkout@v3
+`
+	var workflow map[string]interface{}
+	if err := yaml.Unmarshal([]byte(content), &workflow); err != nil {
+		t.Fatal(err)
+	}
+	updated, _, err := pinActionsPass(content, workflow, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	date := time.Now().Format("2006-01-02")
+	if want := "actions/checkout@" + testSHA + " # v3、" + date + "に固定"; !strings.Contains(updated, want) {
+		t.Errorf("expected a Japanese pin comment %q:\n%s", want, updated)
+	}
+}
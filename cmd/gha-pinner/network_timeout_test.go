@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsNetworkCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected bool
+	}{
+		{"gh", []string{"api", "repos/o/r"}, true},
+		{"gh", []string{"pr", "list", "--repo", "o/r"}, true},
+		{"gh", []string{"repo", "clone", "o/r", "dir"}, false},
+		{"gh", []string{"auth", "status"}, false},
+		{"git", []string{"fetch", "origin"}, false},
+	}
+	for _, tc := range tests {
+		if got := isNetworkCommand(tc.name, tc.args); got != tc.expected {
+			t.Errorf("isNetworkCommand(%s %v) = %v, expected %v", tc.name, tc.args, got, tc.expected)
+		}
+	}
+}
+
+func TestWithNetworkRetry_RetriesTimeouts(t *testing.T) {
+	old := maxRetries
+	t.Cleanup(func() { maxRetries = old })
+	maxRetries = 1
+
+	calls := 0
+	result := withNetworkRetry("GET", func() ExecResult {
+		calls++
+		if calls == 1 {
+			return timeoutResult(time.Second)
+		}
+		return ExecResult{ExitCode: 0, Stdout: "ok"}
+	})
+	if calls != 2 || result.Stdout != "ok" {
+		t.Fatalf("expected one retry and success, got calls=%d result=%+v", calls, result)
+	}
+}
+
+func TestWithNetworkRetry_DoesNotRetryOtherFailures(t *testing.T) {
+	old := maxRetries
+	t.Cleanup(func() { maxRetries = old })
+	maxRetries = 3
+
+	calls := 0
+	result := withNetworkRetry("GET", func() ExecResult {
+		calls++
+		return ExecResult{ExitCode: 1, Stderr: "HTTP 404"}
+	})
+	if calls != 1 || result.ExitCode != 1 {
+		t.Fatalf("expected a single call for non-timeout failure, got calls=%d result=%+v", calls, result)
+	}
+}
+
+func TestExecCommandContext_Timeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("relies on the sleep binary")
+	}
+	old := networkTimeout
+	t.Cleanup(func() { networkTimeout = old })
+	networkTimeout = 50 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), networkTimeout)
+	defer cancel()
+	result := execCommandContext(ctx, "", "sleep", "2")
+	if result.ExitCode != -1 {
+		t.Fatalf("expected timeout exit code -1, got %+v", result)
+	}
+	if !strings.Contains(result.Stderr, "timeout") {
+		t.Fatalf("expected timeout message, got %q", result.Stderr)
+	}
+}
+
+func TestWithNetworkRetry_WritesOnlyOnRefusedConnection(t *testing.T) {
+	old := maxRetries
+	t.Cleanup(func() { maxRetries = old })
+	maxRetries = 3
+
+	calls := 0
+	result := withNetworkRetry("POST", func() ExecResult {
+		calls++
+		return timeoutResult(time.Second)
+	})
+	if calls != 1 || result.ExitCode != -1 {
+		t.Fatalf("expected a timed-out POST not to be retried, got calls=%d result=%+v", calls, result)
+	}
+
+	calls = 0
+	result = withNetworkRetry("POST", func() ExecResult {
+		calls++
+		if calls == 1 {
+			return ExecResult{ExitCode: 1, Stderr: "dial tcp 127.0.0.1:443: connect: connection refused"}
+		}
+		return ExecResult{ExitCode: 0, Stdout: "created"}
+	})
+	if calls != 2 || result.Stdout != "created" {
+		t.Fatalf("expected a refused POST to be retried, got calls=%d result=%+v", calls, result)
+	}
+}
+
+func TestIsReadOnlyGhCommand(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected bool
+	}{
+		{[]string{"api", "repos/o/r"}, true},
+		{[]string{"api", "repos/o/r/milestones", "-X", "POST", "-f", "title=v1"}, false},
+		{[]string{"api", "repos/o/r/labels", "-f", "name=security"}, false},
+		{[]string{"api", "search/issues", "-X", "GET", "-f", "q=pin"}, true},
+		{[]string{"pr", "list", "--repo", "o/r"}, true},
+		{[]string{"pr", "create", "--title", "t"}, false},
+		{[]string{"issue", "edit", "https://github.com/o/r/issues/1"}, false},
+		{[]string{"repo", "view", "o/r"}, true},
+	}
+	for _, tc := range tests {
+		if got := isReadOnlyGhCommand(tc.args); got != tc.expected {
+			t.Errorf("isReadOnlyGhCommand(%v) = %v, expected %v", tc.args, got, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OrgResult summarizes an organization run for notifications.
+type OrgResult struct {
+	Org           string
+	Processed     int
+	Succeeded     int
+	Failed        int
+	ActionsPinned int
+	PRURLs        []string
+	FailedRepos   []string
+}
+
+// notifySlack posts a Block Kit summary of result to a Slack incoming webhook.
+func notifySlack(webhookURL string, result OrgResult) error {
+	payload, err := json.Marshal(buildSlackPayload(result))
+	if err != nil {
+		return fmt.Errorf("failed to encode Slack payload: %v", err)
+	}
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Post(webhookURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("slack webhook returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// buildSlackPayload renders the header, results table, PR links and (when
+// there were failures) a warning block listing the failed repositories.
+func buildSlackPayload(result OrgResult) map[string]interface{} {
+	blocks := []map[string]interface{}{
+		{
+			"type": "header",
+			"text": map[string]interface{}{
+				"type": "plain_text",
+				"text": fmt.Sprintf("gha-pinner: %s", result.Org),
+			},
+		},
+		{
+			"type": "section",
+			"fields": []map[string]interface{}{
+				slackField("Repositories processed", result.Processed),
+				slackField("Successful", result.Succeeded),
+				slackField("Failed", result.Failed),
+				slackField("Actions pinned", result.ActionsPinned),
+			},
+		},
+	}
+
+	if len(result.PRURLs) > 0 {
+		var sb strings.Builder
+		sb.WriteString("*Pull requests created:*\n")
+		for _, prURL := range result.PRURLs {
+			sb.WriteString(fmt.Sprintf("• <%s>\n", prURL))
+		}
+		blocks = append(blocks, slackMarkdownSection(sb.String()))
+	}
+
+	if result.Failed > 0 {
+		var sb strings.Builder
+		sb.WriteString(fmt.Sprintf(":warning: *%d repositories failed*", result.Failed))
+		if len(result.FailedRepos) > 0 {
+			sb.WriteString(":\n")
+			for _, repo := range result.FailedRepos {
+				sb.WriteString(fmt.Sprintf("• %s\n", repo))
+			}
+		}
+		blocks = append(blocks, slackMarkdownSection(sb.String()))
+	}
+
+	return map[string]interface{}{
+		"text":   fmt.Sprintf("gha-pinner processed %d repositories in %s", result.Processed, result.Org),
+		"blocks": blocks,
+	}
+}
+
+func slackField(label string, value int) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "mrkdwn",
+		"text": fmt.Sprintf("*%s*\n%d", label, value),
+	}
+}
+
+func slackMarkdownSection(text string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "section",
+		"text": map[string]interface{}{
+			"type": "mrkdwn",
+			"text": text,
+		},
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBuildSlackPayload_WarningBlockOnlyOnFailure(t *testing.T) {
+	ok := buildSlackPayload(OrgResult{Org: "octo-org", Processed: 2, Succeeded: 2})
+	if blocks := ok["blocks"].([]map[string]interface{}); len(blocks) != 2 {
+		t.Fatalf("expected header and table blocks only, got %d", len(blocks))
+	}
+
+	failed := buildSlackPayload(OrgResult{
+		Org:         "octo-org",
+		Processed:   2,
+		Succeeded:   1,
+		Failed:      1,
+		PRURLs:      []string{"https://github.com/octo-org/a/pull/1"},
+		FailedRepos: []string{"octo-org/b"},
+	})
+	blocks := failed["blocks"].([]map[string]interface{})
+	if len(blocks) != 4 {
+		t.Fatalf("expected header, table, PR and warning blocks, got %d", len(blocks))
+	}
+	warning := blocks[3]["text"].(map[string]interface{})["text"].(string)
+	if !strings.Contains(warning, "octo-org/b") {
+		t.Fatalf("expected failed repo in warning block, got %q", warning)
+	}
+}
+
+func TestNotifySlack(t *testing.T) {
+	var received map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &received)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	if err := notifySlack(server.URL, OrgResult{Org: "octo-org", Processed: 1, Succeeded: 1}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(received["text"].(string), "octo-org") {
+		t.Fatalf("unexpected payload: %v", received)
+	}
+}
+
+func TestNotifySlack_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "invalid_payload", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	if err := notifySlack(server.URL, OrgResult{Org: "octo-org"}); err == nil {
+		t.Fatal("expected error for non-2xx webhook response")
+	}
+}
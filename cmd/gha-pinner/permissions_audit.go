@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// rulePermissionsTooBroad identifies permissions: blocks granting more write
+// access than the workflow's steps need.
+const rulePermissionsTooBroad = "GHA007"
+
+// actionPermissionsDB lists the GITHUB_TOKEN scopes that common actions need
+// write access to, keyed by owner/repo. Actions listed with no scopes only
+// need read access. Actions that are not listed are treated as unknown, and
+// any write scope may be justified by them.
+var actionPermissionsDB = map[string][]string{
+	"actions/checkout":                      {},
+	"actions/setup-go":                      {},
+	"actions/setup-node":                    {},
+	"actions/setup-python":                  {},
+	"actions/setup-java":                    {},
+	"actions/setup-dotnet":                  {},
+	"actions/cache":                         {},
+	"actions/upload-artifact":               {},
+	"actions/download-artifact":             {},
+	"actions/dependency-review-action":      {},
+	"actions/upload-pages-artifact":         {},
+	"actions/configure-pages":               {},
+	"actions/deploy-pages":                  {"pages", "id-token"},
+	"actions/labeler":                       {"pull-requests"},
+	"actions/stale":                         {"issues", "pull-requests"},
+	"actions/first-interaction":             {"issues", "pull-requests"},
+	"actions/attest-build-provenance":       {"id-token", "attestations"},
+	"actions/create-release":                {"contents"},
+	"github/codeql-action":                  {"security-events"},
+	"ossf/scorecard-action":                 {"security-events", "id-token"},
+	"softprops/action-gh-release":           {"contents"},
+	"peter-evans/create-pull-request":       {"contents", "pull-requests"},
+	"stefanzweifel/git-auto-commit-action":  {"contents"},
+	"goreleaser/goreleaser-action":          {"contents"},
+	"docker/setup-buildx-action":            {},
+	"docker/setup-qemu-action":              {},
+	"docker/metadata-action":                {},
+	"docker/login-action":                   {"packages"},
+	"docker/build-push-action":              {"packages"},
+	"aws-actions/configure-aws-credentials": {"id-token"},
+	"google-github-actions/auth":            {"id-token"},
+	"azure/login":                           {"id-token"},
+	"sigstore/cosign-installer":             {},
+	"step-security/harden-runner":           {},
+}
+
+// runScopeRes maps write scopes to run: script patterns that need them. A
+// script referencing the token in any other way is treated as unknown.
+var runScopeRes = map[string]*regexp.Regexp{
+	"contents":      regexp.MustCompile(`\bgit\s+push\b|\bgh\s+release\b`),
+	"pull-requests": regexp.MustCompile(`\bgh\s+pr\b`),
+	"issues":        regexp.MustCompile(`\bgh\s+issue\b`),
+	"packages":      regexp.MustCompile(`\bdocker\s+push\b|ghcr\.io`),
+}
+
+var tokenReferenceRe = regexp.MustCompile(`GITHUB_TOKEN|GH_TOKEN|github\.token|secrets\.GITHUB_TOKEN`)
+
+// permissionsFinding is a GHA007 result for the permissions block of job, or
+// of the whole workflow when job is empty.
+type permissionsFinding struct {
+	job     string
+	message string
+}
+
+// findPermissionsIssues reports permissions blocks that grant write-all and
+// write scopes that no step in their jobs is known to need. Steps using
+// actions missing from actionPermissionsDB, or run: scripts using the token
+// in unrecognised ways, justify every scope, so findings err on the side of
+// silence. Findings are sorted for stable output.
+func findPermissionsIssues(workflow map[string]interface{}) []permissionsFinding {
+	jobs, _ := workflow["jobs"].(map[string]interface{})
+	var findings []permissionsFinding
+
+	// The top-level block applies to every job without its own permissions.
+	var inheriting [][]map[string]interface{}
+	jobNames := make([]string, 0, len(jobs))
+	for name := range jobs {
+		jobNames = append(jobNames, name)
+	}
+	sort.Strings(jobNames)
+	for _, name := range jobNames {
+		job, _ := jobs[name].(map[string]interface{})
+		steps := toStepMaps(stepsOf(job))
+		if uses, ok := job["uses"].(string); ok {
+			// A reusable workflow call is audited like an opaque action step.
+			steps = append(steps, map[string]interface{}{"uses": uses})
+		}
+		if perms, ok := job["permissions"]; ok {
+			findings = append(findings, auditPermissions(name, perms, [][]map[string]interface{}{steps})...)
+			continue
+		}
+		inheriting = append(inheriting, steps)
+	}
+	if perms, ok := workflow["permissions"]; ok {
+		findings = append(auditPermissions("", perms, inheriting), findings...)
+	}
+	return findings
+}
+
+func stepsOf(job map[string]interface{}) []interface{} {
+	steps, _ := job["steps"].([]interface{})
+	return steps
+}
+
+func auditPermissions(job string, perms interface{}, stepGroups [][]map[string]interface{}) []permissionsFinding {
+	switch p := perms.(type) {
+	case string:
+		if p == "write-all" || p == "write" {
+			return []permissionsFinding{{job: job, message: fmt.Sprintf("permissions: %s grants write access to every scope", p)}}
+		}
+	case map[string]interface{}:
+		var scopes []string
+		for scope, level := range p {
+			if s, ok := level.(string); ok && s == "write" {
+				scopes = append(scopes, scope)
+			}
+		}
+		sort.Strings(scopes)
+		var findings []permissionsFinding
+		for _, scope := range scopes {
+			if !scopeJustified(scope, stepGroups) {
+				findings = append(findings, permissionsFinding{job: job, message: fmt.Sprintf("%s: write is not needed by any step, read would suffice", scope)})
+			}
+		}
+		return findings
+	}
+	return nil
+}
+
+// scopeJustified reports whether any step may need write access to scope.
+func scopeJustified(scope string, stepGroups [][]map[string]interface{}) bool {
+	for _, steps := range stepGroups {
+		for _, step := range steps {
+			if uses, ok := step["uses"].(string); ok && uses != "" {
+				if shouldSkipAction(uses) || strings.HasPrefix(uses, "docker://") {
+					// Local and Docker actions are opaque.
+					return true
+				}
+				needed, known := actionPermissionsDB[actionRepo(uses)]
+				if !known {
+					return true
+				}
+				for _, s := range needed {
+					if s == scope {
+						return true
+					}
+				}
+			}
+			if script, ok := step["run"].(string); ok {
+				if re, ok := runScopeRes[scope]; ok && re.MatchString(script) {
+					return true
+				}
+				if tokenReferenceRe.MatchString(script) {
+					return true
+				}
+			}
+			if env, ok := step["env"].(map[string]interface{}); ok {
+				for _, v := range env {
+					if s, ok := v.(string); ok && tokenReferenceRe.MatchString(s) {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// actionRepo returns the owner/repo part of a uses: reference.
+func actionRepo(uses string) string {
+	name := uses
+	if idx := strings.Index(name, "@"); idx != -1 {
+		name = name[:idx]
+	}
+	parts := strings.Split(name, "/")
+	if len(parts) >= 2 {
+		return parts[0] + "/" + parts[1]
+	}
+	return name
+}
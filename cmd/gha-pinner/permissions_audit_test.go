@@ -0,0 +1,72 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestFindPermissionsIssues(t *testing.T) {
+	content := `on: push
+permissions: write-all
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@v4
+  release:
+    runs-on: ubuntu-latest
+    permissions:
+      contents: write
+      issues: write
+      id-token: write
+    steps:
+      - uses: actions/checkout@v4
+      - run: git push origin HEAD:refs/tags/v1
+  labels:
+    runs-on: ubuntu-latest
+    permissions:
+      pull-requests: write
+    steps:
+      - uses: actions/labeler@v5
+  custom:
+    runs-on: ubuntu-latest
+    permissions:
+      contents: write
+    steps:
+      - uses: example/unknown-action@v1
+`
+	var workflow map[string]interface{}
+	if err := yaml.Unmarshal([]byte(content), &workflow); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []permissionsFinding{
+		{job: "", message: "permissions: write-all grants write access to every scope"},
+		{job: "release", message: "id-token: write is not needed by any step, read would suffice"},
+		{job: "release", message: "issues: write is not needed by any step, read would suffice"},
+	}
+	if got := findPermissionsIssues(workflow); !reflect.DeepEqual(got, want) {
+		t.Fatalf("findPermissionsIssues() =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestFindPermissionsIssues_TokenUseIsJustified(t *testing.T) {
+	content := `permissions:
+  contents: write
+jobs:
+  build:
+    steps:
+      - run: ./scripts/publish.sh
+        env:
+          GITHUB_TOKEN: ${{ secrets.GITHUB_TOKEN }}
+`
+	var workflow map[string]interface{}
+	if err := yaml.Unmarshal([]byte(content), &workflow); err != nil {
+		t.Fatal(err)
+	}
+	if got := findPermissionsIssues(workflow); len(got) != 0 {
+		t.Fatalf("expected no findings, got %v", got)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadPRBodyTemplate_MissingFile(t *testing.T) {
+	if _, err := loadPRBodyTemplate(filepath.Join(t.TempDir(), "missing.md")); err == nil {
+		t.Fatal("expected error for missing --pr-body-file")
+	}
+}
+
+func TestRenderPRBodyTemplate(t *testing.T) {
+	summary := runSummary{
+		actionsPinned: 2,
+		changes: []actionChange{
+			{action: "actions/checkout", before: "v4", after: "abc1234 (v4, 2024-01-15)"},
+			{action: "actions/setup-go", before: "v5", after: "def5678 (v5, 2024-01-15)"},
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "body.md")
+	content := "Pinned {{.PinnedCount}} actions in {{.Repo}}:\n{{.ActionsList}}\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := loadPRBodyTemplate(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	body, err := renderPRBodyTemplate(tmpl, "owner/repo", summary)
+	if err != nil {
+		t.Fatalf("unexpected render error: %v", err)
+	}
+	for _, expected := range []string{
+		"Pinned 2 actions in owner/repo:",
+		"- `actions/checkout@v4` → abc1234 (v4, 2024-01-15)",
+		"- `actions/setup-go@v5` → def5678 (v5, 2024-01-15)",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected body to contain %q, got:\n%s", expected, body)
+		}
+	}
+}
+
+func TestGetPRBodyForRepository_UsesBodyFile(t *testing.T) {
+	oldTemplate := prBodyTemplate
+	t.Cleanup(func() { prBodyTemplate = oldTemplate })
+
+	path := filepath.Join(t.TempDir(), "body.md")
+	if err := os.WriteFile(path, []byte("Custom body for {{.Repo}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := loadPRBodyTemplate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prBodyTemplate = tmpl
+
+	if got := getPRBodyForRepository(t.TempDir(), "owner/repo", runSummary{}); got != "Custom body for owner/repo" {
+		t.Fatalf("unexpected PR body: %q", got)
+	}
+}
+
+func TestRenderPRTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "template.md")
+	content := "{{.TotalPinned}} pinned in {{.Repo}} ({{range $i, $f := .WorkflowFiles}}{{if $i}}, {{end}}{{$f}}{{end}})\n" +
+		"{{range .PinnedActions}}| {{.Action}} | {{.OldRef}} | {{.NewHash}} | {{.Date}} |\n{{end}}"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := loadPRTemplateFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	changes := []actionChange{
+		{action: "actions/checkout", before: "v4", hash: "abc1234abc1234abc1234abc1234abc1234abc12", date: "2024-01-15"},
+		{action: "actions/setup-go", before: "v5", hash: "def5678def5678def5678def5678def5678def56", date: "2024-01-15"},
+	}
+	body, err := renderPRTemplate(tmpl, "owner/repo", changes, []string{".github/workflows/ci.yml", ".github/workflows/release.yml"})
+	if err != nil {
+		t.Fatalf("unexpected render error: %v", err)
+	}
+	for _, expected := range []string{
+		"2 pinned in owner/repo (.github/workflows/ci.yml, .github/workflows/release.yml)",
+		"| actions/checkout | v4 | abc1234abc1234abc1234abc1234abc1234abc12 | 2024-01-15 |",
+		"| actions/setup-go | v5 | def5678def5678def5678def5678def5678def56 | 2024-01-15 |",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected body to contain %q, got:\n%s", expected, body)
+		}
+	}
+
+	if _, err := loadPRTemplateFile(filepath.Join(t.TempDir(), "missing.md")); err == nil {
+		t.Fatal("expected error for missing --pr-template-file")
+	}
+}
+
+func TestSelectPRTemplateFromDir(t *testing.T) {
+	repoDir := t.TempDir()
+	if got := selectPRTemplateFromDir(repoDir); got != "" {
+		t.Fatalf("expected no template without the directory, got %q", got)
+	}
+
+	dir := filepath.Join(repoDir, ".github", "PULL_REQUEST_TEMPLATE")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"feature.md", "bug_report.md", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("# "+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := selectPRTemplateFromDir(repoDir), filepath.Join(".github", "PULL_REQUEST_TEMPLATE", "bug_report.md"); got != want {
+		t.Fatalf("expected alphabetically first template %q, got %q", want, got)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "security.md"), []byte("# security"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := selectPRTemplateFromDir(repoDir), filepath.Join(".github", "PULL_REQUEST_TEMPLATE", "security.md"); got != want {
+		t.Fatalf("expected security template %q, got %q", want, got)
+	}
+}
+
+func TestGetPRBodyForRepository_TemplateDirectory(t *testing.T) {
+	oldTemplate, oldIgnore := prBodyTemplate, ignorePRTemplates
+	t.Cleanup(func() { prBodyTemplate, ignorePRTemplates = oldTemplate, oldIgnore })
+	prBodyTemplate, ignorePRTemplates = nil, false
+
+	repoDir := t.TempDir()
+	dir := filepath.Join(repoDir, ".github", "PULL_REQUEST_TEMPLATE")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	template := "## Security change\n\nDescribe the security impact of this change and how it was verified.\n"
+	if err := os.WriteFile(filepath.Join(dir, "security.md"), []byte(template), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := getPRBodyForRepository(repoDir, "owner/repo", runSummary{}); !strings.Contains(got, "## Security change") {
+		t.Fatalf("expected PR body to be based on security.md, got:\n%s", got)
+	}
+}
+
+func TestScorecardDeltaSection(t *testing.T) {
+	tests := []struct {
+		pinned, total int
+		want          int
+	}{
+		{0, 0, 10},
+		{3, 10, 3},
+		{2, 3, 6},
+		{7, 7, 10},
+	}
+	for _, tt := range tests {
+		if got := computeScorecardScore(tt.pinned, tt.total); got != tt.want {
+			t.Errorf("computeScorecardScore(%d, %d) = %d, want %d", tt.pinned, tt.total, got, tt.want)
+		}
+	}
+
+	section := scorecardDeltaSection(3, 7, 10)
+	if !strings.Contains(section, "Before: `🔴 Scorecard: 3/10` → After: `🟢 Scorecard: 10/10`") {
+		t.Errorf("unexpected section:\n%s", section)
+	}
+	if section := scorecardDeltaSection(10, 0, 10); section != "" {
+		t.Errorf("expected no section when everything was already pinned, got:\n%s", section)
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Repository outcomes recorded in RepoResult.Status.
+const (
+	repoStatusPinned        = "pinned"
+	repoStatusAlreadyPinned = "already_pinned"
+	repoStatusNoWorkflows   = "no_workflows"
+)
+
+// RepoResult is the outcome of processing one repository in a run.
+type RepoResult struct {
+	Repo          string
+	ActionsPinned int
+	// PRURLs holds one URL per pull request; --max-files-per-pr can open several.
+	PRURLs []string
+	Error  string
+	Status string
+	// SyntaxIssues lists --verify-workflow-syntax violations as "file: message".
+	SyntaxIssues []string
+	// PinnedByCategory splits ActionsPinned by --detect-third-party-actions category.
+	PinnedByCategory map[ActionCategory]int
+}
+
+// ActionStats lists, for one action@version reference, the repositories
+// where it was pinned and those where it could not be resolved.
+type ActionStats struct {
+	PinnedRepos       []string
+	UnresolvableRepos []string
+}
+
+// globalActionStats aggregates --group-by-action results across every
+// repository of a run, keyed by action@version. Repository workers update it
+// concurrently, so it is guarded by globalActionStatsMu.
+var (
+	globalActionStats   = map[string]ActionStats{}
+	globalActionStatsMu sync.Mutex
+)
+
+// recordActionStats adds the pinned and unresolvable references of one
+// repository to globalActionStats. A repository is counted once per reference
+// however many workflows use it.
+func recordActionStats(repoName string, changes []actionChange, unresolvable []string) {
+	pinned := map[string]bool{}
+	for _, change := range changes {
+		pinned[change.action+"@"+change.before] = true
+	}
+	failed := map[string]bool{}
+	for _, key := range unresolvable {
+		failed[key] = true
+	}
+
+	globalActionStatsMu.Lock()
+	defer globalActionStatsMu.Unlock()
+	for key := range pinned {
+		stats := globalActionStats[key]
+		stats.PinnedRepos = append(stats.PinnedRepos, repoName)
+		globalActionStats[key] = stats
+	}
+	for key := range failed {
+		stats := globalActionStats[key]
+		stats.UnresolvableRepos = append(stats.UnresolvableRepos, repoName)
+		globalActionStats[key] = stats
+	}
+}
+
+// actionStatsKeys returns the references in globalActionStats, most widely
+// used first. The caller must hold globalActionStatsMu.
+func actionStatsKeys() []string {
+	keys := make([]string, 0, len(globalActionStats))
+	for key := range globalActionStats {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := globalActionStats[keys[i]], globalActionStats[keys[j]]
+		na := len(a.PinnedRepos) + len(a.UnresolvableRepos)
+		nb := len(b.PinnedRepos) + len(b.UnresolvableRepos)
+		if na != nb {
+			return na > nb
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// formatActionStats renders one --group-by-action line, e.g.
+// "actions/checkout@v3: pinned in 45 repos, unresolvable in 2".
+func formatActionStats(key string, stats ActionStats) string {
+	line := fmt.Sprintf("%s: pinned in %d repos", key, len(stats.PinnedRepos))
+	if len(stats.UnresolvableRepos) > 0 {
+		line += fmt.Sprintf(", unresolvable in %d", len(stats.UnresolvableRepos))
+	}
+	return line
+}
+
+// printActionStats prints the --group-by-action summary of a run.
+func printActionStats() {
+	globalActionStatsMu.Lock()
+	defer globalActionStatsMu.Unlock()
+	fmt.Printf("\n📦 Actions across repositories:\n")
+	if len(globalActionStats) == 0 {
+		fmt.Printf("   No actions were pinned or found unresolvable\n")
+		return
+	}
+	for _, key := range actionStatsKeys() {
+		fmt.Printf("   • %s\n", formatActionStats(key, globalActionStats[key]))
+	}
+}
+
+// renderActionStatsMarkdown renders the --group-by-action section of the
+// Markdown summary.
+func renderActionStatsMarkdown() string {
+	globalActionStatsMu.Lock()
+	defer globalActionStatsMu.Unlock()
+	if len(globalActionStats) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n## Actions\n\n")
+	sb.WriteString("| Action | Pinned in | Unresolvable in |\n")
+	sb.WriteString("| --- | ---: | ---: |\n")
+	for _, key := range actionStatsKeys() {
+		stats := globalActionStats[key]
+		sb.WriteString(fmt.Sprintf("| `%s` | %d | %d |\n", key, len(stats.PinnedRepos), len(stats.UnresolvableRepos)))
+	}
+	return sb.String()
+}
+
+// writeRunSummaries writes the Markdown summary of the current run to
+// --summary-file and, inside GitHub Actions, appends it to the job summary.
+// Failures only warn so that a run is never failed by its report.
+func writeRunSummaries() {
+	results := runReport.results()
+	if summaryFile != "" {
+		if err := writeSummaryMarkdown(summaryFile, results); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Warning: failed to write summary file: %v\n", err)
+		} else {
+			fmt.Printf("📝 Summary written to %s\n", summaryFile)
+		}
+	}
+	if githubEnvFile != "" {
+		if err := appendGitHubEnvFile(githubEnvFile, results); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Warning: failed to write %s: %v\n", githubEnvFile, err)
+		}
+	}
+	if stepSummary := os.Getenv("GITHUB_STEP_SUMMARY"); stepSummary != "" {
+		f, err := os.OpenFile(stepSummary, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err == nil {
+			_, err = f.WriteString(renderSummaryMarkdown(results, time.Now()))
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Warning: failed to write GITHUB_STEP_SUMMARY: %v\n", err)
+		}
+	}
+}
+
+// appendGitHubEnvFile appends the run totals as key=value lines to path, the
+// format shared by $GITHUB_ENV (environment of later steps) and $GITHUB_OUTPUT
+// (step outputs), for --github-actions-env-file.
+func appendGitHubEnvFile(path string, results []RepoResult) error {
+	totalPinned, success := 0, true
+	for _, r := range results {
+		totalPinned += r.ActionsPinned
+		if r.Error != "" {
+			success = false
+		}
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(f, "GHA_PINNER_TOTAL_PINNED=%d\nGHA_PINNER_TOTAL_REPOS=%d\nGHA_PINNER_SUCCESS=%t\n", totalPinned, len(results), success)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// writeSummaryMarkdown writes a Markdown summary of results to path, suitable
+// for pasting into an issue or wiki page.
+func writeSummaryMarkdown(path string, results []RepoResult) error {
+	return os.WriteFile(path, []byte(renderSummaryMarkdown(results, time.Now())), 0644)
+}
+
+func renderSummaryMarkdown(results []RepoResult, now time.Time) string {
+	totalPinned, prs := 0, 0
+	var failed []RepoResult
+	for _, r := range results {
+		totalPinned += r.ActionsPinned
+		prs += len(r.PRURLs)
+		if r.Error != "" {
+			failed = append(failed, r)
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("# gha-pinner summary\n\n")
+	sb.WriteString(fmt.Sprintf("- **Run:** %s\n", now.UTC().Format(time.RFC3339)))
+	sb.WriteString(fmt.Sprintf("- **Repositories processed:** %d\n", len(results)))
+	sb.WriteString(fmt.Sprintf("- **Actions pinned:** %d\n", totalPinned))
+	sb.WriteString(fmt.Sprintf("- **Pull requests created:** %d\n", prs))
+	sb.WriteString(fmt.Sprintf("- **Failed:** %d\n", len(failed)))
+
+	if len(results) > 0 {
+		sb.WriteString("\n## Repositories\n\n")
+		sb.WriteString("| Repository | Actions pinned | Pull request | Status |\n")
+		sb.WriteString("| --- | ---: | --- | --- |\n")
+		for _, r := range results {
+			pr := prLinks(r.PRURLs)
+			status := "✅"
+			if r.Error != "" {
+				status = "❌"
+			}
+			sb.WriteString(fmt.Sprintf("| `%s` | %d | %s | %s |\n", r.Repo, r.ActionsPinned, pr, status))
+		}
+	}
+
+	if detectThirdParty {
+		sb.WriteString(renderCategoryMarkdown(results))
+	}
+
+	if groupByAction {
+		sb.WriteString(renderActionStatsMarkdown())
+	}
+
+	var withSyntaxIssues []RepoResult
+	for _, r := range results {
+		if len(r.SyntaxIssues) > 0 {
+			withSyntaxIssues = append(withSyntaxIssues, r)
+		}
+	}
+	if len(withSyntaxIssues) > 0 {
+		sb.WriteString("\n## Workflow schema warnings\n\n")
+		for _, r := range withSyntaxIssues {
+			for _, issue := range r.SyntaxIssues {
+				sb.WriteString(fmt.Sprintf("- `%s` %s\n", r.Repo, singleLine(issue)))
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		sb.WriteString("\n## Errors\n\n")
+		for _, r := range failed {
+			sb.WriteString(fmt.Sprintf("- `%s`: %s\n", r.Repo, singleLine(r.Error)))
+		}
+	}
+	return sb.String()
+}
+
+// renderCategoryMarkdown renders the --detect-third-party-actions section of
+// the Markdown summary.
+func renderCategoryMarkdown(results []RepoResult) string {
+	totals := map[ActionCategory]int{}
+	for _, r := range results {
+		for category, count := range r.PinnedByCategory {
+			totals[category] += count
+		}
+	}
+	var sb strings.Builder
+	sb.WriteString("\n## Pinned actions by category\n\n")
+	sb.WriteString("| Category | Actions pinned |\n")
+	sb.WriteString("| --- | ---: |\n")
+	for _, category := range actionCategories {
+		sb.WriteString(fmt.Sprintf("| %s | %d |\n", category, totals[category]))
+	}
+	return sb.String()
+}
+
+// prLinkText shortens a pull request URL to "#123" for table cells.
+func prLinkText(prURL string) string {
+	if idx := strings.LastIndex(prURL, "/pull/"); idx != -1 {
+		return "#" + prURL[idx+len("/pull/"):]
+	}
+	return prURL
+}
+
+// prLinks renders prURLs as comma-separated "#123" links, or "-" when empty.
+func prLinks(prURLs []string) string {
+	if len(prURLs) == 0 {
+		return "-"
+	}
+	links := make([]string, len(prURLs))
+	for i, prURL := range prURLs {
+		links[i] = fmt.Sprintf("[%s](%s)", prLinkText(prURL), prURL)
+	}
+	return strings.Join(links, ", ")
+}
+
+// singleLine collapses whitespace so a multi-line error stays on one list item.
+func singleLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
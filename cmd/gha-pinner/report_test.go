@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderSummaryMarkdown(t *testing.T) {
+	results := []RepoResult{
+		{Repo: "acme/api", ActionsPinned: 3, PRURLs: []string{"https://github.com/acme/api/pull/12"}},
+		{Repo: "acme/web"},
+		{Repo: "acme/broken", Error: "failed to clone:\nexit status 128"},
+		{Repo: "acme/mono", ActionsPinned: 5, PRURLs: []string{"https://github.com/acme/mono/pull/7", "https://github.com/acme/mono/pull/8"}},
+	}
+	got := renderSummaryMarkdown(results, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+
+	for _, want := range []string{
+		"- **Run:** 2024-05-01T12:00:00Z",
+		"- **Repositories processed:** 4",
+		"- **Actions pinned:** 8",
+		"- **Pull requests created:** 3",
+		"| `acme/api` | 3 | [#12](https://github.com/acme/api/pull/12) | ✅ |",
+		"| `acme/web` | 0 | - | ✅ |",
+		"| `acme/broken` | 0 | - | ❌ |",
+		"| `acme/mono` | 5 | [#7](https://github.com/acme/mono/pull/7), [#8](https://github.com/acme/mono/pull/8) | ✅ |",
+		"- `acme/broken`: failed to clone: exit status 128",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("summary missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestWriteRunSummaries_StepSummary(t *testing.T) {
+	dir := t.TempDir()
+	stepSummary := filepath.Join(dir, "step-summary.md")
+	if err := os.WriteFile(stepSummary, []byte("previous step\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GITHUB_STEP_SUMMARY", stepSummary)
+	oldSummaryFile := summaryFile
+	t.Cleanup(func() {
+		summaryFile = oldSummaryFile
+		runReport.reset()
+	})
+	summaryFile = filepath.Join(dir, "summary.md")
+
+	runReport.reset()
+	runReport.addRepo("acme/api")
+	runReport.addRepoPinned("acme/api", 2)
+	writeRunSummaries()
+
+	written, err := os.ReadFile(summaryFile)
+	if err != nil || !strings.Contains(string(written), "| `acme/api` | 2 |") {
+		t.Fatalf("unexpected summary file: %q, %v", written, err)
+	}
+	appended, err := os.ReadFile(stepSummary)
+	if err != nil || !strings.HasPrefix(string(appended), "previous step\n# gha-pinner summary") {
+		t.Fatalf("expected summary appended to GITHUB_STEP_SUMMARY, got %q, %v", appended, err)
+	}
+}
+
+func TestWriteRunSummaries_GitHubEnvFile(t *testing.T) {
+	t.Setenv("GITHUB_STEP_SUMMARY", "")
+	envFile := filepath.Join(t.TempDir(), "github-env")
+	if err := os.WriteFile(envFile, []byte("EARLIER=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	oldSummaryFile, oldEnvFile := summaryFile, githubEnvFile
+	t.Cleanup(func() {
+		summaryFile, githubEnvFile = oldSummaryFile, oldEnvFile
+		runReport.reset()
+	})
+	summaryFile, githubEnvFile = "", envFile
+
+	runReport.reset()
+	runReport.addRepoPinned("acme/api", 2)
+	runReport.addRepoPinned("acme/web", 3)
+	writeRunSummaries()
+	runReport.addFailure("acme/broken", errors.New("clone failed"))
+	writeRunSummaries()
+
+	written, err := os.ReadFile(envFile)
+	want := "EARLIER=1\n" +
+		"GHA_PINNER_TOTAL_PINNED=5\nGHA_PINNER_TOTAL_REPOS=2\nGHA_PINNER_SUCCESS=true\n" +
+		"GHA_PINNER_TOTAL_PINNED=5\nGHA_PINNER_TOTAL_REPOS=3\nGHA_PINNER_SUCCESS=false\n"
+	if err != nil || string(written) != want {
+		t.Fatalf("unexpected env file: %q, %v", written, err)
+	}
+}
+
+func TestRunReport_StatusCount(t *testing.T) {
+	t.Cleanup(runReport.reset)
+	runReport.reset()
+	runReport.setRepoStatus("acme/api", repoStatusPinned)
+	runReport.setRepoStatus("acme/web", repoStatusPinned)
+	runReport.setRepoStatus("acme/docs", repoStatusNoWorkflows)
+	runReport.setRepoStatus("acme/lib", repoStatusAlreadyPinned)
+	runReport.setRepoStatus("acme/broken", repoStatusPinned)
+	runReport.addFailure("acme/broken", errors.New("push rejected"))
+
+	for status, want := range map[string]int{
+		repoStatusPinned:        2,
+		repoStatusAlreadyPinned: 1,
+		repoStatusNoWorkflows:   1,
+	} {
+		if got := runReport.statusCount(status); got != want {
+			t.Errorf("statusCount(%q) = %d, want %d", status, got, want)
+		}
+	}
+}
+
+func TestRecordActionStats_GroupsByAction(t *testing.T) {
+	oldStats, oldGroup := globalActionStats, groupByAction
+	t.Cleanup(func() { globalActionStats, groupByAction = oldStats, oldGroup })
+	globalActionStats = map[string]ActionStats{}
+	groupByAction = true
+
+	checkout := actionChange{action: "actions/checkout", before: "v3"}
+	recordActionStats("acme/api", []actionChange{checkout, checkout}, nil)
+	recordActionStats("acme/web", []actionChange{checkout}, []string{"acme/deploy@main", "acme/deploy@main"})
+	recordActionStats("acme/cli", nil, []string{"actions/checkout@v3"})
+
+	keys := actionStatsKeys()
+	if len(keys) != 2 || keys[0] != "actions/checkout@v3" {
+		t.Fatalf("unexpected key order: %v", keys)
+	}
+	if got, want := formatActionStats(keys[0], globalActionStats[keys[0]]), "actions/checkout@v3: pinned in 2 repos, unresolvable in 1"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := formatActionStats(keys[1], globalActionStats[keys[1]]), "acme/deploy@main: pinned in 0 repos, unresolvable in 1"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	summary := renderSummaryMarkdown(nil, time.Now())
+	if !strings.Contains(summary, "| `actions/checkout@v3` | 2 | 1 |") {
+		t.Errorf("summary missing actions table:\n%s", summary)
+	}
+}
@@ -1,6 +1,20 @@
 package main
 
-import "testing"
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
 
 func TestProcessRepositoryNames_Empty(t *testing.T) {
 	success, failed := processRepositoryNames([]string{})
@@ -9,6 +23,122 @@ func TestProcessRepositoryNames_Empty(t *testing.T) {
 	}
 }
 
+func TestProcessRepoList_FailFast(t *testing.T) {
+	oldMode, oldToken, oldWorkers := saveAuthGlobals()
+	oldFailFast, oldBase, oldSummary := failFast, githubAPIBase, summaryFile
+	t.Cleanup(func() {
+		restoreAuthGlobals(oldMode, oldToken, oldWorkers)
+		failFast, githubAPIBase, summaryFile = oldFailFast, oldBase, oldSummary
+	})
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(server.Close)
+	githubAPIBase = server.URL
+	authMode, githubToken, repoWorkers = "pat", "test-token", 1
+	summaryFile = ""
+
+	failFast = true
+	err := processRepoList(strings.NewReader("acme/one\nacme/two\nacme/three\n"), "--target-repos")
+	if !errors.Is(err, errFailFast) {
+		t.Fatalf("expected errFailFast, got: %v", err)
+	}
+	if requests != 1 {
+		t.Fatalf("expected processing to stop after the first repository, got %d requests", requests)
+	}
+
+	failFast = false
+	requests = 0
+	if err := processRepoList(strings.NewReader("acme/one\nacme/two\n"), "--target-repos"); err != nil {
+		t.Fatalf("expected failures to be collected without --fail-fast, got: %v", err)
+	}
+	if requests != 2 {
+		t.Fatalf("expected every repository to be processed, got %d requests", requests)
+	}
+}
+
+func TestGetDefaultBranch(t *testing.T) {
+	if got := getDefaultBranch(Repository{Name: "repo", DefaultBranchRef: DefaultBranchRef{Name: "trunk"}}); got != "trunk" {
+		t.Fatalf("expected reported default branch, got %q", got)
+	}
+	if got := getDefaultBranch(Repository{Name: "repo"}); got != "main" {
+		t.Fatalf("expected main fallback, got %q", got)
+	}
+}
+
+func TestIsEmptyRepository(t *testing.T) {
+	oldMode, oldToken, oldWorkers := saveAuthGlobals()
+	oldBase := githubAPIBase
+	t.Cleanup(func() {
+		restoreAuthGlobals(oldMode, oldToken, oldWorkers)
+		githubAPIBase = oldBase
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/acme/empty":
+			w.Write([]byte(`{"name": "empty", "size": 0, "default_branch": ""}`))
+		case "/repos/acme/full":
+			w.Write([]byte(`{"name": "full", "size": 120, "default_branch": "main"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	githubAPIBase = server.URL
+	authMode, githubToken = "pat", "test-token"
+
+	if empty, err := isEmptyRepository("acme/empty"); err != nil || !empty {
+		t.Fatalf("expected acme/empty to be empty, got %v, %v", empty, err)
+	}
+	if empty, err := isEmptyRepository("acme/full"); err != nil || empty {
+		t.Fatalf("expected acme/full not to be empty, got %v, %v", empty, err)
+	}
+	if _, err := isEmptyRepository("acme/missing"); err == nil {
+		t.Fatal("expected error for a missing repository")
+	}
+}
+
+func TestProcessRepositoryBatches(t *testing.T) {
+	oldMode, oldToken, oldWorkers := saveAuthGlobals()
+	oldBase, oldSize, oldCooldown := githubAPIBase, repoBatchSize, repoBatchCooldown
+	t.Cleanup(func() {
+		restoreAuthGlobals(oldMode, oldToken, oldWorkers)
+		githubAPIBase, repoBatchSize, repoBatchCooldown = oldBase, oldSize, oldCooldown
+	})
+
+	var mu sync.Mutex
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requested = append(requested, r.URL.Path)
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(server.Close)
+	githubAPIBase = server.URL
+	authMode, githubToken, repoWorkers = "pat", "test-token", 2
+	repoBatchSize, repoBatchCooldown = 2, time.Millisecond
+
+	success, failed := processRepositoryBatches([]string{"acme/one", "acme/two", "acme/three"})
+	if success != 0 || failed != 3 {
+		t.Fatalf("expected all 3 repositories to be attempted, got success=%d failed=%d", success, failed)
+	}
+	if len(requested) != 3 || requested[2] != "/repos/acme/three" {
+		t.Fatalf("expected the last batch to run after the first one finished, got %v", requested)
+	}
+}
+
+func TestProcessRepoList_NoEntries(t *testing.T) {
+	err := processRepoList(strings.NewReader("# only comments\n\n"), "--target-repos")
+	if err == nil || !strings.Contains(err.Error(), "--target-repos") {
+		t.Fatalf("expected error naming the source, got: %v", err)
+	}
+}
+
 func TestGetPRTitleForRepository(t *testing.T) {
 	tests := []struct {
 		repo     string
@@ -44,3 +174,852 @@ func TestGetPRSearchPattern(t *testing.T) {
 		}
 	}
 }
+
+func TestParseDayDuration(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Duration
+		wantErr  bool
+	}{
+		{"7d", 7 * 24 * time.Hour, false},
+		{"36h", 36 * time.Hour, false},
+		{"1.5d", 0, true},
+		{"d", 0, true},
+		{"soon", 0, true},
+	}
+
+	for _, tc := range tests {
+		got, err := parseDayDuration(tc.input)
+		if tc.wantErr {
+			if err == nil {
+				t.Fatalf("expected error for %q", tc.input)
+			}
+			continue
+		}
+		if err != nil || got != tc.expected {
+			t.Fatalf("unexpected result for %q: got=%v err=%v", tc.input, got, err)
+		}
+	}
+}
+
+func TestHasRecentPR(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	prs := `[
+		{"title":"security: pin GitHub Actions to commit hashes","url":"https://github.com/o/r/pull/1","createdAt":"2024-01-01T00:00:00Z"},
+		{"title":"security: pin GitHub Actions to commit hashes","url":"https://github.com/o/r/pull/2","createdAt":"2024-01-13T00:00:00Z"}
+	]`
+
+	recent, url := hasRecentPR(prs, 7*24*time.Hour, now)
+	if !recent || url != "https://github.com/o/r/pull/2" {
+		t.Fatalf("expected recent PR #2, got recent=%v url=%s", recent, url)
+	}
+
+	if recent, _ := hasRecentPR(prs, 24*time.Hour, now); recent {
+		t.Fatal("expected no PR newer than 1 day")
+	}
+	if recent, _ := hasRecentPR("[]", 7*24*time.Hour, now); recent {
+		t.Fatal("expected no recent PR for empty list")
+	}
+}
+
+func TestStalePRURLs(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	prs := `[
+		{"url":"https://github.com/o/r/pull/1","createdAt":"2024-01-01T00:00:00Z"},
+		{"url":"https://github.com/o/r/pull/2","createdAt":"2024-02-25T00:00:00Z"}
+	]`
+	if got := stalePRURLs(prs, 30*24*time.Hour, now); len(got) != 1 || got[0] != "https://github.com/o/r/pull/1" {
+		t.Fatalf("expected only PR #1 to be stale, got %v", got)
+	}
+	if got := stalePRURLs("not json", time.Hour, now); got != nil {
+		t.Fatalf("expected no PRs for invalid JSON, got %v", got)
+	}
+}
+
+func TestCloseStalePRs(t *testing.T) {
+	oldMode, oldToken, oldWorkers := saveAuthGlobals()
+	oldBase, oldStale := githubAPIBase, prCloseStale
+	t.Cleanup(func() {
+		restoreAuthGlobals(oldMode, oldToken, oldWorkers)
+		githubAPIBase, prCloseStale = oldBase, oldStale
+	})
+
+	old := time.Now().Add(-60 * 24 * time.Hour).UTC().Format(time.RFC3339)
+	recent := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	var mu sync.Mutex
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/repos/acme/app/pulls":
+			w.Write([]byte(`[
+				{"title":"security: pin GitHub Actions to commit hashes","html_url":"https://github.com/acme/app/pull/7","created_at":"` + old + `"},
+				{"title":"security: pin GitHub Actions to commit hashes","html_url":"https://github.com/acme/app/pull/9","created_at":"` + recent + `"},
+				{"title":"Unrelated change","html_url":"https://github.com/acme/app/pull/3","created_at":"` + old + `"}
+			]`))
+		case r.Method == "POST" && r.URL.Path == "/repos/acme/app/issues/7/comments":
+			var body map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			calls = append(calls, "comment:"+body["body"])
+			w.Write([]byte(`{}`))
+		case r.Method == "PATCH" && r.URL.Path == "/repos/acme/app/pulls/7":
+			var body map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			calls = append(calls, "state:"+body["state"])
+			w.Write([]byte(`{}`))
+		default:
+			calls = append(calls, r.Method+" "+r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	githubAPIBase = server.URL
+	authMode, githubToken = "pat", "test-token"
+	prCloseStale = 30 * 24 * time.Hour
+
+	if closed := closeStalePRs([]string{"acme/app"}); closed != 1 {
+		t.Fatalf("expected 1 closed PR, got %d", closed)
+	}
+	want := []string{"comment:" + stalePRComment, "state:closed"}
+	if strings.Join(calls, "|") != strings.Join(want, "|") {
+		t.Fatalf("expected calls %v, got %v", want, calls)
+	}
+}
+
+func TestSplitFileBatches(t *testing.T) {
+	files := []string{"a.yml", "b.yml", "c.yml", "d.yml", "e.yml"}
+	got := splitFileBatches(files, 2)
+	want := [][]string{{"a.yml", "b.yml"}, {"c.yml", "d.yml"}, {"e.yml"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("splitFileBatches() = %v, want %v", got, want)
+	}
+	if got := splitFileBatches(files[:2], 2); len(got) != 1 {
+		t.Fatalf("expected a single batch when files fit, got %v", got)
+	}
+}
+
+func TestBrowserOpenCommand(t *testing.T) {
+	tests := []struct {
+		goos     string
+		expected string
+	}{
+		{"darwin", "open"},
+		{"windows", "rundll32"},
+		{"linux", "xdg-open"},
+	}
+	for _, tc := range tests {
+		name, args := browserOpenCommand(tc.goos, "https://github.com/o/r/pull/1")
+		if name != tc.expected {
+			t.Errorf("unexpected opener for %s: %s", tc.goos, name)
+		}
+		if args[len(args)-1] != "https://github.com/o/r/pull/1" {
+			t.Errorf("expected URL as last argument for %s, got %v", tc.goos, args)
+		}
+	}
+}
+
+func TestOrganizationRepoNames_TemplatesRepoFirst(t *testing.T) {
+	repos := []Repository{{Name: "api"}, {Name: "web"}, {Name: ".github"}}
+	got := organizationRepoNames("acme", repos)
+	want := []string{"acme/.github", "acme/api", "acme/web"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("organizationRepoNames() = %v, want %v", got, want)
+	}
+}
+
+func TestFilterReposByVisibility(t *testing.T) {
+	repos := []Repository{
+		{Name: "site", Visibility: "PUBLIC"},
+		{Name: "api", Visibility: "PRIVATE"},
+		{Name: "tools", Visibility: "internal"},
+		{Name: "docs", Visibility: "public"},
+	}
+	names := func(repos []Repository) string {
+		var out []string
+		for _, r := range repos {
+			out = append(out, r.Name)
+		}
+		return strings.Join(out, ",")
+	}
+	if got := names(filterReposByVisibility(repos, false)); got != "site,docs" {
+		t.Errorf("--skip-private-repos kept %s", got)
+	}
+	if got := names(filterReposByVisibility(repos, true)); got != "api,tools" {
+		t.Errorf("--only-private-repos kept %s", got)
+	}
+}
+
+func TestFilterReposByTopics(t *testing.T) {
+	topics := func(names ...string) []RepositoryTopic {
+		var out []RepositoryTopic
+		for _, n := range names {
+			out = append(out, RepositoryTopic{Name: n})
+		}
+		return out
+	}
+	repos := []Repository{
+		{Name: "payments", RepositoryTopics: topics("microservice", "security-critical")},
+		{Name: "auth", RepositoryTopics: topics("security-critical", "deprecated")},
+		{Name: "cli", RepositoryTopics: topics("internal-tool")},
+		{Name: "docs"},
+	}
+	names := func(repos []Repository) string {
+		var out []string
+		for _, r := range repos {
+			out = append(out, r.Name)
+		}
+		return strings.Join(out, ",")
+	}
+	if got := names(filterReposByTopics(repos, []string{"security-critical", "Internal-Tool"}, nil)); got != "payments,auth,cli" {
+		t.Errorf("--repo-topics-filter kept %s", got)
+	}
+	if got := names(filterReposByTopics(repos, nil, []string{"deprecated"})); got != "payments,cli,docs" {
+		t.Errorf("--repo-topics-exclude kept %s", got)
+	}
+	if got := names(filterReposByTopics(repos, []string{"security-critical"}, []string{"deprecated"})); got != "payments" {
+		t.Errorf("combined filters kept %s", got)
+	}
+}
+
+func TestExistingScanDirs(t *testing.T) {
+	for repoName, want := range map[string]string{
+		"acme_.github": ".github/actions,workflow-templates",
+		"acme_api":     ".github/actions",
+	} {
+		repoDir := filepath.Join(t.TempDir(), repoName)
+		for _, dir := range []string{filepath.Join(".github", "actions"), workflowTemplatesDir} {
+			if err := os.MkdirAll(filepath.Join(repoDir, dir), 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := existingScanDirs(repoDir); strings.Join(got, ",") != want {
+			t.Errorf("%s: existingScanDirs() = %v, want %s", repoName, got, want)
+		}
+	}
+}
+
+func TestListScanTargets_WorkflowTemplates(t *testing.T) {
+	repoDir := filepath.Join(t.TempDir(), orgTemplatesRepo)
+	templatesDir := filepath.Join(repoDir, workflowTemplatesDir)
+	if err := os.MkdirAll(templatesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"ci.yml", "ci.properties.json"} {
+		if err := os.WriteFile(filepath.Join(templatesDir, name), []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	targets, err := listScanTargets(repoDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(targets) != 1 || targets[0] != filepath.Join(templatesDir, "ci.yml") {
+		t.Fatalf("expected only the template YAML file, got %v", targets)
+	}
+
+	// Outside the .github repository a workflow-templates directory is ordinary content.
+	otherDir := filepath.Join(t.TempDir(), "acme_api")
+	if err := os.MkdirAll(filepath.Join(otherDir, workflowTemplatesDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(otherDir, workflowTemplatesDir, "ci.yml"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if targets, err := listScanTargets(otherDir); err != nil || len(targets) != 0 {
+		t.Fatalf("expected no targets outside the .github repository, got %v, %v", targets, err)
+	}
+}
+
+func TestGetRepositoryDir(t *testing.T) {
+	oldSkip, oldOutput, oldStructure := skipPRCreation, outputDir, outputDirStructure
+	t.Cleanup(func() {
+		skipPRCreation, outputDir, outputDirStructure = oldSkip, oldOutput, oldStructure
+	})
+	skipPRCreation = true
+	outputDir = filepath.Join("fixed-repos")
+
+	outputDirStructure = "flat"
+	if got, want := getRepositoryDir("myrepo", "myorg/myrepo"), filepath.Join("fixed-repos", "myrepo"); got != want {
+		t.Errorf("flat: got %q, want %q", got, want)
+	}
+
+	outputDirStructure = "org/repo"
+	if got, want := getRepositoryDir("myrepo", "https://github.com/myorg/myrepo.git"), filepath.Join("fixed-repos", "myorg", "myrepo"); got != want {
+		t.Errorf("org/repo: got %q, want %q", got, want)
+	}
+	if got, want := getRepositoryDir("myrepo", "myrepo"), filepath.Join("fixed-repos", "myrepo"); got != want {
+		t.Errorf("org/repo without owner: got %q, want %q", got, want)
+	}
+}
+
+func TestGetReposDir_NoPushUsesOutput(t *testing.T) {
+	oldSkip, oldNoPush, oldOutput := skipPRCreation, noPush, outputDir
+	t.Cleanup(func() {
+		skipPRCreation, noPush, outputDir = oldSkip, oldNoPush, oldOutput
+	})
+	outputDir = "fixed-repos"
+
+	skipPRCreation, noPush = false, false
+	if got := getReposDir(); got == outputDir {
+		t.Errorf("--output must be ignored when branches are pushed, got %q", got)
+	}
+	noPush = true
+	if got := getReposDir(); got != outputDir {
+		t.Errorf("--no-push: got %q, want %q", got, outputDir)
+	}
+}
+
+// TestPatchLocalRepository_ConcurrentSummaries runs repositories in parallel,
+// as --repo-workers does, and checks that each summary counts only its
+// own repository. Run it with go test -race.
+func TestPatchLocalRepository_ConcurrentSummaries(t *testing.T) {
+	oldCache := hashCache
+	t.Cleanup(func() { hashCache = oldCache })
+	hashCache = newActionHashCache()
+	hashCache.put("actions/checkout", "v4", lockEntry{hash: testSHA, resolvedVersion: "v4"})
+	hashCache.put("actions/setup-go", "v5", lockEntry{hash: testSHA, resolvedVersion: "v5"})
+
+	steps := map[int]string{
+		1: "      - uses: actions/checkout@v4\n",
+		3: "      - uses: actions/checkout@v4\n      - uses: actions/setup-go@v5\n      - uses: actions/checkout@v4\n",
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		for want, step := range steps {
+			repoDir := t.TempDir()
+			writeWorkflow(t, repoDir, "ci.yml", "on: push\njobs:\n  build:\n    runs-on: ubuntu-latest\n    steps:\n"+step)
+			wg.Add(1)
+			go func(repoDir string, want int) {
+				defer wg.Done()
+				summary, err := patchLocalRepository(repoDir)
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+					return
+				}
+				if summary.actionsPinned != want || len(summary.changes) != want {
+					t.Errorf("%s: pinned %d with %d changes, expected %d", repoDir, summary.actionsPinned, len(summary.changes), want)
+				}
+				if !reflect.DeepEqual(summary.files, []string{".github/workflows/ci.yml"}) {
+					t.Errorf("%s: unexpected files %v", repoDir, summary.files)
+				}
+			}(repoDir, want)
+		}
+	}
+	wg.Wait()
+}
+
+func TestRunSummary_ForFiles(t *testing.T) {
+	var summary runSummary
+	summary.add(".github/workflows/a.yml", patchResult{actionsPinned: 2, totalActions: 2, changes: make([]actionChange, 2)})
+	summary.add(".github/workflows/b.yml", patchResult{actionsPinned: 1, actionsAlreadyPinned: 1, totalActions: 2, changes: make([]actionChange, 1), syntaxIssues: []string{"bad key"}})
+	summary.add(".github/workflows/c.yml", patchResult{actionsAlreadyPinned: 1, totalActions: 1})
+
+	if summary.actionsPinned != 3 || summary.totalFound != 5 || len(summary.files) != 2 {
+		t.Fatalf("unexpected totals: %+v", summary)
+	}
+	batch := summary.forFiles([]string{".github/workflows/b.yml", ".github/workflows/missing.yml"})
+	if batch.actionsPinned != 1 || batch.alreadyPinned != 1 || batch.totalFound != 2 || len(batch.changes) != 1 {
+		t.Errorf("unexpected batch totals: %+v", batch)
+	}
+	if !reflect.DeepEqual(batch.files, []string{".github/workflows/b.yml"}) {
+		t.Errorf("unexpected batch files: %v", batch.files)
+	}
+	if !reflect.DeepEqual(batch.syntaxIssues, []string{".github/workflows/b.yml: bad key"}) {
+		t.Errorf("unexpected batch syntax issues: %v", batch.syntaxIssues)
+	}
+}
+
+func TestRemoveExistingRepoDir(t *testing.T) {
+	old := forceOverwrite
+	t.Cleanup(func() { forceOverwrite = old })
+	forceOverwrite = false
+
+	if err := removeExistingRepoDir(filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Fatalf("missing directory: unexpected error: %v", err)
+	}
+
+	clone := t.TempDir()
+	if err := os.Mkdir(filepath.Join(clone, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := removeExistingRepoDir(clone); err != nil {
+		t.Fatalf("git directory: unexpected error: %v", err)
+	}
+	if _, err := os.Stat(clone); !os.IsNotExist(err) {
+		t.Errorf("expected previous clone to be removed")
+	}
+
+	userDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(userDir, "notes.txt"), []byte("work"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := removeExistingRepoDir(userDir)
+	if err == nil || !strings.Contains(err.Error(), "is not a git repository; use --force-overwrite") {
+		t.Fatalf("expected non-git directory error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(userDir, "notes.txt")); err != nil {
+		t.Errorf("user files must be kept: %v", err)
+	}
+
+	forceOverwrite = true
+	if err := removeExistingRepoDir(userDir); err != nil {
+		t.Fatalf("--force-overwrite: unexpected error: %v", err)
+	}
+	if _, err := os.Stat(userDir); !os.IsNotExist(err) {
+		t.Errorf("expected --force-overwrite to remove the directory")
+	}
+}
+
+func TestHasWorkflowTargets(t *testing.T) {
+	repoDir := filepath.Join(t.TempDir(), "acme_.github")
+	if hasWorkflowTargets(repoDir) {
+		t.Error("expected repository without workflows to have no targets")
+	}
+	if err := os.MkdirAll(filepath.Join(repoDir, workflowTemplatesDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repoDir, workflowTemplatesDir, "ci.yml"), []byte("jobs: {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !hasWorkflowTargets(repoDir) {
+		t.Error("expected workflow templates to count as targets")
+	}
+
+	repoDir = t.TempDir()
+	if err := os.MkdirAll(filepath.Join(repoDir, ".github", "workflows"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if !hasWorkflowTargets(repoDir) {
+		t.Error("expected .github/workflows to count as a target")
+	}
+}
+
+func TestRepoSelectedByPatterns(t *testing.T) {
+	oldInclude, oldExclude := includePatterns, excludePatterns
+	t.Cleanup(func() {
+		includePatterns, excludePatterns = oldInclude, oldExclude
+	})
+
+	var err error
+	if excludePatterns, err = compileRepoPatterns("--exclude-pattern", []string{`^myorg/deprecated-.*$`, `-archive$`}); err != nil {
+		t.Fatal(err)
+	}
+	includePatterns = nil
+
+	tests := map[string]bool{
+		"myorg/api":             true,
+		"myorg/deprecated-tool": false,
+		"myorg/docs-archive":    false,
+	}
+	for repo, want := range tests {
+		if got := repoSelectedByPatterns(repo); got != want {
+			t.Errorf("exclude only: repoSelectedByPatterns(%q) = %v, want %v", repo, got, want)
+		}
+	}
+
+	if includePatterns, err = compileRepoPatterns("--include-pattern", []string{`^myorg/`}); err != nil {
+		t.Fatal(err)
+	}
+	if repoSelectedByPatterns("other/api") {
+		t.Error("expected repository outside --include-pattern to be skipped")
+	}
+	if !repoSelectedByPatterns("myorg/api") {
+		t.Error("expected included repository to be selected")
+	}
+	if repoSelectedByPatterns("myorg/deprecated-tool") {
+		t.Error("expected --exclude-pattern to win over --include-pattern")
+	}
+}
+
+func TestCompileRepoPatterns_Invalid(t *testing.T) {
+	_, err := compileRepoPatterns("--exclude-pattern", []string{"("})
+	if err == nil || !strings.Contains(err.Error(), "--exclude-pattern") {
+		t.Fatalf("expected helpful error for invalid regex, got %v", err)
+	}
+}
+
+func TestBranchProtectionWarnings(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		login string
+		want  int
+	}{
+		{"reviews only", `{"required_pull_request_reviews":{"required_approving_review_count":1},"required_status_checks":{"strict":true}}`, "alice", 0},
+		{"restricted to other users", `{"restrictions":{"users":[{"login":"bob"}],"teams":[]}}`, "alice", 1},
+		{"restricted and allowed", `{"restrictions":{"users":[{"login":"Alice"}]}}`, "alice", 0},
+		{"restricted with unknown login", `{"restrictions":{"users":[{"login":"bob"}]}}`, "", 1},
+		{"invalid body", `not json`, "alice", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := branchProtectionWarnings(tt.body, tt.login); len(got) != tt.want {
+				t.Errorf("branchProtectionWarnings() = %v, want %d warning(s)", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMilestoneNumber(t *testing.T) {
+	body := `[{"number":3,"title":"Backlog"},{"number":7,"title":"Security Sprint Q1"}]`
+	if n, err := parseMilestoneNumber(body, "Security Sprint Q1"); err != nil || n != 7 {
+		t.Fatalf("parseMilestoneNumber() = %d, %v; want 7", n, err)
+	}
+	if n, err := parseMilestoneNumber(body, "Missing"); err != nil || n != 0 {
+		t.Fatalf("expected 0 for a missing milestone, got %d, %v", n, err)
+	}
+	if _, err := parseMilestoneNumber("oops", "Backlog"); err == nil {
+		t.Fatal("expected error for invalid response")
+	}
+}
+
+func TestCompareContainsBase(t *testing.T) {
+	tests := map[string]bool{
+		`{"status":"identical"}`: true,
+		`{"status":"ahead"}`:     true,
+		`{"status":"behind"}`:    false,
+		`{"status":"diverged"}`:  false,
+		`oops`:                   false,
+	}
+	for body, want := range tests {
+		if got := compareContainsBase(body); got != want {
+			t.Errorf("compareContainsBase(%s) = %v, want %v", body, got, want)
+		}
+	}
+}
+
+func TestPullRequestHasLabel(t *testing.T) {
+	var pr map[string]interface{}
+	if err := json.Unmarshal([]byte(`{"title":"Pin actions","labels":[{"name":"dependencies"},{"name":"GHA-Pinner"}]}`), &pr); err != nil {
+		t.Fatal(err)
+	}
+	if !pullRequestHasLabel(pr, "gha-pinner") {
+		t.Fatal("expected label match to be case-insensitive")
+	}
+	if pullRequestHasLabel(pr, "renovate") {
+		t.Fatal("unexpected match for a missing label")
+	}
+	if pullRequestHasLabel(map[string]interface{}{"title": "x"}, "gha-pinner") {
+		t.Fatal("unexpected match for a PR without labels")
+	}
+}
+
+func TestResolveGitHubUserByEmail(t *testing.T) {
+	oldMode, oldToken, oldWorkers := saveAuthGlobals()
+	oldBase := githubAPIBase
+	t.Cleanup(func() {
+		restoreAuthGlobals(oldMode, oldToken, oldWorkers)
+		githubAPIBase = oldBase
+		githubUsersByEmail = map[string]string{}
+	})
+
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/search/users" {
+			http.NotFound(w, r)
+			return
+		}
+		q := r.URL.Query().Get("q")
+		queries = append(queries, q)
+		if q == "dev@example.com in:email" {
+			w.Write([]byte(`{"total_count": 1, "items": [{"login": "octodev"}]}`))
+			return
+		}
+		w.Write([]byte(`{"total_count": 0, "items": []}`))
+	}))
+	t.Cleanup(server.Close)
+	githubAPIBase = server.URL
+	authMode, githubToken = "pat", "test-token"
+
+	for i := 0; i < 2; i++ {
+		if login, err := resolveGitHubUserByEmail("Dev@example.com"); err != nil || login != "octodev" {
+			t.Fatalf("expected octodev, got %q, %v", login, err)
+		}
+		if _, err := resolveGitHubUserByEmail("nobody@example.com"); err == nil {
+			t.Fatal("expected an error for an email without a user")
+		}
+	}
+	if len(queries) != 2 {
+		t.Fatalf("expected lookups to be cached, got queries %v", queries)
+	}
+
+	if login, err := resolveGitHubUserByEmail("12345+octocat@users.noreply.github.com"); err != nil || login != "octocat" {
+		t.Fatalf("expected the login from a noreply email, got %q, %v", login, err)
+	}
+	if len(queries) != 2 {
+		t.Fatalf("expected noreply emails to be resolved without the API, got queries %v", queries)
+	}
+}
+
+func TestLastAuthorLogins(t *testing.T) {
+	repoDir := t.TempDir()
+	commit := func(email, file string) {
+		t.Helper()
+		writeWorkflow(t, repoDir, file, "on: push # "+email+"\n")
+		for _, args := range [][]string{{"add", "."}, {"-c", "user.name=dev", "-c", "user.email=" + email, "commit", "-q", "-m", file}} {
+			if result := execCommandWithDir(repoDir, "git", args...); result.ExitCode != 0 {
+				t.Fatalf("git %v: %s", args, result.Stderr)
+			}
+		}
+	}
+	if result := execCommandWithDir(repoDir, "git", "init", "-q"); result.ExitCode != 0 {
+		t.Skipf("git not available: %s", result.Stderr)
+	}
+	commit("1+alice@users.noreply.github.com", "a.yml")
+	commit("2+bob@users.noreply.github.com", "b.yml")
+	commit("1+alice@users.noreply.github.com", "c.yml")
+
+	files := []string{".github/workflows/a.yml", ".github/workflows/b.yml", ".github/workflows/c.yml"}
+	if got, want := lastAuthorLogins(repoDir, files), []string{"alice", "bob"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("lastAuthorLogins() = %v, want %v", got, want)
+	}
+}
+
+func TestRemoteWorkflowsPinned(t *testing.T) {
+	oldMode, oldToken, oldWorkers := saveAuthGlobals()
+	oldBase, oldNoCache, oldBranch := githubAPIBase, noCache, baseBranch
+	t.Cleanup(func() {
+		restoreAuthGlobals(oldMode, oldToken, oldWorkers)
+		githubAPIBase, noCache, baseBranch = oldBase, oldNoCache, oldBranch
+	})
+
+	pinnedWorkflow := "jobs:\n  build:\n    steps:\n      - uses: actions/checkout@" + testSHA + " # v4\n      - uses: ./.github/actions/local\n"
+	unpinnedWorkflow := "jobs:\n  release:\n    steps:\n      - uses: actions/setup-go@v5\n"
+	unpinnedComposite := "runs:\n  using: composite\n  steps:\n    - uses: actions/cache@v4\n"
+	files := map[string]map[string]string{
+		"acme/pinned": {
+			".github/workflows/ci.yml":    pinnedWorkflow,
+			".github/workflows/README.md": "not a workflow",
+		},
+		"acme/unpinned": {
+			".github/workflows/ci.yml":      "jobs:\n  build:\n    steps:\n      - uses: actions/checkout@" + testSHA + "\n",
+			".github/workflows/release.yml": unpinnedWorkflow,
+		},
+		"acme/composite": {
+			".github/workflows/ci.yml":                pinnedWorkflow,
+			".github/actions/setup/nested/action.yml": unpinnedComposite,
+		},
+		"acme/.github": {
+			".github/workflows/ci.yml":  pinnedWorkflow,
+			"workflow-templates/ci.yml": unpinnedWorkflow,
+		},
+		"acme/branch@main": {
+			".github/workflows/ci.yml": pinnedWorkflow,
+		},
+		"acme/branch@release": {
+			".github/workflows/ci.yml": unpinnedWorkflow,
+		},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/repos/")
+		parts := strings.SplitN(path, "/contents/", 2)
+		repo := parts[0]
+		if ref := r.URL.Query().Get("ref"); ref != "" {
+			repo += "@" + ref
+		} else if repo == "acme/branch" {
+			repo += "@main"
+		}
+		repoFiles, ok := files[repo]
+		if !ok || len(parts) != 2 {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"Not Found"}`))
+			return
+		}
+		if content, ok := repoFiles[parts[1]]; ok {
+			encoded := base64.StdEncoding.EncodeToString([]byte(content))
+			var wrapped []string
+			for len(encoded) > 60 {
+				wrapped, encoded = append(wrapped, encoded[:60]), encoded[60:]
+			}
+			wrapped = append(wrapped, encoded)
+			json.NewEncoder(w).Encode(map[string]string{"content": strings.Join(wrapped, "\n"), "encoding": "base64"})
+			return
+		}
+		// Directory listing: the direct children of parts[1].
+		seen := map[string]bool{}
+		var listing []map[string]string
+		for name := range repoFiles {
+			rest, ok := strings.CutPrefix(name, parts[1]+"/")
+			if !ok {
+				continue
+			}
+			child, _, isDir := strings.Cut(rest, "/")
+			if seen[child] {
+				continue
+			}
+			seen[child] = true
+			entryType := "file"
+			if isDir {
+				entryType = "dir"
+			}
+			listing = append(listing, map[string]string{"path": parts[1] + "/" + child, "type": entryType})
+		}
+		if len(listing) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"Not Found"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(listing)
+	}))
+	t.Cleanup(server.Close)
+	githubAPIBase = server.URL
+	authMode, githubToken = "pat", "test-token"
+	noCache = true
+
+	for repo, want := range map[string]bool{
+		"acme/pinned":    true,
+		"acme/unpinned":  false,
+		"acme/composite": false,
+		"acme/.github":   false,
+		"acme/branch":    true,
+	} {
+		if pinned, err := remoteWorkflowsPinned(repo); err != nil || pinned != want {
+			t.Errorf("%s: expected pinned = %v, got %v, %v", repo, want, pinned, err)
+		}
+	}
+	if _, err := remoteWorkflowsPinned("acme/missing"); err == nil {
+		t.Error("expected an error when the workflows cannot be listed")
+	}
+
+	baseBranch = "release"
+	if pinned, err := remoteWorkflowsPinned("acme/branch"); err != nil || pinned {
+		t.Errorf("acme/branch at release: expected unpinned, got %v, %v", pinned, err)
+	}
+}
+
+func TestPinningModifiersEnabled(t *testing.T) {
+	oldRunners, oldHarden, oldPerms, oldComment := pinRunners, injectHardenRunner, addPermissions, requireHashComment
+	t.Cleanup(func() {
+		pinRunners, injectHardenRunner, addPermissions, requireHashComment = oldRunners, oldHarden, oldPerms, oldComment
+	})
+
+	pinRunners, injectHardenRunner, addPermissions, requireHashComment = false, false, false, false
+	if pinningModifiersEnabled() {
+		t.Fatal("expected no modifier to be enabled")
+	}
+	for _, flag := range []*bool{&pinRunners, &injectHardenRunner, &addPermissions, &requireHashComment} {
+		*flag = true
+		if !pinningModifiersEnabled() {
+			t.Error("expected a set modifier to disable the pre-clone check")
+		}
+		*flag = false
+	}
+}
+
+func TestCheckForkVisibility(t *testing.T) {
+	oldMode, oldToken, oldWorkers := saveAuthGlobals()
+	oldBase, oldNoCache, oldVisibility := githubAPIBase, noCache, forkVisibility
+	t.Cleanup(func() {
+		restoreAuthGlobals(oldMode, oldToken, oldWorkers)
+		githubAPIBase, noCache, forkVisibility = oldBase, oldNoCache, oldVisibility
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		visibility := map[string]string{"/repos/acme/open": "public", "/repos/acme/closed": "private", "/repos/acme/inner": "internal"}[r.URL.Path]
+		if visibility == "" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"Not Found"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"name": strings.TrimPrefix(r.URL.Path, "/repos/acme/"), "visibility": visibility})
+	}))
+	t.Cleanup(server.Close)
+	githubAPIBase = server.URL
+	authMode, githubToken = "pat", "test-token"
+	noCache = true
+
+	tests := []struct {
+		visibility string
+		repo       string
+		wantErr    bool
+	}{
+		{"public", "acme/open", false},
+		{"public", "acme/closed", true},
+		{"private", "acme/open", true},
+		{"private", "acme/closed", false},
+		{"private", "acme/inner", false},
+		{"private", "acme/missing", true},
+	}
+	for _, tc := range tests {
+		forkVisibility = tc.visibility
+		if err := checkForkVisibility(tc.repo); (err != nil) != tc.wantErr {
+			t.Errorf("checkForkVisibility(%q) with --fork-visibility %s: err = %v, wantErr %v", tc.repo, tc.visibility, err, tc.wantErr)
+		}
+	}
+}
+
+func TestPatchLocalRepository_CompositeActions(t *testing.T) {
+	oldCache, oldInjection, oldMax := hashCache, detectInjection, maxWorkflowFiles
+	t.Cleanup(func() { hashCache, detectInjection, maxWorkflowFiles = oldCache, oldInjection, oldMax })
+	hashCache = newActionHashCache()
+	hashCache.put("actions/checkout", "v4", lockEntry{hash: testSHA, resolvedVersion: "v4"})
+	hashCache.put("actions/cache", "v4", lockEntry{hash: testSHA, resolvedVersion: "v4"})
+	detectInjection, maxWorkflowFiles = true, 0
+
+	repoDir := t.TempDir()
+	writeWorkflow(t, repoDir, "ci.yml", "on: push\njobs:\n  build:\n    runs-on: ubuntu-latest\n    steps:\n      - uses: actions/checkout@v4\n")
+	compositePath := filepath.Join(repoDir, ".github", "actions", "setup", "action.yml")
+	if err := os.MkdirAll(filepath.Dir(compositePath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	composite := "name: setup\nruns:\n  using: composite\n  steps:\n    - uses: actions/cache@v4\n    - run: echo \"${{ github.event.issue.title }}\"\n      shell: bash\n"
+	if err := os.WriteFile(compositePath, []byte(composite), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	summary, patchErr := patchLocalRepository(repoDir)
+	os.Stdout = stdout
+	w.Close()
+	out, _ := io.ReadAll(r)
+	if patchErr != nil {
+		t.Fatalf("unexpected error: %v", patchErr)
+	}
+
+	content, err := os.ReadFile(compositePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "uses: actions/cache@"+testSHA) {
+		t.Errorf("composite action was not pinned:\n%s", content)
+	}
+	if summary.actionsPinned != 2 {
+		t.Errorf("expected the workflow and composite pins to be counted, got %d", summary.actionsPinned)
+	}
+	if _, ok := summary.byFile[".github/actions/setup/action.yml"]; !ok {
+		t.Errorf("expected the composite action in the summary, got %v", summary.byFile)
+	}
+	for _, want := range []string{"Composite action files processed: 1", "Expression injection findings (" + ruleExpressionInjection + "): 1"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("expected %q in summary output:\n%s", want, out)
+		}
+	}
+
+	// The composite action only gets a file left over by --max-workflow-files.
+	hashCache = newActionHashCache()
+	hashCache.put("actions/checkout", "v4", lockEntry{hash: testSHA, resolvedVersion: "v4"})
+	hashCache.put("actions/cache", "v4", lockEntry{hash: testSHA, resolvedVersion: "v4"})
+	limitedDir := t.TempDir()
+	writeWorkflow(t, limitedDir, "ci.yml", "on: push\njobs:\n  build:\n    runs-on: ubuntu-latest\n    steps:\n      - uses: actions/checkout@v4\n")
+	limitedComposite := filepath.Join(limitedDir, ".github", "actions", "setup", "action.yml")
+	if err := os.MkdirAll(filepath.Dir(limitedComposite), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(limitedComposite, []byte(composite), 0644); err != nil {
+		t.Fatal(err)
+	}
+	maxWorkflowFiles = 1
+	if _, err := patchLocalRepository(limitedDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if content, _ := os.ReadFile(limitedComposite); string(content) != composite {
+		t.Errorf("expected --max-workflow-files 1 to leave the composite action alone:\n%s", content)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/xeipuuv/gojsonschema"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultWorkflowSchemaURL is the SchemaStore JSON Schema for workflow files
+// used by --verify-workflow-syntax.
+const defaultWorkflowSchemaURL = "https://json.schemastore.org/github-workflow.json"
+
+// workflowSchemaTTL is how long a downloaded schema is reused before it is
+// fetched again.
+const workflowSchemaTTL = 7 * 24 * time.Hour
+
+// workflowSchemas holds the compiled schema per URL for the rest of the run.
+var (
+	workflowSchemas   = map[string]*gojsonschema.Schema{}
+	workflowSchemasMu sync.Mutex
+)
+
+// workflowSchemaCachePath returns where the schema downloaded from schemaURL
+// is kept between runs.
+func workflowSchemaCachePath(schemaURL string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = filepath.Join(os.TempDir(), ".cache")
+	}
+	sum := sha256.Sum256([]byte(schemaURL))
+	return filepath.Join(dir, "gha-pinner", "schemas", hex.EncodeToString(sum[:8])+".json")
+}
+
+// loadWorkflowSchema returns the compiled schema at schemaURL, downloading it
+// unless a copy younger than workflowSchemaTTL is cached on disk.
+func loadWorkflowSchema(schemaURL string) (*gojsonschema.Schema, error) {
+	workflowSchemasMu.Lock()
+	defer workflowSchemasMu.Unlock()
+	if schema, ok := workflowSchemas[schemaURL]; ok {
+		return schema, nil
+	}
+
+	path := workflowSchemaCachePath(schemaURL)
+	var data []byte
+	if info, err := os.Stat(path); err == nil && !noCache && time.Since(info.ModTime()) < workflowSchemaTTL {
+		data, _ = os.ReadFile(path)
+	}
+	if data == nil {
+		downloaded, err := downloadWorkflowSchema(schemaURL)
+		if err != nil {
+			return nil, err
+		}
+		data = downloaded
+		if mkErr := os.MkdirAll(filepath.Dir(path), 0755); mkErr == nil {
+			_ = os.WriteFile(path, data, 0644)
+		}
+	}
+
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile workflow schema %s: %v", schemaURL, err)
+	}
+	workflowSchemas[schemaURL] = schema
+	return schema, nil
+}
+
+func downloadWorkflowSchema(schemaURL string) ([]byte, error) {
+	timeout := 60 * time.Second
+	if networkTimeout > 0 {
+		timeout = networkTimeout
+	}
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(schemaURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download workflow schema: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("failed to download workflow schema: %s returned %d", schemaURL, resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workflow schema: %w", err)
+	}
+	return data, nil
+}
+
+// validateWorkflowSyntax validates workflow content against the schema at
+// schemaURL and returns one message per violation.
+func validateWorkflowSyntax(content, schemaURL string) ([]string, error) {
+	schema, err := loadWorkflowSchema(schemaURL)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if err := yaml.Unmarshal([]byte(content), &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML: %v", err)
+	}
+	result, err := schema.Validate(gojsonschema.NewGoLoader(jsonCompatible(doc)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate workflow: %v", err)
+	}
+	var violations []string
+	for _, e := range result.Errors() {
+		violations = append(violations, e.String())
+	}
+	return violations, nil
+}
+
+// jsonCompatible converts the maps with non-string keys that YAML allows into
+// string-keyed maps so a decoded document can be handled as JSON.
+func jsonCompatible(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = jsonCompatible(value)
+		}
+		return v
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			converted[fmt.Sprint(key)] = jsonCompatible(value)
+		}
+		return converted
+	case []interface{}:
+		for i, value := range v {
+			v[i] = jsonCompatible(value)
+		}
+		return v
+	}
+	return v
+}
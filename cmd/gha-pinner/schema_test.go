@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// testWorkflowSchema is a small stand-in for the SchemaStore workflow schema.
+const testWorkflowSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "required": ["on", "jobs"],
+  "properties": {
+    "jobs": {
+      "type": "object",
+      "additionalProperties": {
+        "type": "object",
+        "required": ["runs-on"]
+      }
+    }
+  }
+}`
+
+// fakeWorkflowSchema serves testWorkflowSchema and counts downloads. The
+// schema cache is redirected to a temporary directory.
+func fakeWorkflowSchema(t *testing.T) (string, *int) {
+	t.Helper()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		fmt.Fprint(w, testWorkflowSchema)
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() {
+		workflowSchemasMu.Lock()
+		workflowSchemas = map[string]*gojsonschema.Schema{}
+		workflowSchemasMu.Unlock()
+	})
+	return server.URL + "/github-workflow.json", &downloads
+}
+
+func TestValidateWorkflowSyntax(t *testing.T) {
+	schemaURL, downloads := fakeWorkflowSchema(t)
+
+	valid := "on: push\njobs:\n  build:\n    runs-on: ubuntu-latest\n"
+	if issues, err := validateWorkflowSyntax(valid, schemaURL); err != nil || len(issues) != 0 {
+		t.Fatalf("expected a valid workflow, got %v, %v", issues, err)
+	}
+
+	issues, err := validateWorkflowSyntax("on: push\njobs:\n  build:\n    steps: []\n", schemaURL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(issues) != 1 || !strings.Contains(issues[0], "runs-on") {
+		t.Fatalf("expected a missing runs-on violation, got %v", issues)
+	}
+	if *downloads != 1 {
+		t.Fatalf("expected the schema to be downloaded once, got %d", *downloads)
+	}
+	if _, err := os.Stat(workflowSchemaCachePath(schemaURL)); err != nil {
+		t.Fatalf("expected the schema to be cached on disk: %v", err)
+	}
+}
+
+func TestLoadWorkflowSchema_UsesDiskCache(t *testing.T) {
+	schemaURL, downloads := fakeWorkflowSchema(t)
+	path := workflowSchemaCachePath(schemaURL)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(testWorkflowSchema), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := loadWorkflowSchema(schemaURL); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *downloads != 0 {
+		t.Fatalf("expected the cached schema to be used, got %d downloads", *downloads)
+	}
+
+	// An expired copy is downloaded again.
+	workflowSchemas = map[string]*gojsonschema.Schema{}
+	old := time.Now().Add(-workflowSchemaTTL - time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadWorkflowSchema(schemaURL); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *downloads != 1 {
+		t.Fatalf("expected an expired schema to be downloaded, got %d downloads", *downloads)
+	}
+}
+
+func TestWorkflowPatcher_PatchFile_VerifySyntax(t *testing.T) {
+	schemaURL, _ := fakeWorkflowSchema(t)
+	oldURL, oldCache := workflowSchemaURL, hashCache
+	t.Cleanup(func() { workflowSchemaURL, hashCache = oldURL, oldCache })
+	workflowSchemaURL = schemaURL
+	hashCache = newActionHashCache()
+	hashCache.put("actions/checkout", "v4", lockEntry{hash: testSHA, resolvedVersion: "v4"})
+
+	path := filepath.Join(t.TempDir(), "ci.yml")
+	content := "on: push\njobs:\n  build:\n    steps:\n      - uses: actions/checkout@v4\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &WorkflowPatcher{egressPolicy: "audit", verifySyntax: true}
+	res, err := p.patchFile(path)
+	if err != nil {
+		t.Fatalf("schema violations must not fail patching: %v", err)
+	}
+	if res.actionsPinned != 1 || len(res.syntaxIssues) != 1 {
+		t.Fatalf("expected 1 pinned action and 1 schema warning, got %d and %v", res.actionsPinned, res.syntaxIssues)
+	}
+}
+
+func TestRenderSummaryMarkdown_SyntaxIssues(t *testing.T) {
+	results := []RepoResult{{Repo: "acme/api", ActionsPinned: 1, SyntaxIssues: []string{".github/workflows/ci.yml: jobs.build: runs-on is required"}}}
+	out := renderSummaryMarkdown(results, time.Now())
+	if !strings.Contains(out, "## Workflow schema warnings") || !strings.Contains(out, "- `acme/api` .github/workflows/ci.yml: jobs.build: runs-on is required") {
+		t.Fatalf("expected per-file schema warnings in the summary:\n%s", out)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInsertSecurityComment(t *testing.T) {
+	header := "# " + defaultSecurityComment + "\n"
+	tests := []struct {
+		name    string
+		content string
+		text    string
+		want    string
+	}{
+		{"first key", "name: CI\non: [push]\n", defaultSecurityComment, header + "name: CI\non: [push]\n"},
+		{"above attached comment", "# CI pipeline\nname: CI\n", defaultSecurityComment, header + "# CI pipeline\nname: CI\n"},
+		{"after document marker", "---\nname: CI\n", defaultSecurityComment, "---\n" + header + "name: CI\n"},
+		{"already present", "# Actions are pinned to commit hashes.\nname: CI\n", defaultSecurityComment, "# Actions are pinned to commit hashes.\nname: CI\n"},
+		{"custom text", "name: CI\n", "Pinned by platform team.\nAsk in #security.", "# Pinned by platform team.\n# Ask in #security.\nname: CI\n"},
+		{"custom text present", "# Pinned by platform team.\nname: CI\n", "# Pinned by platform team.", "# Pinned by platform team.\nname: CI\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := insertSecurityComment(tt.content, tt.text); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkflowPatcher_SecurityCommentOnlyOnModifiedFiles(t *testing.T) {
+	oldCache := hashCache
+	t.Cleanup(func() { hashCache = oldCache })
+	hashCache = newActionHashCache()
+	hashCache.put("actions/checkout", "v4", lockEntry{hash: testSHA, resolvedVersion: "v4"})
+
+	dir := t.TempDir()
+	unpinned := filepath.Join(dir, "ci.yml")
+	pinned := filepath.Join(dir, "release.yml")
+	pinnedContent := "on: [push]\njobs:\n  build:\n    steps:\n      - uses: actions/checkout@" + testSHA + " # v4\n"
+	if err := os.WriteFile(unpinned, []byte("on: [push]\njobs:\n  build:\n    steps:\n      - uses: actions/checkout@v4\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(pinned, []byte(pinnedContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &WorkflowPatcher{egressPolicy: "audit", securityComment: defaultSecurityComment}
+	for _, path := range []string{unpinned, pinned} {
+		if _, err := p.patchFile(path); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	got, _ := os.ReadFile(unpinned)
+	if !strings.HasPrefix(string(got), "# "+defaultSecurityComment+"\non: [push]\n") {
+		t.Errorf("expected the security comment on the modified workflow:\n%s", got)
+	}
+	if got, _ := os.ReadFile(pinned); string(got) != pinnedContent {
+		t.Errorf("unmodified workflow should be left alone:\n%s", got)
+	}
+}
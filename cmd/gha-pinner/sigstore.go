@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// rekorURL is the sigstore transparency log queried by --check-sigstore;
+// tests point it at a local server.
+var rekorURL = "https://rekor.sigstore.dev"
+
+// Pin comment suffixes for --check-sigstore.
+const (
+	sigstoreVerifiedNote = "sigstore verified"
+	sigstoreNotFoundNote = "sigstore: not found"
+)
+
+// checkSigstore reports whether the commit hash appears in the sigstore
+// transparency log, i.e. whether any signed artifact records it. Rekor indexes
+// entries by artifact digest; a git commit SHA is looked up as a sha1 digest.
+func checkSigstore(hash string) (bool, error) {
+	payload, err := json.Marshal(map[string]string{"hash": "sha1:" + strings.ToLower(hash)})
+	if err != nil {
+		return false, err
+	}
+
+	timeout := 30 * time.Second
+	if networkTimeout > 0 {
+		timeout = networkTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", rekorURL+"/api/v1/index/retrieve", bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("rekor index lookup failed: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var uuids []string
+	if err := json.Unmarshal(body, &uuids); err != nil {
+		return false, fmt.Errorf("failed to parse rekor response: %v", err)
+	}
+	return len(uuids) > 0, nil
+}
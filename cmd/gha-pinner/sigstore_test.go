@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fakeRekor serves the index endpoint, knowing only the digests in known.
+func fakeRekor(t *testing.T, known ...string) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/api/v1/index/retrieve" {
+			http.NotFound(w, r)
+			return
+		}
+		var req struct {
+			Hash string `json:"hash"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, k := range known {
+			if req.Hash == "sha1:"+k {
+				fmt.Fprint(w, `["24296fb24b8ad77a0ad1fb4fb8bbf4d7c7ac6d4b"]`)
+				return
+			}
+		}
+		fmt.Fprint(w, `[]`)
+	}))
+	t.Cleanup(server.Close)
+	old := rekorURL
+	rekorURL = server.URL
+	t.Cleanup(func() { rekorURL = old })
+}
+
+func TestCheckSigstore(t *testing.T) {
+	fakeRekor(t, testSHA)
+
+	if found, err := checkSigstore(testSHA); err != nil || !found {
+		t.Fatalf("expected %s to be found, got %v, %v", testSHA, found, err)
+	}
+	if found, err := checkSigstore(strings.Repeat("b", 40)); err != nil || found {
+		t.Fatalf("expected unknown hash not to be found, got %v, %v", found, err)
+	}
+
+	rekorURL = rekorURL + "/missing"
+	if _, err := checkSigstore(testSHA); err == nil {
+		t.Fatal("expected error for a failed lookup")
+	}
+}
+
+func TestPinActionsPass_SigstoreAnnotation(t *testing.T) {
+	otherSHA := strings.Repeat("c", 40)
+	fakeRekor(t, testSHA)
+	oldCache, oldCheck := hashCache, checkSigstoreLog
+	t.Cleanup(func() { hashCache, checkSigstoreLog = oldCache, oldCheck })
+	hashCache = newActionHashCache()
+	hashCache.put("example/signed", "v3", lockEntry{hash: testSHA, resolvedVersion: "v3"})
+	hashCache.put("example/unsigned", "v1", lockEntry{hash: otherSHA, resolvedVersion: "v1"})
+	checkSigstoreLog = true
+
+	content := `jobs:
+  build:
+    steps:
+      - uses: example/signed@v3
+      - uses: example/unsigned@v1
+`
+	var workflow map[string]interface{}
+	if err := yaml.Unmarshal([]byte(content), &workflow); err != nil {
+		t.Fatal(err)
+	}
+	updated, _, err := pinActionsPass(content, workflow, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(updated, "example/signed@"+testSHA+" # v3 on ") || !strings.Contains(updated, "(sigstore verified)") {
+		t.Errorf("expected signed action to be marked verified:\n%s", updated)
+	}
+	if !strings.Contains(updated, "example/unsigned@"+otherSHA+" # v1 on ") || !strings.Contains(updated, "(sigstore: not found)") {
+		t.Errorf("expected unsigned action to be pinned and marked not found:\n%s", updated)
+	}
+}
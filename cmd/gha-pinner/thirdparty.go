@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ActionCategory is the trust profile of an action's owner, reported by
+// --detect-third-party-actions.
+type ActionCategory string
+
+const (
+	// categoryOfficial covers the actions and github organizations.
+	categoryOfficial ActionCategory = "official"
+	// categoryVerifiedCreator covers owners GitHub has verified, as shown by
+	// the verified badge on the organization and its Marketplace listings.
+	categoryVerifiedCreator ActionCategory = "verified-creator"
+	// categoryCommunity covers every other owner, including personal accounts.
+	categoryCommunity ActionCategory = "community"
+)
+
+// actionCategories lists the categories in report order.
+var actionCategories = []ActionCategory{categoryOfficial, categoryVerifiedCreator, categoryCommunity}
+
+// ownerCategories caches classifyAction per lower-cased owner for the run.
+var (
+	ownerCategories   = map[string]ActionCategory{}
+	ownerCategoriesMu sync.Mutex
+)
+
+// classifyAction returns the category of actions published by owner. Owners
+// other than actions and github are looked up once through the organizations
+// API; personal accounts and failed lookups count as community.
+func classifyAction(owner string) ActionCategory {
+	owner = strings.ToLower(owner)
+	if owner == "actions" || owner == "github" {
+		return categoryOfficial
+	}
+
+	ownerCategoriesMu.Lock()
+	defer ownerCategoriesMu.Unlock()
+	if category, ok := ownerCategories[owner]; ok {
+		return category
+	}
+	category := categoryCommunity
+	result := githubAPI("GET", "orgs/"+owner, nil)
+	if result.ExitCode == 0 {
+		var org struct {
+			IsVerified bool `json:"is_verified"`
+		}
+		if err := json.Unmarshal([]byte(result.Stdout), &org); err == nil && org.IsVerified {
+			category = categoryVerifiedCreator
+		}
+	} else if debug {
+		fmt.Printf("Classifying %s as %s: %s\n", owner, categoryCommunity, strings.TrimSpace(result.Stderr))
+	}
+	ownerCategories[owner] = category
+	return category
+}
+
+// countByCategory counts the pinned references in changes per category of
+// their owner.
+func countByCategory(changes []actionChange) map[ActionCategory]int {
+	counts := map[ActionCategory]int{}
+	for _, change := range changes {
+		owner, _, _ := strings.Cut(change.action, "/")
+		counts[classifyAction(owner)]++
+	}
+	return counts
+}
+
+// formatCategoryCounts renders counts as "official 3, verified-creator 1,
+// community 2".
+func formatCategoryCounts(counts map[ActionCategory]int) string {
+	parts := make([]string, 0, len(actionCategories))
+	for _, category := range actionCategories {
+		parts = append(parts, fmt.Sprintf("%s %d", category, counts[category]))
+	}
+	return strings.Join(parts, ", ")
+}
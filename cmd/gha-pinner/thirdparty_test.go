@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestClassifyAction(t *testing.T) {
+	oldMode, oldToken, oldWorkers := saveAuthGlobals()
+	oldBase, oldNoCache := githubAPIBase, noCache
+	t.Cleanup(func() {
+		restoreAuthGlobals(oldMode, oldToken, oldWorkers)
+		githubAPIBase, noCache = oldBase, oldNoCache
+		ownerCategories = map[string]ActionCategory{}
+	})
+	ownerCategories = map[string]ActionCategory{}
+
+	var mu sync.Mutex
+	lookups := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		lookups[r.URL.Path]++
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/orgs/hashicorp":
+			w.Write([]byte(`{"login": "hashicorp", "is_verified": true}`))
+		case "/orgs/small-org":
+			w.Write([]byte(`{"login": "small-org", "is_verified": false}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	githubAPIBase = server.URL
+	authMode, githubToken = "pat", "test-token"
+	noCache = true
+
+	tests := map[string]ActionCategory{
+		"actions":   categoryOfficial,
+		"GitHub":    categoryOfficial,
+		"hashicorp": categoryVerifiedCreator,
+		"small-org": categoryCommunity,
+		"someone":   categoryCommunity,
+	}
+	for owner, want := range tests {
+		if got := classifyAction(owner); got != want {
+			t.Errorf("classifyAction(%q) = %s, want %s", owner, got, want)
+		}
+	}
+
+	counts := countByCategory([]actionChange{
+		{action: "actions/checkout"},
+		{action: "github/codeql-action/init"},
+		{action: "hashicorp/setup-terraform"},
+		{action: "someone/deploy"},
+	})
+	if got, want := formatCategoryCounts(counts), "official 2, verified-creator 1, community 1"; got != want {
+		t.Errorf("formatCategoryCounts() = %q, want %q", got, want)
+	}
+	if lookups["/orgs/hashicorp"] != 1 || lookups["/orgs/someone"] != 1 || lookups["/orgs/actions"] != 0 {
+		t.Errorf("expected one lookup per third-party owner, got %v", lookups)
+	}
+}
+
+func TestRenderCategoryMarkdown(t *testing.T) {
+	out := renderCategoryMarkdown([]RepoResult{
+		{Repo: "acme/api", PinnedByCategory: map[ActionCategory]int{categoryOfficial: 3, categoryCommunity: 1}},
+		{Repo: "acme/web", PinnedByCategory: map[ActionCategory]int{categoryOfficial: 1, categoryVerifiedCreator: 2}},
+	})
+	for _, want := range []string{"| official | 4 |", "| verified-creator | 2 |", "| community | 1 |"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("category section missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package main
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 func TestExtractRepoNameFromURL_ValidFormats(t *testing.T) {
 	tests := []struct {
@@ -38,3 +41,20 @@ func TestExtractRepoNameFromURL_InvalidFormats(t *testing.T) {
 		}
 	}
 }
+
+func TestExpandRepoLine(t *testing.T) {
+	t.Setenv("GHA_PINNER_TEST_ORG", "octo-org")
+
+	got, missing := expandRepoLine("${GHA_PINNER_TEST_ORG}/service-api")
+	if got != "octo-org/service-api" || len(missing) != 0 {
+		t.Fatalf("unexpected expansion: got=%q missing=%v", got, missing)
+	}
+
+	got, missing = expandRepoLine("${GHA_PINNER_TEST_UNDEFINED}/repo")
+	if got != "${GHA_PINNER_TEST_UNDEFINED}/repo" {
+		t.Fatalf("expected undefined variable to stay literal, got %q", got)
+	}
+	if !reflect.DeepEqual(missing, []string{"GHA_PINNER_TEST_UNDEFINED"}) {
+		t.Fatalf("unexpected missing variables: %v", missing)
+	}
+}
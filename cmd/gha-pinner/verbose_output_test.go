@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatActionChangeTable(t *testing.T) {
+	changes := []actionChange{
+		{action: "actions/checkout", before: "v3", after: "abc1234 (v3, 2024-01-15)"},
+		{action: "actions/setup-go", before: "v5", after: "def5678 (v5, 2024-01-15)"},
+	}
+	out := formatActionChangeTable(changes)
+
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected header + 2 rows, got %d lines:\n%s", len(lines), out)
+	}
+	if !strings.Contains(lines[0], "Action") || !strings.Contains(lines[0], "Before") || !strings.Contains(lines[0], "After") {
+		t.Errorf("unexpected header: %q", lines[0])
+	}
+	if !strings.Contains(lines[1], "actions/checkout") || !strings.Contains(lines[1], "abc1234 (v3, 2024-01-15)") {
+		t.Errorf("unexpected first row: %q", lines[1])
+	}
+	// Columns are aligned, so "Before" values start at the same offset.
+	if strings.Index(lines[1], "v3") != strings.Index(lines[2], "v5") {
+		t.Errorf("expected aligned columns:\n%s", out)
+	}
+}
+
+func TestShortHash(t *testing.T) {
+	if got := shortHash("abc1234def5678"); got != "abc1234" {
+		t.Errorf("expected abc1234, got %s", got)
+	}
+	if got := shortHash("abc"); got != "abc" {
+		t.Errorf("expected short input unchanged, got %s", got)
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// watchPollInterval is how often the watch command rescans the workflows
+// directory. Polling keeps the command dependency-free and behaves the same on
+// every platform and filesystem, including network mounts.
+const watchPollInterval = 500 * time.Millisecond
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// workflowWatcher tracks workflow files in a directory and reports the ones
+// that were created or modified and have since been quiet for the debounce
+// period, so that files are never processed mid-write.
+type workflowWatcher struct {
+	dir      string
+	debounce time.Duration
+	stamps   map[string]fileStamp
+	pending  map[string]time.Time
+}
+
+func newWorkflowWatcher(dir string, debounce time.Duration) *workflowWatcher {
+	w := &workflowWatcher{
+		dir:      dir,
+		debounce: debounce,
+		stamps:   map[string]fileStamp{},
+		pending:  map[string]time.Time{},
+	}
+	// Files that already exist are the baseline; only later changes are processed.
+	w.stamps = w.scan()
+	return w
+}
+
+func (w *workflowWatcher) scan() map[string]fileStamp {
+	stamps := map[string]fileStamp{}
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return stamps
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || (!strings.HasSuffix(name, ".yml") && !strings.HasSuffix(name, ".yaml")) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		stamps[filepath.Join(w.dir, name)] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+	}
+	return stamps
+}
+
+// poll rescans the directory and returns, sorted, the files whose last change
+// is at least debounce old.
+func (w *workflowWatcher) poll(now time.Time) []string {
+	current := w.scan()
+	for path, stamp := range current {
+		if previous, ok := w.stamps[path]; !ok || previous != stamp {
+			w.pending[path] = now
+		}
+	}
+	for path := range w.pending {
+		if _, ok := current[path]; !ok {
+			delete(w.pending, path)
+		}
+	}
+	w.stamps = current
+
+	var ready []string
+	for path, changed := range w.pending {
+		if now.Sub(changed) >= w.debounce {
+			ready = append(ready, path)
+			delete(w.pending, path)
+		}
+	}
+	sort.Strings(ready)
+	return ready
+}
+
+// refresh records the current state of path so that our own write to it is
+// not reported as a new change.
+func (w *workflowWatcher) refresh(path string) {
+	if info, err := os.Stat(path); err == nil {
+		w.stamps[path] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+	}
+}
+
+// watchRepository pins actions in workflow files under repoDir as they are
+// created or modified, until ctx is cancelled, then prints a session summary.
+func watchRepository(ctx context.Context, repoDir string, debounce time.Duration) error {
+	workflowsDir := filepath.Join(repoDir, ".github", "workflows")
+	if info, err := os.Stat(workflowsDir); err != nil || !info.IsDir() {
+		return fmt.Errorf("no .github/workflows directory found in %s", repoDir)
+	}
+
+	ignore, err := loadIgnoreFile(repoDir)
+	if err != nil {
+		return err
+	}
+	patcher := &WorkflowPatcher{
+		injectHardenRunner: injectHardenRunner,
+		egressPolicy:       egressPolicy,
+		pinRunners:         pinRunners,
+		runnerMap:          runnerMap,
+		requirePermissions: requirePermissions,
+		addPermissions:     addPermissions,
+		detectInjection:    detectInjection,
+		detectDispatch:     detectDispatchInputs,
+		verifySyntax:       verifyWorkflowSyntax,
+		reportPermissions:  reportPermissions,
+		minWorkflowSize:    minWorkflowSize,
+		preserveMtime:      preserveMtime,
+		securityComment:    patcherSecurityComment(),
+	}
+	watcher := newWorkflowWatcher(workflowsDir, debounce)
+	fmt.Printf("👀 Watching %s for workflow changes (debounce %s, Ctrl+C to stop)\n", workflowsDir, debounce)
+
+	filesPatched := map[string]bool{}
+	var sessionChanges []actionChange
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			printWatchSummary(filesPatched, sessionChanges)
+			return nil
+		case now := <-ticker.C:
+			for _, path := range watcher.poll(now) {
+				name := filepath.Base(path)
+				if ignore.matches(filepath.ToSlash(filepath.Join(".github", "workflows", name))) {
+					continue
+				}
+				res, err := patcher.patchFile(path)
+				watcher.refresh(path)
+				if err != nil {
+					fmt.Printf("⚠️  Warning: failed to process %s: %v\n", name, err)
+					continue
+				}
+				if len(res.changes) == 0 {
+					fmt.Printf("✓ %s\n", name)
+					continue
+				}
+				fmt.Printf("📌 %s: pinned %d action(s)\n", name, len(res.changes))
+				for _, change := range res.changes {
+					fmt.Printf("   %s@%s → %s\n", change.action, change.before, change.after)
+				}
+				filesPatched[name] = true
+				sessionChanges = append(sessionChanges, res.changes...)
+			}
+		}
+	}
+}
+
+func printWatchSummary(filesPatched map[string]bool, changes []actionChange) {
+	fmt.Printf("\n📊 Watch session summary:\n")
+	if len(changes) == 0 {
+		fmt.Printf("   No actions were pinned\n")
+		return
+	}
+	names := make([]string, 0, len(filesPatched))
+	for name := range filesPatched {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Printf("   Pinned %d action(s) in %d file(s): %s\n", len(changes), len(names), strings.Join(names, ", "))
+}
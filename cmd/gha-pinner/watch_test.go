@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWorkflowWatcher_Debounce(t *testing.T) {
+	repoDir := t.TempDir()
+	existing := writeWorkflow(t, repoDir, "existing.yml", "on: push\n")
+	dir := filepath.Dir(existing)
+
+	w := newWorkflowWatcher(dir, 2*time.Second)
+	start := time.Now()
+	if got := w.poll(start); len(got) != 0 {
+		t.Fatalf("existing files must not be reported, got %v", got)
+	}
+
+	created := writeWorkflow(t, repoDir, "new.yml", "on: push\n")
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.poll(start.Add(time.Second)); len(got) != 0 {
+		t.Fatalf("changed file reported before the debounce elapsed: %v", got)
+	}
+
+	// A further write restarts the debounce window.
+	if err := os.WriteFile(created, []byte("on: push\njobs: {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.poll(start.Add(2 * time.Second)); len(got) != 0 {
+		t.Fatalf("file reported while still being written: %v", got)
+	}
+	if got := w.poll(start.Add(4 * time.Second)); !reflect.DeepEqual(got, []string{created}) {
+		t.Fatalf("poll() = %v, want [%s]", got, created)
+	}
+	if got := w.poll(start.Add(10 * time.Second)); len(got) != 0 {
+		t.Fatalf("file reported twice: %v", got)
+	}
+}
@@ -0,0 +1,56 @@
+// Package i18n translates the inline comments gha-pinner writes next to pinned
+// actions. Console output is not translated.
+package i18n
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DefaultLanguage is used for unknown languages and missing translations.
+const DefaultLanguage = "en"
+
+// Translation keys.
+const (
+	// PinComment follows a pinned commit hash; its arguments are the version
+	// and the date it was pinned on. The version must stay the first word so
+	// existing pins can be parsed back.
+	PinComment = "pin_comment"
+)
+
+var translations = map[string]map[string]string{
+	"en": {PinComment: "%s on %s"},
+	"de": {PinComment: "%s gepinnt am %s"},
+	"es": {PinComment: "%s fijado el %s"},
+	"fr": {PinComment: "%s épinglé le %s"},
+	"ja": {PinComment: "%s、%sに固定"},
+}
+
+// T formats the template for key in lang with args, falling back to English.
+// An unknown key is returned as is.
+func T(lang, key string, args ...interface{}) string {
+	format, ok := translations[lang][key]
+	if !ok {
+		format, ok = translations[DefaultLanguage][key]
+	}
+	if !ok {
+		return key
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// Supported reports whether lang has translations.
+func Supported(lang string) bool {
+	_, ok := translations[lang]
+	return ok
+}
+
+// Languages returns the supported language codes, sorted.
+func Languages() []string {
+	langs := make([]string, 0, len(translations))
+	for lang := range translations {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
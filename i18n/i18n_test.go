@@ -0,0 +1,43 @@
+package i18n
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestT(t *testing.T) {
+	tests := []struct {
+		lang     string
+		expected string
+	}{
+		{"en", "v3 on 2024-01-15"},
+		{"ja", "v3、2024-01-15に固定"},
+		{"de", "v3 gepinnt am 2024-01-15"},
+		{"fr", "v3 épinglé le 2024-01-15"},
+		{"es", "v3 fijado el 2024-01-15"},
+		{"xx", "v3 on 2024-01-15"},
+	}
+
+	for _, test := range tests {
+		if got := T(test.lang, PinComment, "v3", "2024-01-15"); got != test.expected {
+			t.Errorf("T(%q) = %q, expected %q", test.lang, got, test.expected)
+		}
+	}
+	if got := T("ja", "missing_key"); got != "missing_key" {
+		t.Errorf("expected an unknown key to be returned as is, got %q", got)
+	}
+}
+
+func TestLanguages(t *testing.T) {
+	if got, want := Languages(), []string{"de", "en", "es", "fr", "ja"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Languages() = %v, expected %v", got, want)
+	}
+	for _, lang := range Languages() {
+		if !Supported(lang) {
+			t.Errorf("expected %s to be supported", lang)
+		}
+	}
+	if Supported("xx") {
+		t.Error("expected xx to be unsupported")
+	}
+}